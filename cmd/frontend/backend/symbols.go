@@ -15,9 +15,30 @@ type symbols struct{}
 
 // ListTags returns symbols in a repository from ctags.
 func (symbols) ListTags(ctx context.Context, args search.SymbolsParameters) ([]protocol.Symbol, error) {
+	if Mocks.Symbols.ListTags != nil {
+		return Mocks.Symbols.ListTags(ctx, args)
+	}
 	result, err := symbolsclient.DefaultClient.Search(ctx, args)
 	if result == nil {
 		return nil, err
 	}
 	return result.Symbols, err
 }
+
+// List returns all symbols of a commit from the symbols service, up to
+// args.First.
+func (symbols) List(ctx context.Context, args protocol.ListArgs) ([]protocol.Symbol, error) {
+	if Mocks.Symbols.List != nil {
+		return Mocks.Symbols.List(ctx, args)
+	}
+	result, err := symbolsclient.DefaultClient.List(ctx, args)
+	if result == nil {
+		return nil, err
+	}
+	return result.Symbols, err
+}
+
+type MockSymbols struct {
+	ListTags func(ctx context.Context, args search.SymbolsParameters) ([]protocol.Symbol, error)
+	List     func(ctx context.Context, args protocol.ListArgs) ([]protocol.Symbol, error)
+}
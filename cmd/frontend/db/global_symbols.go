@@ -0,0 +1,316 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/keegancsmith/sqlf"
+	"github.com/lib/pq"
+	"github.com/segmentio/fasthash/fnv1"
+
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/types"
+	"github.com/sourcegraph/sourcegraph/internal/api"
+	"github.com/sourcegraph/sourcegraph/internal/db/dbconn"
+	"github.com/sourcegraph/sourcegraph/internal/db/dbutil"
+	"github.com/sourcegraph/sourcegraph/internal/search"
+	"github.com/sourcegraph/sourcegraph/internal/symbols/protocol"
+)
+
+// globalSymbols is the global symbol index, which holds the symbols of the
+// default branch of repositories so that symbol searches over many
+// repositories do not have to ask the symbols service about each one.
+type globalSymbols struct{}
+
+// globalSymbolsInsertBatchSize is the number of symbols inserted per
+// statement. Postgres allows at most 65535 parameters per statement.
+const globalSymbolsInsertBatchSize = 5000
+
+var globalSymbolsLockNamespace = int32(fnv1.HashString32("global_symbols"))
+
+// IndexedCommits returns the indexed commit of each of the given repositories
+// which is in the global symbol index.
+func (s *globalSymbols) IndexedCommits(ctx context.Context, repoIDs []api.RepoID) (map[api.RepoID]api.CommitID, error) {
+	if Mocks.GlobalSymbols.IndexedCommits != nil {
+		return Mocks.GlobalSymbols.IndexedCommits(ctx, repoIDs)
+	}
+
+	q := sqlf.Sprintf("SELECT repo_id, commit_id FROM global_symbol_repos WHERE repo_id = ANY(%s)", pq.Array(repoIDs))
+	rows, err := dbconn.Global.QueryContext(ctx, q.Query(sqlf.PostgresBindVar), q.Args()...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	commits := make(map[api.RepoID]api.CommitID)
+	for rows.Next() {
+		var (
+			repoID   api.RepoID
+			commitID api.CommitID
+		)
+		if err := rows.Scan(&repoID, &commitID); err != nil {
+			return nil, err
+		}
+		commits[repoID] = commitID
+	}
+	return commits, rows.Err()
+}
+
+// Update replaces the symbols of a repository in the index with the symbols
+// of commitID returned by fetch, unless commitID is already indexed or
+// another process is updating the repository at the same time. It reports
+// whether the symbols were replaced.
+//
+// fetch runs before the transaction that replaces the symbols, because
+// extracting the symbols of a large repository can take minutes. It is not
+// called when commitID is already indexed.
+func (s *globalSymbols) Update(ctx context.Context, repoID api.RepoID, commitID api.CommitID, fetch func(context.Context) ([]protocol.Symbol, error)) (updated bool, err error) {
+	if Mocks.GlobalSymbols.Update != nil {
+		return Mocks.GlobalSymbols.Update(ctx, repoID, commitID, fetch)
+	}
+
+	indexedCommit := func(ctx context.Context, dbh interface {
+		QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+	}) (api.CommitID, error) {
+		var indexed api.CommitID
+		q := sqlf.Sprintf("SELECT commit_id FROM global_symbol_repos WHERE repo_id=%s", repoID)
+		if err := dbh.QueryRowContext(ctx, q.Query(sqlf.PostgresBindVar), q.Args()...).Scan(&indexed); err != nil && err != sql.ErrNoRows {
+			return "", err
+		}
+		return indexed, nil
+	}
+
+	indexed, err := indexedCommit(ctx, dbconn.Global)
+	if err != nil || indexed == commitID {
+		return false, err
+	}
+	symbols, err := fetch(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	err = dbutil.Transaction(ctx, dbconn.Global, func(tx *sql.Tx) error {
+		// Postgres advisory lock ids are a global namespace within one
+		// database, so they are namespaced by a hash of the table name.
+		var locked bool
+		q := sqlf.Sprintf("SELECT pg_try_advisory_xact_lock(%s, %s)", globalSymbolsLockNamespace, int32(repoID))
+		if err := tx.QueryRowContext(ctx, q.Query(sqlf.PostgresBindVar), q.Args()...).Scan(&locked); err != nil {
+			return err
+		}
+		if !locked {
+			return nil
+		}
+
+		// Another process may have indexed the commit while the symbols
+		// were fetched.
+		indexed, err := indexedCommit(ctx, tx)
+		if err != nil || indexed == commitID {
+			return err
+		}
+
+		q = sqlf.Sprintf(`
+INSERT INTO global_symbol_repos(repo_id, commit_id, symbol_count) VALUES(%s, %s, %s)
+ON CONFLICT (repo_id) DO UPDATE SET commit_id=excluded.commit_id, symbol_count=excluded.symbol_count, indexed_at=now()`,
+			repoID, commitID, len(symbols),
+		)
+		if _, err := tx.ExecContext(ctx, q.Query(sqlf.PostgresBindVar), q.Args()...); err != nil {
+			return err
+		}
+		q = sqlf.Sprintf("DELETE FROM global_symbols WHERE repo_id=%s", repoID)
+		if _, err := tx.ExecContext(ctx, q.Query(sqlf.PostgresBindVar), q.Args()...); err != nil {
+			return err
+		}
+		for len(symbols) > 0 {
+			batch := symbols
+			if len(batch) > globalSymbolsInsertBatchSize {
+				batch = batch[:globalSymbolsInsertBatchSize]
+			}
+			symbols = symbols[len(batch):]
+
+			values := make([]*sqlf.Query, len(batch))
+			for i, sym := range batch {
+				values[i] = sqlf.Sprintf("(%s, %s, %s, %s, %s, %s, %s, %s, %s, %s)",
+					repoID, sym.Name, sym.Path, sym.Line, sym.Kind, sym.Language, sym.Parent, sym.ParentKind, sym.Signature, sym.Pattern,
+				)
+			}
+			q = sqlf.Sprintf("INSERT INTO global_symbols(repo_id, name, path, line, kind, language, parent, parent_kind, signature, pattern) VALUES %s", sqlf.Join(values, ","))
+			if _, err := tx.ExecContext(ctx, q.Query(sqlf.PostgresBindVar), q.Args()...); err != nil {
+				return err
+			}
+		}
+		updated = true
+		return nil
+	})
+	return updated && err == nil, err
+}
+
+// Delete removes a repository and its symbols from the index.
+func (s *globalSymbols) Delete(ctx context.Context, repoID api.RepoID) error {
+	if Mocks.GlobalSymbols.Delete != nil {
+		return Mocks.GlobalSymbols.Delete(ctx, repoID)
+	}
+
+	q := sqlf.Sprintf("DELETE FROM global_symbol_repos WHERE repo_id=%s", repoID)
+	_, err := dbconn.Global.ExecContext(ctx, q.Query(sqlf.PostgresBindVar), q.Args()...)
+	return err
+}
+
+// Search returns the symbols of the given repositories in the index which
+// match args, like a search of each repository on the symbols service. The
+// Repo and CommitID of args are ignored, and args.First is the maximum
+// number of symbols returned.
+func (s *globalSymbols) Search(ctx context.Context, repoIDs []api.RepoID, args search.SymbolsParameters) ([]*types.GlobalSymbol, error) {
+	if Mocks.GlobalSymbols.Search != nil {
+		return Mocks.GlobalSymbols.Search(ctx, repoIDs, args)
+	}
+
+	conds, order, err := globalSymbolsSearchConditions(args)
+	if err != nil {
+		return nil, err
+	}
+	conds = append([]*sqlf.Query{sqlf.Sprintf("s.repo_id = ANY(%s)", pq.Array(repoIDs))}, conds...)
+	q := sqlf.Sprintf(`
+SELECT s.repo_id, r.commit_id, s.name, s.path, s.line, s.kind, s.language, s.parent, s.parent_kind, s.signature, s.pattern
+FROM global_symbols s
+JOIN global_symbol_repos r ON r.repo_id = s.repo_id
+WHERE %s
+ORDER BY %s
+LIMIT %s`,
+		sqlf.Join(conds, "AND"), order, args.First,
+	)
+	rows, err := dbconn.Global.QueryContext(ctx, q.Query(sqlf.PostgresBindVar), q.Args()...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var symbols []*types.GlobalSymbol
+	for rows.Next() {
+		var sym types.GlobalSymbol
+		if err := rows.Scan(
+			&sym.RepoID,
+			&sym.CommitID,
+			&sym.Name,
+			&sym.Path,
+			&sym.Line,
+			&sym.Kind,
+			&sym.Language,
+			&sym.Parent,
+			&sym.ParentKind,
+			&sym.Signature,
+			&sym.Pattern,
+		); err != nil {
+			return nil, err
+		}
+		symbols = append(symbols, &sym)
+	}
+	return symbols, rows.Err()
+}
+
+// globalSymbolsSearchConditions returns the conditions on global_symbols (as
+// s) matching args, and the order of the results. Names are always compared
+// in lowercase too, so that the indexes on lower(name) are used. Regular
+// expressions are translated to Postgres, and ErrGlobalSymbolsUnsupportedPattern
+// is returned if one can't be.
+func globalSymbolsSearchConditions(args search.SymbolsParameters) (conds []*sqlf.Query, order *sqlf.Query, err error) {
+	// matchColumn matches column against a translated regular expression.
+	matchColumn := func(column, pattern string, negated bool) *sqlf.Query {
+		not := ""
+		if negated {
+			not = "!"
+		}
+		if args.IsCaseSensitive {
+			return sqlf.Sprintf(column+" "+not+"~ %s", pattern)
+		}
+		return sqlf.Sprintf("lower("+column+") "+not+"~* %s", pattern)
+	}
+
+	order = sqlf.Sprintf("s.repo_id, s.path, s.line")
+	query := args.Query
+	switch args.NameMatch {
+	case protocol.NameMatchRegexp:
+		if query != "" {
+			pattern, err := postgresRegexp(query)
+			if err != nil {
+				return nil, nil, err
+			}
+			conds = append(conds, sqlf.Sprintf("lower(s.name) ~* %s", pattern))
+			if args.IsCaseSensitive {
+				conds = append(conds, matchColumn("s.name", pattern, false))
+			}
+		}
+	case protocol.NameMatchExact:
+		if query != "" {
+			conds = append(conds, sqlf.Sprintf("lower(s.name) = %s", strings.ToLower(query)))
+			if args.IsCaseSensitive {
+				conds = append(conds, sqlf.Sprintf("s.name = %s", query))
+			}
+		}
+	case protocol.NameMatchPrefix:
+		conds = append(conds, sqlf.Sprintf("lower(s.name) LIKE %s", escapeLike(strings.ToLower(query))+"%"))
+		if args.IsCaseSensitive {
+			conds = append(conds, sqlf.Sprintf("s.name LIKE %s", escapeLike(query)+"%"))
+		}
+	case protocol.NameMatchFuzzy:
+		var chars []string
+		for _, r := range query {
+			chars = append(chars, regexp.QuoteMeta(string(r)))
+		}
+		fuzzy, err := postgresRegexp(strings.Join(chars, ".*"))
+		if err != nil {
+			return nil, nil, err
+		}
+		conds = append(conds, sqlf.Sprintf("lower(s.name) ~* %s", fuzzy))
+		if args.IsCaseSensitive {
+			conds = append(conds, matchColumn("s.name", fuzzy, false))
+		}
+		// Prefer the closest matches.
+		order = sqlf.Sprintf("length(s.name), s.name, s.repo_id, s.path, s.line")
+	default:
+		return nil, nil, fmt.Errorf("invalid NameMatch %q", args.NameMatch)
+	}
+
+	for _, p := range args.IncludePatterns {
+		pattern, err := postgresRegexp(p)
+		if err != nil {
+			return nil, nil, err
+		}
+		conds = append(conds, matchColumn("s.path", pattern, false))
+	}
+	if args.ExcludePattern != "" {
+		pattern, err := postgresRegexp(args.ExcludePattern)
+		if err != nil {
+			return nil, nil, err
+		}
+		conds = append(conds, matchColumn("s.path", pattern, true))
+	}
+	if len(args.Kinds) > 0 {
+		conds = append(conds, sqlf.Sprintf("lower(s.kind) = ANY(%s)", pq.Array(lowerAll(args.Kinds))))
+	}
+	if len(args.Languages) > 0 {
+		conds = append(conds, sqlf.Sprintf("lower(s.language) = ANY(%s)", pq.Array(lowerAll(args.Languages))))
+	}
+	if args.Parent != "" {
+		if args.IsCaseSensitive {
+			conds = append(conds, sqlf.Sprintf("s.parent = %s", args.Parent))
+		} else {
+			conds = append(conds, sqlf.Sprintf("lower(s.parent) = %s", strings.ToLower(args.Parent)))
+		}
+	}
+	return conds, order, nil
+}
+
+// escapeLike escapes the wildcards of a LIKE pattern in s.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+func lowerAll(values []string) []string {
+	lower := make([]string, len(values))
+	for i, v := range values {
+		lower[i] = strings.ToLower(v)
+	}
+	return lower
+}
@@ -0,0 +1,17 @@
+package db
+
+import (
+	"context"
+
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/types"
+	"github.com/sourcegraph/sourcegraph/internal/api"
+	"github.com/sourcegraph/sourcegraph/internal/search"
+	"github.com/sourcegraph/sourcegraph/internal/symbols/protocol"
+)
+
+type MockGlobalSymbols struct {
+	IndexedCommits func(ctx context.Context, repoIDs []api.RepoID) (map[api.RepoID]api.CommitID, error)
+	Update         func(ctx context.Context, repoID api.RepoID, commitID api.CommitID, fetch func(context.Context) ([]protocol.Symbol, error)) (bool, error)
+	Delete         func(ctx context.Context, repoID api.RepoID) error
+	Search         func(ctx context.Context, repoIDs []api.RepoID, args search.SymbolsParameters) ([]*types.GlobalSymbol, error)
+}
@@ -0,0 +1,184 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"regexp/syntax"
+	"strings"
+	"unicode"
+)
+
+// ErrGlobalSymbolsUnsupportedPattern occurs when a regular expression of a
+// global symbol search has no equivalent in Postgres. Such searches must use
+// the symbols service.
+var ErrGlobalSymbolsUnsupportedPattern = errors.New("regular expression not supported by the global symbol index")
+
+// postgresRegexpMaxRepeat is the largest bound of a repetition in Postgres
+// regular expressions.
+const postgresRegexpMaxRepeat = 255
+
+// postgresWordChar is the class of the characters of words of RE2, which
+// considers only ASCII characters, unlike \y and \Y of Postgres.
+const postgresWordChar = "[0-9A-Za-z_]"
+
+// postgresRegexp translates the RE2 regular expression pattern to a Postgres
+// advanced regular expression which matches the same strings. It returns
+// ErrGlobalSymbolsUnsupportedPattern for the parts of RE2 without an
+// equivalent, such as multi-line anchors.
+func postgresRegexp(pattern string) (string, error) {
+	re, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	if err := writePostgresRegexp(&b, re); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+func writePostgresRegexp(b *strings.Builder, re *syntax.Regexp) error {
+	switch re.Op {
+	case syntax.OpEmptyMatch:
+		// Empty branches match the empty string in Postgres too.
+	case syntax.OpLiteral:
+		for _, r := range re.Rune {
+			if r == 0 {
+				return ErrGlobalSymbolsUnsupportedPattern // Postgres text never contains NUL
+			}
+			if re.Flags&syntax.FoldCase != 0 && unicode.SimpleFold(r) != r {
+				b.WriteByte('[')
+				for f := r; ; {
+					writePostgresRune(b, f, true)
+					if f = unicode.SimpleFold(f); f == r {
+						break
+					}
+				}
+				b.WriteByte(']')
+			} else {
+				writePostgresRune(b, r, false)
+			}
+		}
+	case syntax.OpCharClass:
+		var class strings.Builder
+		for i := 0; i < len(re.Rune); i += 2 {
+			lo, hi := re.Rune[i], re.Rune[i+1]
+			// Postgres text never contains NUL, and doesn't allow it in
+			// regular expressions.
+			if lo == 0 {
+				if hi == 0 {
+					continue
+				}
+				lo = 1
+			}
+			writePostgresRune(&class, lo, true)
+			if hi != lo {
+				class.WriteByte('-')
+				writePostgresRune(&class, hi, true)
+			}
+		}
+		if class.Len() == 0 {
+			return ErrGlobalSymbolsUnsupportedPattern // matches nothing
+		}
+		b.WriteByte('[')
+		b.WriteString(class.String())
+		b.WriteByte(']')
+	case syntax.OpAnyChar:
+		// Outside of newline-sensitive mode, . matches newlines in Postgres.
+		b.WriteByte('.')
+	case syntax.OpAnyCharNotNL:
+		b.WriteString(`[^\n]`)
+	case syntax.OpBeginText:
+		b.WriteByte('^')
+	case syntax.OpEndText:
+		b.WriteByte('$')
+	case syntax.OpWordBoundary:
+		fmt.Fprintf(b, "(?:(?<!%[1]s)(?=%[1]s)|(?<=%[1]s)(?!%[1]s))", postgresWordChar)
+	case syntax.OpNoWordBoundary:
+		fmt.Fprintf(b, "(?:(?<=%[1]s)(?=%[1]s)|(?<!%[1]s)(?!%[1]s))", postgresWordChar)
+	case syntax.OpCapture:
+		b.WriteString("(?:")
+		if err := writePostgresRegexp(b, re.Sub[0]); err != nil {
+			return err
+		}
+		b.WriteByte(')')
+	case syntax.OpStar, syntax.OpPlus, syntax.OpQuest, syntax.OpRepeat:
+		// Whether a repetition is greedy doesn't change whether a string
+		// matches, so it is ignored.
+		sub := re.Sub[0]
+		atom := sub.Op == syntax.OpCharClass || sub.Op == syntax.OpAnyChar || sub.Op == syntax.OpAnyCharNotNL ||
+			(sub.Op == syntax.OpLiteral && len(sub.Rune) == 1)
+		if !atom {
+			b.WriteString("(?:")
+		}
+		if err := writePostgresRegexp(b, sub); err != nil {
+			return err
+		}
+		if !atom {
+			b.WriteByte(')')
+		}
+		switch re.Op {
+		case syntax.OpStar:
+			b.WriteByte('*')
+		case syntax.OpPlus:
+			b.WriteByte('+')
+		case syntax.OpQuest:
+			b.WriteByte('?')
+		case syntax.OpRepeat:
+			if re.Min > postgresRegexpMaxRepeat || re.Max > postgresRegexpMaxRepeat {
+				return ErrGlobalSymbolsUnsupportedPattern
+			}
+			switch {
+			case re.Max == -1:
+				fmt.Fprintf(b, "{%d,}", re.Min)
+			case re.Max == re.Min:
+				fmt.Fprintf(b, "{%d}", re.Min)
+			default:
+				fmt.Fprintf(b, "{%d,%d}", re.Min, re.Max)
+			}
+		}
+	case syntax.OpConcat:
+		for _, sub := range re.Sub {
+			if err := writePostgresRegexp(b, sub); err != nil {
+				return err
+			}
+		}
+	case syntax.OpAlternate:
+		b.WriteString("(?:")
+		for i, sub := range re.Sub {
+			if i > 0 {
+				b.WriteByte('|')
+			}
+			if err := writePostgresRegexp(b, sub); err != nil {
+				return err
+			}
+		}
+		b.WriteByte(')')
+	default:
+		// OpNoMatch, and the multi-line anchors OpBeginLine and OpEndLine,
+		// which Postgres only supports for a whole regular expression.
+		return ErrGlobalSymbolsUnsupportedPattern
+	}
+	return nil
+}
+
+// writePostgresRune writes r as it matches itself in a Postgres regular
+// expression, or in a bracket expression if inBracket.
+func writePostgresRune(b *strings.Builder, r rune, inBracket bool) {
+	switch {
+	case r > unicode.MaxASCII || !unicode.IsPrint(r):
+		if r > 0xFFFF {
+			fmt.Fprintf(b, `\U%08X`, r)
+		} else {
+			fmt.Fprintf(b, `\u%04X`, r)
+		}
+	case inBracket && strings.ContainsRune(`\]^-[`, r):
+		b.WriteByte('\\')
+		b.WriteRune(r)
+	case !inBracket && strings.ContainsRune(`\^$.[]|()*+?{}`, r):
+		b.WriteByte('\\')
+		b.WriteRune(r)
+	default:
+		b.WriteRune(r)
+	}
+}
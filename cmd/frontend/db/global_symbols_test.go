@@ -0,0 +1,183 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/keegancsmith/sqlf"
+
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/types"
+	"github.com/sourcegraph/sourcegraph/internal/api"
+	"github.com/sourcegraph/sourcegraph/internal/db/dbtesting"
+	"github.com/sourcegraph/sourcegraph/internal/search"
+	"github.com/sourcegraph/sourcegraph/internal/symbols/protocol"
+)
+
+func TestGlobalSymbols(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+	dbtesting.SetupGlobalTestDB(t)
+	ctx := context.Background()
+
+	repos := mustCreate(ctx, t, &types.Repo{Name: "a"}, &types.Repo{Name: "b"})
+	a, b := repos[0].ID, repos[1].ID
+
+	fetched := 0
+	fetch := func(symbols ...protocol.Symbol) func(context.Context) ([]protocol.Symbol, error) {
+		return func(context.Context) ([]protocol.Symbol, error) {
+			fetched++
+			return symbols, nil
+		}
+	}
+	update := func(repoID api.RepoID, commitID api.CommitID, fetch func(context.Context) ([]protocol.Symbol, error)) bool {
+		t.Helper()
+		updated, err := GlobalSymbols.Update(ctx, repoID, commitID, fetch)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return updated
+	}
+
+	if !update(a, "a1", fetch(protocol.Symbol{Name: "Old", Path: "old.go", Kind: "func"})) {
+		t.Error("a@a1 was not indexed")
+	}
+	if !update(a, "a2", fetch(
+		protocol.Symbol{Name: "NewClient", Path: "client.go", Line: 3, Kind: "func", Language: "Go", Parent: "http", ParentKind: "package"},
+		protocol.Symbol{Name: "Do", Path: "client.go", Line: 7, Kind: "method", Language: "Go", Parent: "Client", ParentKind: "type"},
+		protocol.Symbol{Name: "newClient_test", Path: "client_test.go", Line: 1, Kind: "func", Language: "Go"},
+	)) {
+		t.Error("a@a2 was not indexed")
+	}
+	if !update(b, "b1", fetch(protocol.Symbol{Name: "Client", Path: "client.ts", Line: 1, Kind: "class", Language: "TypeScript"})) {
+		t.Error("b@b1 was not indexed")
+	}
+	if update(a, "a2", fetch()) || fetched != 3 {
+		t.Error("a@a2 was indexed again")
+	}
+	if _, err := GlobalSymbols.Update(ctx, b, "b2", func(context.Context) ([]protocol.Symbol, error) {
+		return nil, errors.New("boom")
+	}); err == nil {
+		t.Error("got no error from a failed fetch")
+	}
+
+	commits, err := GlobalSymbols.IndexedCommits(ctx, []api.RepoID{a, b, 12345})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[api.RepoID]api.CommitID{a: "a2", b: "b1"}; !reflect.DeepEqual(commits, want) {
+		t.Errorf("got indexed commits %v, want %v", commits, want)
+	}
+
+	for _, tc := range []struct {
+		name string
+		args search.SymbolsParameters
+		want []string
+	}{
+		{"all", search.SymbolsParameters{}, []string{"NewClient", "Do", "newClient_test", "Client"}},
+		{"regexp", search.SymbolsParameters{Query: "client$"}, []string{"NewClient", "Client"}},
+		{"regexp case sensitive", search.SymbolsParameters{Query: "^new", IsCaseSensitive: true}, []string{"newClient_test"}},
+		{"exact", search.SymbolsParameters{Query: "client", NameMatch: protocol.NameMatchExact}, []string{"Client"}},
+		{"prefix", search.SymbolsParameters{Query: "newclient_", NameMatch: protocol.NameMatchPrefix}, []string{"newClient_test"}},
+		{"fuzzy", search.SymbolsParameters{Query: "nc", NameMatch: protocol.NameMatchFuzzy}, []string{"NewClient", "newClient_test"}},
+		{"kinds", search.SymbolsParameters{Kinds: []string{"METHOD", "class"}}, []string{"Do", "Client"}},
+		{"parent", search.SymbolsParameters{Parent: "client"}, []string{"Do"}},
+		{"languages", search.SymbolsParameters{Languages: []string{"typescript"}}, []string{"Client"}},
+		{"paths", search.SymbolsParameters{IncludePatterns: []string{`\.go$`}, ExcludePattern: "_test"}, []string{"NewClient", "Do"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.args.First = 10
+			symbols, err := GlobalSymbols.Search(ctx, []api.RepoID{a, b}, tc.args)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var names []string
+			for _, sym := range symbols {
+				names = append(names, sym.Name)
+			}
+			if !reflect.DeepEqual(names, tc.want) {
+				t.Errorf("got %q, want %q", names, tc.want)
+			}
+		})
+	}
+
+	if err := GlobalSymbols.Delete(ctx, a); err != nil {
+		t.Fatal(err)
+	}
+	symbols, err := GlobalSymbols.Search(ctx, []api.RepoID{a, b}, search.SymbolsParameters{First: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(symbols) != 1 || symbols[0].RepoID != b || symbols[0].CommitID != "b1" {
+		t.Errorf("got %+v after deleting a, want only the symbol of b@b1", symbols)
+	}
+}
+
+func TestGlobalSymbolsSearchConditions(t *testing.T) {
+	for _, tc := range []struct {
+		args      search.SymbolsParameters
+		wantConds string
+		wantArgs  []interface{}
+	}{
+		{
+			args:      search.SymbolsParameters{Query: "a_b%", NameMatch: protocol.NameMatchPrefix, IsCaseSensitive: true},
+			wantConds: "lower(s.name) LIKE $1 AND s.name LIKE $2",
+			wantArgs:  []interface{}{`a\_b\%%`, `a\_b\%%`},
+		},
+		{
+			args:      search.SymbolsParameters{Query: "a.b", NameMatch: protocol.NameMatchFuzzy, ExcludePattern: "_test"},
+			wantConds: "lower(s.name) ~* $1 AND lower(s.path) !~* $2",
+			wantArgs:  []interface{}{`a[^\n]*\.[^\n]*b`, "_test"},
+		},
+	} {
+		conds, _, err := globalSymbolsSearchConditions(tc.args)
+		if err != nil {
+			t.Fatal(err)
+		}
+		q := sqlf.Join(conds, "AND")
+		if got := q.Query(sqlf.PostgresBindVar); got != tc.wantConds {
+			t.Errorf("got conditions %q, want %q", got, tc.wantConds)
+		}
+		if got := q.Args(); !reflect.DeepEqual(got, tc.wantArgs) {
+			t.Errorf("got args %q, want %q", got, tc.wantArgs)
+		}
+	}
+
+	if _, _, err := globalSymbolsSearchConditions(search.SymbolsParameters{NameMatch: "soundex"}); err == nil {
+		t.Error("got no error for an invalid NameMatch")
+	}
+	if _, _, err := globalSymbolsSearchConditions(search.SymbolsParameters{IncludePatterns: []string{"(?m)^main$"}}); err != ErrGlobalSymbolsUnsupportedPattern {
+		t.Errorf("got error %v for a multi-line pattern, want ErrGlobalSymbolsUnsupportedPattern", err)
+	}
+}
+
+func TestPostgresRegexp(t *testing.T) {
+	wordChar := "[0-9A-Za-z_]"
+	for _, tc := range []struct {
+		pattern string
+		want    string
+		wantErr error
+	}{
+		{pattern: "^foo$", want: "^foo$"},
+		{pattern: `\.go$|\.(c|h)$`, want: `\.(?:go$|(?:[ch])$)`},
+		{pattern: "(?i)ab", want: "[Aa][Bb]"},
+		{pattern: "a.b(?s:.)", want: `a[^\n]b.`},
+		{pattern: `\d+x{2,3}(ab)*`, want: `[0-9]+x{2,3}(?:(?:ab))*`},
+		{pattern: "[^a-z]", want: "[\\u0001-`{-\\U0010FFFF]"},
+		{pattern: `a]{`, want: `a\]\{`},
+		{pattern: "é", want: `\u00E9`},
+		{pattern: `\bfoo`, want: "(?:(?<!" + wordChar + ")(?=" + wordChar + ")|(?<=" + wordChar + ")(?!" + wordChar + "))foo"},
+		{pattern: "(?m)^foo", wantErr: ErrGlobalSymbolsUnsupportedPattern},
+		{pattern: "a{1000}", wantErr: ErrGlobalSymbolsUnsupportedPattern},
+		{pattern: `[^\x00-\x{10FFFF}]`, wantErr: ErrGlobalSymbolsUnsupportedPattern},
+	} {
+		got, err := postgresRegexp(tc.pattern)
+		if err != tc.wantErr {
+			t.Errorf("%q: got error %v, want %v", tc.pattern, err, tc.wantErr)
+		} else if got != tc.want {
+			t.Errorf("%q: got %q, want %q", tc.pattern, got, tc.want)
+		}
+	}
+}
@@ -22,5 +22,13 @@ type MockStores struct {
 
 	ExternalServices MockExternalServices
 
+	SearchContexts MockSearchContexts
+
+	SearchHistory MockSearchHistory
+
+	GlobalSymbols MockGlobalSymbols
+
+	RepoGroups MockRepoGroups
+
 	Authz MockAuthz
 }
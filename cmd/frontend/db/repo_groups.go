@@ -0,0 +1,129 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/keegancsmith/sqlf"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/types"
+	"github.com/sourcegraph/sourcegraph/internal/db/dbconn"
+)
+
+// ErrRepoGroupNotFound occurs when a database operation expects a specific
+// repository group to exist but it does not exist.
+var ErrRepoGroupNotFound = errors.New("repository group not found")
+
+type repoGroups struct{}
+
+const repoGroupColumns = "id, name, description, repositories, COALESCE(pattern, ''), topics, created_at, updated_at"
+
+// Create creates a repository group.
+//
+// 🚨 SECURITY: This method does NOT verify that the current user is a site
+// admin. It is the caller's responsibility.
+func (s *repoGroups) Create(ctx context.Context, group *types.RepoGroup) (*types.RepoGroup, error) {
+	q := sqlf.Sprintf(
+		"INSERT INTO repo_groups(name, description, repositories, pattern, topics) VALUES(%s, %s, %s, NULLIF(%s, ''), %s) RETURNING "+repoGroupColumns,
+		group.Name, group.Description, pq.Array(nonNilStrings(group.Repositories)), group.Pattern, pq.Array(nonNilStrings(group.Topics)),
+	)
+	return s.getOneBySQL(ctx, q)
+}
+
+// GetByID returns the repository group with the given ID, or
+// ErrRepoGroupNotFound.
+func (s *repoGroups) GetByID(ctx context.Context, id int32) (*types.RepoGroup, error) {
+	return s.getOneBySQL(ctx, sqlf.Sprintf("SELECT "+repoGroupColumns+" FROM repo_groups WHERE id=%s", id))
+}
+
+// List lists all repository groups, ordered by name.
+func (s *repoGroups) List(ctx context.Context) ([]*types.RepoGroup, error) {
+	if Mocks.RepoGroups.List != nil {
+		return Mocks.RepoGroups.List(ctx)
+	}
+
+	q := sqlf.Sprintf("SELECT " + repoGroupColumns + " FROM repo_groups ORDER BY name")
+	rows, err := dbconn.Global.QueryContext(ctx, q.Query(sqlf.PostgresBindVar), q.Args()...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var groups []*types.RepoGroup
+	for rows.Next() {
+		g, err := scanRepoGroup(rows)
+		if err != nil {
+			return nil, err
+		}
+		groups = append(groups, g)
+	}
+	return groups, rows.Err()
+}
+
+// Update updates all fields of a repository group except its ID.
+//
+// 🚨 SECURITY: This method does NOT verify that the current user is a site
+// admin. It is the caller's responsibility.
+func (s *repoGroups) Update(ctx context.Context, group *types.RepoGroup) (*types.RepoGroup, error) {
+	q := sqlf.Sprintf(
+		"UPDATE repo_groups SET name=%s, description=%s, repositories=%s, pattern=NULLIF(%s, ''), topics=%s, updated_at=now() WHERE id=%s RETURNING "+repoGroupColumns,
+		group.Name, group.Description, pq.Array(nonNilStrings(group.Repositories)), group.Pattern, pq.Array(nonNilStrings(group.Topics)), group.ID,
+	)
+	return s.getOneBySQL(ctx, q)
+}
+
+// Delete deletes a repository group.
+//
+// 🚨 SECURITY: This method does NOT verify that the current user is a site
+// admin. It is the caller's responsibility.
+func (s *repoGroups) Delete(ctx context.Context, id int32) error {
+	q := sqlf.Sprintf("DELETE FROM repo_groups WHERE id=%s", id)
+	res, err := dbconn.Global.ExecContext(ctx, q.Query(sqlf.PostgresBindVar), q.Args()...)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrRepoGroupNotFound
+	}
+	return nil
+}
+
+func (s *repoGroups) getOneBySQL(ctx context.Context, q *sqlf.Query) (*types.RepoGroup, error) {
+	g, err := scanRepoGroup(dbconn.Global.QueryRowContext(ctx, q.Query(sqlf.PostgresBindVar), q.Args()...))
+	if err == sql.ErrNoRows {
+		return nil, ErrRepoGroupNotFound
+	}
+	return g, err
+}
+
+func scanRepoGroup(s interface{ Scan(...interface{}) error }) (*types.RepoGroup, error) {
+	var g types.RepoGroup
+	if err := s.Scan(
+		&g.ID,
+		&g.Name,
+		&g.Description,
+		pq.Array(&g.Repositories),
+		&g.Pattern,
+		pq.Array(&g.Topics),
+		&g.CreatedAt,
+		&g.UpdatedAt,
+	); err != nil {
+		return nil, err
+	}
+	return &g, nil
+}
+
+// nonNilStrings returns s, or an empty slice if s is nil, so that it is stored
+// as an empty array instead of NULL.
+func nonNilStrings(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
@@ -0,0 +1,11 @@
+package db
+
+import (
+	"context"
+
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/types"
+)
+
+type MockRepoGroups struct {
+	List func(ctx context.Context) ([]*types.RepoGroup, error)
+}
@@ -0,0 +1,62 @@
+package db
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/types"
+	"github.com/sourcegraph/sourcegraph/internal/db/dbtesting"
+)
+
+func TestRepoGroups(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+	dbtesting.SetupGlobalTestDB(t)
+	ctx := context.Background()
+
+	created, err := RepoGroups.Create(ctx, &types.RepoGroup{
+		Name:         "payments",
+		Description:  "Payments services",
+		Repositories: []string{"github.com/acme/billing"},
+		Topics:       []string{"payments"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created.Pattern != "" || !reflect.DeepEqual(created.Topics, []string{"payments"}) {
+		t.Errorf("unexpected created group %+v", created)
+	}
+	if _, err := RepoGroups.Create(ctx, &types.RepoGroup{Name: "payments"}); err == nil {
+		t.Error("expected an error creating a repository group with a duplicate name")
+	}
+
+	created.Pattern = "^github\\.com/acme/"
+	created.Topics = nil
+	updated, err := RepoGroups.Update(ctx, created)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Pattern != created.Pattern || len(updated.Topics) != 0 {
+		t.Errorf("unexpected updated group %+v", updated)
+	}
+
+	groups, err := RepoGroups.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(groups) != 1 || !reflect.DeepEqual(groups[0], updated) {
+		t.Errorf("got groups %+v, want [%+v]", groups, updated)
+	}
+
+	if err := RepoGroups.Delete(ctx, created.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := RepoGroups.GetByID(ctx, created.ID); err != ErrRepoGroupNotFound {
+		t.Errorf("got error %v, want ErrRepoGroupNotFound", err)
+	}
+	if err := RepoGroups.Delete(ctx, created.ID); err != ErrRepoGroupNotFound {
+		t.Errorf("got error %v, want ErrRepoGroupNotFound", err)
+	}
+}
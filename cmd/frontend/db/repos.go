@@ -136,6 +136,49 @@ func (s *repos) Count(ctx context.Context, opt ReposListOptions) (int, error) {
 	return count, nil
 }
 
+// GetStars returns the number of stars of the repositories with the given
+// IDs, as reported by their code host (GitHub stargazers and GitLab stars).
+// Repositories without a star count are omitted.
+//
+// 🚨 SECURITY: This method does NOT check that the current user may access
+// the repositories. It is the caller's responsibility.
+func (s *repos) GetStars(ctx context.Context, ids ...api.RepoID) (map[api.RepoID]int, error) {
+	if Mocks.Repos.GetStars != nil {
+		return Mocks.Repos.GetStars(ctx, ids...)
+	}
+
+	stars := make(map[api.RepoID]int, len(ids))
+	if len(ids) == 0 {
+		return stars, nil
+	}
+
+	items := make([]*sqlf.Query, len(ids))
+	for i := range ids {
+		items[i] = sqlf.Sprintf("%d", ids[i])
+	}
+	q := sqlf.Sprintf(`
+SELECT id, COALESCE((metadata->>'StargazerCount')::int, (metadata->>'star_count')::int)
+FROM repo
+WHERE id IN (%s) AND deleted_at IS NULL AND (metadata ? 'StargazerCount' OR metadata ? 'star_count')`,
+		sqlf.Join(items, ","),
+	)
+	rows, err := dbconn.Global.QueryContext(ctx, q.Query(sqlf.PostgresBindVar), q.Args()...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id api.RepoID
+		var count int
+		if err := rows.Scan(&id, &count); err != nil {
+			return nil, err
+		}
+		stars[id] = count
+	}
+	return stars, rows.Err()
+}
+
 const getRepoByQueryFmtstr = `
 SELECT %s
 FROM repo
@@ -248,8 +291,13 @@ type ReposListOptions struct {
 	// and this may be replaced by the version context name.
 	Names []string
 
+	// MetadataFilters limits the results to repositories whose code host
+	// metadata matches all of these filters.
+	MetadataFilters []RepoMetadataFilter
+
 	// PatternQuery is an expression tree of patterns to query. The atoms of
-	// the query are strings which are regular expression patterns.
+	// the query are strings which are regular expression patterns, and
+	// RepoMetadataFilters.
 	PatternQuery query.Q
 
 	// NoForks excludes forks from the list.
@@ -285,6 +333,48 @@ type ReposListOptions struct {
 	*LimitOffset
 }
 
+// RepoMetadataKind is a kind of code host metadata that repositories can be
+// filtered on.
+type RepoMetadataKind int
+
+const (
+	// RepoMetadataTopic is a GitHub repository topic.
+	RepoMetadataTopic RepoMetadataKind = iota
+	// RepoMetadataLabel is a GitLab project tag.
+	RepoMetadataLabel
+	// RepoMetadataProject is the key of a Bitbucket Server project.
+	RepoMetadataProject
+)
+
+// RepoMetadataFilter matches repositories that have (or, if Negated, do not
+// have) Value in their code host metadata of the given kind.
+type RepoMetadataFilter struct {
+	Kind    RepoMetadataKind
+	Value   string
+	Negated bool
+}
+
+func (f RepoMetadataFilter) sqlCondition() (*sqlf.Query, error) {
+	// The conditions are written so that they can use the indexes on the
+	// metadata expressions. They are NULL for repositories without the
+	// metadata, which is why negations need the COALESCE.
+	var cond *sqlf.Query
+	switch f.Kind {
+	case RepoMetadataTopic:
+		cond = sqlf.Sprintf("metadata->'Topics' ? %s", f.Value)
+	case RepoMetadataLabel:
+		cond = sqlf.Sprintf("metadata->'tag_list' ? %s", f.Value)
+	case RepoMetadataProject:
+		cond = sqlf.Sprintf("metadata->'project'->>'key' = %s", f.Value)
+	default:
+		return nil, errors.Errorf("unknown repository metadata kind %d", f.Kind)
+	}
+	if f.Negated {
+		return sqlf.Sprintf("NOT COALESCE(%s, FALSE)", cond), nil
+	}
+	return sqlf.Sprintf("(%s)", cond), nil
+}
+
 type RepoListOrderBy []RepoListSort
 
 func (r RepoListOrderBy) SQL() *sqlf.Query {
@@ -427,6 +517,9 @@ func (*repos) listSQL(opt ReposListOptions) (conds []*sqlf.Query, err error) {
 	}
 	if opt.PatternQuery != nil {
 		cond, err := query.Eval(opt.PatternQuery, func(q query.Q) (*sqlf.Query, error) {
+			if f, ok := q.(RepoMetadataFilter); ok {
+				return f.sqlCondition()
+			}
 			pattern, ok := q.(string)
 			if !ok {
 				return nil, errors.Errorf("unexpected token in repo listing query: %q", q)
@@ -471,6 +564,13 @@ func (*repos) listSQL(opt ReposListOptions) (conds []*sqlf.Query, err error) {
 		}
 		conds = append(conds, sqlf.Sprintf("NAME IN (%s)", sqlf.Join(queries, ", ")))
 	}
+	for _, f := range opt.MetadataFilters {
+		cond, err := f.sqlCondition()
+		if err != nil {
+			return nil, err
+		}
+		conds = append(conds, cond)
+	}
 
 	if opt.Index != nil {
 		// We don't currently have an index column, but when we want the
@@ -7,8 +7,10 @@ import (
 	"testing"
 
 	"github.com/keegancsmith/sqlf"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/db/query"
 	"github.com/sourcegraph/sourcegraph/internal/actor"
 	"github.com/sourcegraph/sourcegraph/internal/api"
+	"github.com/sourcegraph/sourcegraph/internal/db/dbconn"
 	"github.com/sourcegraph/sourcegraph/internal/db/dbtesting"
 	"github.com/sourcegraph/sourcegraph/internal/errcode"
 )
@@ -242,3 +244,145 @@ func TestRepos_UpsertForkAndArchivedFields(t *testing.T) {
 		}
 	}
 }
+
+func TestRepos_List_patternQueryMetadata(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+	dbtesting.SetupGlobalTestDB(t)
+	ctx := context.Background()
+	ctx = actor.WithActor(ctx, &actor.Actor{UID: 1, Internal: true})
+
+	for name, metadata := range map[string]string{
+		"github.com/acme/billing": `{"Topics": ["payments", "go"]}`,
+		"gitlab.com/acme/ledger":  `{"tag_list": ["payments"]}`,
+		"github.com/acme/docs":    `{"Topics": ["docs"]}`,
+		"github.com/acme/infra":   `{}`,
+	} {
+		if _, err := dbconn.Global.Exec("INSERT INTO repo(name, metadata) VALUES ($1, $2)", name, metadata); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Metadata filters can be combined with patterns in any way.
+	repos, err := Repos.List(ctx, ReposListOptions{
+		PatternQuery: query.Or(
+			`^github\.com/acme/docs$`,
+			RepoMetadataFilter{Kind: RepoMetadataTopic, Value: "payments"},
+			RepoMetadataFilter{Kind: RepoMetadataLabel, Value: "payments"},
+		),
+		OrderBy: RepoListOrderBy{{Field: RepoListName}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, repo := range repos {
+		got = append(got, string(repo.Name))
+	}
+	if want := []string{"github.com/acme/billing", "github.com/acme/docs", "gitlab.com/acme/ledger"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestRepos_List_metadataFilters(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+	dbtesting.SetupGlobalTestDB(t)
+	ctx := context.Background()
+	ctx = actor.WithActor(ctx, &actor.Actor{UID: 1, Internal: true})
+
+	for name, metadata := range map[string]string{
+		"github.com/acme/billing":      `{"Topics": ["payments", "go"]}`,
+		"github.com/acme/docs":         `{"Topics": ["docs"]}`,
+		"gitlab.com/acme/ledger":       `{"tag_list": ["payments"]}`,
+		"bitbucket.acme.com/OPS/infra": `{"project": {"key": "OPS"}}`,
+	} {
+		if _, err := dbconn.Global.Exec("INSERT INTO repo(name, metadata) VALUES ($1, $2)", name, metadata); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		filters []RepoMetadataFilter
+		want    []string
+	}{
+		{
+			name:    "topic",
+			filters: []RepoMetadataFilter{{Kind: RepoMetadataTopic, Value: "payments"}},
+			want:    []string{"github.com/acme/billing"},
+		},
+		{
+			name:    "label",
+			filters: []RepoMetadataFilter{{Kind: RepoMetadataLabel, Value: "payments"}},
+			want:    []string{"gitlab.com/acme/ledger"},
+		},
+		{
+			name:    "project",
+			filters: []RepoMetadataFilter{{Kind: RepoMetadataProject, Value: "OPS"}},
+			want:    []string{"bitbucket.acme.com/OPS/infra"},
+		},
+		{
+			name: "all filters must match",
+			filters: []RepoMetadataFilter{
+				{Kind: RepoMetadataTopic, Value: "payments"},
+				{Kind: RepoMetadataTopic, Value: "docs"},
+			},
+		},
+		{
+			name:    "negated",
+			filters: []RepoMetadataFilter{{Kind: RepoMetadataTopic, Value: "payments", Negated: true}},
+			want:    []string{"bitbucket.acme.com/OPS/infra", "github.com/acme/docs", "gitlab.com/acme/ledger"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			repos, err := Repos.List(ctx, ReposListOptions{MetadataFilters: test.filters, OrderBy: RepoListOrderBy{{Field: RepoListName}}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, repo := range repos {
+				got = append(got, string(repo.Name))
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestRepos_GetStars(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+	dbtesting.SetupGlobalTestDB(t)
+	ctx := context.Background()
+
+	ids := map[string]api.RepoID{}
+	for name, metadata := range map[string]string{
+		"github.com/acme/popular": `{"StargazerCount": 1200}`,
+		"gitlab.com/acme/ledger":  `{"star_count": 7}`,
+		"github.com/acme/unknown": `{}`,
+	} {
+		var id api.RepoID
+		if err := dbconn.Global.QueryRow("INSERT INTO repo(name, metadata) VALUES ($1, $2) RETURNING id", name, metadata).Scan(&id); err != nil {
+			t.Fatal(err)
+		}
+		ids[name] = id
+	}
+
+	stars, err := Repos.GetStars(ctx, ids["github.com/acme/popular"], ids["gitlab.com/acme/ledger"], ids["github.com/acme/unknown"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[api.RepoID]int{
+		ids["github.com/acme/popular"]: 1200,
+		ids["gitlab.com/acme/ledger"]:  7,
+	}
+	if !reflect.DeepEqual(stars, want) {
+		t.Errorf("got %v, want %v", stars, want)
+	}
+}
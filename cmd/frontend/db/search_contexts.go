@@ -0,0 +1,289 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/keegancsmith/sqlf"
+	"github.com/pkg/errors"
+
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/types"
+	"github.com/sourcegraph/sourcegraph/internal/db/dbconn"
+	"github.com/sourcegraph/sourcegraph/internal/db/dbutil"
+)
+
+// ErrSearchContextNotFound occurs when a database operation expects a specific
+// search context to exist but it does not exist.
+var ErrSearchContextNotFound = errors.New("search context not found")
+
+type searchContexts struct{}
+
+const searchContextColumns = `
+	sc.id,
+	sc.name,
+	sc.description,
+	sc.public,
+	sc.namespace_user_id,
+	sc.namespace_org_id,
+	COALESCE(u.username, o.name, ''),
+	sc.created_at,
+	sc.updated_at`
+
+const searchContextFrom = `
+FROM search_contexts sc
+LEFT JOIN users u ON u.id = sc.namespace_user_id
+LEFT JOIN orgs o ON o.id = sc.namespace_org_id`
+
+// SearchContextsListOptions specifies the options for listing search
+// contexts.
+type SearchContextsListOptions struct {
+	// ViewerUserID is the user the search contexts are listed for. Private
+	// search contexts are only listed if they are owned by this user or by an
+	// organization this user is a member of. If zero, only public search
+	// contexts are listed.
+	ViewerUserID int32
+
+	// IncludeAllPrivate lists all private search contexts. It should only be
+	// set for site admins.
+	IncludeAllPrivate bool
+
+	*LimitOffset
+}
+
+func (o SearchContextsListOptions) sqlConditions() *sqlf.Query {
+	if o.IncludeAllPrivate {
+		return sqlf.Sprintf("TRUE")
+	}
+	conds := []*sqlf.Query{sqlf.Sprintf("sc.public")}
+	if o.ViewerUserID != 0 {
+		conds = append(conds,
+			sqlf.Sprintf("sc.namespace_user_id = %s", o.ViewerUserID),
+			sqlf.Sprintf("sc.namespace_org_id IN (SELECT org_id FROM org_members WHERE user_id = %s)", o.ViewerUserID),
+		)
+	}
+	return sqlf.Sprintf("(%s)", sqlf.Join(conds, " OR "))
+}
+
+// Create creates a search context with the given repository revisions. Only
+// the Name, Description, Public, NamespaceUserID and NamespaceOrgID fields of
+// searchContext are used.
+//
+// 🚨 SECURITY: This method does NOT verify that the current user may create a
+// search context in the given namespace. It is the caller's responsibility.
+func (s *searchContexts) Create(ctx context.Context, searchContext *types.SearchContext, repositoryRevisions []*types.SearchContextRepositoryRevisions) (*types.SearchContext, error) {
+	if searchContext.NamespaceUserID != nil && searchContext.NamespaceOrgID != nil {
+		return nil, errors.New("a search context cannot be owned by both a user and an organization")
+	}
+	var id int64
+	err := dbutil.Transaction(ctx, dbconn.Global, func(tx *sql.Tx) error {
+		q := sqlf.Sprintf(
+			"INSERT INTO search_contexts(name, description, public, namespace_user_id, namespace_org_id) VALUES(%s, %s, %s, %s, %s) RETURNING id",
+			searchContext.Name, searchContext.Description, searchContext.Public, searchContext.NamespaceUserID, searchContext.NamespaceOrgID,
+		)
+		if err := tx.QueryRowContext(ctx, q.Query(sqlf.PostgresBindVar), q.Args()...).Scan(&id); err != nil {
+			return err
+		}
+		return setRepositoryRevisions(ctx, tx, id, repositoryRevisions)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.GetByID(ctx, id)
+}
+
+// GetByID returns the search context with the given ID, or
+// ErrSearchContextNotFound.
+//
+// 🚨 SECURITY: This method does NOT verify that the current user may view the
+// search context. It is the caller's responsibility.
+func (s *searchContexts) GetByID(ctx context.Context, id int64) (*types.SearchContext, error) {
+	if Mocks.SearchContexts.GetByID != nil {
+		return Mocks.SearchContexts.GetByID(ctx, id)
+	}
+	return s.getOneBySQL(ctx, sqlf.Sprintf("WHERE sc.id = %s", id))
+}
+
+// GetBySpec returns the search context with the given name in the namespace
+// of the user or organization with the given name, or
+// ErrSearchContextNotFound. An empty namespaceName refers to the
+// instance-wide search contexts.
+//
+// 🚨 SECURITY: This method does NOT verify that the current user may view the
+// search context. It is the caller's responsibility.
+func (s *searchContexts) GetBySpec(ctx context.Context, namespaceName, name string) (*types.SearchContext, error) {
+	if Mocks.SearchContexts.GetBySpec != nil {
+		return Mocks.SearchContexts.GetBySpec(ctx, namespaceName, name)
+	}
+	if namespaceName == "" {
+		return s.getOneBySQL(ctx, sqlf.Sprintf("WHERE sc.name = %s AND sc.namespace_user_id IS NULL AND sc.namespace_org_id IS NULL", name))
+	}
+	return s.getOneBySQL(ctx, sqlf.Sprintf("WHERE sc.name = %s AND (u.username = %s OR o.name = %s)", name, namespaceName, namespaceName))
+}
+
+// List lists the search contexts visible according to opt, ordered by ID.
+func (s *searchContexts) List(ctx context.Context, opt SearchContextsListOptions) ([]*types.SearchContext, error) {
+	q := sqlf.Sprintf("SELECT "+searchContextColumns+searchContextFrom+" WHERE %s ORDER BY sc.id %s", opt.sqlConditions(), opt.LimitOffset.SQL())
+	rows, err := dbconn.Global.QueryContext(ctx, q.Query(sqlf.PostgresBindVar), q.Args()...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var searchContexts []*types.SearchContext
+	for rows.Next() {
+		sc, err := scanSearchContext(rows)
+		if err != nil {
+			return nil, err
+		}
+		searchContexts = append(searchContexts, sc)
+	}
+	return searchContexts, rows.Err()
+}
+
+// Count counts the search contexts visible according to opt.
+func (s *searchContexts) Count(ctx context.Context, opt SearchContextsListOptions) (int, error) {
+	q := sqlf.Sprintf("SELECT COUNT(*)"+searchContextFrom+" WHERE %s", opt.sqlConditions())
+	var count int
+	err := dbconn.Global.QueryRowContext(ctx, q.Query(sqlf.PostgresBindVar), q.Args()...).Scan(&count)
+	return count, err
+}
+
+// Update updates the name, description and visibility of a search context,
+// and replaces its repository revisions.
+//
+// 🚨 SECURITY: This method does NOT verify that the current user may update
+// the search context. It is the caller's responsibility.
+func (s *searchContexts) Update(ctx context.Context, searchContext *types.SearchContext, repositoryRevisions []*types.SearchContextRepositoryRevisions) (*types.SearchContext, error) {
+	err := dbutil.Transaction(ctx, dbconn.Global, func(tx *sql.Tx) error {
+		q := sqlf.Sprintf(
+			"UPDATE search_contexts SET name=%s, description=%s, public=%s, updated_at=now() WHERE id=%s",
+			searchContext.Name, searchContext.Description, searchContext.Public, searchContext.ID,
+		)
+		res, err := tx.ExecContext(ctx, q.Query(sqlf.PostgresBindVar), q.Args()...)
+		if err != nil {
+			return err
+		}
+		if n, err := res.RowsAffected(); err != nil {
+			return err
+		} else if n == 0 {
+			return ErrSearchContextNotFound
+		}
+		return setRepositoryRevisions(ctx, tx, searchContext.ID, repositoryRevisions)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.GetByID(ctx, searchContext.ID)
+}
+
+// Delete deletes a search context and its repository revisions.
+//
+// 🚨 SECURITY: This method does NOT verify that the current user may delete
+// the search context. It is the caller's responsibility.
+func (s *searchContexts) Delete(ctx context.Context, id int64) error {
+	return s.exec(ctx, sqlf.Sprintf("DELETE FROM search_contexts WHERE id=%s", id))
+}
+
+// setRepositoryRevisions replaces the repository revisions of the search
+// context with the given ID in tx.
+func setRepositoryRevisions(ctx context.Context, tx *sql.Tx, id int64, repositoryRevisions []*types.SearchContextRepositoryRevisions) error {
+	q := sqlf.Sprintf("DELETE FROM search_context_repos WHERE search_context_id=%s", id)
+	if _, err := tx.ExecContext(ctx, q.Query(sqlf.PostgresBindVar), q.Args()...); err != nil {
+		return err
+	}
+
+	var values []*sqlf.Query
+	for _, repoRevs := range repositoryRevisions {
+		for _, rev := range repoRevs.Revisions {
+			values = append(values, sqlf.Sprintf("(%s, %s, %s)", id, repoRevs.RepoID, rev))
+		}
+	}
+	if len(values) == 0 {
+		return nil
+	}
+	q = sqlf.Sprintf("INSERT INTO search_context_repos(search_context_id, repo_id, revision) VALUES %s ON CONFLICT DO NOTHING", sqlf.Join(values, ","))
+	_, err := tx.ExecContext(ctx, q.Query(sqlf.PostgresBindVar), q.Args()...)
+	return err
+}
+
+// GetRepositoryRevisions returns the repositories of a search context and
+// their revisions, ordered by repository name. Deleted repositories are
+// omitted.
+//
+// 🚨 SECURITY: This method does NOT filter the repositories by the current
+// user's permissions. It is the caller's responsibility.
+func (s *searchContexts) GetRepositoryRevisions(ctx context.Context, id int64) ([]*types.SearchContextRepositoryRevisions, error) {
+	if Mocks.SearchContexts.GetRepositoryRevisions != nil {
+		return Mocks.SearchContexts.GetRepositoryRevisions(ctx, id)
+	}
+
+	q := sqlf.Sprintf(`
+SELECT r.id, r.name, scr.revision
+FROM search_context_repos scr
+JOIN repo r ON r.id = scr.repo_id
+WHERE scr.search_context_id = %s AND r.deleted_at IS NULL
+ORDER BY r.name, scr.revision`, id)
+	rows, err := dbconn.Global.QueryContext(ctx, q.Query(sqlf.PostgresBindVar), q.Args()...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var repositoryRevisions []*types.SearchContextRepositoryRevisions
+	for rows.Next() {
+		var repoRevs types.SearchContextRepositoryRevisions
+		var rev string
+		if err := rows.Scan(&repoRevs.RepoID, &repoRevs.RepoName, &rev); err != nil {
+			return nil, err
+		}
+		if n := len(repositoryRevisions); n > 0 && repositoryRevisions[n-1].RepoID == repoRevs.RepoID {
+			repositoryRevisions[n-1].Revisions = append(repositoryRevisions[n-1].Revisions, rev)
+			continue
+		}
+		repoRevs.Revisions = []string{rev}
+		repositoryRevisions = append(repositoryRevisions, &repoRevs)
+	}
+	return repositoryRevisions, rows.Err()
+}
+
+func (s *searchContexts) exec(ctx context.Context, q *sqlf.Query) error {
+	res, err := dbconn.Global.ExecContext(ctx, q.Query(sqlf.PostgresBindVar), q.Args()...)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrSearchContextNotFound
+	}
+	return nil
+}
+
+func (s *searchContexts) getOneBySQL(ctx context.Context, cond *sqlf.Query) (*types.SearchContext, error) {
+	q := sqlf.Sprintf("SELECT "+searchContextColumns+searchContextFrom+" %s LIMIT 1", cond)
+	sc, err := scanSearchContext(dbconn.Global.QueryRowContext(ctx, q.Query(sqlf.PostgresBindVar), q.Args()...))
+	if err == sql.ErrNoRows {
+		return nil, ErrSearchContextNotFound
+	}
+	return sc, err
+}
+
+func scanSearchContext(s interface{ Scan(...interface{}) error }) (*types.SearchContext, error) {
+	var sc types.SearchContext
+	if err := s.Scan(
+		&sc.ID,
+		&sc.Name,
+		&sc.Description,
+		&sc.Public,
+		&sc.NamespaceUserID,
+		&sc.NamespaceOrgID,
+		&sc.NamespaceName,
+		&sc.CreatedAt,
+		&sc.UpdatedAt,
+	); err != nil {
+		return nil, err
+	}
+	return &sc, nil
+}
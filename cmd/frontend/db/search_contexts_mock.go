@@ -0,0 +1,13 @@
+package db
+
+import (
+	"context"
+
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/types"
+)
+
+type MockSearchContexts struct {
+	GetByID                func(ctx context.Context, id int64) (*types.SearchContext, error)
+	GetBySpec              func(ctx context.Context, namespaceName, name string) (*types.SearchContext, error)
+	GetRepositoryRevisions func(ctx context.Context, id int64) ([]*types.SearchContextRepositoryRevisions, error)
+}
@@ -0,0 +1,157 @@
+package db
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/types"
+	"github.com/sourcegraph/sourcegraph/internal/api"
+	"github.com/sourcegraph/sourcegraph/internal/db/dbtesting"
+)
+
+func TestSearchContexts(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+	dbtesting.SetupGlobalTestDB(t)
+	ctx := context.Background()
+
+	alice, err := Users.Create(ctx, NewUser{Username: "alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bob, err := Users.Create(ctx, NewUser{Username: "bob"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	org, err := Orgs.Create(ctx, "acme", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OrgMembers.Create(ctx, org.ID, bob.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	global, err := SearchContexts.Create(ctx, &types.SearchContext{Name: "ctx", Public: true}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	alicePrivate, err := SearchContexts.Create(ctx, &types.SearchContext{Name: "ctx", NamespaceUserID: &alice.ID}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	orgPrivate, err := SearchContexts.Create(ctx, &types.SearchContext{Name: "ctx", NamespaceOrgID: &org.ID}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := SearchContexts.Create(ctx, &types.SearchContext{Name: "ctx", NamespaceUserID: &alice.ID}, nil); err == nil {
+		t.Error("expected an error creating a search context with a duplicate name in the same namespace")
+	}
+
+	for _, tc := range []struct {
+		namespace string
+		want      int64
+	}{
+		{"", global.ID},
+		{"alice", alicePrivate.ID},
+		{"acme", orgPrivate.ID},
+	} {
+		got, err := SearchContexts.GetBySpec(ctx, tc.namespace, "ctx")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.ID != tc.want || got.NamespaceName != tc.namespace {
+			t.Errorf("GetBySpec(%q): got %+v, want ID %d", tc.namespace, got, tc.want)
+		}
+	}
+	if _, err := SearchContexts.GetBySpec(ctx, "bob", "ctx"); err != ErrSearchContextNotFound {
+		t.Errorf("got error %v, want ErrSearchContextNotFound", err)
+	}
+
+	listIDs := func(opt SearchContextsListOptions) []int64 {
+		t.Helper()
+		contexts, err := SearchContexts.List(ctx, opt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		count, err := SearchContexts.Count(ctx, opt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if count != len(contexts) {
+			t.Errorf("got count %d, want %d", count, len(contexts))
+		}
+		var ids []int64
+		for _, sc := range contexts {
+			ids = append(ids, sc.ID)
+		}
+		return ids
+	}
+	if got, want := listIDs(SearchContextsListOptions{ViewerUserID: alice.ID}), []int64{global.ID, alicePrivate.ID}; !reflect.DeepEqual(got, want) {
+		t.Errorf("alice: got %v, want %v", got, want)
+	}
+	if got, want := listIDs(SearchContextsListOptions{ViewerUserID: bob.ID}), []int64{global.ID, orgPrivate.ID}; !reflect.DeepEqual(got, want) {
+		t.Errorf("bob: got %v, want %v", got, want)
+	}
+
+	global.Description = "updated"
+	global.Public = false
+	if _, err := SearchContexts.Update(ctx, global, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := listIDs(SearchContextsListOptions{}); len(got) != 0 {
+		t.Errorf("anonymous: got %v, want no search contexts", got)
+	}
+	if got, want := listIDs(SearchContextsListOptions{IncludeAllPrivate: true}), []int64{global.ID, alicePrivate.ID, orgPrivate.ID}; !reflect.DeepEqual(got, want) {
+		t.Errorf("site admin: got %v, want %v", got, want)
+	}
+
+	for _, name := range []string{"a/b", "a/c"} {
+		if err := Repos.Upsert(ctx, InsertRepoOp{Name: api.RepoName(name)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	b, err := Repos.GetByName(ctx, "a/b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := Repos.GetByName(ctx, "a/c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []*types.SearchContextRepositoryRevisions{
+		{RepoID: b.ID, RepoName: b.Name, Revisions: []string{"main", "v1"}},
+		{RepoID: c.ID, RepoName: c.Name, Revisions: []string{""}},
+	}
+	if _, err := SearchContexts.Update(ctx, global, want); err != nil {
+		t.Fatal(err)
+	}
+	got, err := SearchContexts.GetRepositoryRevisions(ctx, global.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got repository revisions %+v, want %+v", got, want)
+	}
+
+	// A search context is not created if its repository revisions can't be
+	// stored.
+	missingRepo := []*types.SearchContextRepositoryRevisions{{RepoID: c.ID + 1000, Revisions: []string{""}}}
+	if _, err := SearchContexts.Create(ctx, &types.SearchContext{Name: "missing-repo", Public: true}, missingRepo); err == nil {
+		t.Error("expected an error creating a search context with a missing repository")
+	}
+	if _, err := SearchContexts.GetBySpec(ctx, "", "missing-repo"); err != ErrSearchContextNotFound {
+		t.Errorf("got error %v, want ErrSearchContextNotFound", err)
+	}
+
+	if err := SearchContexts.Delete(ctx, global.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := SearchContexts.GetByID(ctx, global.ID); err != ErrSearchContextNotFound {
+		t.Errorf("got error %v, want ErrSearchContextNotFound", err)
+	}
+	if got, err := SearchContexts.GetRepositoryRevisions(ctx, global.ID); err != nil || len(got) != 0 {
+		t.Errorf("got %v, %v, want the repository revisions to be deleted", got, err)
+	}
+}
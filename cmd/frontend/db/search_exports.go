@@ -0,0 +1,218 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"io"
+	"time"
+
+	"github.com/keegancsmith/sqlf"
+	"github.com/pkg/errors"
+
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/types"
+	"github.com/sourcegraph/sourcegraph/internal/db/dbconn"
+)
+
+// ErrSearchExportNotFound occurs when a database operation expects a specific
+// search export to exist but it does not exist.
+var ErrSearchExportNotFound = errors.New("search export not found")
+
+// The states of a search export.
+const (
+	SearchExportQueued     = "queued"
+	SearchExportProcessing = "processing"
+	SearchExportCompleted  = "completed"
+	SearchExportErrored    = "errored"
+)
+
+type searchExports struct{}
+
+const searchExportColumns = "id, user_id, query, version, pattern_type, format, state, failure_message, result_count, created_at, started_at, finished_at"
+
+// Create queues a new search export. Only the UserID, Query, Version,
+// PatternType and Format fields of export are used.
+func (s *searchExports) Create(ctx context.Context, export *types.SearchExport) (*types.SearchExport, error) {
+	q := sqlf.Sprintf(
+		"INSERT INTO search_exports(user_id, query, version, pattern_type, format) VALUES(%s, %s, %s, %s, %s) RETURNING "+searchExportColumns,
+		export.UserID, export.Query, export.Version, export.PatternType, export.Format,
+	)
+	return s.getOneBySQL(ctx, q)
+}
+
+// GetByID returns the search export with the given ID, or
+// ErrSearchExportNotFound.
+func (s *searchExports) GetByID(ctx context.Context, id int64) (*types.SearchExport, error) {
+	return s.getOneBySQL(ctx, sqlf.Sprintf("SELECT "+searchExportColumns+" FROM search_exports WHERE id=%s", id))
+}
+
+// Dequeue marks the oldest queued search export as processing and returns it.
+// It returns nil if no search export is queued. Exports which are being
+// dequeued concurrently by another process are skipped.
+func (s *searchExports) Dequeue(ctx context.Context) (*types.SearchExport, error) {
+	q := sqlf.Sprintf(`
+UPDATE search_exports SET state=%s, started_at=now(), heartbeat_at=now()
+WHERE id = (
+	SELECT id FROM search_exports
+	WHERE state=%s
+	ORDER BY id
+	FOR UPDATE SKIP LOCKED
+	LIMIT 1
+)
+RETURNING `+searchExportColumns,
+		SearchExportProcessing, SearchExportQueued,
+	)
+	export, err := s.getOneBySQL(ctx, q)
+	if err == ErrSearchExportNotFound {
+		return nil, nil
+	}
+	return export, err
+}
+
+// Heartbeat records that the search export with the given ID is still being
+// processed.
+func (s *searchExports) Heartbeat(ctx context.Context, id int64) error {
+	q := sqlf.Sprintf("UPDATE search_exports SET heartbeat_at=now() WHERE id=%s AND state=%s", id, SearchExportProcessing)
+	return s.exec(ctx, q)
+}
+
+// ResetStalled marks search exports which are processing but whose last
+// heartbeat is older than maxAge as queued again, and deletes the data they
+// stored so far. The process which was working on them has stopped, while
+// processes still working on an export keep its heartbeat recent.
+func (s *searchExports) ResetStalled(ctx context.Context, maxAge time.Duration) error {
+	q := sqlf.Sprintf(`
+WITH reset AS (
+	UPDATE search_exports SET state=%s, started_at=NULL, heartbeat_at=NULL
+	WHERE state=%s AND heartbeat_at < now() - (%s * interval '1 second')
+	RETURNING id
+)
+DELETE FROM search_export_chunks WHERE export_id IN (SELECT id FROM reset)`,
+		SearchExportQueued, SearchExportProcessing, int64(maxAge/time.Second),
+	)
+	_, err := dbconn.Global.ExecContext(ctx, q.Query(sqlf.PostgresBindVar), q.Args()...)
+	return err
+}
+
+// AppendChunk stores the seq'th chunk of the exported data of a search export
+// which is processing. Chunks are returned by WriteData in the order of seq.
+func (s *searchExports) AppendChunk(ctx context.Context, id int64, seq int32, data []byte) error {
+	q := sqlf.Sprintf(`
+INSERT INTO search_export_chunks(export_id, seq, data)
+SELECT id, %s, %s FROM search_exports WHERE id=%s AND state=%s
+ON CONFLICT (export_id, seq) DO UPDATE SET data=excluded.data`,
+		seq, data, id, SearchExportProcessing,
+	)
+	return s.exec(ctx, q)
+}
+
+// MarkCompleted marks a search export which is processing as completed. Its
+// exported data must already be stored with AppendChunk.
+func (s *searchExports) MarkCompleted(ctx context.Context, id int64, resultCount int32) error {
+	q := sqlf.Sprintf(
+		"UPDATE search_exports SET state=%s, result_count=%s, finished_at=now() WHERE id=%s AND state=%s",
+		SearchExportCompleted, resultCount, id, SearchExportProcessing,
+	)
+	return s.exec(ctx, q)
+}
+
+// MarkErrored records why a search export which is processing failed, marks
+// it as errored and deletes the data it stored so far.
+func (s *searchExports) MarkErrored(ctx context.Context, id int64, failureMessage string) error {
+	q := sqlf.Sprintf(`
+WITH errored AS (
+	UPDATE search_exports SET state=%s, failure_message=%s, finished_at=now()
+	WHERE id=%s AND state=%s
+	RETURNING id
+), deleted AS (
+	DELETE FROM search_export_chunks WHERE export_id IN (SELECT id FROM errored)
+)
+SELECT count(*) FROM errored`,
+		SearchExportErrored, failureMessage, id, SearchExportProcessing,
+	)
+	var n int
+	if err := dbconn.Global.QueryRowContext(ctx, q.Query(sqlf.PostgresBindVar), q.Args()...).Scan(&n); err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrSearchExportNotFound
+	}
+	return nil
+}
+
+// WriteData writes the exported data of a completed search export to w one
+// chunk at a time. It returns ErrSearchExportNotFound if the export is not
+// completed.
+func (s *searchExports) WriteData(ctx context.Context, id int64, w io.Writer) error {
+	export, err := s.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if export.State != SearchExportCompleted {
+		return ErrSearchExportNotFound
+	}
+
+	q := sqlf.Sprintf("SELECT data FROM search_export_chunks WHERE export_id=%s ORDER BY seq", id)
+	rows, err := dbconn.Global.QueryContext(ctx, q.Query(sqlf.PostgresBindVar), q.Args()...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var data []byte
+		if err := rows.Scan(&data); err != nil {
+			return err
+		}
+		if _, err := w.Write(data); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// DeleteOlderThan deletes finished search exports which finished before the
+// given number of days ago.
+func (s *searchExports) DeleteOlderThan(ctx context.Context, days int) error {
+	q := sqlf.Sprintf("DELETE FROM search_exports WHERE finished_at < now() - (%s * interval '1 day')", days)
+	_, err := dbconn.Global.ExecContext(ctx, q.Query(sqlf.PostgresBindVar), q.Args()...)
+	return err
+}
+
+func (s *searchExports) exec(ctx context.Context, q *sqlf.Query) error {
+	res, err := dbconn.Global.ExecContext(ctx, q.Query(sqlf.PostgresBindVar), q.Args()...)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrSearchExportNotFound
+	}
+	return nil
+}
+
+func (s *searchExports) getOneBySQL(ctx context.Context, q *sqlf.Query) (*types.SearchExport, error) {
+	var e types.SearchExport
+	err := dbconn.Global.QueryRowContext(ctx, q.Query(sqlf.PostgresBindVar), q.Args()...).Scan(
+		&e.ID,
+		&e.UserID,
+		&e.Query,
+		&e.Version,
+		&e.PatternType,
+		&e.Format,
+		&e.State,
+		&e.FailureMessage,
+		&e.ResultCount,
+		&e.CreatedAt,
+		&e.StartedAt,
+		&e.FinishedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, ErrSearchExportNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
@@ -0,0 +1,115 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/types"
+	"github.com/sourcegraph/sourcegraph/internal/db/dbtesting"
+)
+
+func TestSearchExports(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+	dbtesting.SetupGlobalTestDB(t)
+	ctx := context.Background()
+
+	user, err := Users.Create(ctx, NewUser{Username: "u"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	created, err := SearchExports.Create(ctx, &types.SearchExport{
+		UserID:      user.ID,
+		Query:       "secret",
+		Version:     "V2",
+		PatternType: "literal",
+		Format:      "csv",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created.State != SearchExportQueued {
+		t.Errorf("got state %q, want %q", created.State, SearchExportQueued)
+	}
+
+	dequeued, err := SearchExports.Dequeue(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dequeued == nil || dequeued.ID != created.ID || dequeued.State != SearchExportProcessing || dequeued.StartedAt == nil {
+		t.Fatalf("unexpected dequeued export %+v", dequeued)
+	}
+	if again, err := SearchExports.Dequeue(ctx); err != nil || again != nil {
+		t.Fatalf("got %+v, %v, want no queued exports", again, err)
+	}
+
+	// Exports with a recent heartbeat are being processed by another
+	// process, and are not reset.
+	if err := SearchExports.Heartbeat(ctx, created.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := SearchExports.ResetStalled(ctx, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := SearchExports.GetByID(ctx, created.ID); err != nil || got.State != SearchExportProcessing {
+		t.Fatalf("got %+v, %v, want the export still processing", got, err)
+	}
+	// Resetting a stalled export deletes the data it stored so far.
+	if err := SearchExports.AppendChunk(ctx, created.ID, 0, []byte("stale\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := SearchExports.ResetStalled(ctx, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := SearchExports.AppendChunk(ctx, created.ID, 0, []byte("x")); err != ErrSearchExportNotFound {
+		t.Fatalf("got error %v for chunk of queued export, want ErrSearchExportNotFound", err)
+	}
+	if dequeued, err = SearchExports.Dequeue(ctx); err != nil || dequeued == nil || dequeued.ID != created.ID {
+		t.Fatalf("got %+v, %v, want the stalled export queued again", dequeued, err)
+	}
+
+	if err := SearchExports.WriteData(ctx, created.ID, &bytes.Buffer{}); err != ErrSearchExportNotFound {
+		t.Fatalf("got error %v for data of unfinished export, want ErrSearchExportNotFound", err)
+	}
+	for i, chunk := range []string{"a\n", "b\n"} {
+		if err := SearchExports.AppendChunk(ctx, created.ID, int32(i), []byte(chunk)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := SearchExports.MarkCompleted(ctx, created.ID, 2); err != nil {
+		t.Fatal(err)
+	}
+	got, err := SearchExports.GetByID(ctx, created.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.State != SearchExportCompleted || got.ResultCount != 2 || got.FinishedAt == nil {
+		t.Errorf("unexpected completed export %+v", got)
+	}
+	var data bytes.Buffer
+	if err := SearchExports.WriteData(ctx, created.ID, &data); err != nil {
+		t.Fatal(err)
+	}
+	if data.String() != "a\nb\n" {
+		t.Errorf("got data %q", data.String())
+	}
+
+	// Only exports which are processing can be finished.
+	if err := SearchExports.MarkErrored(ctx, created.ID, "boom"); err != ErrSearchExportNotFound {
+		t.Errorf("got error %v for completed export, want ErrSearchExportNotFound", err)
+	}
+	if err := SearchExports.MarkCompleted(ctx, created.ID, 3); err != ErrSearchExportNotFound {
+		t.Errorf("got error %v for completed export, want ErrSearchExportNotFound", err)
+	}
+
+	if _, err := SearchExports.GetByID(ctx, created.ID+1); err != ErrSearchExportNotFound {
+		t.Errorf("got error %v, want ErrSearchExportNotFound", err)
+	}
+	if err := SearchExports.MarkErrored(ctx, created.ID+1, "boom"); err != ErrSearchExportNotFound {
+		t.Errorf("got error %v, want ErrSearchExportNotFound", err)
+	}
+}
@@ -0,0 +1,146 @@
+package db
+
+import (
+	"context"
+
+	"github.com/keegancsmith/sqlf"
+
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/types"
+	"github.com/sourcegraph/sourcegraph/internal/db/dbconn"
+)
+
+type searchHistory struct{}
+
+const searchHistoryColumns = "id, user_id, query, pattern_type, version_context, result_count, created_at"
+
+// SearchHistoryListOptions specifies the options for listing the search
+// history of a user.
+type SearchHistoryListOptions struct {
+	// UserID is the user whose search history is listed.
+	UserID int32
+
+	// Unique lists only the most recent entry of each distinct query (with
+	// the same pattern type and version context).
+	Unique bool
+
+	// BeforeID lists only the entries older than the entry with this ID. It
+	// is used to page through the history. If zero, the most recent entries
+	// are listed.
+	BeforeID int64
+
+	// Limit is the maximum number of entries to list. If zero, all entries
+	// are listed.
+	Limit int
+}
+
+func (o SearchHistoryListOptions) sqlConditions() *sqlf.Query {
+	conds := []*sqlf.Query{sqlf.Sprintf("h.user_id = %s", o.UserID)}
+	if o.Unique {
+		conds = append(conds, sqlf.Sprintf(`NOT EXISTS (
+	SELECT 1 FROM search_history later
+	WHERE later.user_id = h.user_id AND later.query = h.query AND later.pattern_type = h.pattern_type
+	AND later.version_context = h.version_context AND later.id > h.id
+)`))
+	}
+	return sqlf.Join(conds, "AND")
+}
+
+// Create adds an entry to the search history of a user. Only the UserID,
+// Query, PatternType, VersionContext and ResultCount fields of entry are
+// used.
+//
+// 🚨 SECURITY: This method does NOT verify that the current user may add to
+// the search history of the user. It is the caller's responsibility.
+func (s *searchHistory) Create(ctx context.Context, entry *types.SearchHistoryEntry) (*types.SearchHistoryEntry, error) {
+	if Mocks.SearchHistory.Create != nil {
+		return Mocks.SearchHistory.Create(ctx, entry)
+	}
+
+	q := sqlf.Sprintf(
+		"INSERT INTO search_history(user_id, query, pattern_type, version_context, result_count) VALUES(%s, %s, %s, %s, %s) RETURNING "+searchHistoryColumns,
+		entry.UserID, entry.Query, entry.PatternType, entry.VersionContext, entry.ResultCount,
+	)
+	return scanSearchHistoryEntry(dbconn.Global.QueryRowContext(ctx, q.Query(sqlf.PostgresBindVar), q.Args()...))
+}
+
+// List lists the search history entries of a user, most recent first.
+//
+// 🚨 SECURITY: This method does NOT verify that the current user may view the
+// search history of the user. It is the caller's responsibility.
+func (s *searchHistory) List(ctx context.Context, opt SearchHistoryListOptions) ([]*types.SearchHistoryEntry, error) {
+	if Mocks.SearchHistory.List != nil {
+		return Mocks.SearchHistory.List(ctx, opt)
+	}
+
+	conds := opt.sqlConditions()
+	if opt.BeforeID != 0 {
+		conds = sqlf.Sprintf("%s AND h.id < %s", conds, opt.BeforeID)
+	}
+	limit := sqlf.Sprintf("")
+	if opt.Limit > 0 {
+		limit = sqlf.Sprintf("LIMIT %s", opt.Limit)
+	}
+	q := sqlf.Sprintf("SELECT "+searchHistoryColumns+" FROM search_history h WHERE %s ORDER BY h.id DESC %s", conds, limit)
+	rows, err := dbconn.Global.QueryContext(ctx, q.Query(sqlf.PostgresBindVar), q.Args()...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []*types.SearchHistoryEntry
+	for rows.Next() {
+		entry, err := scanSearchHistoryEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+// Count counts the search history entries of a user. The BeforeID and Limit
+// options are ignored.
+func (s *searchHistory) Count(ctx context.Context, opt SearchHistoryListOptions) (int, error) {
+	if Mocks.SearchHistory.Count != nil {
+		return Mocks.SearchHistory.Count(ctx, opt)
+	}
+
+	q := sqlf.Sprintf("SELECT COUNT(*) FROM search_history h WHERE %s", opt.sqlConditions())
+	var count int
+	err := dbconn.Global.QueryRowContext(ctx, q.Query(sqlf.PostgresBindVar), q.Args()...).Scan(&count)
+	return count, err
+}
+
+// DeleteByUser deletes the search history of a user.
+//
+// 🚨 SECURITY: This method does NOT verify that the current user may delete
+// the search history of the user. It is the caller's responsibility.
+func (s *searchHistory) DeleteByUser(ctx context.Context, userID int32) error {
+	q := sqlf.Sprintf("DELETE FROM search_history WHERE user_id=%s", userID)
+	_, err := dbconn.Global.ExecContext(ctx, q.Query(sqlf.PostgresBindVar), q.Args()...)
+	return err
+}
+
+// DeleteOlderThan deletes the search history entries created before the
+// given number of days ago.
+func (s *searchHistory) DeleteOlderThan(ctx context.Context, days int) error {
+	q := sqlf.Sprintf("DELETE FROM search_history WHERE created_at < now() - (%s * interval '1 day')", days)
+	_, err := dbconn.Global.ExecContext(ctx, q.Query(sqlf.PostgresBindVar), q.Args()...)
+	return err
+}
+
+func scanSearchHistoryEntry(s interface{ Scan(...interface{}) error }) (*types.SearchHistoryEntry, error) {
+	var e types.SearchHistoryEntry
+	if err := s.Scan(
+		&e.ID,
+		&e.UserID,
+		&e.Query,
+		&e.PatternType,
+		&e.VersionContext,
+		&e.ResultCount,
+		&e.CreatedAt,
+	); err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
@@ -0,0 +1,13 @@
+package db
+
+import (
+	"context"
+
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/types"
+)
+
+type MockSearchHistory struct {
+	Create func(ctx context.Context, entry *types.SearchHistoryEntry) (*types.SearchHistoryEntry, error)
+	List   func(ctx context.Context, opt SearchHistoryListOptions) ([]*types.SearchHistoryEntry, error)
+	Count  func(ctx context.Context, opt SearchHistoryListOptions) (int, error)
+}
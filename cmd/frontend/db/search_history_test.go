@@ -0,0 +1,100 @@
+package db
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/types"
+	"github.com/sourcegraph/sourcegraph/internal/db/dbconn"
+	"github.com/sourcegraph/sourcegraph/internal/db/dbtesting"
+)
+
+func TestSearchHistory(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+	dbtesting.SetupGlobalTestDB(t)
+	ctx := context.Background()
+
+	alice, err := Users.Create(ctx, NewUser{Username: "alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bob, err := Users.Create(ctx, NewUser{Username: "bob"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, entry := range []*types.SearchHistoryEntry{
+		{UserID: alice.ID, Query: "foo", PatternType: "literal", ResultCount: 3},
+		{UserID: alice.ID, Query: "bar", PatternType: "regexp", VersionContext: "release", ResultCount: 1},
+		{UserID: bob.ID, Query: "baz", PatternType: "literal"},
+		{UserID: alice.ID, Query: "foo", PatternType: "literal", ResultCount: 4},
+	} {
+		created, err := SearchHistory.Create(ctx, entry)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if created.ID == 0 || created.Query != entry.Query || created.VersionContext != entry.VersionContext || created.CreatedAt.IsZero() {
+			t.Errorf("unexpected created entry %+v", created)
+		}
+	}
+
+	queries := func(opt SearchHistoryListOptions) []string {
+		t.Helper()
+		entries, err := SearchHistory.List(ctx, opt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var queries []string
+		for _, e := range entries {
+			queries = append(queries, e.Query)
+		}
+		return queries
+	}
+
+	if got, want := queries(SearchHistoryListOptions{UserID: alice.ID}), []string{"foo", "bar", "foo"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := queries(SearchHistoryListOptions{UserID: alice.ID, Unique: true}), []string{"foo", "bar"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got unique %v, want %v", got, want)
+	}
+
+	// Page through the history.
+	page, err := SearchHistory.List(ctx, SearchHistoryListOptions{UserID: alice.ID, Limit: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page) != 2 {
+		t.Fatalf("got %d entries, want 2", len(page))
+	}
+	if got, want := queries(SearchHistoryListOptions{UserID: alice.ID, BeforeID: page[1].ID, Limit: 2}), []string{"foo"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got second page %v, want %v", got, want)
+	}
+
+	if count, err := SearchHistory.Count(ctx, SearchHistoryListOptions{UserID: alice.ID, Unique: true, Limit: 1}); err != nil || count != 2 {
+		t.Errorf("got count %d (error %v), want 2", count, err)
+	}
+
+	// Entries older than the retention period are deleted.
+	if _, err := dbconn.Global.ExecContext(ctx, "UPDATE search_history SET created_at = now() - interval '40 days' WHERE query = 'bar'"); err != nil {
+		t.Fatal(err)
+	}
+	if err := SearchHistory.DeleteOlderThan(ctx, 30); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := queries(SearchHistoryListOptions{UserID: alice.ID}), []string{"foo", "foo"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v after deleting old entries, want %v", got, want)
+	}
+
+	if err := SearchHistory.DeleteByUser(ctx, alice.ID); err != nil {
+		t.Fatal(err)
+	}
+	if got := queries(SearchHistoryListOptions{UserID: alice.ID}); len(got) != 0 {
+		t.Errorf("got %v after deleting the history, want none", got)
+	}
+	if got, want := queries(SearchHistoryListOptions{UserID: bob.ID}), []string{"baz"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v for another user, want %v", got, want)
+	}
+}
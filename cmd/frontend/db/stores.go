@@ -17,6 +17,16 @@ var (
 
 	SurveyResponses = &surveyResponses{}
 
+	SearchExports = &searchExports{}
+
+	GlobalSymbols = &globalSymbols{}
+
+	SearchContexts = &searchContexts{}
+
+	SearchHistory = &searchHistory{}
+
+	RepoGroups = &repoGroups{}
+
 	ExternalAccounts = &userExternalAccounts{}
 
 	OrgInvitations = &orgInvitations{}
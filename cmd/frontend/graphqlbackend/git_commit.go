@@ -10,8 +10,10 @@ import (
 	"github.com/sourcegraph/sourcegraph/cmd/frontend/graphqlbackend/externallink"
 	"github.com/sourcegraph/sourcegraph/cmd/frontend/graphqlbackend/graphqlutil"
 	"github.com/sourcegraph/sourcegraph/internal/api"
+	"github.com/sourcegraph/sourcegraph/internal/archive"
 	"github.com/sourcegraph/sourcegraph/internal/gitserver"
 	"github.com/sourcegraph/sourcegraph/internal/vcs/git"
+	"github.com/sourcegraph/sourcegraph/internal/vcs/util"
 
 	graphql "github.com/graph-gophers/graphql-go"
 	"github.com/graph-gophers/graphql-go/relay"
@@ -211,6 +213,9 @@ func (r *GitCommitResolver) Blob(ctx context.Context, args *struct {
 	if err != nil {
 		return nil, err
 	}
+	if archivePath, name, ok := archive.SplitPath(args.Path); ok {
+		return r.archiveBlob(ctx, *cachedRepo, archivePath, name)
+	}
 	stat, err := git.Stat(ctx, *cachedRepo, api.CommitID(r.oid), args.Path)
 	if err != nil {
 		return nil, err
@@ -224,6 +229,34 @@ func (r *GitCommitResolver) Blob(ctx context.Context, args *struct {
 	}, nil
 }
 
+// archiveBlob resolves the file name in the archive at archivePath, which
+// searches report at paths like lib/foo.jar!/com/x/Y.java.
+func (r *GitCommitResolver) archiveBlob(ctx context.Context, repo gitserver.Repo, archivePath, name string) (*GitTreeEntryResolver, error) {
+	path := archivePath + archive.PathSeparator + name
+	stat, err := git.Stat(ctx, repo, api.CommitID(r.oid), archivePath)
+	if err != nil {
+		return nil, err
+	}
+	if !stat.Mode().IsRegular() {
+		return nil, fmt.Errorf("not a blob: %q", path)
+	}
+	data, err := git.ReadFile(ctx, repo, api.CommitID(r.oid), archivePath, 0)
+	if err != nil {
+		return nil, err
+	}
+	content, err := archive.ReadFile(archivePath, data, name)
+	if err != nil {
+		return nil, err
+	}
+	entry := &GitTreeEntryResolver{
+		commit: r,
+		stat:   &util.FileInfo{Name_: path, Mode_: stat.Mode(), Size_: int64(len(content)), ModTime_: stat.ModTime()},
+	}
+	// The content can't be read from the repository at path.
+	entry.contentOnce.Do(func() { entry.content = content })
+	return entry, nil
+}
+
 func (r *GitCommitResolver) File(ctx context.Context, args *struct {
 	Path string
 }) (*GitTreeEntryResolver, error) {
@@ -1,6 +1,21 @@
 package graphqlbackend
 
-import "testing"
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"os"
+	"testing"
+
+	"github.com/graph-gophers/graphql-go/gqltesting"
+
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/backend"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/db"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/types"
+	"github.com/sourcegraph/sourcegraph/internal/api"
+	"github.com/sourcegraph/sourcegraph/internal/vcs/git"
+	"github.com/sourcegraph/sourcegraph/internal/vcs/util"
+)
 
 func TestGitCommitBody(t *testing.T) {
 	tests := map[string]string{
@@ -18,3 +33,74 @@ func TestGitCommitBody(t *testing.T) {
 		}
 	}
 }
+
+func TestGitCommitBlob_archive(t *testing.T) {
+	resetMocks()
+	db.Mocks.ExternalServices.List = func(opt db.ExternalServicesListOptions) ([]*types.ExternalService, error) {
+		return nil, nil
+	}
+	db.Mocks.Repos.MockGetByName(t, "github.com/gorilla/mux", 2)
+	backend.Mocks.Repos.ResolveRev = func(ctx context.Context, repo *types.Repo, rev string) (api.CommitID, error) {
+		return exampleCommitSHA1, nil
+	}
+	backend.Mocks.Repos.MockGetCommit_Return_NoCheck(t, &git.Commit{ID: exampleCommitSHA1})
+
+	var jar bytes.Buffer
+	zw := zip.NewWriter(&jar)
+	w, err := zw.Create("com/x/Y.java")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write([]byte("class Y {}")); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	git.Mocks.Stat = func(commit api.CommitID, path string) (os.FileInfo, error) {
+		if want := "lib/foo.jar"; path != want {
+			t.Errorf("got path %q, want %q", path, want)
+		}
+		return &util.FileInfo{Name_: path, Mode_: 0644}, nil
+	}
+	git.Mocks.ReadFile = func(commit api.CommitID, name string) ([]byte, error) {
+		if want := "lib/foo.jar"; name != want {
+			t.Errorf("got name %q, want %q", name, want)
+		}
+		return jar.Bytes(), nil
+	}
+	defer git.ResetMocks()
+
+	gqltesting.RunTests(t, []*gqltesting.Test{
+		{
+			Schema: mustParseGraphQLSchema(t),
+			Query: `
+				{
+					repository(name: "github.com/gorilla/mux") {
+						commit(rev: "` + exampleCommitSHA1 + `") {
+							blob(path: "lib/foo.jar!/com/x/Y.java") {
+								name
+								path
+								content
+							}
+						}
+					}
+				}
+			`,
+			ExpectedResult: `
+{
+  "repository": {
+    "commit": {
+      "blob": {
+        "name": "Y.java",
+        "path": "lib/foo.jar!/com/x/Y.java",
+        "content": "class Y {}"
+      }
+    }
+  }
+}
+			`,
+		},
+	})
+}
@@ -486,6 +486,11 @@ func (r *NodeResolver) ToSavedSearch() (*savedSearchResolver, bool) {
 	return n, ok
 }
 
+func (r *NodeResolver) ToSearchContext() (*searchContextResolver, bool) {
+	n, ok := r.Node.(*searchContextResolver)
+	return n, ok
+}
+
 func (r *NodeResolver) ToSite() (*siteResolver, bool) {
 	n, ok := r.Node.(*siteResolver)
 	return n, ok
@@ -587,6 +592,8 @@ func (r *schemaResolver) nodeByID(ctx context.Context, id graphql.ID) (Node, err
 		return RegistryExtensionByID(ctx, id)
 	case "SavedSearch":
 		return savedSearchByID(ctx, id)
+	case "SearchContext":
+		return searchContextByID(ctx, id)
 	case "Site":
 		return siteByGQLID(ctx, id)
 	case "LSIFUpload":
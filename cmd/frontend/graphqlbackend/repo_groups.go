@@ -2,35 +2,277 @@ package graphqlbackend
 
 import (
 	"context"
+	"regexp"
 
+	graphql "github.com/graph-gophers/graphql-go"
+	"github.com/graph-gophers/graphql-go/relay"
+	"github.com/pkg/errors"
+
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/backend"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/db"
+	dbquery "github.com/sourcegraph/sourcegraph/cmd/frontend/db/query"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/types"
 	"github.com/sourcegraph/sourcegraph/internal/api"
 )
 
+// repoGroupDefinition is the definition of a repo group: the repositories
+// it lists, and for stored groups, the patterns and code host topics of the
+// other repositories in the group.
+type repoGroupDefinition struct {
+	repos    []*types.Repo
+	patterns []string
+	topics   []string
+}
+
+// add adds the repositories of the stored group to d.
+func (d *repoGroupDefinition) add(group *types.RepoGroup) {
+	for _, name := range group.Repositories {
+		d.repos = append(d.repos, &types.Repo{Name: api.RepoName(name)})
+	}
+	if group.Pattern != "" {
+		d.patterns = append(d.patterns, group.Pattern)
+	}
+	d.topics = append(d.topics, group.Topics...)
+}
+
+// listed reports whether all repositories of d are listed in d.repos.
+func (d *repoGroupDefinition) listed() bool {
+	return len(d.patterns) == 0 && len(d.topics) == 0
+}
+
+// query returns the query for db.ReposListOptions.PatternQuery matching the
+// repositories of d. Topics match GitHub topics and GitLab project tags.
+func (d *repoGroupDefinition) query() dbquery.Q {
+	atoms := make([]dbquery.Q, 0, 1+len(d.patterns)+2*len(d.topics))
+	if len(d.repos) > 0 {
+		names := make([]string, len(d.repos))
+		for i, repo := range d.repos {
+			names[i] = "^" + regexp.QuoteMeta(string(repo.Name)) + "$"
+		}
+		atoms = append(atoms, unionRegExps(names))
+	}
+	for _, pattern := range d.patterns {
+		atoms = append(atoms, pattern)
+	}
+	for _, topic := range d.topics {
+		atoms = append(atoms,
+			db.RepoMetadataFilter{Kind: db.RepoMetadataTopic, Value: topic},
+			db.RepoMetadataFilter{Kind: db.RepoMetadataLabel, Value: topic},
+		)
+	}
+	return dbquery.Or(atoms...)
+}
+
 type repoGroup struct {
-	name         string
-	repositories []api.RepoName
+	name       string
+	definition *repoGroupDefinition
+
+	// group is the stored repository group, or nil if the group is only
+	// defined in settings.
+	group *types.RepoGroup
+}
+
+func marshalRepoGroupID(id int32) graphql.ID {
+	return relay.MarshalID("RepoGroup", id)
+}
+
+func unmarshalRepoGroupID(id graphql.ID) (repoGroupID int32, err error) {
+	err = relay.UnmarshalSpec(id, &repoGroupID)
+	return
+}
+
+func (g repoGroup) ID() *graphql.ID {
+	if g.group == nil {
+		return nil
+	}
+	id := marshalRepoGroupID(g.group.ID)
+	return &id
 }
 
 func (g repoGroup) Name() string { return g.name }
 
-func (g repoGroup) Repositories() []string { return repoNamesToStrings(g.repositories) }
+func (g repoGroup) Description() string {
+	if g.group == nil {
+		return ""
+	}
+	return g.group.Description
+}
+
+func (g repoGroup) Repositories(ctx context.Context) ([]string, error) {
+	if g.group == nil && g.definition.listed() {
+		return repoNamesToStrings(repoNames(g.definition.repos)), nil
+	}
+	// 🚨 SECURITY: db.Repos.List omits the repositories the current user
+	// cannot access.
+	repos, err := db.Repos.List(ctx, db.ReposListOptions{
+		OnlyRepoIDs:  true,
+		PatternQuery: g.definition.query(),
+		OrderBy:      db.RepoListOrderBy{{Field: db.RepoListName}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return repoNamesToStrings(repoNames(repos)), nil
+}
+
+func (g repoGroup) ExplicitRepositories() []string {
+	if g.group == nil {
+		return repoNamesToStrings(repoNames(g.definition.repos))
+	}
+	return g.group.Repositories
+}
+
+func (g repoGroup) Pattern() *string {
+	if g.group == nil || g.group.Pattern == "" {
+		return nil
+	}
+	return &g.group.Pattern
+}
+
+func (g repoGroup) Topics() []string {
+	if g.group == nil || g.group.Topics == nil {
+		return []string{}
+	}
+	return g.group.Topics
+}
 
 func (r *schemaResolver) RepoGroups(ctx context.Context) ([]*repoGroup, error) {
-	groupsByName, err := resolveRepoGroups(ctx)
+	definitions, err := resolveRepoGroups(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	groups := make([]*repoGroup, 0, len(groupsByName))
-	for name, repos := range groupsByName {
-		repoPaths := make([]api.RepoName, len(repos))
-		for i, repo := range repos {
-			repoPaths[i] = repo.Name
-		}
+	dbGroups, err := db.RepoGroups.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	dbGroupsByName := make(map[string]*types.RepoGroup, len(dbGroups))
+	for _, group := range dbGroups {
+		dbGroupsByName[group.Name] = group
+	}
+
+	groups := make([]*repoGroup, 0, len(definitions))
+	for name, definition := range definitions {
 		groups = append(groups, &repoGroup{
-			name:         name,
-			repositories: repoPaths,
+			name:       name,
+			definition: definition,
+			group:      dbGroupsByName[name],
 		})
 	}
 	return groups, nil
 }
+
+func repoNames(repos []*types.Repo) []api.RepoName {
+	names := make([]api.RepoName, len(repos))
+	for i, repo := range repos {
+		names[i] = repo.Name
+	}
+	return names
+}
+
+type repoGroupArgs struct {
+	Name         string
+	Description  *string
+	Repositories *[]string
+	Pattern      *string
+	Topics       *[]string
+}
+
+func (args *repoGroupArgs) toRepoGroup() (*types.RepoGroup, error) {
+	group := &types.RepoGroup{Name: args.Name}
+	if args.Description != nil {
+		group.Description = *args.Description
+	}
+	if args.Repositories != nil {
+		group.Repositories = *args.Repositories
+	}
+	if args.Pattern != nil {
+		if _, err := regexp.Compile(*args.Pattern); err != nil {
+			return nil, errors.Wrap(err, "invalid repository group pattern")
+		}
+		group.Pattern = *args.Pattern
+	}
+	if args.Topics != nil {
+		group.Topics = *args.Topics
+	}
+	if group.Name == "" {
+		return nil, errors.New("the repository group name must not be empty")
+	}
+	if len(group.Repositories) == 0 && group.Pattern == "" && len(group.Topics) == 0 {
+		return nil, errors.New("a repository group needs at least one of repositories, pattern or topics")
+	}
+	return group, nil
+}
+
+func (r *schemaResolver) CreateRepoGroup(ctx context.Context, args *repoGroupArgs) (*repoGroup, error) {
+	// 🚨 SECURITY: Only site admins can manage repository groups.
+	if err := backend.CheckCurrentUserIsSiteAdmin(ctx); err != nil {
+		return nil, err
+	}
+	group, err := args.toRepoGroup()
+	if err != nil {
+		return nil, err
+	}
+	group, err = db.RepoGroups.Create(ctx, group)
+	if err != nil {
+		return nil, err
+	}
+	return storedRepoGroup(group), nil
+}
+
+func (r *schemaResolver) UpdateRepoGroup(ctx context.Context, args *struct {
+	ID           graphql.ID
+	Name         string
+	Description  *string
+	Repositories *[]string
+	Pattern      *string
+	Topics       *[]string
+}) (*repoGroup, error) {
+	// 🚨 SECURITY: Only site admins can manage repository groups.
+	if err := backend.CheckCurrentUserIsSiteAdmin(ctx); err != nil {
+		return nil, err
+	}
+	id, err := unmarshalRepoGroupID(args.ID)
+	if err != nil {
+		return nil, err
+	}
+	group, err := (&repoGroupArgs{
+		Name:         args.Name,
+		Description:  args.Description,
+		Repositories: args.Repositories,
+		Pattern:      args.Pattern,
+		Topics:       args.Topics,
+	}).toRepoGroup()
+	if err != nil {
+		return nil, err
+	}
+	group.ID = id
+	group, err = db.RepoGroups.Update(ctx, group)
+	if err != nil {
+		return nil, err
+	}
+	return storedRepoGroup(group), nil
+}
+
+func (r *schemaResolver) DeleteRepoGroup(ctx context.Context, args *struct {
+	ID graphql.ID
+}) (*EmptyResponse, error) {
+	// 🚨 SECURITY: Only site admins can manage repository groups.
+	if err := backend.CheckCurrentUserIsSiteAdmin(ctx); err != nil {
+		return nil, err
+	}
+	id, err := unmarshalRepoGroupID(args.ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.RepoGroups.Delete(ctx, id); err != nil {
+		return nil, err
+	}
+	return &EmptyResponse{}, nil
+}
+
+func storedRepoGroup(group *types.RepoGroup) *repoGroup {
+	definition := &repoGroupDefinition{}
+	definition.add(group)
+	return &repoGroup{name: group.Name, definition: definition, group: group}
+}
@@ -0,0 +1,133 @@
+package graphqlbackend
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/db"
+	dbquery "github.com/sourcegraph/sourcegraph/cmd/frontend/db/query"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/types"
+	"github.com/sourcegraph/sourcegraph/schema"
+)
+
+func TestResolveRepoGroups(t *testing.T) {
+	mockDecodedViewerFinalSettings = &schema.Settings{
+		SearchRepositoryGroups: map[string][]string{
+			"payments": {"github.com/acme/legacy"},
+			"docs":     {"github.com/acme/docs"},
+		},
+	}
+	db.Mocks.RepoGroups.List = func(ctx context.Context) ([]*types.RepoGroup, error) {
+		return []*types.RepoGroup{{
+			ID:           1,
+			Name:         "payments",
+			Repositories: []string{"github.com/acme/billing"},
+			Pattern:      "^github\\.com/acme/pay",
+			Topics:       []string{"payments"},
+		}}, nil
+	}
+	db.Mocks.Repos.List = func(ctx context.Context, opt db.ReposListOptions) ([]*types.Repo, error) {
+		t.Fatalf("unexpected repository listing %+v", opt)
+		return nil, nil
+	}
+	defer func() {
+		mockDecodedViewerFinalSettings = nil
+		db.Mocks.RepoGroups = db.MockRepoGroups{}
+		db.Mocks.Repos.List = nil
+	}()
+
+	groups, err := resolveRepoGroups(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]string{}
+	for name, group := range groups {
+		got[name] = dbquery.Print(group.query())
+	}
+	want := map[string]string{
+		"payments": dbquery.Print(dbquery.Or(
+			`^github\.com/acme/legacy$|^github\.com/acme/billing$`,
+			"^github\\.com/acme/pay",
+			db.RepoMetadataFilter{Kind: db.RepoMetadataTopic, Value: "payments"},
+			db.RepoMetadataFilter{Kind: db.RepoMetadataLabel, Value: "payments"},
+		)),
+		"docs": dbquery.Print(dbquery.Or(`^github\.com/acme/docs$`)),
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if !groups["docs"].listed() || groups["payments"].listed() {
+		t.Errorf("got listed %v for docs and %v for payments, want true and false", groups["docs"].listed(), groups["payments"].listed())
+	}
+
+	// Only the given groups are resolved.
+	groups, err = resolveRepoGroups(context.Background(), "docs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(groups) != 1 || len(groups["docs"].repos) != 1 {
+		t.Errorf("got groups %v, want only docs", groups)
+	}
+}
+
+func TestResolveRepositories_repoGroups(t *testing.T) {
+	mockResolveRepoGroups = func() (map[string]*repoGroupDefinition, error) {
+		return map[string]*repoGroupDefinition{
+			"payments": {patterns: []string{"^github\\.com/acme/pay"}, topics: []string{"payments"}},
+		}, nil
+	}
+	var gotOpt db.ReposListOptions
+	db.Mocks.Repos.List = func(ctx context.Context, opt db.ReposListOptions) ([]*types.Repo, error) {
+		gotOpt = opt
+		return []*types.Repo{{Name: "github.com/acme/payouts"}}, nil
+	}
+	defer func() {
+		mockResolveRepoGroups = nil
+		db.Mocks.Repos.List = nil
+	}()
+
+	repos, _, _, _, err := resolveRepositories(context.Background(), resolveRepoOp{repoGroupFilters: []string{"payments"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(repos) != 1 {
+		t.Errorf("got %d repositories, want 1", len(repos))
+	}
+	// The pattern and topics of the group are queried together with the
+	// other filters, instead of listing the repositories of the group.
+	want := dbquery.Or(dbquery.Or(
+		"^github\\.com/acme/pay",
+		db.RepoMetadataFilter{Kind: db.RepoMetadataTopic, Value: "payments"},
+		db.RepoMetadataFilter{Kind: db.RepoMetadataLabel, Value: "payments"},
+	))
+	if !reflect.DeepEqual(gotOpt.PatternQuery, want) || len(gotOpt.IncludePatterns) != 0 {
+		t.Errorf("got options %+v, want PatternQuery %s", gotOpt, dbquery.Print(want))
+	}
+}
+
+func TestRepoGroupArgs(t *testing.T) {
+	str := func(s string) *string { return &s }
+	strs := func(s ...string) *[]string { return &s }
+	tests := []struct {
+		args    repoGroupArgs
+		wantErr string
+	}{
+		{args: repoGroupArgs{Name: "g", Pattern: str("^a")}},
+		{args: repoGroupArgs{Name: "g", Topics: strs("payments")}},
+		{args: repoGroupArgs{Name: "g", Repositories: strs("a/b")}},
+		{args: repoGroupArgs{Name: "g"}, wantErr: "a repository group needs at least one of repositories, pattern or topics"},
+		{args: repoGroupArgs{Pattern: str("a")}, wantErr: "the repository group name must not be empty"},
+		{args: repoGroupArgs{Name: "g", Pattern: str("(")}, wantErr: "invalid repository group pattern: error parsing regexp: missing closing ): `(`"},
+	}
+	for _, test := range tests {
+		_, err := test.args.toRepoGroup()
+		var gotErr string
+		if err != nil {
+			gotErr = err.Error()
+		}
+		if gotErr != test.wantErr {
+			t.Errorf("%+v: got error %q, want %q", test.args, gotErr, test.wantErr)
+		}
+	}
+}
@@ -389,6 +389,55 @@ type Mutation {
     ): SavedSearch!
     # Deletes a saved search
     deleteSavedSearch(id: ID!): EmptyResponse
+    # Deletes all entries of the user's search history.
+    #
+    # Only the user and site admins may perform this mutation.
+    clearSearchHistory(user: ID!): EmptyResponse!
+    # Creates a search context. Only site admins can create search contexts
+    # without a namespace.
+    createSearchContext(
+        searchContext: SearchContextInput!
+        repositories: [SearchContextRepositoryRevisionsInput!]!
+    ): SearchContext!
+    # Updates a search context and replaces its repositories and revisions.
+    updateSearchContext(
+        id: ID!
+        searchContext: SearchContextEditInput!
+        repositories: [SearchContextRepositoryRevisionsInput!]!
+    ): SearchContext!
+    # Deletes a search context.
+    deleteSearchContext(id: ID!): EmptyResponse!
+    # Creates a repository group. A repository belongs to the group if it is listed
+    # in repositories, if its name matches pattern, or if it has one of topics.
+    #
+    # Only site admins may perform this mutation.
+    createRepoGroup(
+        # The name of the group, used in the repogroup: search field.
+        name: String!
+        # The description of the group.
+        description: String
+        # The names of repositories in the group.
+        repositories: [String!]
+        # A regular expression matching the names of repositories in the group.
+        pattern: String
+        # Code host topics (GitHub topics and GitLab project tags) of repositories in the group.
+        topics: [String!]
+    ): RepoGroup!
+    # Updates a repository group, replacing all of its fields.
+    #
+    # Only site admins may perform this mutation.
+    updateRepoGroup(
+        id: ID!
+        name: String!
+        description: String
+        repositories: [String!]
+        pattern: String
+        topics: [String!]
+    ): RepoGroup!
+    # Deletes a repository group. Groups defined in settings are not affected.
+    #
+    # Only site admins may perform this mutation.
+    deleteRepoGroup(id: ID!): EmptyResponse!
 
     # (experimental) The LSIF API may change substantially in the near future as we
     # continue to adjust it for our use cases. Changes will not be documented in the
@@ -1212,6 +1261,36 @@ type Query {
     clientConfiguration: ClientConfigurationDetails!
     # Fetch search filter suggestions for autocompletion.
     searchFilterSuggestions: SearchFilterSuggestions!
+    # Checks a search query for errors and likely mistakes without running it, such as unbalanced
+    # parentheses, regular expressions that can never match, file: values that look like globs and
+    # redundant repo: filters. Editor integrations use this to show diagnostics while a query is typed.
+    validateQuery(
+        # The version of the search syntax being used.
+        version: SearchVersion = V1
+        # The pattern type of the query, if and only if it is not specified in the query string using
+        # the patternType: field.
+        patternType: SearchPatternType
+        # The search query.
+        query: String!
+    ): [SearchQueryDiagnostic!]!
+    # Compares the file content matches of a search query at two revisions of each repository the
+    # query searches, such as to find the matches a migration still has to fix that were not there at
+    # the last release. Matches are identified by their file path and matched text, so a match that
+    # moved to another line is neither added nor removed. Revisions in the repo: filters of the query
+    # are ignored.
+    searchDiff(
+        # The version of the search syntax being used.
+        version: SearchVersion = V1
+        # The pattern type of the query, if and only if it is not specified in the query string using
+        # the patternType: field.
+        patternType: SearchPatternType
+        # The search query.
+        query: String!
+        # The revision to compare against, such as the last release tag.
+        base: String!
+        # The revision to compare. Defaults to the default branch.
+        head: String = ""
+    ): SearchDiff!
     # Runs a search.
     search(
         # The version of the search syntax being used.
@@ -1223,8 +1302,11 @@ type Query {
         # The search query (such as "foo" or "repo:myrepo foo").
         query: String = ""
 
-        # (experimental) Optionally specify the versionContext. If not specified the
+        # (deprecated) Optionally specify the versionContext. If not specified the
         # default version context is used (all repositories on the default branch).
+        #
+        # The version context is resolved like the context: field of the query. Use
+        # the context: field instead.
         versionContext: String
 
         # (experimental) Sourcegraph 3.9 added support for cursor-based paginated
@@ -1246,13 +1328,23 @@ type Query {
         # the first N results (relative to the cursor) should be returned. i.e.
         # how many results to return per page. It must be in the range of 0-5000.
         first: Int
+
+        # Whether to record the search in the search history of the current user.
+        # Clients set it for searches that the user typed, so that searches run on
+        # the user's behalf (such as by extensions or integrations) are not recorded.
+        recordHistory: Boolean = false
     ): Search
     # All saved searches configured for the current user, merged from all configurations.
     savedSearches: [SavedSearch!]!
     # All repository groups for the current user, merged from all configurations.
     repoGroups: [RepoGroup!]!
     # (experimental) All version contexts.
-    versionContexts: [VersionContext!]!
+    versionContexts: [VersionContext!]! @deprecated(reason: "use searchContexts instead")
+    # The search contexts visible to the current user.
+    searchContexts(
+        # Returns the first n search contexts from the list.
+        first: Int
+    ): SearchContextConnection!
     # The current site.
     site: Site!
     # Retrieve responses to surveys.
@@ -1524,6 +1616,46 @@ type SearchResultsStats {
     #
     # Known issue: The LanguageStatistics.totalBytes field values are incorrect in the result.
     languages: [LanguageStatistics!]!
+
+    # Counts of the matches in the search results, grouped by a property of each match. Unlike the
+    # other statistics, the counts are computed over every result of the search instead of only the
+    # first page of results (up to 100,000 results).
+    aggregations(
+        # The property of each match to group by.
+        groupBy: SearchAggregationGroupBy!
+    ): SearchAggregations!
+}
+
+# Counts of search matches grouped by a property of each match.
+type SearchAggregations {
+    # The counts, ordered by descending count.
+    nodes: [SearchAggregation!]!
+    # Whether the search had more results than were counted, in which case the counts are
+    # incomplete.
+    limitHit: Boolean!
+}
+
+# A property of search matches to group search result counts by.
+enum SearchAggregationGroupBy {
+    # Group by the repository of the match.
+    REPO
+    # Group by the path of the file containing the match.
+    PATH
+    # Group by the language of the file containing the match.
+    LANGUAGE
+    # Group by the author of the commit or diff match. Other matches are not counted.
+    AUTHOR
+    # Group by the text matched by the first capture group of the regexp search pattern. Only
+    # content matches are counted.
+    CAPTURE_GROUP
+}
+
+# The number of search matches with a given value of the SearchAggregationGroupBy property.
+type SearchAggregation {
+    # The value of the property, e.g. the repository name when grouping by REPO.
+    value: String!
+    # The number of matches with the value.
+    count: Int!
 }
 
 # A search filter.
@@ -1588,10 +1720,22 @@ type SearchQueryDescription {
 
 # A group of repositories.
 type RepoGroup {
+    # The ID of the group, or null if the group is only defined in settings.
+    id: ID
     # The name.
     name: String!
-    # The repositories.
+    # The description of the group. Empty for groups defined in settings.
+    description: String!
+    # The repositories. Repositories of groups created with the GraphQL API that the
+    # current user cannot access are omitted.
     repositories: [String!]!
+    # The repositories that are listed in the group's definition.
+    explicitRepositories: [String!]!
+    # A regular expression matching the names of repositories in the group, if any.
+    pattern: String
+    # The code host topics (GitHub topics and GitLab project tags) of repositories in
+    # the group.
+    topics: [String!]!
 }
 
 # A diff between two diffable Git objects.
@@ -2047,6 +2191,89 @@ type VersionContext implements Node {
     description: String!
 }
 
+# A search context: a named set of repositories and revisions that a search can
+# be scoped to with the context: field.
+type SearchContext implements Node {
+    # The unique ID for the search context.
+    id: ID!
+    # The name of the search context, unique within its namespace.
+    name: String!
+    # The value of the context: field which scopes a search to this search context,
+    # such as "@alice/my-context" for a search context owned by a user or
+    # organization, or "my-context" for an instance-wide search context.
+    spec: String!
+    # The description of the search context.
+    description: String!
+    # Whether the search context is visible to all users. Private search contexts
+    # are only visible to their owner (or the members of the owning organization)
+    # and site admins.
+    public: Boolean!
+    # The user or organization that owns the search context, or null for an
+    # instance-wide search context.
+    namespace: Namespace
+    # The repositories and revisions searched with this search context. Repositories
+    # the current user does not have access to are omitted.
+    repositories: [SearchContextRepositoryRevisions!]!
+    # Whether the current user can update and delete the search context.
+    viewerCanManage: Boolean!
+    # The date when the search context was created.
+    createdAt: DateTime!
+    # The date when the search context was last updated.
+    updatedAt: DateTime!
+}
+
+# A list of search contexts.
+type SearchContextConnection {
+    # A list of search contexts.
+    nodes: [SearchContext!]!
+    # The total count of search contexts in the connection. This total count may be
+    # larger than the number of nodes in this object when the result is paginated.
+    totalCount: Int!
+}
+
+# A repository and the revisions of it that belong to a search context.
+type SearchContextRepositoryRevisions {
+    # The repository.
+    repository: Repository!
+    # The revisions. An empty string is the default branch. If there are no revisions, the default
+    # branch is searched.
+    revisions: [String!]!
+}
+
+# The fields of a new search context.
+input SearchContextInput {
+    # The name of the search context, unique within its namespace. It may only
+    # contain letters, digits, ".", "_" and "-".
+    name: String!
+    # The description of the search context.
+    description: String!
+    # Whether the search context is visible to all users.
+    public: Boolean!
+    # The ID of the user or organization that owns the search context. If null,
+    # the search context is an instance-wide search context.
+    namespace: ID
+}
+
+# The editable fields of a search context.
+input SearchContextEditInput {
+    # The name of the search context, unique within its namespace. It may only
+    # contain letters, digits, ".", "_" and "-".
+    name: String!
+    # The description of the search context.
+    description: String!
+    # Whether the search context is visible to all users.
+    public: Boolean!
+}
+
+# A repository and the revisions of it that belong to a search context.
+input SearchContextRepositoryRevisionsInput {
+    # The ID of the repository.
+    repositoryID: ID!
+    # The revisions. An empty string is the default branch. If there are no revisions, the default
+    # branch is searched.
+    revisions: [String!]!
+}
+
 # Information about a repository's text search index.
 type RepositoryTextSearchIndex {
     # The indexed repository.
@@ -2395,6 +2622,17 @@ enum SymbolKind {
     TYPEPARAMETER
 }
 
+# A symbol in the outline of a file, with the symbols defined in it.
+type SymbolOutlineNode {
+    # The symbol.
+    symbol: Symbol!
+    # The range of the whole definition of the symbol, such as a class including its body. It is the
+    # range of the symbol's name when the end of its definition is unknown.
+    range: Range!
+    # The symbols defined in this symbol, in the order they are defined.
+    children: [SymbolOutlineNode!]!
+}
+
 # A list of symbols.
 type SymbolConnection {
     # A list of symbols.
@@ -2920,6 +3158,10 @@ type GitBlob implements TreeEntry & File2 {
         # Return symbols matching the query.
         query: String
     ): SymbolConnection!
+    # The outline of the symbols defined in this blob, as a tree in which each symbol contains the
+    # symbols defined in it (such as the methods of a class). It is built from the symbols that the
+    # symbols service extracts with ctags, so it is available without precise code intelligence data.
+    symbolOutline: [SymbolOutlineNode!]!
     # Always false, since a blob is a file, not directory.
     isSingleChild(
         # Returns the first n files in the tree.
@@ -3026,6 +3268,10 @@ type FileMatch {
     # The revspec of the revision that contains this match. If no revspec was given (such as when no
     # repository filter or revspec is specified in the search query), it is null.
     revSpec: GitRevSpec
+    # The revspecs of all revisions that contain this match with the same line matches, when the
+    # repository is searched at several revisions (such as with repo:foo@*refs/heads/release-*).
+    # Empty otherwise.
+    revSpecs: [String!]!
     # The resource.
     resource: String! @deprecated(reason: "use the file field instead")
     # The symbols found in this file that match the query.
@@ -3036,6 +3282,35 @@ type FileMatch {
     limitHit: Boolean!
 }
 
+# The difference between the file content matches of a search query at two revisions.
+type SearchDiff {
+    # The matches at the head revision that are not at the base revision.
+    added: [SearchDiffMatch!]!
+    # The matches at the base revision that are not at the head revision.
+    removed: [SearchDiffMatch!]!
+    # The repositories that were not compared because they could not be searched at one of the
+    # revisions, such as because the revision does not exist or the repository is being cloned.
+    skippedRepositories: [Repository!]!
+    # Whether the limit on results was hit at one of the revisions, in which case the diff is
+    # incomplete: only the files returned at both revisions are compared.
+    limitHit: Boolean!
+}
+
+# A match added or removed between two revisions.
+type SearchDiffMatch {
+    # The repository containing the match.
+    repository: Repository!
+    # The revspec of the revision containing the match: the head revision for added matches, and the
+    # base revision for removed matches.
+    revSpec: String!
+    # The path of the file containing the match.
+    path: String!
+    # The matched text. Empty if the path of the file matched the query.
+    text: String!
+    # The line containing the match, or null if the path of the file matched the query.
+    lineMatch: LineMatch
+}
+
 # A line match.
 type LineMatch {
     # The preview.
@@ -3047,6 +3322,25 @@ type LineMatch {
     offsetAndLengths: [[Int!]!]!
     # Whether or not the limit was hit.
     limitHit: Boolean!
+    # The named capture groups of the regexp search pattern in the matches on
+    # the line, such as v in version="(?P<v>[0-9.]+)". Empty unless the search
+    # pattern is a regexp with named capture groups.
+    captureGroups: [CaptureGroup!]!
+}
+
+# The span of a named regexp capture group in a line match.
+type CaptureGroup {
+    # The name of the capture group.
+    name: String!
+    # The text matched by the capture group. It may span several lines.
+    value: String!
+    # The offset of the capture group in the line's preview, measured in
+    # characters (not bytes).
+    offset: Int!
+    # The length of the capture group in the line's preview, measured in
+    # characters (not bytes). If the value spans several lines, only the part
+    # on this line is included.
+    length: Int!
 }
 
 # A hunk.
@@ -3150,6 +3444,18 @@ type User implements Node & SettingsSubject & Namespace {
         # Returns the first n event logs from the list.
         first: Int
     ): EventLogsConnection!
+    # The searches the user ran, most recent first. Searches are only recorded when search history is
+    # enabled in the site configuration.
+    #
+    # Only the user and site admins can access this field.
+    searchHistory(
+        # Returns the first n entries of the search history.
+        first: Int
+        # Opaque pagination cursor.
+        after: String
+        # Whether to return only the most recent of identical searches.
+        unique: Boolean = false
+    ): SearchHistoryConnection!
     # The user's email addresses.
     #
     # Only the user and site admins can access this field.
@@ -4679,6 +4985,54 @@ type EventLog {
     timestamp: DateTime!
 }
 
+# A list of searches in a user's search history.
+type SearchHistoryConnection {
+    # A list of searches.
+    nodes: [SearchHistoryEntry!]!
+    # The total count of searches in the connection. This total count may be larger than the number of
+    # nodes in this object when the result is paginated.
+    totalCount: Int!
+    # Pagination information.
+    pageInfo: PageInfo!
+}
+
+# A problem found in a search query.
+type SearchQueryDiagnostic {
+    # The severity of the problem. Queries with ERROR diagnostics cannot be run.
+    severity: DiagnosticSeverity!
+    # Identifies the kind of problem, such as "unbalanced-parentheses" or "redundant-repo".
+    code: String!
+    # A human-readable description of the problem.
+    message: String!
+    # The range of the query to which the diagnostic applies. Lines and characters are counted in the
+    # query string, with characters counted in Unicode code points.
+    range: Range!
+    # Rewritten queries that fix the problem.
+    fixes: [SearchQueryFix!]!
+}
+
+# A rewritten search query that fixes a problem found in a query.
+type SearchQueryFix {
+    # A human-readable description of the fix.
+    description: String!
+    # The rewritten query.
+    query: String!
+}
+
+# A search in a user's search history.
+type SearchHistoryEntry {
+    # The search query.
+    query: String!
+    # The pattern type of the search.
+    patternType: SearchPatternType!
+    # The version context of the search, if any.
+    versionContext: String
+    # The number of results the search returned.
+    resultCount: Int!
+    # The time the search was run.
+    createdAt: DateTime!
+}
+
 # A list of event logs.
 type EventLogsConnection {
     # A list of event logs.
@@ -18,6 +18,7 @@ import (
 	"github.com/pkg/errors"
 
 	"github.com/sourcegraph/sourcegraph/cmd/frontend/db"
+	dbquery "github.com/sourcegraph/sourcegraph/cmd/frontend/db/query"
 	"github.com/sourcegraph/sourcegraph/cmd/frontend/envvar"
 	"github.com/sourcegraph/sourcegraph/cmd/frontend/types"
 	"github.com/sourcegraph/sourcegraph/internal/api"
@@ -58,6 +59,16 @@ type SearchArgs struct {
 	After          *string
 	First          *int32
 	VersionContext *string
+
+	// Stream, if non-nil, receives results as they are produced by each
+	// search backend. Every result in the final result set is sent on Stream.
+	// Stream is not closed by the search.
+	Stream chan<- SearchEvent
+
+	// RecordHistory, if true, records the search in the search history of
+	// the user running it. Clients set it for the searches that users type in
+	// the search UI.
+	RecordHistory bool
 }
 
 type SearchImplementer interface {
@@ -68,8 +79,8 @@ type SearchImplementer interface {
 }
 
 // NewSearchImplementer returns a SearchImplementer that provides search results and suggestions.
-func NewSearchImplementer(args *SearchArgs) (SearchImplementer, error) {
-	tr, _ := trace.New(context.Background(), "graphql.schemaResolver", "Search")
+func NewSearchImplementer(ctx context.Context, args *SearchArgs) (SearchImplementer, error) {
+	tr, ctx := trace.New(ctx, "graphql.schemaResolver", "Search")
 	defer tr.Finish()
 
 	searchType, err := detectSearchType(args.Version, args.PatternType, args.Query)
@@ -81,27 +92,19 @@ func NewSearchImplementer(args *SearchArgs) (SearchImplementer, error) {
 		return nil, errors.New("Structural search is disabled in the site configuration.")
 	}
 
-	var queryString string
-	if searchType == query.SearchTypeLiteral {
-		queryString = query.ConvertToLiteral(args.Query)
-	} else {
-		queryString = args.Query
+	// The viewer's settings only change how queries containing globs are
+	// processed, so they are not loaded for other queries.
+	var opts query.ProcessOptions
+	if query.MayContainGlobs(args.Query) {
+		if opts, err = processOptions(ctx); err != nil {
+			log15.Warn("loading viewer settings for search, processing query without globbing", "error", err)
+		}
 	}
 
-	var queryInfo query.QueryInfo
-	if conf.AndOrQueryEnabled() && searchType != query.SearchTypeLiteral && query.ContainsAndOrKeyword(args.Query) {
-		// To process the input as an and/or query, the flag must be enabled, not be a
-		// literal search, and must contain either an 'and' or 'or' expression.
-		// Else, fallback to the older existing parser.
-		queryInfo, err = query.ProcessAndOr(args.Query)
-		if err != nil {
-			return alertForQuery(args.Query, err), nil
-		}
-	} else {
-		queryInfo, err = query.Process(queryString, searchType)
-		if err != nil {
-			return alertForQuery(queryString, err), nil
-		}
+	queryString := queryStringForSearchType(args.Query, searchType)
+	queryInfo, err := processQuery(args.Query, searchType, opts)
+	if err != nil {
+		return alertForQuery(queryString, err), nil
 	}
 
 	// If stable:truthy is specified, make the query return a stable result ordering.
@@ -121,7 +124,7 @@ func NewSearchImplementer(args *SearchArgs) (SearchImplementer, error) {
 		}
 	}
 
-	return &searchResolver{
+	r := &searchResolver{
 		query:          queryInfo,
 		originalQuery:  args.Query,
 		versionContext: args.VersionContext,
@@ -129,11 +132,49 @@ func NewSearchImplementer(args *SearchArgs) (SearchImplementer, error) {
 		patternType:    searchType,
 		zoekt:          search.Indexed(),
 		searcherURLs:   search.SearcherURLs(),
+		resultChannel:  args.Stream,
+		recordHistory:  args.RecordHistory,
+	}
+	if sp := r.selectPath(); sp != nil && args.Stream != nil {
+		r.streamSelector = newResultSelector(sp)
+	}
+	return r, nil
+}
+
+// queryStringForSearchType returns the query that is processed for the input
+// of a search of the given type.
+func queryStringForSearchType(input string, searchType query.SearchType) string {
+	if searchType == query.SearchTypeLiteral {
+		return query.ConvertToLiteral(input)
+	}
+	return input
+}
+
+// processOptions returns the options for processing queries that depend on
+// the viewer's settings.
+func processOptions(ctx context.Context) (query.ProcessOptions, error) {
+	settings, err := decodedViewerFinalSettings(ctx)
+	if err != nil {
+		return query.ProcessOptions{}, err
+	}
+	return query.ProcessOptions{
+		Globbing: settings.SearchGlobbing != nil && *settings.SearchGlobbing,
 	}, nil
 }
 
-func (r *schemaResolver) Search(args *SearchArgs) (SearchImplementer, error) {
-	return NewSearchImplementer(args)
+// processQuery parses and validates the input of a search of the given type.
+func processQuery(input string, searchType query.SearchType, opts query.ProcessOptions) (query.QueryInfo, error) {
+	if conf.AndOrQueryEnabled() && searchType != query.SearchTypeLiteral && query.ContainsAndOrKeyword(input) {
+		// To process the input as an and/or query, the flag must be enabled, not be a
+		// literal search, and must contain either an 'and' or 'or' expression.
+		// Else, fallback to the older existing parser.
+		return query.ProcessAndOrWithOptions(input, opts)
+	}
+	return query.ProcessWithOptions(queryStringForSearchType(input, searchType), searchType, opts)
+}
+
+func (r *schemaResolver) Search(ctx context.Context, args *SearchArgs) (SearchImplementer, error) {
+	return NewSearchImplementer(ctx, args)
 }
 
 // queryForStableResults transforms a query that returns a stable result
@@ -241,6 +282,7 @@ type searchResolver struct {
 	pagination     *searchPaginationInfo // pagination information, or nil if the request is not paginated.
 	patternType    query.SearchType
 	versionContext *string
+	recordHistory  bool // whether to record the search in the user's search history
 
 	// Cached resolveRepositories results.
 	reposMu                   sync.Mutex
@@ -251,6 +293,33 @@ type searchResolver struct {
 
 	zoekt        *searchbackend.Zoekt
 	searcherURLs *endpoint.Map
+
+	// resultChannel, if non-nil, receives results as they are found. See
+	// SearchArgs.Stream.
+	resultChannel chan<- SearchEvent
+
+	// resultLimit, if non-zero, overrides the result limit of the query. It
+	// is used to compute statistics over (almost) every result.
+	resultLimit int32
+
+	// streamSelector, if non-nil, projects results sent on resultChannel
+	// according to the select: field.
+	streamSelector *resultSelector
+
+	// admission admits the searches of the query, and holds its search
+	// slot until they are done.
+	admissionMu sync.Mutex
+	admission   *searchAdmission
+}
+
+// searchAdmission returns the admission of the searches of the query.
+func (r *searchResolver) searchAdmission() *searchAdmission {
+	r.admissionMu.Lock()
+	defer r.admissionMu.Unlock()
+	if r.admission == nil {
+		r.admission = &searchAdmission{}
+	}
+	return r.admission
 }
 
 // rawQuery returns the original query string input.
@@ -274,6 +343,9 @@ func (r *searchResolver) maxResults() int32 {
 		// search_pagination.go for details on why this is necessary .
 		return math.MaxInt32
 	}
+	if r.resultLimit > 0 {
+		return r.resultLimit
+	}
 	count, _ := r.query.StringValues(query.FieldCount)
 	if len(count) > 0 {
 		n, _ := strconv.Atoi(count[0])
@@ -308,14 +380,37 @@ func decodedViewerFinalSettings(ctx context.Context) (*schema.Settings, error) {
 	return &settings, nil
 }
 
-var mockResolveRepoGroups func() (map[string][]*types.Repo, error)
+var mockResolveRepoGroups func() (map[string]*repoGroupDefinition, error)
 
-func resolveRepoGroups(ctx context.Context) (map[string][]*types.Repo, error) {
+// resolveRepoGroups returns the definitions of the repo groups with the given
+// names, or of all repo groups if no names are given. The repositories
+// matching the pattern and topics of stored groups are not listed, so that
+// the repository resolver can query them together with the other filters of
+// a search.
+func resolveRepoGroups(ctx context.Context, names ...string) (map[string]*repoGroupDefinition, error) {
 	if mockResolveRepoGroups != nil {
 		return mockResolveRepoGroups()
 	}
 
-	groups := map[string][]*types.Repo{}
+	wanted := func(name string) bool {
+		if len(names) == 0 {
+			return true
+		}
+		for _, n := range names {
+			if n == name {
+				return true
+			}
+		}
+		return false
+	}
+
+	groups := map[string]*repoGroupDefinition{}
+	group := func(name string) *repoGroupDefinition {
+		if groups[name] == nil {
+			groups[name] = &repoGroupDefinition{}
+		}
+		return groups[name]
+	}
 
 	// Repo groups can be defined in the search.repoGroups settings field.
 	settings, err := decodedViewerFinalSettings(ctx)
@@ -323,25 +418,89 @@ func resolveRepoGroups(ctx context.Context) (map[string][]*types.Repo, error) {
 		return nil, err
 	}
 	for name, repoPaths := range settings.SearchRepositoryGroups {
-		repos := make([]*types.Repo, len(repoPaths))
-		for i, repoPath := range repoPaths {
-			repos[i] = &types.Repo{Name: api.RepoName(repoPath)}
+		if !wanted(name) {
+			continue
+		}
+		g := group(name)
+		for _, repoPath := range repoPaths {
+			g.repos = append(g.repos, &types.Repo{Name: api.RepoName(repoPath)})
+		}
+	}
+
+	// Repo groups can also be stored in the database. If a group with the
+	// same name is defined in settings, the group contains the repositories
+	// of both.
+	dbGroups, err := db.RepoGroups.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, dbGroup := range dbGroups {
+		if !wanted(dbGroup.Name) {
+			continue
 		}
-		groups[name] = repos
+		group(dbGroup.Name).add(dbGroup)
 	}
 
 	return groups, nil
 }
 
+// repoGroupNames returns the sorted names of the repo groups defined in
+// settings or stored in the database, without resolving their repositories.
+func repoGroupNames(ctx context.Context) ([]string, error) {
+	settings, err := decodedViewerFinalSettings(ctx)
+	if err != nil {
+		return nil, err
+	}
+	dbGroups, err := db.RepoGroups.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	set := make(map[string]struct{}, len(settings.SearchRepositoryGroups)+len(dbGroups))
+	for name := range settings.SearchRepositoryGroups {
+		set[name] = struct{}{}
+	}
+	for _, group := range dbGroups {
+		set[group.Name] = struct{}{}
+	}
+	names := make([]string, 0, len(set))
+	for name := range set {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// resolveVersionContext returns the repositories and revisions of the version
+// context with the given name. Version contexts defined in the deprecated
+// experimentalFeatures.versionContexts site configuration are used first.
+// Other names are resolved like the value of a context: field, so that
+// version contexts are search contexts.
+//
 // NOTE: This function is not called if the version context is not used
-func resolveVersionContext(versionContext string) (*schema.VersionContext, error) {
+func resolveVersionContext(ctx context.Context, versionContext string) ([]*types.SearchContextRepositoryRevisions, error) {
 	for _, vc := range conf.Get().ExperimentalFeatures.VersionContexts {
 		if vc.Name == versionContext {
-			return vc, nil
+			var repositoryRevisions []*types.SearchContextRepositoryRevisions
+			for _, rev := range vc.Revisions {
+				if n := len(repositoryRevisions); n > 0 && string(repositoryRevisions[n-1].RepoName) == rev.Repo {
+					repositoryRevisions[n-1].Revisions = append(repositoryRevisions[n-1].Revisions, rev.Rev)
+					continue
+				}
+				repositoryRevisions = append(repositoryRevisions, &types.SearchContextRepositoryRevisions{
+					RepoName:  api.RepoName(rev.Repo),
+					Revisions: []string{rev.Rev},
+				})
+			}
+			return repositoryRevisions, nil
 		}
 	}
 
-	return nil, errors.New("version context not found")
+	_, repositoryRevisions, err := resolveSearchContext(ctx, versionContext)
+	if err != nil {
+		return nil, errors.New("version context not found")
+	}
+	return repositoryRevisions, nil
 }
 
 // Cf. golang/go/src/regexp/syntax/parse.go.
@@ -475,6 +634,8 @@ func (r *searchResolver) resolveRepositories(ctx context.Context, effectiveRepoF
 
 	commitAfter, _ := r.query.StringValue(query.FieldRepoHasCommitAfter)
 
+	searchContextSpec, _ := r.query.StringValue(query.FieldContext)
+
 	var versionContextName string
 	if r.versionContext != nil {
 		versionContextName = *r.versionContext
@@ -485,6 +646,7 @@ func (r *searchResolver) resolveRepositories(ctx context.Context, effectiveRepoF
 		repoFilters:        repoFilters,
 		minusRepoFilters:   minusRepoFilters,
 		repoGroupFilters:   repoGroupFilters,
+		searchContextSpec:  searchContextSpec,
 		versionContextName: versionContextName,
 		onlyForks:          fork == Only || fork == True,
 		noForks:            fork == No || fork == False,
@@ -600,10 +762,26 @@ func findPatternRevs(includePatterns []string) (includePatternRevs []patternRevs
 	return
 }
 
+// repoMetadataFilters converts repo: predicates to the corresponding filters
+// on repository metadata.
+func repoMetadataFilters(predicates []query.RepoPredicate, negated bool) []db.RepoMetadataFilter {
+	kinds := map[string]db.RepoMetadataKind{
+		query.PredicateHasTopic:   db.RepoMetadataTopic,
+		query.PredicateHasLabel:   db.RepoMetadataLabel,
+		query.PredicateHasProject: db.RepoMetadataProject,
+	}
+	filters := make([]db.RepoMetadataFilter, 0, len(predicates))
+	for _, p := range predicates {
+		filters = append(filters, db.RepoMetadataFilter{Kind: kinds[p.Name], Value: p.Argument, Negated: negated})
+	}
+	return filters
+}
+
 type resolveRepoOp struct {
 	repoFilters        []string
 	minusRepoFilters   []string
 	repoGroupFilters   []string
+	searchContextSpec  string
 	versionContextName string
 	noForks            bool
 	onlyForks          bool
@@ -622,35 +800,36 @@ func resolveRepositories(ctx context.Context, op resolveRepoOp) (repoRevisions,
 		tr.Finish()
 	}()
 
-	includePatterns := op.repoFilters
-	if includePatterns != nil {
-		// Copy to avoid race condition.
-		includePatterns = append([]string{}, includePatterns...)
-	}
-
-	excludePatterns := op.minusRepoFilters
+	// Predicates like repo:has.topic(payments) filter on the metadata of
+	// repositories instead of on their names.
+	includePredicates, includePatterns := query.PartitionRepoPredicates(op.repoFilters)
+	excludePredicates, excludePatterns := query.PartitionRepoPredicates(op.minusRepoFilters)
+	metadataFilters := append(repoMetadataFilters(includePredicates, false), repoMetadataFilters(excludePredicates, true)...)
 
 	maxRepoListSize := maxReposToSearch()
 
 	// If any repo groups are specified, take the intersection of the repo
 	// groups and the set of repos specified with repo:. (If none are specified
 	// with repo:, then include all from the group.)
+	var repoGroupQuery dbquery.Q
 	if groupNames := op.repoGroupFilters; len(groupNames) > 0 {
-		groups, err := resolveRepoGroups(ctx)
+		groups, err := resolveRepoGroups(ctx, groupNames...)
 		if err != nil {
 			return nil, nil, false, nil, err
 		}
-		var patterns []string
+		queries := make([]dbquery.Q, 0, len(groupNames))
+		explicitRepos := 0
 		for _, groupName := range groupNames {
-			for _, repo := range groups[groupName] {
-				patterns = append(patterns, "^"+regexp.QuoteMeta(string(repo.Name))+"$")
+			if group := groups[groupName]; group != nil {
+				queries = append(queries, group.query())
+				explicitRepos += len(group.repos)
 			}
 		}
-		includePatterns = append(includePatterns, unionRegExps(patterns))
+		repoGroupQuery = dbquery.Or(queries...)
 
 		// Ensure we don't omit any repos explicitly included via a repo group.
-		if len(patterns) > maxRepoListSize {
-			maxRepoListSize = len(patterns)
+		if explicitRepos > maxRepoListSize {
+			maxRepoListSize = explicitRepos
 		}
 	}
 
@@ -661,24 +840,43 @@ func resolveRepositories(ctx context.Context, op resolveRepoOp) (repoRevisions,
 		return nil, nil, false, nil, err
 	}
 
-	// If a version context is specified, gather the list of repository names
-	// to limit the results to these repositories.
-	var versionContextRepositories []string
-	var versionContext *schema.VersionContext
-	// If a ref is specified we skip using version contexts.
-	if len(includePatternRevs) == 0 && op.versionContextName != "" {
-		versionContext, err = resolveVersionContext(op.versionContextName)
+	// If a search context or version context is specified, gather the list
+	// of repository names to limit the results to these repositories.
+	var searchContextRepositories []string
+	var searchContextRevisions map[api.RepoName][]search.RevisionSpecifier
+	var contextRepositoryRevisions []*types.SearchContextRepositoryRevisions
+	if op.searchContextSpec != "" {
+		_, contextRepositoryRevisions, err = resolveSearchContext(ctx, op.searchContextSpec)
 		if err != nil {
 			return nil, nil, false, nil, err
 		}
-
-		for _, revision := range versionContext.Revisions {
-			versionContextRepositories = append(versionContextRepositories, revision.Repo)
+		if len(contextRepositoryRevisions) == 0 {
+			// The search context is empty, so there is nothing to search.
+			return nil, nil, false, nil, nil
+		}
+	} else if len(includePatternRevs) == 0 && op.versionContextName != "" {
+		// If a ref is specified we skip using version contexts.
+		contextRepositoryRevisions, err = resolveVersionContext(ctx, op.versionContextName)
+		if err != nil {
+			return nil, nil, false, nil, err
+		}
+	}
+	for _, repoRevs := range contextRepositoryRevisions {
+		searchContextRepositories = append(searchContextRepositories, string(repoRevs.RepoName))
+	}
+	// Revisions specified in the query take precedence over the revisions
+	// of the search context.
+	if len(contextRepositoryRevisions) > 0 && len(includePatternRevs) == 0 {
+		searchContextRevisions = make(map[api.RepoName][]search.RevisionSpecifier, len(contextRepositoryRevisions))
+		for _, repoRevs := range contextRepositoryRevisions {
+			for _, rev := range repoRevs.Revisions {
+				searchContextRevisions[repoRevs.RepoName] = append(searchContextRevisions[repoRevs.RepoName], search.RevisionSpecifier{RevSpec: rev})
+			}
 		}
 	}
 
 	var defaultRepos []*types.Repo
-	if envvar.SourcegraphDotComMode() && len(includePatterns) == 0 {
+	if envvar.SourcegraphDotComMode() && len(includePatterns) == 0 && len(metadataFilters) == 0 && repoGroupQuery == nil {
 		getIndexedRepos := func(ctx context.Context, revs []*search.RepositoryRevisions) (indexed, unindexed []*search.RepositoryRevisions, err error) {
 			return zoektIndexedRepos(ctx, search.Indexed(), revs, nil)
 		}
@@ -699,8 +897,10 @@ func resolveRepositories(ctx context.Context, op resolveRepoOp) (repoRevisions,
 		options := db.ReposListOptions{
 			OnlyRepoIDs:     true,
 			IncludePatterns: includePatterns,
-			Names:           versionContextRepositories,
+			Names:           searchContextRepositories,
 			ExcludePattern:  unionRegExps(excludePatterns),
+			MetadataFilters: metadataFilters,
+			PatternQuery:    repoGroupQuery,
 			// List N+1 repos so we can see if there are repos omitted due to our repo limit.
 			LimitOffset:  &db.LimitOffset{Limit: maxRepoListSize + 1},
 			NoForks:      op.noForks,
@@ -725,14 +925,10 @@ func resolveRepositories(ctx context.Context, op resolveRepoOp) (repoRevisions,
 	for _, repo := range repos {
 		var repoRev search.RepositoryRevisions
 		var revs []search.RevisionSpecifier
-		// versionContext will be nil if the query contains revision specifiers
-		if versionContext != nil {
-			for _, vcRepoRev := range versionContext.Revisions {
-				if vcRepoRev.Repo == string(repo.Name) {
-					repoRev.Repo = repo
-					revs = append(revs, search.RevisionSpecifier{RevSpec: vcRepoRev.Rev})
-				}
-			}
+		// searchContextRevisions will be nil if the query contains revision specifiers
+		if searchContextRevisions != nil {
+			repoRev.Repo = repo
+			revs = append(revs, searchContextRevisions[repo.Name]...)
 		} else {
 			var clashingRevs []search.RevisionSpecifier
 			revs, clashingRevs = getRevsForMatchedRepo(repo.Name, includePatternRevs)
@@ -0,0 +1,426 @@
+package graphqlbackend
+
+import (
+	"context"
+	"fmt"
+	"regexp/syntax"
+	"strconv"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/inconshreveable/log15"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/sourcegraph/sourcegraph/internal/actor"
+	"github.com/sourcegraph/sourcegraph/internal/conf"
+	"github.com/sourcegraph/sourcegraph/internal/randstring"
+	"github.com/sourcegraph/sourcegraph/internal/redispool"
+	"github.com/sourcegraph/sourcegraph/internal/search"
+	"github.com/sourcegraph/sourcegraph/internal/search/query"
+	"github.com/sourcegraph/sourcegraph/schema"
+)
+
+const (
+	// unindexedRepoCost is the cost of searching a repository revision
+	// without the index, relative to searching it with the index. Searcher
+	// fetches an archive of the revision from gitserver and scans all of it.
+	unindexedRepoCost = 10
+
+	// commitRepoCost is the cost of searching the history of a repository
+	// revision for commits or diffs, relative to searching it with the
+	// index. Gitserver runs git log over every commit reachable from it.
+	commitRepoCost = 100
+
+	// expensivePatternComplexity multiplies the cost of searches with
+	// patterns that can't use the index efficiently.
+	expensivePatternComplexity = 10
+
+	// minIndexedLiteralLen is the length of the shortest literal a pattern
+	// must contain to be matched efficiently with the trigram index.
+	minIndexedLiteralLen = 3
+)
+
+// searchCost is the estimated cost of a search.
+type searchCost struct {
+	indexedRepos   int // repositories searched with the index
+	unindexedRepos int // repository revisions searched without the index
+	commitRepos    int // repository revisions whose history is searched
+	complexity     int // multiplier for how expensive the pattern is to match
+}
+
+func (c searchCost) total() int {
+	return (c.indexedRepos + unindexedRepoCost*c.unindexedRepos + commitRepoCost*c.commitRepos) * c.complexity
+}
+
+// estimateSearchCost estimates the cost of the search of args for results
+// of resultTypes.
+func estimateSearchCost(ctx context.Context, args *search.TextParameters, resultTypes []string) searchCost {
+	cost := searchCost{complexity: patternComplexity(args.PatternInfo)}
+
+	var searchesContent, searchesHistory bool
+	for _, resultType := range resultTypes {
+		switch resultType {
+		case "file", "path", "symbol":
+			searchesContent = true
+		case "commit", "diff":
+			searchesHistory = true
+		}
+	}
+	if searchesHistory {
+		cost.commitRepos = countRevisions(args.Repos)
+	}
+	if !searchesContent {
+		return cost
+	}
+
+	unindexed := args.Repos
+	if args.Zoekt != nil && args.Zoekt.Enabled() {
+		var indexed []*search.RepositoryRevisions
+		var err error
+		indexed, unindexed, err = zoektIndexedRepos(ctx, args.Zoekt, args.Repos, nil)
+		if err != nil {
+			// Assume all repositories are searched with the index, as
+			// the search does if the index is available.
+			indexed, unindexed = args.Repos, nil
+		}
+		if index, _ := args.Query.StringValues(query.FieldIndex); len(index) > 0 {
+			switch parseYesNoOnly(index[len(index)-1]) {
+			case Only:
+				unindexed = nil
+			case No, False:
+				unindexed = args.Repos
+				indexed = nil
+			}
+		}
+		cost.indexedRepos = len(indexed)
+	}
+	cost.unindexedRepos = countRevisions(unindexed)
+	return cost
+}
+
+// countRevisions returns the number of repository revisions in repos.
+func countRevisions(repos []*search.RepositoryRevisions) int {
+	n := 0
+	for _, repo := range repos {
+		if len(repo.Revs) > 1 {
+			n += len(repo.Revs)
+		} else {
+			n++
+		}
+	}
+	return n
+}
+
+// patternComplexity returns expensivePatternComplexity if p can't use the
+// index efficiently, and 1 otherwise. A pattern can use the index if every
+// match contains a literal of at least minIndexedLiteralLen characters.
+func patternComplexity(p *search.TextPatternInfo) int {
+	switch {
+	case p.IsStructuralPat || p.IsNegated:
+		return expensivePatternComplexity
+	case p.Pattern == "":
+		return 1
+	case !p.IsRegExp:
+		if utf8.RuneCountInString(p.Pattern) < minIndexedLiteralLen {
+			return expensivePatternComplexity
+		}
+		return 1
+	}
+	re, err := syntax.Parse(p.Pattern, syntax.Perl)
+	if err != nil {
+		return 1
+	}
+	if requiredLiteralLen(re.Simplify()) < minIndexedLiteralLen {
+		return expensivePatternComplexity
+	}
+	return 1
+}
+
+// requiredLiteralLen returns the length of the longest literal that every
+// match of re contains.
+func requiredLiteralLen(re *syntax.Regexp) int {
+	switch re.Op {
+	case syntax.OpLiteral:
+		return len(re.Rune)
+	case syntax.OpCapture, syntax.OpPlus:
+		return requiredLiteralLen(re.Sub[0])
+	case syntax.OpRepeat:
+		if re.Min == 0 {
+			return 0
+		}
+		return requiredLiteralLen(re.Sub[0])
+	case syntax.OpConcat:
+		longest, run := 0, 0
+		for _, sub := range re.Sub {
+			if sub.Op == syntax.OpLiteral {
+				run += len(sub.Rune)
+			} else {
+				run = 0
+			}
+			if n := requiredLiteralLen(sub); n > longest {
+				longest = n
+			}
+			if run > longest {
+				longest = run
+			}
+		}
+		return longest
+	case syntax.OpAlternate:
+		shortest := -1
+		for _, sub := range re.Sub {
+			if n := requiredLiteralLen(sub); shortest == -1 || n < shortest {
+				shortest = n
+			}
+		}
+		if shortest < 0 {
+			return 0
+		}
+		return shortest
+	}
+	return 0
+}
+
+// admitSearch decides whether the search of args for results of
+// resultTypes may run, per the search.admission site configuration. If it
+// may, admitSearch returns a function to call once the search is done.
+// Otherwise it returns an alert explaining why the search was rejected.
+//
+// Expensive searches of a user beyond the configured concurrency wait for
+// another of their expensive searches to finish. Anonymous users share a
+// single limit. Errors talking to Redis admit the search.
+func admitSearch(ctx context.Context, args *search.TextParameters, resultTypes []string) (release func(), alert *searchAlert) {
+	c := conf.Get().SearchAdmission
+	expensive, alert := checkSearchCost(ctx, c, args, resultTypes)
+	if !expensive {
+		return func() {}, alert
+	}
+	return acquireExpensiveSearchSlot(ctx, c)
+}
+
+// checkSearchCost reports whether the search of args is expensive and
+// needs a search slot. If the search costs more than the configured
+// maximum, it returns an alert explaining why it was rejected.
+func checkSearchCost(ctx context.Context, c *schema.SearchAdmission, args *search.TextParameters, resultTypes []string) (expensive bool, alert *searchAlert) {
+	if c == nil || (c.MaxCost == 0 && c.MaxConcurrentExpensivePerUser == 0) {
+		return false, nil
+	}
+
+	cost := estimateSearchCost(ctx, args, resultTypes)
+	searchCostHistogram.Observe(float64(cost.total()))
+	if c.MaxCost > 0 && cost.total() > c.MaxCost {
+		searchRejectedCounter.WithLabelValues("cost").Inc()
+		return false, alertForExpensiveSearch(cost)
+	}
+	return c.MaxConcurrentExpensivePerUser > 0 && cost.total() > expensiveSearchCost(c), nil
+}
+
+// acquireExpensiveSearchSlot acquires one of the search slots of the
+// current user, waiting up to the configured queue timeout for one.
+func acquireExpensiveSearchSlot(ctx context.Context, c *schema.SearchAdmission) (release func(), alert *searchAlert) {
+	release = func() {}
+	key := searchSlotsKey(actor.FromContext(ctx))
+
+	token := randstring.NewLen(20)
+	queueTimeout := searchQueueTimeout(c)
+	deadline := time.Now().Add(queueTimeout)
+	queued := false
+	for {
+		ok, err := acquireSearchSlot(key, token, c.MaxConcurrentExpensivePerUser)
+		if err != nil {
+			log15.Warn("admitSearch: failed to acquire search slot, admitting search", "error", err)
+			return release, nil
+		}
+		if ok {
+			return func() { releaseSearchSlot(key, token) }, nil
+		}
+		if !queued {
+			queued = true
+			searchQueuedCounter.Inc()
+		}
+		if time.Now().Add(searchSlotPollInterval).After(deadline) {
+			searchRejectedCounter.WithLabelValues("concurrency").Inc()
+			return nil, &searchAlert{
+				prometheusType: "too_many_concurrent_expensive_searches",
+				title:          "Too many expensive searches at the same time",
+				description:    fmt.Sprintf("You are running %d expensive searches already, and none of them finished within %s. Wait for them to finish, or narrow this search with repo: filters or a more specific pattern.", c.MaxConcurrentExpensivePerUser, queueTimeout),
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return release, nil // the search reports the context error
+		case <-time.After(searchSlotPollInterval):
+		}
+	}
+}
+
+// searchAdmission admits the searches a user query runs, such as one for
+// each operand of an and/or expression. Each search is costed on its own,
+// but the query takes at most one search slot, held until the last of its
+// searches is done.
+type searchAdmission struct {
+	acquireMu sync.Mutex // serializes acquiring the search slot
+
+	mu      sync.Mutex
+	holders int
+	release func()
+}
+
+// admit admits the search of args for results of resultTypes, and returns
+// the alert explaining why it was rejected, if it was. The caller must hold
+// the admission.
+func (a *searchAdmission) admit(ctx context.Context, args *search.TextParameters, resultTypes []string) *searchAlert {
+	c := conf.Get().SearchAdmission
+	expensive, alert := checkSearchCost(ctx, c, args, resultTypes)
+	if !expensive {
+		return alert
+	}
+
+	a.acquireMu.Lock()
+	defer a.acquireMu.Unlock()
+	a.mu.Lock()
+	held := a.release != nil
+	a.mu.Unlock()
+	if held {
+		return nil
+	}
+
+	release, alert := acquireExpensiveSearchSlot(ctx, c)
+	if alert != nil {
+		return alert
+	}
+	a.mu.Lock()
+	a.release = release
+	a.mu.Unlock()
+	return nil
+}
+
+// hold keeps the search slot of the query until the returned function is
+// called.
+func (a *searchAdmission) hold() func() {
+	a.mu.Lock()
+	a.holders++
+	a.mu.Unlock()
+	return func() {
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		a.holders--
+		if a.holders == 0 && a.release != nil {
+			a.release()
+			a.release = nil
+		}
+	}
+}
+
+func alertForExpensiveSearch(cost searchCost) *searchAlert {
+	description := fmt.Sprintf("This search would search %d repositories with the index and %d repository revisions without it", cost.indexedRepos, cost.unindexedRepos)
+	if cost.commitRepos > 0 {
+		description += fmt.Sprintf(", and the history of %d repository revisions", cost.commitRepos)
+	}
+	if cost.complexity > 1 {
+		description += ", with a pattern that can't use the index efficiently"
+	}
+	description += ". Narrow it with repo: or file: filters, or use a more specific pattern with at least 3 literal characters."
+	return &searchAlert{
+		prometheusType: "search_too_expensive",
+		title:          "Search is too expensive",
+		description:    description,
+	}
+}
+
+func expensiveSearchCost(c *schema.SearchAdmission) int {
+	if c.ExpensiveCost <= 0 {
+		return 1000
+	}
+	return c.ExpensiveCost
+}
+
+func searchQueueTimeout(c *schema.SearchAdmission) time.Duration {
+	if d, err := time.ParseDuration(c.QueueTimeout); err == nil && d >= 0 {
+		return d
+	}
+	return 10 * time.Second
+}
+
+const (
+	// searchSlotPollInterval is how often a queued search checks whether
+	// it may run.
+	searchSlotPollInterval = 200 * time.Millisecond
+
+	// searchSlotTTL is how long a search slot is held at most, in case the
+	// frontend holding it dies before releasing it. It is longer than
+	// maxTimeout, after which searches time out.
+	searchSlotTTL = 2 * time.Minute
+)
+
+// searchSlotsPool is the Redis store, because search slots evicted from a
+// cache would let searches past the limit.
+var searchSlotsPool = redispool.Store
+
+// searchSlotsScript adds the token ARGV[4] to the sorted set KEYS[1] of
+// search slots, scored by their expiry time, unless it holds ARGV[3] slots
+// that expire after ARGV[1] already.
+var searchSlotsScript = redis.NewScript(1, `
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', ARGV[1])
+if redis.call('ZCARD', KEYS[1]) >= tonumber(ARGV[3]) then
+	return 0
+end
+redis.call('ZADD', KEYS[1], ARGV[2], ARGV[4])
+redis.call('EXPIRE', KEYS[1], ARGV[5])
+return 1
+`)
+
+// mockAcquireSearchSlot, if set, is called instead of acquiring a search
+// slot in Redis.
+var mockAcquireSearchSlot func(key, token string, limit int) (bool, error)
+
+// acquireSearchSlot acquires one of the limit expensive search slots in key,
+// and reports whether one was free.
+func acquireSearchSlot(key, token string, limit int) (bool, error) {
+	if mockAcquireSearchSlot != nil {
+		return mockAcquireSearchSlot(key, token, limit)
+	}
+	c := searchSlotsPool.Get()
+	defer c.Close()
+	now := time.Now()
+	return redis.Bool(searchSlotsScript.Do(c, key, now.Unix(), now.Add(searchSlotTTL).Unix(), limit, token, int(searchSlotTTL/time.Second)))
+}
+
+// releaseSearchSlot releases the search slot acquired with token.
+func releaseSearchSlot(key, token string) {
+	if mockAcquireSearchSlot != nil {
+		return
+	}
+	c := searchSlotsPool.Get()
+	defer c.Close()
+	if _, err := c.Do("ZREM", key, token); err != nil {
+		log15.Warn("releaseSearchSlot: failed to release search slot", "error", err)
+	}
+}
+
+// searchSlotsKey returns the key of the search slots of a. Anonymous users
+// share their search slots.
+func searchSlotsKey(a *actor.Actor) string {
+	if !a.IsAuthenticated() {
+		return "search_slots:anonymous"
+	}
+	return "search_slots:" + strconv.Itoa(int(a.UID))
+}
+
+var (
+	searchCostHistogram = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "src_graphql_search_cost",
+		Help:    "The estimated cost of searches checked by admission control.",
+		Buckets: prometheus.ExponentialBuckets(10, 4, 10),
+	})
+	searchRejectedCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "src_graphql_search_rejected_total",
+		Help: "Number of searches rejected by admission control, by reason (cost, concurrency).",
+	}, []string{"reason"})
+	searchQueuedCounter = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "src_graphql_search_queued_total",
+		Help: "Number of expensive searches that waited for another expensive search of the same user to finish.",
+	})
+)
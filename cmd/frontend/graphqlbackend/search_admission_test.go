@@ -0,0 +1,301 @@
+package graphqlbackend
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/zoekt"
+	"github.com/sourcegraph/sourcegraph/internal/actor"
+	"github.com/sourcegraph/sourcegraph/internal/conf"
+	"github.com/sourcegraph/sourcegraph/internal/search"
+	searchbackend "github.com/sourcegraph/sourcegraph/internal/search/backend"
+	"github.com/sourcegraph/sourcegraph/internal/search/query"
+	"github.com/sourcegraph/sourcegraph/schema"
+)
+
+func TestPatternComplexity(t *testing.T) {
+	cases := []struct {
+		pattern *search.TextPatternInfo
+		want    int
+	}{
+		{&search.TextPatternInfo{Pattern: ""}, 1},
+		{&search.TextPatternInfo{Pattern: "foo"}, 1},
+		{&search.TextPatternInfo{Pattern: "fo"}, expensivePatternComplexity},
+		{&search.TextPatternInfo{Pattern: "foo", IsNegated: true}, expensivePatternComplexity},
+		{&search.TextPatternInfo{Pattern: ":[x]", IsStructuralPat: true}, expensivePatternComplexity},
+		{&search.TextPatternInfo{Pattern: ".*", IsRegExp: true}, expensivePatternComplexity},
+		{&search.TextPatternInfo{Pattern: `\w+`, IsRegExp: true}, expensivePatternComplexity},
+		{&search.TextPatternInfo{Pattern: "a.*b", IsRegExp: true}, expensivePatternComplexity},
+		{&search.TextPatternInfo{Pattern: "foo.*bar", IsRegExp: true}, 1},
+		{&search.TextPatternInfo{Pattern: "(?i)FooBar", IsRegExp: true}, 1},
+		{&search.TextPatternInfo{Pattern: "foo(bar)?", IsRegExp: true}, 1},
+		{&search.TextPatternInfo{Pattern: "(foo|bar)", IsRegExp: true}, 1},
+		{&search.TextPatternInfo{Pattern: "(foo|b)", IsRegExp: true}, expensivePatternComplexity},
+		{&search.TextPatternInfo{Pattern: "(abc)+d", IsRegExp: true}, 1},
+		{&search.TextPatternInfo{Pattern: "x?yz", IsRegExp: true}, expensivePatternComplexity},
+	}
+	for _, c := range cases {
+		if got := patternComplexity(c.pattern); got != c.want {
+			t.Errorf("%s: got %d, want %d", c.pattern, got, c.want)
+		}
+	}
+}
+
+var fileResultTypes = []string{"file", "path", "repo"}
+
+func TestEstimateSearchCost(t *testing.T) {
+	z := &searchbackend.Zoekt{
+		Client: &fakeSearcher{repos: &zoekt.RepoList{Repos: []*zoekt.RepoListEntry{{
+			Repository: zoekt.Repository{Name: "indexed", Branches: []zoekt.RepositoryBranch{{Name: "HEAD", Version: "deadbeef"}}},
+		}}}},
+		DisableCache: true,
+	}
+
+	cases := []struct {
+		query       string
+		pattern     *search.TextPatternInfo
+		zoekt       *searchbackend.Zoekt
+		resultTypes []string
+		want        searchCost
+	}{{
+		query:   "foo",
+		pattern: &search.TextPatternInfo{Pattern: "foo"},
+		zoekt:   z,
+		want:    searchCost{indexedRepos: 1, unindexedRepos: 2, complexity: 1},
+	}, {
+		query:       "foo type:commit",
+		pattern:     &search.TextPatternInfo{Pattern: "foo"},
+		zoekt:       z,
+		resultTypes: []string{"commit"},
+		want:        searchCost{commitRepos: 3, complexity: 1},
+	}, {
+		query:       "foo type:diff type:file",
+		pattern:     &search.TextPatternInfo{Pattern: "foo"},
+		zoekt:       z,
+		resultTypes: []string{"diff", "file"},
+		want:        searchCost{indexedRepos: 1, unindexedRepos: 2, commitRepos: 3, complexity: 1},
+	}, {
+		query:       "foo type:repo",
+		pattern:     &search.TextPatternInfo{Pattern: "foo"},
+		zoekt:       z,
+		resultTypes: []string{"repo"},
+		want:        searchCost{complexity: 1},
+	}, {
+		query:   "foo index:no",
+		pattern: &search.TextPatternInfo{Pattern: "foo"},
+		zoekt:   z,
+		want:    searchCost{unindexedRepos: 3, complexity: 1},
+	}, {
+		query:   "foo index:only",
+		pattern: &search.TextPatternInfo{Pattern: "foo"},
+		zoekt:   z,
+		want:    searchCost{indexedRepos: 1, complexity: 1},
+	}, {
+		query:   ".*",
+		pattern: &search.TextPatternInfo{Pattern: ".*", IsRegExp: true},
+		want:    searchCost{unindexedRepos: 3, complexity: expensivePatternComplexity},
+	}}
+	for _, c := range cases {
+		t.Run(c.query, func(t *testing.T) {
+			q, err := query.ParseAndCheck(c.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			args := &search.TextParameters{
+				PatternInfo: c.pattern,
+				Repos:       makeRepositoryRevisions("indexed", "unindexed", "unindexed2"),
+				Query:       q,
+				Zoekt:       c.zoekt,
+			}
+			resultTypes := c.resultTypes
+			if resultTypes == nil {
+				resultTypes = fileResultTypes
+			}
+			if got := estimateSearchCost(context.Background(), args, resultTypes); got != c.want {
+				t.Errorf("got %+v, want %+v", got, c.want)
+			}
+		})
+	}
+
+	if got, want := (searchCost{indexedRepos: 5, unindexedRepos: 2, commitRepos: 1, complexity: 10}).total(), 1250; got != want {
+		t.Errorf("got total %d, want %d", got, want)
+	}
+}
+
+func TestAdmitSearch(t *testing.T) {
+	q, err := query.ParseAndCheck(".*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	args := &search.TextParameters{
+		PatternInfo: &search.TextPatternInfo{Pattern: ".*", IsRegExp: true},
+		Repos:       makeRepositoryRevisions("a", "b"),
+		Query:       q,
+	}
+	// The cost of args is 2 unindexed repos * 10 * 10 = 200.
+	userCtx := actor.WithActor(context.Background(), actor.FromUser(1))
+
+	mockConf := func(c *schema.SearchAdmission) {
+		conf.Mock(&conf.Unified{SiteConfiguration: schema.SiteConfiguration{SearchAdmission: c}})
+	}
+	defer conf.Mock(nil)
+	defer func() { mockAcquireSearchSlot = nil }()
+
+	t.Run("disabled", func(t *testing.T) {
+		mockConf(nil)
+		if _, alert := admitSearch(userCtx, args, fileResultTypes); alert != nil {
+			t.Errorf("got alert %q, want admitted", alert.title)
+		}
+	})
+
+	t.Run("max cost", func(t *testing.T) {
+		mockConf(&schema.SearchAdmission{MaxCost: 199})
+		if _, alert := admitSearch(userCtx, args, fileResultTypes); alert == nil || alert.prometheusType != "search_too_expensive" {
+			t.Errorf("got alert %v, want search_too_expensive", alert)
+		}
+		mockConf(&schema.SearchAdmission{MaxCost: 200})
+		if _, alert := admitSearch(userCtx, args, fileResultTypes); alert != nil {
+			t.Errorf("got alert %q, want admitted", alert.title)
+		}
+	})
+
+	t.Run("concurrency", func(t *testing.T) {
+		mockConf(&schema.SearchAdmission{ExpensiveCost: 100, MaxConcurrentExpensivePerUser: 1, QueueTimeout: "0s"})
+
+		acquired := map[string]int{}
+		mockAcquireSearchSlot = func(key, token string, limit int) (bool, error) {
+			if limit != 1 {
+				t.Errorf("got limit %d, want 1", limit)
+			}
+			acquired[key]++
+			return acquired[key] == 1, nil
+		}
+		release, alert := admitSearch(userCtx, args, fileResultTypes)
+		if alert != nil {
+			t.Fatalf("got alert %q, want admitted", alert.title)
+		}
+		defer release()
+
+		if _, alert := admitSearch(userCtx, args, fileResultTypes); alert == nil || alert.prometheusType != "too_many_concurrent_expensive_searches" {
+			t.Errorf("got alert %v, want too_many_concurrent_expensive_searches", alert)
+		}
+
+		// Anonymous users share a limit.
+		if _, alert := admitSearch(context.Background(), args, fileResultTypes); alert != nil {
+			t.Errorf("got alert %q for anonymous user, want admitted", alert.title)
+		}
+		if _, alert := admitSearch(context.Background(), args, fileResultTypes); alert == nil || alert.prometheusType != "too_many_concurrent_expensive_searches" {
+			t.Errorf("got alert %v for anonymous user, want too_many_concurrent_expensive_searches", alert)
+		}
+		if got, want := len(acquired), 2; got != want {
+			t.Errorf("got %d slot keys, want %d", got, want)
+		}
+
+		// Cheap searches are not limited.
+		mockConf(&schema.SearchAdmission{ExpensiveCost: 200, MaxConcurrentExpensivePerUser: 1, QueueTimeout: "0s"})
+		if _, alert := admitSearch(userCtx, args, fileResultTypes); alert != nil {
+			t.Errorf("got alert %q for cheap search, want admitted", alert.title)
+		}
+	})
+
+	t.Run("redis unavailable", func(t *testing.T) {
+		mockConf(&schema.SearchAdmission{ExpensiveCost: 100, MaxConcurrentExpensivePerUser: 1})
+		mockAcquireSearchSlot = func(key, token string, limit int) (bool, error) {
+			return false, errors.New("connection refused")
+		}
+		if _, alert := admitSearch(userCtx, args, fileResultTypes); alert != nil {
+			t.Errorf("got alert %q, want admitted", alert.title)
+		}
+	})
+}
+
+func TestSearchAdmission(t *testing.T) {
+	q, err := query.ParseAndCheck(".*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	args := &search.TextParameters{
+		PatternInfo: &search.TextPatternInfo{Pattern: ".*", IsRegExp: true},
+		Repos:       makeRepositoryRevisions("a", "b"),
+		Query:       q,
+	}
+	userCtx := actor.WithActor(context.Background(), actor.FromUser(1))
+
+	conf.Mock(&conf.Unified{SiteConfiguration: schema.SiteConfiguration{SearchAdmission: &schema.SearchAdmission{
+		ExpensiveCost:                 100,
+		MaxConcurrentExpensivePerUser: 1,
+		QueueTimeout:                  "0s",
+	}}})
+	defer conf.Mock(nil)
+	var acquired int
+	mockAcquireSearchSlot = func(key, token string, limit int) (bool, error) {
+		acquired++
+		return true, nil
+	}
+	defer func() { mockAcquireSearchSlot = nil }()
+
+	// The query holds its search slot across the searches of its operands.
+	var a searchAdmission
+	release := a.hold()
+	for i := 0; i < 3; i++ {
+		releaseOperand := a.hold()
+		if alert := a.admit(userCtx, args, fileResultTypes); alert != nil {
+			t.Fatalf("got alert %q, want admitted", alert.title)
+		}
+		releaseOperand()
+		if a.release == nil {
+			t.Fatal("search slot released before the query is done")
+		}
+	}
+	release()
+	if a.release != nil {
+		t.Error("search slot not released once the query is done")
+	}
+	if acquired != 1 {
+		t.Errorf("acquired %d search slots, want 1", acquired)
+	}
+}
+
+func TestSearchAdmission_CostsEachSearch(t *testing.T) {
+	cheap, err := query.ParseAndCheck("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expensive, err := query.ParseAndCheck(".*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	userCtx := actor.WithActor(context.Background(), actor.FromUser(1))
+
+	conf.Mock(&conf.Unified{SiteConfiguration: schema.SiteConfiguration{SearchAdmission: &schema.SearchAdmission{MaxCost: 100}}})
+	defer conf.Mock(nil)
+
+	// The operands of foo or .* are admitted separately, and .* costs
+	// 2 unindexed repos * 10 * 10 = 200.
+	var a searchAdmission
+	defer a.hold()()
+	if alert := a.admit(userCtx, &search.TextParameters{
+		PatternInfo: &search.TextPatternInfo{Pattern: "foo"},
+		Repos:       makeRepositoryRevisions("a", "b"),
+		Query:       cheap,
+	}, fileResultTypes); alert != nil {
+		t.Fatalf("got alert %q for foo, want admitted", alert.title)
+	}
+	if alert := a.admit(userCtx, &search.TextParameters{
+		PatternInfo: &search.TextPatternInfo{Pattern: ".*", IsRegExp: true},
+		Repos:       makeRepositoryRevisions("a", "b"),
+		Query:       expensive,
+	}, fileResultTypes); alert == nil || alert.prometheusType != "search_too_expensive" {
+		t.Errorf("got alert %v for .*, want search_too_expensive", alert)
+	}
+
+	// Commit searches are costed too: 2 repos * 100 = 200.
+	if alert := a.admit(userCtx, &search.TextParameters{
+		PatternInfo: &search.TextPatternInfo{Pattern: "foo"},
+		Repos:       makeRepositoryRevisions("a", "b"),
+		Query:       cheap,
+	}, []string{"commit"}); alert == nil || alert.prometheusType != "search_too_expensive" {
+		t.Errorf("got alert %v for commit search, want search_too_expensive", alert)
+	}
+}
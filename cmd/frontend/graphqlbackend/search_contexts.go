@@ -0,0 +1,391 @@
+package graphqlbackend
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	graphql "github.com/graph-gophers/graphql-go"
+	"github.com/graph-gophers/graphql-go/relay"
+	"github.com/pkg/errors"
+
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/backend"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/db"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/types"
+	"github.com/sourcegraph/sourcegraph/internal/actor"
+	"github.com/sourcegraph/sourcegraph/internal/api"
+	"github.com/sourcegraph/sourcegraph/internal/lazyregexp"
+)
+
+var validSearchContextName = lazyregexp.New(`^[a-zA-Z0-9_.-]+$`)
+
+type searchContextResolver struct {
+	sc *types.SearchContext
+}
+
+func marshalSearchContextID(id int64) graphql.ID {
+	return relay.MarshalID("SearchContext", id)
+}
+
+func unmarshalSearchContextID(id graphql.ID) (searchContextID int64, err error) {
+	err = relay.UnmarshalSpec(id, &searchContextID)
+	return
+}
+
+func searchContextByID(ctx context.Context, id graphql.ID) (*searchContextResolver, error) {
+	searchContextID, err := unmarshalSearchContextID(id)
+	if err != nil {
+		return nil, err
+	}
+	sc, err := db.SearchContexts.GetByID(ctx, searchContextID)
+	if err != nil {
+		return nil, err
+	}
+	// 🚨 SECURITY: Private search contexts are reported as not found to users
+	// who cannot view them, to avoid leaking their existence.
+	if err := checkSearchContextReadAccess(ctx, sc); err != nil {
+		return nil, db.ErrSearchContextNotFound
+	}
+	return &searchContextResolver{sc}, nil
+}
+
+// parseSearchContextSpec splits the value of a context: field into the
+// namespace name and the search context name. "@alice/ctx" refers to the
+// search context "ctx" owned by the user or organization "alice", and "ctx"
+// refers to the instance-wide search context "ctx".
+func parseSearchContextSpec(spec string) (namespaceName, name string, err error) {
+	if strings.HasPrefix(spec, "@") {
+		i := strings.Index(spec, "/")
+		if i < 0 {
+			return "", "", fmt.Errorf("invalid search context %q: expected @namespace/name", spec)
+		}
+		namespaceName, name = spec[1:i], spec[i+1:]
+		if namespaceName == "" {
+			return "", "", fmt.Errorf("invalid search context %q: empty namespace", spec)
+		}
+	} else {
+		name = spec
+	}
+	if !validSearchContextName.MatchString(name) {
+		return "", "", fmt.Errorf("invalid search context %q: the name may only contain letters, digits, \".\", \"_\" and \"-\"", spec)
+	}
+	return namespaceName, name, nil
+}
+
+// searchContextSpec returns the value of the context: field which refers to
+// the search context.
+func searchContextSpec(sc *types.SearchContext) string {
+	if sc.NamespaceName == "" {
+		return sc.Name
+	}
+	return "@" + sc.NamespaceName + "/" + sc.Name
+}
+
+// resolveSearchContext returns the search context referred to by the value of
+// a context: field, and its repositories and revisions. The repositories are
+// not filtered by the current user's permissions; that is done when the
+// repositories are listed for a search.
+func resolveSearchContext(ctx context.Context, spec string) (*types.SearchContext, []*types.SearchContextRepositoryRevisions, error) {
+	namespaceName, name, err := parseSearchContextSpec(spec)
+	if err != nil {
+		return nil, nil, err
+	}
+	sc, err := db.SearchContexts.GetBySpec(ctx, namespaceName, name)
+	if err == db.ErrSearchContextNotFound {
+		return nil, nil, fmt.Errorf("search context %q not found", spec)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	// 🚨 SECURITY: Private search contexts are reported as not found to users
+	// who cannot view them, to avoid leaking their existence.
+	if err := checkSearchContextReadAccess(ctx, sc); err != nil {
+		return nil, nil, fmt.Errorf("search context %q not found", spec)
+	}
+	repositoryRevisions, err := db.SearchContexts.GetRepositoryRevisions(ctx, sc.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return sc, repositoryRevisions, nil
+}
+
+// checkSearchContextReadAccess returns an error if the current user may not
+// view the search context.
+func checkSearchContextReadAccess(ctx context.Context, sc *types.SearchContext) error {
+	if sc.Public {
+		return nil
+	}
+	return checkSearchContextWriteAccess(ctx, sc.NamespaceUserID, sc.NamespaceOrgID)
+}
+
+// checkSearchContextWriteAccess returns an error if the current user may not
+// create, update or delete search contexts in the given namespace. Users can
+// manage their own search contexts, organization members the organization's
+// search contexts, and site admins all search contexts.
+func checkSearchContextWriteAccess(ctx context.Context, namespaceUserID, namespaceOrgID *int32) error {
+	if namespaceUserID != nil {
+		return backend.CheckSiteAdminOrSameUser(ctx, *namespaceUserID)
+	}
+	if namespaceOrgID != nil {
+		return backend.CheckOrgAccess(ctx, *namespaceOrgID)
+	}
+	return backend.CheckCurrentUserIsSiteAdmin(ctx)
+}
+
+func (r *searchContextResolver) ID() graphql.ID { return marshalSearchContextID(r.sc.ID) }
+
+func (r *searchContextResolver) Name() string { return r.sc.Name }
+
+func (r *searchContextResolver) Spec() string { return searchContextSpec(r.sc) }
+
+func (r *searchContextResolver) Description() string { return r.sc.Description }
+
+func (r *searchContextResolver) Public() bool { return r.sc.Public }
+
+func (r *searchContextResolver) Namespace(ctx context.Context) (*NamespaceResolver, error) {
+	var id graphql.ID
+	switch {
+	case r.sc.NamespaceUserID != nil:
+		id = MarshalUserID(*r.sc.NamespaceUserID)
+	case r.sc.NamespaceOrgID != nil:
+		id = marshalOrgID(*r.sc.NamespaceOrgID)
+	default:
+		return nil, nil
+	}
+	n, err := NamespaceByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return &NamespaceResolver{n}, nil
+}
+
+func (r *searchContextResolver) Repositories(ctx context.Context) ([]*searchContextRepositoryRevisionsResolver, error) {
+	repositoryRevisions, err := db.SearchContexts.GetRepositoryRevisions(ctx, r.sc.ID)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]api.RepoID, 0, len(repositoryRevisions))
+	for _, repoRevs := range repositoryRevisions {
+		ids = append(ids, repoRevs.RepoID)
+	}
+	// 🚨 SECURITY: db.Repos.GetByIDs omits the repositories the current user
+	// cannot access.
+	repos, err := db.Repos.GetByIDs(ctx, ids...)
+	if err != nil {
+		return nil, err
+	}
+	reposByID := make(map[api.RepoID]*types.Repo, len(repos))
+	for _, repo := range repos {
+		reposByID[repo.ID] = repo
+	}
+
+	resolvers := make([]*searchContextRepositoryRevisionsResolver, 0, len(repos))
+	for _, repoRevs := range repositoryRevisions {
+		if repo, ok := reposByID[repoRevs.RepoID]; ok {
+			resolvers = append(resolvers, &searchContextRepositoryRevisionsResolver{
+				repository: NewRepositoryResolver(repo),
+				revisions:  repoRevs.Revisions,
+			})
+		}
+	}
+	return resolvers, nil
+}
+
+func (r *searchContextResolver) ViewerCanManage(ctx context.Context) bool {
+	return checkSearchContextWriteAccess(ctx, r.sc.NamespaceUserID, r.sc.NamespaceOrgID) == nil
+}
+
+func (r *searchContextResolver) CreatedAt() DateTime { return DateTime{Time: r.sc.CreatedAt} }
+
+func (r *searchContextResolver) UpdatedAt() DateTime { return DateTime{Time: r.sc.UpdatedAt} }
+
+type searchContextRepositoryRevisionsResolver struct {
+	repository *RepositoryResolver
+	revisions  []string
+}
+
+func (r *searchContextRepositoryRevisionsResolver) Repository() *RepositoryResolver {
+	return r.repository
+}
+
+func (r *searchContextRepositoryRevisionsResolver) Revisions() []string { return r.revisions }
+
+type searchContextConnectionResolver struct {
+	opt db.SearchContextsListOptions
+}
+
+func (r *searchContextConnectionResolver) Nodes(ctx context.Context) ([]*searchContextResolver, error) {
+	searchContexts, err := db.SearchContexts.List(ctx, r.opt)
+	if err != nil {
+		return nil, err
+	}
+	resolvers := make([]*searchContextResolver, 0, len(searchContexts))
+	for _, sc := range searchContexts {
+		resolvers = append(resolvers, &searchContextResolver{sc})
+	}
+	return resolvers, nil
+}
+
+func (r *searchContextConnectionResolver) TotalCount(ctx context.Context) (int32, error) {
+	count, err := db.SearchContexts.Count(ctx, r.opt)
+	return int32(count), err
+}
+
+func (r *schemaResolver) SearchContexts(ctx context.Context, args *struct {
+	First *int32
+}) (*searchContextConnectionResolver, error) {
+	// 🚨 SECURITY: Only list the search contexts the current user can view.
+	opt := db.SearchContextsListOptions{
+		ViewerUserID:      actor.FromContext(ctx).UID,
+		IncludeAllPrivate: backend.CheckCurrentUserIsSiteAdmin(ctx) == nil,
+	}
+	if args.First != nil {
+		opt.LimitOffset = &db.LimitOffset{Limit: int(*args.First)}
+	}
+	return &searchContextConnectionResolver{opt: opt}, nil
+}
+
+type searchContextInput struct {
+	Name        string
+	Description string
+	Public      bool
+	Namespace   *graphql.ID
+}
+
+type searchContextEditInput struct {
+	Name        string
+	Description string
+	Public      bool
+}
+
+type searchContextRepositoryRevisionsInput struct {
+	RepositoryID graphql.ID
+	Revisions    []string
+}
+
+func unmarshalSearchContextRepositoryRevisions(inputs []searchContextRepositoryRevisionsInput) ([]*types.SearchContextRepositoryRevisions, error) {
+	repositoryRevisions := make([]*types.SearchContextRepositoryRevisions, 0, len(inputs))
+	for _, input := range inputs {
+		repoID, err := UnmarshalRepositoryID(input.RepositoryID)
+		if err != nil {
+			return nil, err
+		}
+		revisions := input.Revisions
+		if len(revisions) == 0 {
+			// A repository without revisions is searched at its default
+			// branch.
+			revisions = []string{""}
+		}
+		repositoryRevisions = append(repositoryRevisions, &types.SearchContextRepositoryRevisions{
+			RepoID:    repoID,
+			Revisions: revisions,
+		})
+	}
+	return repositoryRevisions, nil
+}
+
+func (r *schemaResolver) CreateSearchContext(ctx context.Context, args *struct {
+	SearchContext searchContextInput
+	Repositories  []searchContextRepositoryRevisionsInput
+}) (*searchContextResolver, error) {
+	if !validSearchContextName.MatchString(args.SearchContext.Name) {
+		return nil, errors.New("the search context name may only contain letters, digits, \".\", \"_\" and \"-\"")
+	}
+
+	var namespaceUserID, namespaceOrgID *int32
+	if args.SearchContext.Namespace != nil {
+		switch relay.UnmarshalKind(*args.SearchContext.Namespace) {
+		case "User":
+			id, err := UnmarshalUserID(*args.SearchContext.Namespace)
+			if err != nil {
+				return nil, err
+			}
+			namespaceUserID = &id
+		case "Org":
+			id, err := UnmarshalOrgID(*args.SearchContext.Namespace)
+			if err != nil {
+				return nil, err
+			}
+			namespaceOrgID = &id
+		default:
+			return nil, errors.New("invalid ID for namespace")
+		}
+	}
+	// 🚨 SECURITY: Make sure the current user has permission to create a search context in the namespace.
+	if err := checkSearchContextWriteAccess(ctx, namespaceUserID, namespaceOrgID); err != nil {
+		return nil, err
+	}
+
+	repositoryRevisions, err := unmarshalSearchContextRepositoryRevisions(args.Repositories)
+	if err != nil {
+		return nil, err
+	}
+
+	sc, err := db.SearchContexts.Create(ctx, &types.SearchContext{
+		Name:            args.SearchContext.Name,
+		Description:     args.SearchContext.Description,
+		Public:          args.SearchContext.Public,
+		NamespaceUserID: namespaceUserID,
+		NamespaceOrgID:  namespaceOrgID,
+	}, repositoryRevisions)
+	if err != nil {
+		return nil, err
+	}
+	return &searchContextResolver{sc}, nil
+}
+
+func (r *schemaResolver) UpdateSearchContext(ctx context.Context, args *struct {
+	ID            graphql.ID
+	SearchContext searchContextEditInput
+	Repositories  []searchContextRepositoryRevisionsInput
+}) (*searchContextResolver, error) {
+	if !validSearchContextName.MatchString(args.SearchContext.Name) {
+		return nil, errors.New("the search context name may only contain letters, digits, \".\", \"_\" and \"-\"")
+	}
+
+	// 🚨 SECURITY: searchContextByID reports the search contexts the current
+	// user cannot view as not found.
+	resolver, err := searchContextByID(ctx, args.ID)
+	if err != nil {
+		return nil, err
+	}
+	sc := resolver.sc
+	// 🚨 SECURITY: Make sure the current user has permission to update the search context.
+	if err := checkSearchContextWriteAccess(ctx, sc.NamespaceUserID, sc.NamespaceOrgID); err != nil {
+		return nil, err
+	}
+
+	repositoryRevisions, err := unmarshalSearchContextRepositoryRevisions(args.Repositories)
+	if err != nil {
+		return nil, err
+	}
+
+	sc.Name = args.SearchContext.Name
+	sc.Description = args.SearchContext.Description
+	sc.Public = args.SearchContext.Public
+	sc, err = db.SearchContexts.Update(ctx, sc, repositoryRevisions)
+	if err != nil {
+		return nil, err
+	}
+	return &searchContextResolver{sc}, nil
+}
+
+func (r *schemaResolver) DeleteSearchContext(ctx context.Context, args *struct {
+	ID graphql.ID
+}) (*EmptyResponse, error) {
+	// 🚨 SECURITY: searchContextByID reports the search contexts the current
+	// user cannot view as not found.
+	resolver, err := searchContextByID(ctx, args.ID)
+	if err != nil {
+		return nil, err
+	}
+	sc := resolver.sc
+	// 🚨 SECURITY: Make sure the current user has permission to delete the search context.
+	if err := checkSearchContextWriteAccess(ctx, sc.NamespaceUserID, sc.NamespaceOrgID); err != nil {
+		return nil, err
+	}
+	if err := db.SearchContexts.Delete(ctx, sc.ID); err != nil {
+		return nil, err
+	}
+	return &EmptyResponse{}, nil
+}
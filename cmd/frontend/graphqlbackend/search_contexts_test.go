@@ -0,0 +1,78 @@
+package graphqlbackend
+
+import (
+	"context"
+	"testing"
+
+	graphql "github.com/graph-gophers/graphql-go"
+
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/db"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/types"
+	"github.com/sourcegraph/sourcegraph/internal/actor"
+)
+
+func TestSearchContextAccess(t *testing.T) {
+	alice := int32(1)
+	contexts := map[int64]*types.SearchContext{
+		1: {ID: 1, Name: "private", NamespaceUserID: &alice, NamespaceName: "alice"},
+		2: {ID: 2, Name: "public", Public: true, NamespaceUserID: &alice, NamespaceName: "alice"},
+	}
+	db.Mocks.SearchContexts.GetByID = func(ctx context.Context, id int64) (*types.SearchContext, error) {
+		if sc, ok := contexts[id]; ok {
+			sc := *sc
+			return &sc, nil
+		}
+		return nil, db.ErrSearchContextNotFound
+	}
+	// The current user is not alice, and not a site admin.
+	db.Mocks.Users.GetByCurrentAuthUser = func(ctx context.Context) (*types.User, error) {
+		return &types.User{ID: 2, Username: "bob"}, nil
+	}
+	db.Mocks.Users.GetByID = func(ctx context.Context, id int32) (*types.User, error) {
+		return &types.User{ID: id, Username: "alice"}, nil
+	}
+	defer func() {
+		db.Mocks.SearchContexts = db.MockSearchContexts{}
+		db.Mocks.Users = db.MockUsers{}
+	}()
+	ctx := actor.WithActor(context.Background(), &actor.Actor{UID: 2})
+
+	update := func(id int64) error {
+		_, err := (&schemaResolver{}).UpdateSearchContext(ctx, &struct {
+			ID            graphql.ID
+			SearchContext searchContextEditInput
+			Repositories  []searchContextRepositoryRevisionsInput
+		}{ID: marshalSearchContextID(id), SearchContext: searchContextEditInput{Name: "renamed"}})
+		return err
+	}
+	del := func(id int64) error {
+		_, err := (&schemaResolver{}).DeleteSearchContext(ctx, &struct{ ID graphql.ID }{ID: marshalSearchContextID(id)})
+		return err
+	}
+	get := func(id int64) error {
+		_, err := searchContextByID(ctx, marshalSearchContextID(id))
+		return err
+	}
+
+	for name, op := range map[string]func(int64) error{"get": get, "update": update, "delete": del} {
+		t.Run(name, func(t *testing.T) {
+			// Private search contexts of other users can't be told apart from
+			// search contexts that don't exist.
+			for _, id := range []int64{1, 3} {
+				if err := op(id); err != db.ErrSearchContextNotFound {
+					t.Errorf("search context %d: got error %v, want %v", id, err, db.ErrSearchContextNotFound)
+				}
+			}
+		})
+	}
+
+	// Public search contexts of other users can be viewed, but not changed.
+	if err := get(2); err != nil {
+		t.Errorf("got error %v viewing a public search context", err)
+	}
+	for name, op := range map[string]func(int64) error{"update": update, "delete": del} {
+		if err := op(2); err == nil || err == db.ErrSearchContextNotFound {
+			t.Errorf("%s: got error %v for a public search context of another user, want a permission error", name, err)
+		}
+	}
+}
@@ -0,0 +1,311 @@
+package graphqlbackend
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/types"
+	"github.com/sourcegraph/sourcegraph/internal/api"
+	"github.com/sourcegraph/sourcegraph/internal/gitserver"
+	"github.com/sourcegraph/sourcegraph/internal/search"
+	"github.com/sourcegraph/sourcegraph/internal/search/query"
+	"github.com/sourcegraph/sourcegraph/internal/vcs/git"
+)
+
+type searchDiffArgs struct {
+	Version     string
+	PatternType *string
+	Query       string
+	Base        string
+	Head        string
+}
+
+// SearchDiff runs the file content search of a query at two revisions of each
+// repository it searches, and returns the matches added and removed between
+// them.
+func (r *schemaResolver) SearchDiff(ctx context.Context, args *searchDiffArgs) (*searchDiffResolver, error) {
+	impl, err := NewSearchImplementer(ctx, &SearchArgs{Version: args.Version, PatternType: args.PatternType, Query: args.Query})
+	if err != nil {
+		return nil, err
+	}
+	sr, ok := impl.(*searchResolver)
+	if !ok {
+		if alert, ok := impl.(*searchAlert); ok {
+			return nil, fmt.Errorf("%s: %s", alert.title, alert.description)
+		}
+		return nil, errors.New("invalid search query")
+	}
+	return sr.diff(ctx, args.Base, args.Head)
+}
+
+// diff returns the file content matches of r that are at head but not at base
+// (added), and at base but not at head (removed), in each repository r
+// searches. Revisions in the repo: filters of r are ignored.
+func (r *searchResolver) diff(ctx context.Context, base, head string) (*searchDiffResolver, error) {
+	if r.patternType == query.SearchTypeStructural {
+		return nil, errors.New("search diffs do not support structural search")
+	}
+
+	ctx, cancel, err := r.withTimeout(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer cancel()
+
+	repos, _, _, overLimit, err := r.resolveRepositories(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	if overLimit {
+		return nil, errors.New("too many matching repositories to compare; narrow the search with repo:")
+	}
+
+	options := &getPatternInfoOptions{
+		performLiteralSearch: r.patternType == query.SearchTypeLiteral,
+		fileMatchLimit:       r.maxResults(),
+	}
+	p, err := r.getPatternInfo(options)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.Validate(); err != nil {
+		return nil, &badRequestError{err}
+	}
+
+	// Repositories that could not be searched at both revisions are not
+	// compared, since all of their matches at the other revision would be
+	// reported. Searching a revision that doesn't exist fails the whole
+	// search, so repositories lacking one of the revisions are skipped
+	// before searching.
+	skipped := map[api.RepoName]*types.Repo{}
+	compared := make([]*types.Repo, 0, len(repos))
+	for _, repo := range repos {
+		if searchDiffRevisionsExist(ctx, repo, base, head) {
+			compared = append(compared, repo.Repo)
+		} else {
+			skipped[repo.Repo.Name] = repo.Repo
+		}
+	}
+
+	if len(compared) == 0 {
+		return &searchDiffResolver{skipped: sortedRepos(skipped)}, nil
+	}
+
+	searchAt := func(rev string) ([]*FileMatchResolver, *searchResultsCommon, error) {
+		revRepos := make([]*search.RepositoryRevisions, len(compared))
+		for i, repo := range compared {
+			revRepos[i] = &search.RepositoryRevisions{Repo: repo, Revs: []search.RevisionSpecifier{{RevSpec: rev}}}
+		}
+		return searchFilesInRepos(ctx, &search.TextParameters{
+			PatternInfo:     p,
+			Repos:           revRepos,
+			Query:           r.query,
+			UseFullDeadline: r.searchTimeoutFieldSet(),
+			Zoekt:           r.zoekt,
+			SearcherURLs:    r.searcherURLs,
+		})
+	}
+	baseMatches, baseCommon, err := searchAt(base)
+	if err != nil {
+		return nil, err
+	}
+	headMatches, headCommon, err := searchAt(head)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, common := range []*searchResultsCommon{baseCommon, headCommon} {
+		if common == nil {
+			continue
+		}
+		for _, repos := range [][]*types.Repo{common.missing, common.cloning, common.timedout} {
+			for _, repo := range repos {
+				skipped[repo.Name] = repo
+			}
+		}
+	}
+	limitHit := (baseCommon != nil && baseCommon.limitHit) || (headCommon != nil && headCommon.limitHit)
+
+	// If the limit was hit, a file returned at one revision may match at the
+	// other too without being returned, so only the files returned at both
+	// revisions are compared.
+	type file struct {
+		repo api.RepoName
+		path string
+	}
+	returned := func(matches []*FileMatchResolver) map[file]bool {
+		files := make(map[file]bool, len(matches))
+		for _, fm := range matches {
+			files[file{fm.Repo.repo.Name, fm.JPath}] = true
+		}
+		return files
+	}
+	baseFiles, headFiles := returned(baseMatches), returned(headMatches)
+	compare := func(matches []*FileMatchResolver) []*FileMatchResolver {
+		kept := matches[:0:0]
+		for _, fm := range matches {
+			if _, ok := skipped[fm.Repo.repo.Name]; ok {
+				continue
+			}
+			if f := (file{fm.Repo.repo.Name, fm.JPath}); limitHit && !(baseFiles[f] && headFiles[f]) {
+				continue
+			}
+			kept = append(kept, fm)
+		}
+		return kept
+	}
+
+	added, removed := diffSearchMatches(compare(baseMatches), compare(headMatches), base, head)
+	return &searchDiffResolver{
+		added:    added,
+		removed:  removed,
+		skipped:  sortedRepos(skipped),
+		limitHit: limitHit,
+	}, nil
+}
+
+// sortedRepos returns the repositories of reposByName sorted by name.
+func sortedRepos(reposByName map[api.RepoName]*types.Repo) []*types.Repo {
+	repos := make([]*types.Repo, 0, len(reposByName))
+	for _, repo := range reposByName {
+		repos = append(repos, repo)
+	}
+	sort.Slice(repos, func(i, j int) bool { return repos[i].Name < repos[j].Name })
+	return repos
+}
+
+// searchDiffRevisionsExist reports whether repo has the base and head
+// revisions. Like resolveRepositories, it doesn't ask gitserver to fetch
+// missing revisions, and leaves other errors, such as for repositories that
+// are being cloned, to the search.
+func searchDiffRevisionsExist(ctx context.Context, repo *search.RepositoryRevisions, base, head string) bool {
+	for _, rev := range []string{base, head} {
+		if rev == "" {
+			// The default branch of an empty repository has no matches.
+			continue
+		}
+		if _, err := git.ResolveRevision(ctx, repo.GitserverRepo(), nil, rev, &git.ResolveRevisionOptions{NoEnsureRevision: true}); gitserver.IsRevisionNotFound(err) {
+			return false
+		}
+	}
+	return true
+}
+
+// searchDiffKey identifies a match across revisions: the repository and file
+// path of the match, and the text matched. Line numbers are not part of it, so
+// a match that moved within a file is neither added nor removed.
+type searchDiffKey struct {
+	repo api.RepoName
+	path string
+	text string
+}
+
+// diffSearchMatches returns the matches in head but not in base (added), and
+// in base but not in head (removed). A text matched n times in a file at base
+// and m > n times at head is reported as added m-n times, at its last
+// occurrences at head, and vice versa. A file whose path matched has a single
+// match with empty text.
+func diffSearchMatches(base, head []*FileMatchResolver, baseRev, headRev string) (added, removed []*searchDiffMatchResolver) {
+	baseByKey, baseKeys := searchDiffMatches(base, baseRev)
+	headByKey, headKeys := searchDiffMatches(head, headRev)
+	for _, key := range headKeys {
+		if h, b := headByKey[key], baseByKey[key]; len(h) > len(b) {
+			added = append(added, h[len(b):]...)
+		}
+	}
+	for _, key := range baseKeys {
+		if b, h := baseByKey[key], headByKey[key]; len(b) > len(h) {
+			removed = append(removed, b[len(h):]...)
+		}
+	}
+	sortSearchDiffMatches(added)
+	sortSearchDiffMatches(removed)
+	return added, removed
+}
+
+// searchDiffMatches returns the matches of fileMatches at rev by key, and the
+// keys in the order they first occur.
+func searchDiffMatches(fileMatches []*FileMatchResolver, rev string) (map[searchDiffKey][]*searchDiffMatchResolver, []searchDiffKey) {
+	byKey := map[searchDiffKey][]*searchDiffMatchResolver{}
+	var keys []searchDiffKey
+	add := func(key searchDiffKey, m *searchDiffMatchResolver) {
+		if _, ok := byKey[key]; !ok {
+			keys = append(keys, key)
+		}
+		byKey[key] = append(byKey[key], m)
+	}
+	for _, fm := range fileMatches {
+		if len(fm.JLineMatches) == 0 {
+			add(searchDiffKey{repo: fm.Repo.repo.Name, path: fm.JPath}, &searchDiffMatchResolver{repo: fm.Repo, rev: rev, path: fm.JPath})
+			continue
+		}
+		for _, lm := range fm.JLineMatches {
+			preview := []rune(lm.JPreview)
+			for _, ol := range lm.JOffsetAndLengths {
+				start, end := int(ol[0]), int(ol[0]+ol[1])
+				if start < 0 || end > len(preview) || start > end {
+					continue
+				}
+				text := string(preview[start:end])
+				add(searchDiffKey{repo: fm.Repo.repo.Name, path: fm.JPath, text: text}, &searchDiffMatchResolver{
+					repo:      fm.Repo,
+					rev:       rev,
+					path:      fm.JPath,
+					text:      text,
+					lineMatch: lm,
+				})
+			}
+		}
+	}
+	return byKey, keys
+}
+
+func sortSearchDiffMatches(matches []*searchDiffMatchResolver) {
+	sort.SliceStable(matches, func(i, j int) bool {
+		a, b := matches[i], matches[j]
+		if a.repo.repo.Name != b.repo.repo.Name {
+			return a.repo.repo.Name < b.repo.repo.Name
+		}
+		if a.path != b.path {
+			return a.path < b.path
+		}
+		return a.lineNumber() < b.lineNumber()
+	})
+}
+
+type searchDiffResolver struct {
+	added, removed []*searchDiffMatchResolver
+	skipped        []*types.Repo
+	limitHit       bool
+}
+
+func (r *searchDiffResolver) Added() []*searchDiffMatchResolver   { return r.added }
+func (r *searchDiffResolver) Removed() []*searchDiffMatchResolver { return r.removed }
+func (r *searchDiffResolver) LimitHit() bool                      { return r.limitHit }
+
+func (r *searchDiffResolver) SkippedRepositories() []*RepositoryResolver {
+	return RepositoryResolvers(r.skipped)
+}
+
+type searchDiffMatchResolver struct {
+	repo      *RepositoryResolver
+	rev       string
+	path      string
+	text      string
+	lineMatch *lineMatch // nil if the path matched
+}
+
+func (r *searchDiffMatchResolver) Repository() *RepositoryResolver { return r.repo }
+func (r *searchDiffMatchResolver) RevSpec() string                 { return r.rev }
+func (r *searchDiffMatchResolver) Path() string                    { return r.path }
+func (r *searchDiffMatchResolver) Text() string                    { return r.text }
+func (r *searchDiffMatchResolver) LineMatch() *lineMatch           { return r.lineMatch }
+
+func (r *searchDiffMatchResolver) lineNumber() int32 {
+	if r.lineMatch == nil {
+		return -1
+	}
+	return r.lineMatch.JLineNumber
+}
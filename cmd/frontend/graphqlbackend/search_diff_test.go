@@ -0,0 +1,219 @@
+package graphqlbackend
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/zoekt"
+
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/db"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/types"
+	"github.com/sourcegraph/sourcegraph/internal/api"
+	"github.com/sourcegraph/sourcegraph/internal/endpoint"
+	"github.com/sourcegraph/sourcegraph/internal/gitserver"
+	"github.com/sourcegraph/sourcegraph/internal/search"
+	searchbackend "github.com/sourcegraph/sourcegraph/internal/search/backend"
+	"github.com/sourcegraph/sourcegraph/internal/vcs/git"
+	"github.com/sourcegraph/sourcegraph/schema"
+)
+
+func TestDiffSearchMatches(t *testing.T) {
+	repo := &RepositoryResolver{repo: &types.Repo{ID: 1, Name: "repo"}}
+	fm := func(path string, lines ...*lineMatch) *FileMatchResolver {
+		return &FileMatchResolver{JPath: path, JLineMatches: lines, Repo: repo}
+	}
+	lm := func(line int32, preview string, offsetAndLengths ...[2]int32) *lineMatch {
+		return &lineMatch{JLineNumber: line, JPreview: preview, JOffsetAndLengths: offsetAndLengths}
+	}
+
+	base := []*FileMatchResolver{
+		fm("a.go", lm(1, "foo(bar)", [2]int32{0, 3}), lm(5, "foo(baz)", [2]int32{0, 3})),
+		fm("b.go", lm(2, "oldFoo()", [2]int32{0, 6})),
+		fm("gone.go"),
+	}
+	head := []*FileMatchResolver{
+		// The match of foo moved from line 5 to line 9, and one was added.
+		fm("a.go", lm(1, "foo(bar) foo", [2]int32{0, 3}, [2]int32{9, 3}), lm(9, "foo(baz)", [2]int32{0, 3})),
+		fm("b.go", lm(2, "newFoo()", [2]int32{0, 6})),
+		fm("c.go", lm(0, "é foo", [2]int32{2, 3})),
+	}
+
+	describe := func(matches []*searchDiffMatchResolver) []string {
+		var s []string
+		for _, m := range matches {
+			s = append(s, fmt.Sprintf("%s@%s:%d:%s", m.path, m.rev, m.lineNumber(), m.text))
+		}
+		return s
+	}
+
+	added, removed := diffSearchMatches(base, head, "v1", "HEAD")
+	if got, want := describe(added), []string{
+		"a.go@HEAD:9:foo",
+		"b.go@HEAD:2:newFoo",
+		"c.go@HEAD:0:foo",
+	}; !reflect.DeepEqual(got, want) {
+		t.Errorf("added: got %q, want %q", got, want)
+	}
+	if got, want := describe(removed), []string{
+		"b.go@v1:2:oldFoo",
+		"gone.go@v1:-1:",
+	}; !reflect.DeepEqual(got, want) {
+		t.Errorf("removed: got %q, want %q", got, want)
+	}
+}
+
+func TestSearchDiff(t *testing.T) {
+	mockDecodedViewerFinalSettings = &schema.Settings{}
+	defer func() { mockDecodedViewerFinalSettings = nil }()
+
+	db.Mocks.Repos.List = func(_ context.Context, op db.ReposListOptions) ([]*types.Repo, error) {
+		return []*types.Repo{{ID: 1, Name: "repo"}, {ID: 2, Name: "other"}}, nil
+	}
+	db.Mocks.Repos.Count = mockCount
+	defer func() { db.Mocks = db.MockStores{} }()
+
+	git.Mocks.ResolveRevision = func(spec string, opt *git.ResolveRevisionOptions) (api.CommitID, error) {
+		if spec == "v0" {
+			return "", &gitserver.RevisionNotFoundError{Spec: spec}
+		}
+		return api.CommitID("commit-" + spec), nil
+	}
+	defer git.ResetMocks()
+
+	mockSearchFilesInRepos = func(args *search.TextParameters) ([]*FileMatchResolver, *searchResultsCommon, error) {
+		rev := args.Repos[0].Revs[0].RevSpec
+		for _, repoRev := range args.Repos {
+			if len(repoRev.Revs) != 1 || repoRev.Revs[0].RevSpec != rev {
+				t.Errorf("got revisions %v for %s, want %q", repoRev.Revs, repoRev.Repo.Name, rev)
+			}
+		}
+		repo := &RepositoryResolver{repo: args.Repos[0].Repo}
+		other := &RepositoryResolver{repo: args.Repos[1].Repo}
+		if rev == "v1" {
+			return []*FileMatchResolver{
+				{JPath: "a.go", JLineMatches: []*lineMatch{{JPreview: "deprecatedFoo()", JOffsetAndLengths: [][2]int32{{0, 13}}}}, Repo: repo},
+			}, &searchResultsCommon{missing: []*types.Repo{args.Repos[1].Repo}}, nil
+		}
+		return []*FileMatchResolver{
+			{JPath: "a.go", JLineMatches: []*lineMatch{{JPreview: "deprecatedFoo()", JOffsetAndLengths: [][2]int32{{0, 13}}}}, Repo: repo},
+			{JPath: "b.go", JLineMatches: []*lineMatch{{JPreview: "deprecatedFoo()", JOffsetAndLengths: [][2]int32{{0, 13}}}}, Repo: repo},
+			{JPath: "c.go", JLineMatches: []*lineMatch{{JPreview: "deprecatedFoo()", JOffsetAndLengths: [][2]int32{{0, 13}}}}, Repo: other},
+		}, &searchResultsCommon{}, nil
+	}
+	defer func() { mockSearchFilesInRepos = nil }()
+
+	res, err := (&schemaResolver{}).SearchDiff(context.Background(), &searchDiffArgs{Version: "V2", Query: "deprecatedFoo", Base: "v1", Head: ""})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Added()) != 1 || res.Added()[0].Path() != "b.go" || res.Added()[0].Text() != "deprecatedFoo" {
+		t.Errorf("got added %+v, want b.go", res.Added())
+	}
+	if len(res.Removed()) != 0 {
+		t.Errorf("got removed %+v, want none", res.Removed())
+	}
+	if skipped := res.SkippedRepositories(); len(skipped) != 1 || skipped[0].Name() != "other" {
+		t.Errorf("got skipped repositories %v, want other", skipped)
+	}
+
+	// Repositories lacking one of the revisions are skipped instead of
+	// failing the search.
+	res, err = (&schemaResolver{}).SearchDiff(context.Background(), &searchDiffArgs{Version: "V2", Query: "deprecatedFoo", Base: "v0", Head: ""})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if skipped := res.SkippedRepositories(); len(skipped) != 2 || len(res.Added()) != 0 || len(res.Removed()) != 0 {
+		t.Errorf("got skipped repositories %v, added %v and removed %v, want all repositories skipped", skipped, res.Added(), res.Removed())
+	}
+}
+
+func TestSearchDiff_searchFilesInRepos(t *testing.T) {
+	mockDecodedViewerFinalSettings = &schema.Settings{}
+	defer func() { mockDecodedViewerFinalSettings = nil }()
+
+	db.Mocks.Repos.List = func(_ context.Context, op db.ReposListOptions) ([]*types.Repo, error) {
+		return []*types.Repo{{ID: 1, Name: "indexed"}, {ID: 2, Name: "unindexed"}}, nil
+	}
+	db.Mocks.Repos.Count = mockCount
+	defer func() { db.Mocks = db.MockStores{} }()
+
+	git.Mocks.ResolveRevision = func(spec string, opt *git.ResolveRevisionOptions) (api.CommitID, error) {
+		return api.CommitID("commit-" + spec), nil
+	}
+	defer git.ResetMocks()
+
+	fm := func(repo *types.Repo, path string) *FileMatchResolver {
+		return &FileMatchResolver{Repo: &RepositoryResolver{repo: repo}, JPath: path, JLineMatches: []*lineMatch{{JPreview: "deprecatedFoo()", JOffsetAndLengths: [][2]int32{{0, 13}}}}}
+	}
+	// Searcher searches the base revision of both repositories, and the head
+	// of the unindexed repository.
+	mockSearchFilesInRepo = func(ctx context.Context, repo *types.Repo, gitserverRepo gitserver.Repo, rev string, info *search.TextPatternInfo, fetchTimeout time.Duration) ([]*FileMatchResolver, bool, error) {
+		if info.FileMatchLimit == 0 {
+			t.Error("got no file match limit")
+		}
+		switch {
+		case repo.Name == "indexed" && rev == "v1":
+			return []*FileMatchResolver{fm(repo, "a.go"), fm(repo, "gone.go")}, false, nil
+		case repo.Name == "unindexed" && rev == "v1":
+			return nil, false, context.DeadlineExceeded
+		case repo.Name == "unindexed" && rev == "":
+			return []*FileMatchResolver{fm(repo, "c.go")}, false, nil
+		}
+		t.Errorf("unexpected search of %s@%s", repo.Name, rev)
+		return nil, false, nil
+	}
+	defer func() { mockSearchFilesInRepo = nil }()
+
+	// Zoekt searches the head of the indexed repository.
+	zoektFile := func(name string) zoekt.FileMatch {
+		return zoekt.FileMatch{
+			FileName:   name,
+			Repository: "indexed",
+			LineMatches: []zoekt.LineMatch{{
+				Line:          []byte("deprecatedFoo()"),
+				LineFragments: []zoekt.LineFragmentMatch{{MatchLength: 13}},
+			}},
+		}
+	}
+	z := &searchbackend.Zoekt{
+		Client: &fakeSearcher{
+			repos: &zoekt.RepoList{Repos: []*zoekt.RepoListEntry{{
+				Repository: zoekt.Repository{Name: "indexed", Branches: []zoekt.RepositoryBranch{{Name: "HEAD", Version: "deadbeef"}}},
+			}}},
+			result: &zoekt.SearchResult{Files: []zoekt.FileMatch{zoektFile("a.go"), zoektFile("b.go")}},
+		},
+		DisableCache: true,
+	}
+
+	impl, err := NewSearchImplementer(context.Background(), &SearchArgs{Version: "V2", Query: "deprecatedFoo"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sr := impl.(*searchResolver)
+	sr.zoekt = z
+	sr.searcherURLs = endpoint.Static("test")
+
+	res, err := sr.diff(context.Background(), "v1", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	describe := func(matches []*searchDiffMatchResolver) []string {
+		var s []string
+		for _, m := range matches {
+			s = append(s, fmt.Sprintf("%s/%s@%s:%s", m.repo.Name(), m.path, m.rev, m.text))
+		}
+		return s
+	}
+	if got, want := describe(res.Added()), []string{"indexed/b.go@:deprecatedFoo"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("added: got %q, want %q", got, want)
+	}
+	if got, want := describe(res.Removed()), []string{"indexed/gone.go@v1:deprecatedFoo"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("removed: got %q, want %q", got, want)
+	}
+	if skipped := res.SkippedRepositories(); len(skipped) != 1 || skipped[0].Name() != "unindexed" {
+		t.Errorf("got skipped repositories %v, want unindexed", skipped)
+	}
+}
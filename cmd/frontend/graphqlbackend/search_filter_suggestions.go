@@ -10,14 +10,12 @@ import (
 
 // SearchFilterSuggestions provides search filter and default value suggestions.
 func (r *schemaResolver) SearchFilterSuggestions(ctx context.Context) (*searchFilterSuggestions, error) {
-	groupsByName, err := resolveRepoGroups(ctx)
+	// Only the names of the repo groups are suggested, so don't resolve their
+	// repositories.
+	repoGroups, err := repoGroupNames(ctx)
 	if err != nil {
 		return nil, err
 	}
-	repoGroups := make([]string, 0, len(groupsByName))
-	for name := range groupsByName {
-		repoGroups = append(repoGroups, name)
-	}
 
 	// List at most 10 repositories as default suggestions.
 	repos, err := backend.Repos.List(ctx, db.ReposListOptions{
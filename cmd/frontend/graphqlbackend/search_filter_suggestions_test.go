@@ -8,18 +8,30 @@ import (
 
 	"github.com/sourcegraph/sourcegraph/cmd/frontend/db"
 	"github.com/sourcegraph/sourcegraph/cmd/frontend/types"
+	"github.com/sourcegraph/sourcegraph/schema"
 )
 
 func TestSearchFilterSuggestions(t *testing.T) {
-	mockResolveRepoGroups = func() (map[string][]*types.Repo, error) {
-		return map[string][]*types.Repo{
-			"repogroup1": {},
-			"repogroup2": {},
+	mockDecodedViewerFinalSettings = &schema.Settings{
+		SearchRepositoryGroups: map[string][]string{
+			"repogroup1": {"github.com/foo/repo"},
+		},
+	}
+	db.Mocks.RepoGroups.List = func(ctx context.Context) ([]*types.RepoGroup, error) {
+		return []*types.RepoGroup{
+			{Name: "repogroup1", Pattern: "^github\\.com/"},
+			{Name: "repogroup2", Pattern: "repo"},
 		}, nil
 	}
-	defer func() { mockResolveRepoGroups = nil }()
+	defer func() {
+		mockDecodedViewerFinalSettings = nil
+		db.Mocks.RepoGroups = db.MockRepoGroups{}
+	}()
 
-	db.Mocks.Repos.List = func(_ context.Context, _ db.ReposListOptions) ([]*types.Repo, error) {
+	db.Mocks.Repos.List = func(_ context.Context, opt db.ReposListOptions) ([]*types.Repo, error) {
+		if len(opt.IncludePatterns) > 0 {
+			t.Fatalf("unexpected listing of repo group repositories with %+v", opt)
+		}
 		return []*types.Repo{
 			{Name: "github.com/foo/repo"},
 			{Name: "bar-repo"},
@@ -0,0 +1,160 @@
+package graphqlbackend
+
+import (
+	"context"
+	"strconv"
+
+	graphql "github.com/graph-gophers/graphql-go"
+	"github.com/inconshreveable/log15"
+
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/backend"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/db"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/graphqlbackend/graphqlutil"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/types"
+	"github.com/sourcegraph/sourcegraph/internal/actor"
+	"github.com/sourcegraph/sourcegraph/internal/conf"
+	"github.com/sourcegraph/sourcegraph/internal/search/query"
+)
+
+// searchPatternTypeName returns the name of a search type in the GraphQL
+// SearchPatternType enum.
+func searchPatternTypeName(searchType query.SearchType) string {
+	switch searchType {
+	case query.SearchTypeLiteral:
+		return "literal"
+	case query.SearchTypeStructural:
+		return "structural"
+	default:
+		return "regexp"
+	}
+}
+
+// recordSearchHistory adds a search that completed with the given number of
+// results to the search history of the current user. Searches by anonymous
+// users and internal searches are not recorded, and neither are searches
+// when search history is disabled in the site configuration.
+func (r *searchResolver) recordSearchHistory(ctx context.Context, resultCount int32) {
+	a := actor.FromContext(ctx)
+	if !r.recordHistory || !a.IsAuthenticated() || a.Internal || !conf.SearchHistoryEnabled() {
+		return
+	}
+	if r.pagination != nil && r.pagination.cursor != nil {
+		// Only the request for the first page of a paginated search is a
+		// search run by the user.
+		return
+	}
+
+	entry := &types.SearchHistoryEntry{
+		UserID:      a.UID,
+		Query:       r.originalQuery,
+		PatternType: searchPatternTypeName(r.patternType),
+		ResultCount: resultCount,
+	}
+	if r.versionContext != nil {
+		entry.VersionContext = *r.versionContext
+	}
+	if _, err := db.SearchHistory.Create(ctx, entry); err != nil {
+		log15.Warn("Failed to record search history.", "user", a.UID, "error", err)
+	}
+}
+
+func (r *UserResolver) SearchHistory(ctx context.Context, args *struct {
+	First  *int32
+	After  *string
+	Unique bool
+}) (*searchHistoryConnectionResolver, error) {
+	// 🚨 SECURITY: The search history can only be viewed by the user or site admin.
+	if err := backend.CheckSiteAdminOrSameUser(ctx, r.user.ID); err != nil {
+		return nil, err
+	}
+	opt := db.SearchHistoryListOptions{UserID: r.user.ID, Unique: args.Unique}
+	if args.After != nil {
+		id, err := strconv.ParseInt(*args.After, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		opt.BeforeID = id
+	}
+	if args.First != nil {
+		// Fetch one more entry to know whether there is a next page.
+		opt.Limit = int(*args.First) + 1
+	}
+	return &searchHistoryConnectionResolver{opt: opt}, nil
+}
+
+type searchHistoryConnectionResolver struct {
+	opt db.SearchHistoryListOptions
+}
+
+func (r *searchHistoryConnectionResolver) compute(ctx context.Context) ([]*types.SearchHistoryEntry, bool, error) {
+	entries, err := db.SearchHistory.List(ctx, r.opt)
+	if err != nil {
+		return nil, false, err
+	}
+	if r.opt.Limit > 0 && len(entries) == r.opt.Limit {
+		return entries[:len(entries)-1], true, nil
+	}
+	return entries, false, nil
+}
+
+func (r *searchHistoryConnectionResolver) Nodes(ctx context.Context) ([]*searchHistoryEntryResolver, error) {
+	entries, _, err := r.compute(ctx)
+	if err != nil {
+		return nil, err
+	}
+	resolvers := make([]*searchHistoryEntryResolver, 0, len(entries))
+	for _, entry := range entries {
+		resolvers = append(resolvers, &searchHistoryEntryResolver{entry: entry})
+	}
+	return resolvers, nil
+}
+
+func (r *searchHistoryConnectionResolver) TotalCount(ctx context.Context) (int32, error) {
+	count, err := db.SearchHistory.Count(ctx, r.opt)
+	return int32(count), err
+}
+
+func (r *searchHistoryConnectionResolver) PageInfo(ctx context.Context) (*graphqlutil.PageInfo, error) {
+	entries, hasNextPage, err := r.compute(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !hasNextPage || len(entries) == 0 {
+		return graphqlutil.HasNextPage(false), nil
+	}
+	return graphqlutil.NextPageCursor(strconv.FormatInt(entries[len(entries)-1].ID, 10)), nil
+}
+
+type searchHistoryEntryResolver struct {
+	entry *types.SearchHistoryEntry
+}
+
+func (r *searchHistoryEntryResolver) Query() string       { return r.entry.Query }
+func (r *searchHistoryEntryResolver) PatternType() string { return r.entry.PatternType }
+func (r *searchHistoryEntryResolver) VersionContext() *string {
+	if r.entry.VersionContext == "" {
+		return nil
+	}
+	return &r.entry.VersionContext
+}
+func (r *searchHistoryEntryResolver) ResultCount() int32 { return r.entry.ResultCount }
+func (r *searchHistoryEntryResolver) CreatedAt() DateTime {
+	return DateTime{Time: r.entry.CreatedAt}
+}
+
+func (r *schemaResolver) ClearSearchHistory(ctx context.Context, args *struct {
+	User graphql.ID
+}) (*EmptyResponse, error) {
+	userID, err := UnmarshalUserID(args.User)
+	if err != nil {
+		return nil, err
+	}
+	// 🚨 SECURITY: The search history can only be cleared by the user or site admin.
+	if err := backend.CheckSiteAdminOrSameUser(ctx, userID); err != nil {
+		return nil, err
+	}
+	if err := db.SearchHistory.DeleteByUser(ctx, userID); err != nil {
+		return nil, err
+	}
+	return &EmptyResponse{}, nil
+}
@@ -0,0 +1,196 @@
+package graphqlbackend
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/db"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/types"
+	"github.com/sourcegraph/sourcegraph/internal/actor"
+	"github.com/sourcegraph/sourcegraph/internal/conf"
+	"github.com/sourcegraph/sourcegraph/internal/search/query"
+	"github.com/sourcegraph/sourcegraph/schema"
+)
+
+func TestUserSearchHistory(t *testing.T) {
+	defer resetMocks()
+	db.Mocks.Users.GetByCurrentAuthUser = func(context.Context) (*types.User, error) {
+		return &types.User{ID: 1}, nil
+	}
+
+	entries := []*types.SearchHistoryEntry{
+		{ID: 5, UserID: 1, Query: "e"},
+		{ID: 4, UserID: 1, Query: "d"},
+		{ID: 3, UserID: 1, Query: "c"},
+		{ID: 2, UserID: 1, Query: "b"},
+		{ID: 1, UserID: 1, Query: "a"},
+	}
+	db.Mocks.SearchHistory.List = func(ctx context.Context, opt db.SearchHistoryListOptions) ([]*types.SearchHistoryEntry, error) {
+		var page []*types.SearchHistoryEntry
+		for _, e := range entries {
+			if opt.BeforeID != 0 && e.ID >= opt.BeforeID {
+				continue
+			}
+			if opt.Limit > 0 && len(page) == opt.Limit {
+				break
+			}
+			page = append(page, e)
+		}
+		return page, nil
+	}
+	db.Mocks.SearchHistory.Count = func(ctx context.Context, opt db.SearchHistoryListOptions) (int, error) {
+		return len(entries), nil
+	}
+
+	ctx := actor.WithActor(context.Background(), actor.FromUser(1))
+	user := &UserResolver{user: &types.User{ID: 1}}
+
+	var (
+		queries []string
+		after   *string
+	)
+	for pages := 0; ; pages++ {
+		if pages > len(entries) {
+			t.Fatal("too many pages")
+		}
+		first := int32(2)
+		conn, err := user.SearchHistory(ctx, &struct {
+			First  *int32
+			After  *string
+			Unique bool
+		}{First: &first, After: after})
+		if err != nil {
+			t.Fatal(err)
+		}
+		nodes, err := conn.Nodes(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, node := range nodes {
+			queries = append(queries, node.Query())
+		}
+		totalCount, err := conn.TotalCount(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if totalCount != int32(len(entries)) {
+			t.Errorf("got total count %d, want %d", totalCount, len(entries))
+		}
+		pageInfo, err := conn.PageInfo(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !pageInfo.HasNextPage() {
+			break
+		}
+		after = pageInfo.EndCursor()
+	}
+	if diff := cmp.Diff([]string{"e", "d", "c", "b", "a"}, queries); diff != "" {
+		t.Errorf("mismatch (-want, +got):\n%s", diff)
+	}
+
+	t.Run("other users", func(t *testing.T) {
+		db.Mocks.Users.GetByID = func(ctx context.Context, id int32) (*types.User, error) {
+			return &types.User{ID: id}, nil
+		}
+		other := &UserResolver{user: &types.User{ID: 2}}
+		if _, err := other.SearchHistory(ctx, &struct {
+			First  *int32
+			After  *string
+			Unique bool
+		}{}); err == nil {
+			t.Error("expected an error viewing the search history of another user")
+		}
+	})
+}
+
+func TestRecordSearchHistory(t *testing.T) {
+	defer resetMocks()
+
+	var recorded []*types.SearchHistoryEntry
+	db.Mocks.SearchHistory.Create = func(ctx context.Context, entry *types.SearchHistoryEntry) (*types.SearchHistoryEntry, error) {
+		recorded = append(recorded, entry)
+		return entry, nil
+	}
+
+	versionContext := "3.16"
+	newResolver := func() *searchResolver {
+		return &searchResolver{
+			originalQuery:  "foo repo:bar",
+			patternType:    query.SearchTypeStructural,
+			versionContext: &versionContext,
+			recordHistory:  true,
+		}
+	}
+	userCtx := actor.WithActor(context.Background(), actor.FromUser(1))
+	disabled := false
+
+	tests := []struct {
+		name   string
+		ctx    context.Context
+		r      *searchResolver
+		config schema.SiteConfiguration
+		want   []*types.SearchHistoryEntry
+	}{
+		{
+			name: "user search",
+			ctx:  userCtx,
+			r:    newResolver(),
+			want: []*types.SearchHistoryEntry{{
+				UserID:         1,
+				Query:          "foo repo:bar",
+				PatternType:    "structural",
+				VersionContext: "3.16",
+				ResultCount:    7,
+			}},
+		},
+		{
+			name: "anonymous user",
+			ctx:  context.Background(),
+			r:    newResolver(),
+		},
+		{
+			name: "internal search",
+			ctx:  actor.WithActor(context.Background(), &actor.Actor{Internal: true}),
+			r:    newResolver(),
+		},
+		{
+			name: "not a user search",
+			ctx:  userCtx,
+			r: func() *searchResolver {
+				r := newResolver()
+				r.recordHistory = false
+				return r
+			}(),
+		},
+		{
+			name: "next page",
+			ctx:  userCtx,
+			r: func() *searchResolver {
+				r := newResolver()
+				r.pagination = &searchPaginationInfo{cursor: &searchCursor{}}
+				return r
+			}(),
+		},
+		{
+			name:   "disabled",
+			ctx:    userCtx,
+			r:      newResolver(),
+			config: schema.SiteConfiguration{SearchHistoryEnabled: &disabled},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			conf.Mock(&conf.Unified{SiteConfiguration: test.config})
+			defer conf.Mock(nil)
+			recorded = nil
+
+			test.r.recordSearchHistory(test.ctx, 7)
+			if diff := cmp.Diff(test.want, recorded); diff != "" {
+				t.Errorf("mismatch (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
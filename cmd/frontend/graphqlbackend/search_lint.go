@@ -0,0 +1,104 @@
+package graphqlbackend
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/sourcegraph/go-langserver/pkg/lsp"
+
+	"github.com/sourcegraph/sourcegraph/internal/conf"
+	"github.com/sourcegraph/sourcegraph/internal/search/query"
+)
+
+func (r *schemaResolver) ValidateQuery(ctx context.Context, args *struct {
+	Version     string
+	PatternType *string
+	Query       string
+}) ([]*searchQueryDiagnosticResolver, error) {
+	opts, err := processOptions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return queryDiagnostics(args.Query, args.Version, args.PatternType, opts), nil
+}
+
+// queryDiagnostics returns the diagnostics of a search query. Errors that
+// prevent the query from running are reported by the search as alerts and
+// are reported as diagnostics here, unless the lint pass already reported
+// them more precisely.
+func queryDiagnostics(input, version string, patternType *string, opts query.ProcessOptions) []*searchQueryDiagnosticResolver {
+	var diagnostics []query.Diagnostic
+	searchType, err := detectSearchType(version, patternType, input)
+	if err == nil && searchType == query.SearchTypeStructural && !conf.StructuralSearchEnabled() {
+		err = errors.New("Structural search is disabled in the site configuration.")
+	}
+	if err != nil {
+		diagnostics = append(diagnostics, query.ErrorDiagnostic(input, err, query.SearchTypeLiteral))
+	} else {
+		diagnostics = query.Lint(input, searchType, opts)
+		if _, err := processQuery(input, searchType, opts); err != nil && !hasErrorDiagnostic(diagnostics) {
+			diagnostics = append([]query.Diagnostic{query.ErrorDiagnostic(input, err, searchType)}, diagnostics...)
+		}
+	}
+
+	resolvers := make([]*searchQueryDiagnosticResolver, 0, len(diagnostics))
+	for _, d := range diagnostics {
+		resolvers = append(resolvers, &searchQueryDiagnosticResolver{input: input, diagnostic: d})
+	}
+	return resolvers
+}
+
+func hasErrorDiagnostic(diagnostics []query.Diagnostic) bool {
+	for _, d := range diagnostics {
+		if d.Severity == query.SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+type searchQueryDiagnosticResolver struct {
+	input      string
+	diagnostic query.Diagnostic
+}
+
+func (r *searchQueryDiagnosticResolver) Severity() string {
+	return strings.ToUpper(string(r.diagnostic.Severity))
+}
+
+func (r *searchQueryDiagnosticResolver) Code() string    { return r.diagnostic.Code }
+func (r *searchQueryDiagnosticResolver) Message() string { return capFirst(r.diagnostic.Message) }
+
+func (r *searchQueryDiagnosticResolver) Range() RangeResolver {
+	return NewRangeResolver(lsp.Range{
+		Start: queryPosition(r.input, r.diagnostic.Range.Start),
+		End:   queryPosition(r.input, r.diagnostic.Range.End),
+	})
+}
+
+func (r *searchQueryDiagnosticResolver) Fixes() []*searchQueryFixResolver {
+	fixes := make([]*searchQueryFixResolver, 0, len(r.diagnostic.Fixes))
+	for _, fix := range r.diagnostic.Fixes {
+		fixes = append(fixes, &searchQueryFixResolver{fix: fix})
+	}
+	return fixes
+}
+
+// queryPosition returns the line and character of a byte offset in a query.
+func queryPosition(input string, offset int) lsp.Position {
+	if offset > len(input) {
+		offset = len(input)
+	}
+	line := strings.Count(input[:offset], "\n")
+	lineStart := strings.LastIndexByte(input[:offset], '\n') + 1
+	return lsp.Position{Line: line, Character: utf8.RuneCountInString(input[lineStart:offset])}
+}
+
+type searchQueryFixResolver struct {
+	fix query.Fix
+}
+
+func (r *searchQueryFixResolver) Description() string { return r.fix.Description }
+func (r *searchQueryFixResolver) Query() string       { return r.fix.Query }
@@ -0,0 +1,133 @@
+package graphqlbackend
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/sourcegraph/go-langserver/pkg/lsp"
+
+	"github.com/sourcegraph/sourcegraph/internal/search/query"
+)
+
+func TestQueryDiagnostics(t *testing.T) {
+	type diagnostic struct {
+		Severity, Code string
+		Start, End     lsp.Position
+		Fixes          []string
+	}
+	diagnostics := func(input, patternType string, opts query.ProcessOptions) []diagnostic {
+		var ds []diagnostic
+		for _, r := range queryDiagnostics(input, "V2", &patternType, opts) {
+			rng := r.Range()
+			d := diagnostic{
+				Severity: r.Severity(),
+				Code:     r.Code(),
+				Start:    lsp.Position{Line: int(rng.Start().Line()), Character: int(rng.Start().Character())},
+				End:      lsp.Position{Line: int(rng.End().Line()), Character: int(rng.End().Character())},
+			}
+			for _, fix := range r.Fixes() {
+				d.Fixes = append(d.Fixes, fix.Query())
+			}
+			ds = append(ds, d)
+		}
+		return ds
+	}
+
+	tests := []struct {
+		name        string
+		input       string
+		patternType string
+		opts        query.ProcessOptions
+		want        []diagnostic
+	}{
+		{
+			name:        "valid",
+			input:       "repo:acme foo",
+			patternType: "literal",
+		},
+		{
+			name:        "lint error replaces the processing error",
+			input:       "repo:acme (foo",
+			patternType: "regexp",
+			want: []diagnostic{{
+				Severity: "ERROR",
+				Code:     "unbalanced-parentheses",
+				Start:    lsp.Position{Character: 10},
+				End:      lsp.Position{Character: 11},
+				Fixes:    []string{`repo:acme \(foo`},
+			}},
+		},
+		{
+			name:        "processing error",
+			input:       "foo case:yes case:no",
+			patternType: "literal",
+			want: []diagnostic{{
+				Severity: "ERROR",
+				Code:     "invalid-query",
+				End:      lsp.Position{Character: 20},
+			}},
+		},
+		{
+			name:        "glob without globbing",
+			input:       "file:*.go foo",
+			patternType: "literal",
+			want: []diagnostic{{
+				Severity: "ERROR",
+				Code:     "glob-pattern",
+				Start:    lsp.Position{Character: 5},
+				End:      lsp.Position{Character: 9},
+				Fixes:    []string{`file:(^|/)[^/]*\.go(/|$) foo`},
+			}},
+		},
+		{
+			name:        "glob with globbing",
+			input:       "file:*.go foo",
+			patternType: "literal",
+			opts:        query.ProcessOptions{Globbing: true},
+		},
+		{
+			name:        "invalid glob",
+			input:       "file:{a,b foo",
+			patternType: "literal",
+			opts:        query.ProcessOptions{Globbing: true},
+			want: []diagnostic{{
+				Severity: "ERROR",
+				Code:     "invalid-query",
+				End:      lsp.Position{Character: 13},
+			}},
+		},
+		{
+			name:        "positions count code points",
+			input:       "é repo:x repo:x",
+			patternType: "literal",
+			want: []diagnostic{{
+				Severity: "WARNING",
+				Code:     "redundant-repo",
+				Start:    lsp.Position{Character: 9},
+				End:      lsp.Position{Character: 15},
+				Fixes:    []string{"é repo:x"},
+			}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if diff := cmp.Diff(test.want, diagnostics(test.input, test.patternType, test.opts)); diff != "" {
+				t.Errorf("mismatch (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestQueryPosition(t *testing.T) {
+	input := "a\nbé c"
+	for offset, want := range map[int]lsp.Position{
+		0:          {Line: 0, Character: 0},
+		2:          {Line: 1, Character: 0},
+		5:          {Line: 1, Character: 2},
+		len(input): {Line: 1, Character: 4},
+	} {
+		if got := queryPosition(input, offset); got != want {
+			t.Errorf("queryPosition(%q, %d) = %+v, want %+v", input, offset, got, want)
+		}
+	}
+}
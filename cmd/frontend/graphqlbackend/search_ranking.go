@@ -0,0 +1,328 @@
+package graphqlbackend
+
+import (
+	"context"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/inconshreveable/log15"
+	"github.com/neelance/parallel"
+
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/backend"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/db"
+	"github.com/sourcegraph/sourcegraph/internal/api"
+	"github.com/sourcegraph/sourcegraph/internal/gitserver"
+	"github.com/sourcegraph/sourcegraph/internal/lazyregexp"
+	"github.com/sourcegraph/sourcegraph/internal/search"
+	"github.com/sourcegraph/sourcegraph/internal/search/query"
+	"github.com/sourcegraph/sourcegraph/internal/vcs/git"
+)
+
+// Weights of the relevance signals of a file match. A file that defines a
+// symbol matching the query outranks any combination of the other signals.
+const (
+	definitionWeight  = 4.0
+	starsWeight       = 0.5 // per order of magnitude of stars
+	recencyWeight     = 1.0 // for a file modified just now, halved after a month
+	testPathPenalty   = 1.5
+	vendorPathPenalty = 3.0
+)
+
+// Fetching ranking signals must not slow searches down noticeably, so it is
+// bounded in time and in the number of requests. Results whose signals could
+// not be fetched are ranked by the signals that are available.
+const (
+	rankingTimeout          = 500 * time.Millisecond
+	rankingMaxSymbolRepos   = 20
+	rankingRecencyParallel  = 10
+	rankingMaxSymbolsOfRepo = 1000
+)
+
+var (
+	testPathPattern   = lazyregexp.New(`(^|/)(tests?|__tests__|spec|testdata)/|_test\.[a-z]+$|[._-](test|spec)\.[a-z]+$|(^|/)test_[^/]*$`)
+	vendorPathPattern = lazyregexp.New(`(^|/)(vendor|node_modules|third_party|bower_components)/`)
+)
+
+// rankingSignals are the relevance signals of a file match.
+type rankingSignals struct {
+	definesSymbol bool      // a symbol matching the query is defined on a matched line
+	stars         int       // stars of the repository on its code host
+	lastModified  time.Time // date of the last commit that modified the file, zero if unknown
+}
+
+// score combines the signals of the file match with the given path into a
+// relevance score. Higher is more relevant.
+func (s *rankingSignals) score(path string, now time.Time) float64 {
+	var score float64
+	if s.definesSymbol {
+		score += definitionWeight
+	}
+	if s.stars > 0 {
+		score += starsWeight * math.Log10(float64(1+s.stars))
+	}
+	if !s.lastModified.IsZero() {
+		days := math.Max(now.Sub(s.lastModified).Hours()/24, 0)
+		score += recencyWeight / (1 + days/30)
+	}
+	switch {
+	case vendorPathPattern.MatchString(path):
+		score -= vendorPathPenalty
+	case testPathPattern.MatchString(path):
+		score -= testPathPenalty
+	}
+	return score
+}
+
+// sortBy returns the order of the results requested with the sort: field.
+func (r *searchResolver) sortBy() query.SortBy {
+	value, _ := r.query.StringValue(query.FieldSort)
+	sortBy, err := query.ParseSortBy(value)
+	if err != nil {
+		// Unreachable, the query has been validated.
+		return query.SortByPath
+	}
+	return sortBy
+}
+
+// rankResults orders results as requested by sortBy. Results are first
+// ordered by path, which breaks ties between results that rank the same.
+// Results other than file matches have no signals, so they rank like a file
+// match without any signals.
+func rankResults(ctx context.Context, results []SearchResultResolver, sortBy query.SortBy, patternInfo *search.TextPatternInfo) {
+	sortResults(results)
+	if sortBy != query.SortByRelevance && sortBy != query.SortByRecency {
+		return
+	}
+
+	var fileMatches []*FileMatchResolver
+	for _, result := range results {
+		if fm, ok := result.ToFileMatch(); ok {
+			fileMatches = append(fileMatches, fm)
+		}
+	}
+	if len(fileMatches) == 0 {
+		return
+	}
+	signals := fetchRankingSignals(ctx, fileMatches, sortBy, patternInfo)
+
+	now := time.Now()
+	scores := make([]float64, len(results))
+	for i, result := range results {
+		s := &rankingSignals{}
+		fm, ok := result.ToFileMatch()
+		if ok {
+			s = signals[fm]
+		}
+		if sortBy == query.SortByRecency {
+			if !s.lastModified.IsZero() {
+				scores[i] = float64(s.lastModified.Unix())
+			}
+			continue
+		}
+		_, path := result.searchResultURIs()
+		scores[i] = s.score(path, now)
+	}
+
+	sort.Stable(rankedResults{results: results, scores: scores})
+}
+
+// rankedResults sorts results by descending score.
+type rankedResults struct {
+	results []SearchResultResolver
+	scores  []float64
+}
+
+func (r rankedResults) Len() int           { return len(r.results) }
+func (r rankedResults) Less(i, j int) bool { return r.scores[i] > r.scores[j] }
+func (r rankedResults) Swap(i, j int) {
+	r.results[i], r.results[j] = r.results[j], r.results[i]
+	r.scores[i], r.scores[j] = r.scores[j], r.scores[i]
+}
+
+// fetchRankingSignals fetches the signals needed to order fileMatches by
+// sortBy. It returns signals for every file match, possibly incomplete ones if
+// fetching a signal failed or took too long.
+func fetchRankingSignals(ctx context.Context, fileMatches []*FileMatchResolver, sortBy query.SortBy, patternInfo *search.TextPatternInfo) map[*FileMatchResolver]*rankingSignals {
+	signals := make(map[*FileMatchResolver]*rankingSignals, len(fileMatches))
+	for _, fm := range fileMatches {
+		signals[fm] = &rankingSignals{}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, rankingTimeout)
+	defer cancel()
+
+	// Each of the goroutines below sets a different field of the signals.
+	var wg sync.WaitGroup
+	fetch := func(name string, f func(context.Context, []*FileMatchResolver, map[*FileMatchResolver]*rankingSignals) error) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := f(ctx, fileMatches, signals); err != nil && !isContextError(ctx, err) {
+				log15.Warn("Failed to fetch search ranking signal.", "signal", name, "error", err)
+			}
+		}()
+	}
+	if sortBy == query.SortByRelevance {
+		fetch("stars", fetchStarsSignal)
+		if patternInfo != nil && patternInfo.Pattern != "" && !patternInfo.IsStructuralPat {
+			fetch("definitions", func(ctx context.Context, fileMatches []*FileMatchResolver, signals map[*FileMatchResolver]*rankingSignals) error {
+				return fetchDefinitionsSignal(ctx, fileMatches, signals, patternInfo)
+			})
+		}
+	}
+	fetch("recency", fetchRecencySignal)
+	wg.Wait()
+
+	return signals
+}
+
+// fetchStarsSignal sets the stars of the repositories of the file matches.
+func fetchStarsSignal(ctx context.Context, fileMatches []*FileMatchResolver, signals map[*FileMatchResolver]*rankingSignals) error {
+	seen := make(map[api.RepoID]bool)
+	var ids []api.RepoID
+	for _, fm := range fileMatches {
+		if id := fm.Repo.repo.ID; !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	stars, err := db.Repos.GetStars(ctx, ids...)
+	if err != nil {
+		return err
+	}
+	for _, fm := range fileMatches {
+		signals[fm].stars = stars[fm.Repo.repo.ID]
+	}
+	return nil
+}
+
+// fetchDefinitionsSignal records which file matches define a symbol matching
+// the search pattern on one of their matched lines. Symbols are fetched from
+// the symbols service for the first rankingMaxSymbolRepos repository
+// revisions.
+func fetchDefinitionsSignal(ctx context.Context, fileMatches []*FileMatchResolver, signals map[*FileMatchResolver]*rankingSignals, patternInfo *search.TextPatternInfo) error {
+	type repoCommit struct {
+		repo   api.RepoName
+		commit api.CommitID
+	}
+	var order []repoCommit
+	byRepoCommit := make(map[repoCommit]map[string][]*FileMatchResolver)
+	for _, fm := range fileMatches {
+		if len(fm.JLineMatches) == 0 || fm.CommitID == "" {
+			continue
+		}
+		key := repoCommit{repo: fm.Repo.repo.Name, commit: fm.CommitID}
+		if _, ok := byRepoCommit[key]; !ok {
+			if len(order) == rankingMaxSymbolRepos {
+				continue
+			}
+			order = append(order, key)
+			byRepoCommit[key] = make(map[string][]*FileMatchResolver)
+		}
+		byRepoCommit[key][fm.JPath] = append(byRepoCommit[key][fm.JPath], fm)
+	}
+
+	var (
+		mu       sync.Mutex
+		firstErr error
+		wg       sync.WaitGroup
+	)
+	for _, key := range order {
+		filesByPath := byRepoCommit[key]
+		paths := make([]string, 0, len(filesByPath))
+		for path := range filesByPath {
+			paths = append(paths, "^"+regexp.QuoteMeta(path)+"$")
+		}
+		sort.Strings(paths)
+
+		wg.Add(1)
+		go func(key repoCommit) {
+			defer wg.Done()
+			symbols, err := backend.Symbols.ListTags(ctx, search.SymbolsParameters{
+				Repo:            key.repo,
+				CommitID:        key.commit,
+				Query:           patternInfo.Pattern,
+				IsRegExp:        patternInfo.IsRegExp,
+				IsCaseSensitive: patternInfo.IsCaseSensitive,
+				IncludePatterns: []string{strings.Join(paths, "|")},
+				First:           rankingMaxSymbolsOfRepo,
+			})
+			if err != nil {
+				mu.Lock()
+				if firstErr == nil {
+					firstErr = err
+				}
+				mu.Unlock()
+				return
+			}
+			for _, symbol := range symbols {
+				for _, fm := range filesByPath[symbol.Path] {
+					for _, lm := range fm.JLineMatches {
+						// Symbol lines are 1-based, line matches are 0-based.
+						if int(lm.JLineNumber) == symbol.Line-1 {
+							signals[fm].definesSymbol = true
+						}
+					}
+				}
+			}
+		}(key)
+	}
+	wg.Wait()
+	return firstErr
+}
+
+// fetchRecencySignal sets the date of the last commit that modified the
+// files of the file matches, with one request per repository and commit.
+func fetchRecencySignal(ctx context.Context, fileMatches []*FileMatchResolver, signals map[*FileMatchResolver]*rankingSignals) error {
+	type repoCommit struct {
+		repo   api.RepoName
+		commit api.CommitID
+	}
+	var (
+		keys  []repoCommit
+		byKey = make(map[repoCommit][]*FileMatchResolver)
+	)
+	for _, fm := range fileMatches {
+		if fm.CommitID == "" {
+			continue
+		}
+		key := repoCommit{repo: fm.Repo.repo.Name, commit: fm.CommitID}
+		if _, ok := byKey[key]; !ok {
+			keys = append(keys, key)
+		}
+		byKey[key] = append(byKey[key], fm)
+	}
+
+	var (
+		mu       sync.Mutex
+		firstErr error
+	)
+	run := parallel.NewRun(rankingRecencyParallel)
+	for _, key := range keys {
+		run.Acquire()
+		go func(key repoCommit) {
+			defer run.Release()
+			fms := byKey[key]
+			paths := make([]string, len(fms))
+			for i, fm := range fms {
+				paths[i] = fm.JPath
+			}
+			// The dates found before an error are still used.
+			modified, err := git.LastModified(ctx, gitserver.Repo{Name: key.repo}, key.commit, paths)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil && firstErr == nil {
+				firstErr = err
+			}
+			for _, fm := range fms {
+				signals[fm].lastModified = modified[fm.JPath]
+			}
+		}(key)
+	}
+	run.Wait()
+	return firstErr
+}
@@ -0,0 +1,214 @@
+package graphqlbackend
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/backend"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/db"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/types"
+	"github.com/sourcegraph/sourcegraph/internal/api"
+	"github.com/sourcegraph/sourcegraph/internal/gitserver"
+	"github.com/sourcegraph/sourcegraph/internal/search"
+	"github.com/sourcegraph/sourcegraph/internal/search/query"
+	"github.com/sourcegraph/sourcegraph/internal/symbols/protocol"
+	"github.com/sourcegraph/sourcegraph/internal/vcs/git"
+)
+
+func TestRankingSignalsScore(t *testing.T) {
+	now := time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC)
+	score := func(s rankingSignals, path string) float64 { return s.score(path, now) }
+
+	if got := score(rankingSignals{}, "main.go"); got != 0 {
+		t.Errorf("got score %v for a file without signals, want 0", got)
+	}
+	if !(score(rankingSignals{}, "main_test.go") < 0) {
+		t.Error("expected test files to be penalized")
+	}
+	if !(score(rankingSignals{}, "vendor/a/b.go") < score(rankingSignals{}, "test/b.go")) {
+		t.Error("expected vendored files to rank below test files")
+	}
+	if !(score(rankingSignals{stars: 1000}, "a.go") > score(rankingSignals{stars: 10}, "a.go")) {
+		t.Error("expected more stars to rank higher")
+	}
+	recent := rankingSignals{lastModified: now.Add(-24 * time.Hour)}
+	old := rankingSignals{lastModified: now.Add(-365 * 24 * time.Hour)}
+	if !(score(recent, "a.go") > score(old, "a.go")) {
+		t.Error("expected recently modified files to rank higher")
+	}
+	definition := rankingSignals{definesSymbol: true}
+	everythingElse := rankingSignals{stars: 100000, lastModified: now}
+	if !(score(definition, "a.go") > score(everythingElse, "a.go")) {
+		t.Error("expected symbol definitions to outrank the other signals")
+	}
+}
+
+func TestRankingPaths(t *testing.T) {
+	for path, want := range map[string]bool{
+		"foo_test.go":             true,
+		"src/foo.test.ts":         true,
+		"src/foo.spec.js":         true,
+		"test/foo.c":              true,
+		"a/__tests__/foo.js":      true,
+		"tests/test_foo.py":       true,
+		"pkg/testdata/x.json":     true,
+		"src/contest.go":          false,
+		"src/latest/foo.go":       false,
+		"cmd/frontend/testing.go": false,
+	} {
+		if got := testPathPattern.MatchString(path); got != want {
+			t.Errorf("testPathPattern.MatchString(%q) = %v, want %v", path, got, want)
+		}
+	}
+	for path, want := range map[string]bool{
+		"vendor/github.com/a/b.go": true,
+		"web/node_modules/x.js":    true,
+		"third_party/lib.c":        true,
+		"src/vendors.go":           false,
+	} {
+		if got := vendorPathPattern.MatchString(path); got != want {
+			t.Errorf("vendorPathPattern.MatchString(%q) = %v, want %v", path, got, want)
+		}
+	}
+}
+
+func TestRankResults(t *testing.T) {
+	popular := &types.Repo{ID: 1, Name: "github.com/acme/popular"}
+	obscure := &types.Repo{ID: 2, Name: "github.com/acme/obscure"}
+	fileMatch := func(repo *types.Repo, path string, lines ...int32) *FileMatchResolver {
+		fm := &FileMatchResolver{
+			JPath:    path,
+			Repo:     NewRepositoryResolver(repo),
+			CommitID: api.CommitID("c" + string(repo.Name)),
+			uri:      "git://" + string(repo.Name) + "#" + path,
+		}
+		for _, line := range lines {
+			fm.JLineMatches = append(fm.JLineMatches, &lineMatch{JLineNumber: line})
+		}
+		return fm
+	}
+
+	db.Mocks.Repos.GetStars = func(ctx context.Context, ids ...api.RepoID) (map[api.RepoID]int, error) {
+		return map[api.RepoID]int{popular.ID: 5000, obscure.ID: 3}, nil
+	}
+	backend.Mocks.Symbols.ListTags = func(ctx context.Context, args search.SymbolsParameters) ([]protocol.Symbol, error) {
+		if args.Query != "NewClient" {
+			t.Errorf("got symbols query %q, want %q", args.Query, "NewClient")
+		}
+		if args.Repo != obscure.Name {
+			return nil, nil
+		}
+		return []protocol.Symbol{
+			{Name: "NewClient", Path: "client.go", Line: 10},
+			// Not on a matched line, so not a definition of the match.
+			{Name: "NewClient", Path: "other.go", Line: 99},
+		}, nil
+	}
+	modified := map[string]time.Time{
+		"client.go":       time.Now().Add(-365 * 24 * time.Hour),
+		"other.go":        time.Now().Add(-400 * 24 * time.Hour),
+		"client_test.go":  time.Now().Add(-time.Hour),
+		"vendor/x/new.go": time.Now().Add(-2 * time.Hour),
+		"api.go":          time.Now().Add(-3 * time.Hour),
+	}
+	git.Mocks.LastModified = func(repo gitserver.Repo, commit api.CommitID, paths []string) (map[string]time.Time, error) {
+		if want := api.CommitID("c" + string(repo.Name)); commit != want {
+			t.Errorf("got commit %q, want %q", commit, want)
+		}
+		got := map[string]time.Time{}
+		for _, p := range paths {
+			if date, ok := modified[p]; ok {
+				got[p] = date
+			}
+		}
+		return got, nil
+	}
+	defer func() {
+		db.Mocks.Repos.GetStars = nil
+		backend.Mocks.Symbols = backend.MockSymbols{}
+		git.ResetMocks()
+	}()
+
+	newResults := func() []SearchResultResolver {
+		return []SearchResultResolver{
+			fileMatch(popular, "client_test.go", 3),
+			fileMatch(popular, "vendor/x/new.go", 4),
+			fileMatch(obscure, "client.go", 9),
+			fileMatch(obscure, "other.go", 1),
+			fileMatch(popular, "api.go", 7),
+		}
+	}
+	paths := func(results []SearchResultResolver) []string {
+		var paths []string
+		for _, result := range results {
+			repo, file := result.searchResultURIs()
+			paths = append(paths, repo+"/"+file)
+		}
+		return paths
+	}
+	patternInfo := &search.TextPatternInfo{Pattern: "NewClient", IsRegExp: true}
+
+	tests := []struct {
+		sortBy query.SortBy
+		want   []string
+	}{
+		{
+			sortBy: query.SortByPath,
+			want: []string{
+				"github.com/acme/obscure/client.go",
+				"github.com/acme/obscure/other.go",
+				"github.com/acme/popular/api.go",
+				"github.com/acme/popular/client_test.go",
+				"github.com/acme/popular/vendor/x/new.go",
+			},
+		},
+		{
+			sortBy: query.SortByRelevance,
+			want: []string{
+				"github.com/acme/obscure/client.go",
+				"github.com/acme/popular/api.go",
+				"github.com/acme/popular/client_test.go",
+				"github.com/acme/obscure/other.go",
+				"github.com/acme/popular/vendor/x/new.go",
+			},
+		},
+		{
+			sortBy: query.SortByRecency,
+			want: []string{
+				"github.com/acme/popular/client_test.go",
+				"github.com/acme/popular/vendor/x/new.go",
+				"github.com/acme/popular/api.go",
+				"github.com/acme/obscure/client.go",
+				"github.com/acme/obscure/other.go",
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(string(test.sortBy), func(t *testing.T) {
+			results := newResults()
+			rankResults(context.Background(), results, test.sortBy, patternInfo)
+			if diff := cmp.Diff(test.want, paths(results)); diff != "" {
+				t.Errorf("mismatch (-want, +got):\n%s", diff)
+			}
+		})
+	}
+	// The recency of every file is known, not only of the first files in
+	// path order.
+	t.Run("many files", func(t *testing.T) {
+		var results []SearchResultResolver
+		for i := 0; i < 100; i++ {
+			results = append(results, fileMatch(obscure, fmt.Sprintf("old%03d.go", i), 1))
+			modified[fmt.Sprintf("old%03d.go", i)] = time.Now().Add(-time.Duration(1000+i) * time.Hour)
+		}
+		results = append(results, fileMatch(obscure, "zz_new.go", 1))
+		modified["zz_new.go"] = time.Now()
+		rankResults(context.Background(), results, query.SortByRecency, patternInfo)
+		if got := paths(results)[0]; got != "github.com/acme/obscure/zz_new.go" {
+			t.Errorf("got %s first, want the most recently modified file", got)
+		}
+	})
+}
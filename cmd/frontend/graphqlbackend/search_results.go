@@ -583,7 +583,7 @@ func (r *searchResolver) evaluateLeaf(ctx context.Context) (*SearchResultsResolv
 	start := time.Now()
 	// If the request specifies stable:truthy, use pagination to return a stable ordering.
 	if r.query.BoolValue("stable") {
-		result, err := r.paginatedResults(ctx)
+		result, err := r.withoutStreaming(func() (*SearchResultsResolver, error) { return r.paginatedResults(ctx) })
 		if err != nil {
 			return nil, err
 		}
@@ -606,7 +606,7 @@ func (r *searchResolver) evaluateLeaf(ctx context.Context) (*SearchResultsResolv
 	// If the request is a paginated one, we handle it separately. See
 	// paginatedResults for more details.
 	if r.pagination != nil {
-		return r.paginatedResults(ctx)
+		return r.withoutStreaming(func() (*SearchResultsResolver, error) { return r.paginatedResults(ctx) })
 	}
 
 	rr, err := r.resultsWithTimeoutSuggestion(ctx)
@@ -660,7 +660,163 @@ func (r *searchResolver) evaluateLeaf(ctx context.Context) (*SearchResultsResolv
 	return rr, err
 }
 
-// union returns the union of two sets of search results and merges common search data.
+// And/or expressions combine search results at the level of the repository,
+// file or commit that they belong to:
+//
+//   - Results of the same kind are the same result if they are the same
+//     repository, file or commit. Their matches are merged.
+//   - A repository result of an expression that asks for repositories with
+//     type:repo corresponds to the file and commit results in the same
+//     repository, which are more specific. Other repository results, such as
+//     the repositories whose names match a pattern without type:, only
+//     correspond to the same repository, so that "foo and bar" doesn't
+//     return the files containing bar in the repositories named foo.
+//   - File and commit results correspond if they are in the same repository,
+//     since neither is more specific than the other.
+//
+// For example, intersecting the files that match one expression with the
+// commits that match another yields both the files and the commits of the
+// repositories that have results for each expression.
+
+// resultSetIndex indexes a set of search results by the repository, file and
+// commit that they belong to.
+type resultSetIndex struct {
+	repos       map[string]*RepositoryResolver
+	files       map[string]*FileMatchResolver
+	commits     map[string]*commitSearchResultResolver
+	fileRepos   map[string]bool // repositories with a file result
+	commitRepos map[string]bool // repositories with a commit result
+}
+
+func newResultSetIndex(results []SearchResultResolver) *resultSetIndex {
+	idx := &resultSetIndex{
+		repos:       map[string]*RepositoryResolver{},
+		files:       map[string]*FileMatchResolver{},
+		commits:     map[string]*commitSearchResultResolver{},
+		fileRepos:   map[string]bool{},
+		commitRepos: map[string]bool{},
+	}
+	for _, result := range results {
+		if repo, ok := result.ToRepository(); ok {
+			idx.repos[repo.Name()] = repo
+		} else if fm, ok := result.ToFileMatch(); ok {
+			idx.files[fm.uri] = fm
+			idx.fileRepos[fm.Repo.Name()] = true
+		} else if c, ok := result.ToCommitSearchResult(); ok {
+			idx.commits[commitResultKey(c)] = c
+			idx.commitRepos[c.commit.repoResolver.Name()] = true
+		}
+	}
+	return idx
+}
+
+func commitResultKey(c *commitSearchResultResolver) string {
+	return c.commit.repoResolver.Name() + "@" + string(c.commit.oid)
+}
+
+// corresponds reports whether result corresponds to a result in idx, by the
+// rules described above. resultRepos and idxRepos report whether the
+// repository results of result and idx were asked for with type:repo. Results
+// of other kinds never correspond.
+func (idx *resultSetIndex) corresponds(result SearchResultResolver, resultRepos, idxRepos bool) bool {
+	if repo, ok := result.ToRepository(); ok {
+		name := repo.Name()
+		return idx.repos[name] != nil || resultRepos && (idx.fileRepos[name] || idx.commitRepos[name])
+	}
+	if fm, ok := result.ToFileMatch(); ok {
+		name := fm.Repo.Name()
+		return idx.files[fm.uri] != nil || idx.commitRepos[name] || idxRepos && idx.repos[name] != nil
+	}
+	if c, ok := result.ToCommitSearchResult(); ok {
+		name := c.commit.repoResolver.Name()
+		return idx.commits[commitResultKey(c)] != nil || idx.fileRepos[name] || idxRepos && idx.repos[name] != nil
+	}
+	return false
+}
+
+// same returns the result in idx which is the same repository, file or commit
+// as result, or nil.
+func (idx *resultSetIndex) same(result SearchResultResolver) SearchResultResolver {
+	if repo, ok := result.ToRepository(); ok {
+		if r := idx.repos[repo.Name()]; r != nil {
+			return r
+		}
+	} else if fm, ok := result.ToFileMatch(); ok {
+		if r := idx.files[fm.uri]; r != nil {
+			return r
+		}
+	} else if c, ok := result.ToCommitSearchResult(); ok {
+		if r := idx.commits[commitResultKey(c)]; r != nil {
+			return r
+		}
+	}
+	return nil
+}
+
+// mergeResult merges the matches of src into dst, which is the same
+// repository, file or commit.
+func mergeResult(dst, src SearchResultResolver) {
+	if dstFile, ok := dst.ToFileMatch(); ok {
+		srcFile, _ := src.ToFileMatch()
+		var duplicates int
+		dstFile.JLineMatches, duplicates = mergeLineMatches(dstFile.JLineMatches, srcFile.JLineMatches)
+		// Matches in both files are only counted once.
+		dstFile.MatchCount += srcFile.MatchCount - duplicates
+		dstFile.symbols = append(dstFile.symbols, srcFile.symbols...)
+		dstFile.JLimitHit = dstFile.JLimitHit || srcFile.JLimitHit
+	} else if dstCommit, ok := dst.ToCommitSearchResult(); ok {
+		srcCommit, _ := src.ToCommitSearchResult()
+		dstCommit.matches = append(dstCommit.matches, srcCommit.matches...)
+		if dstCommit.diffPreview == nil {
+			dstCommit.diffPreview = srcCommit.diffPreview
+		}
+		if dstCommit.messagePreview == nil {
+			dstCommit.messagePreview = srcCommit.messagePreview
+		}
+	}
+}
+
+// mergeLineMatches returns the line matches of a file in both a and b, with
+// the matches on the same line merged into one line match, and the number of
+// matches of b which are also in a.
+func mergeLineMatches(a, b []*lineMatch) (merged []*lineMatch, duplicates int) {
+	if len(b) == 0 {
+		return a, 0
+	}
+	byLine := make(map[int32]*lineMatch, len(a)+len(b))
+	merged = make([]*lineMatch, 0, len(a)+len(b))
+	for _, lineMatches := range [][]*lineMatch{a, b} {
+		for _, lm := range lineMatches {
+			existing, ok := byLine[lm.JLineNumber]
+			if !ok {
+				byLine[lm.JLineNumber] = lm
+				merged = append(merged, lm)
+				continue
+			}
+		offsets:
+			for _, offset := range lm.JOffsetAndLengths {
+				for _, o := range existing.JOffsetAndLengths {
+					if o == offset {
+						duplicates++
+						continue offsets
+					}
+				}
+				existing.JOffsetAndLengths = append(existing.JOffsetAndLengths, offset)
+			}
+			existing.JCaptureGroups = append(existing.JCaptureGroups, lm.JCaptureGroups...)
+			existing.JLimitHit = existing.JLimitHit || lm.JLimitHit
+		}
+	}
+	sort.Slice(merged, func(i, j int) bool { return merged[i].JLineNumber < merged[j].JLineNumber })
+	for _, lm := range merged {
+		sort.Slice(lm.JOffsetAndLengths, func(i, j int) bool { return lm.JOffsetAndLengths[i][0] < lm.JOffsetAndLengths[j][0] })
+	}
+	return merged, duplicates
+}
+
+// union returns the union of two sets of search results and merges common
+// search data. Results which are the same repository, file or commit are
+// merged into one result.
 func union(left, right *SearchResultsResolver) *SearchResultsResolver {
 	if right == nil {
 		return left
@@ -669,7 +825,14 @@ func union(left, right *SearchResultsResolver) *SearchResultsResolver {
 		return right
 	}
 	if left.SearchResults != nil && right.SearchResults != nil {
-		left.SearchResults = append(left.SearchResults, right.SearchResults...)
+		leftIndex := newResultSetIndex(left.SearchResults)
+		for _, r := range right.SearchResults {
+			if l := leftIndex.same(r); l != nil {
+				mergeResult(l, r)
+				continue
+			}
+			left.SearchResults = append(left.SearchResults, r)
+		}
 		// merge common search data.
 		left.searchResultsCommon.update(right.searchResultsCommon)
 		return left
@@ -679,44 +842,66 @@ func union(left, right *SearchResultsResolver) *SearchResultsResolver {
 	return left
 }
 
-// intersect returns the intersection of two sets of search result content
-// matches, based on whether a single file path contains content matches in both
-// sets.
-func intersect(left, right *SearchResultsResolver) (*SearchResultsResolver, error) {
+// intersect returns the intersection of two sets of search results: the
+// results of each set which correspond to a result in the other set, by the
+// rules described above. leftRepos and rightRepos report whether the
+// repository results of left and right were asked for with type:repo. Results
+// which are the same repository, file or commit are merged into one result.
+// Repository results are left out if the intersection contains more specific
+// file or commit results in the same repository.
+func intersect(left, right *SearchResultsResolver, leftRepos, rightRepos bool) (*SearchResultsResolver, error) {
 	if left == nil || right == nil {
 		return nil, nil
 	}
 
-	rFileMatches := make(map[string]*FileMatchResolver)
+	leftIndex := newResultSetIndex(left.SearchResults)
+	rightIndex := newResultSetIndex(right.SearchResults)
 
-	for _, r := range right.SearchResults {
-		if fileMatch, ok := r.ToFileMatch(); ok {
-			rFileMatches[fileMatch.uri] = fileMatch
+	var merged []SearchResultResolver
+	for _, l := range left.SearchResults {
+		if !rightIndex.corresponds(l, leftRepos, rightRepos) {
+			continue
+		}
+		if r := rightIndex.same(l); r != nil {
+			mergeResult(l, r)
 		}
+		merged = append(merged, l)
 	}
-
-	var merged []SearchResultResolver
-	for _, ltmp := range left.SearchResults {
-		ltmpFileMatch, ok := ltmp.ToFileMatch()
-		if !ok {
+	for _, r := range right.SearchResults {
+		if leftIndex.same(r) != nil || !leftIndex.corresponds(r, rightRepos, leftRepos) {
 			continue
 		}
+		merged = append(merged, r)
+	}
 
-		rtmpFileMatch := rFileMatches[ltmpFileMatch.uri]
-		if rtmpFileMatch == nil {
+	// Leave out repository results which are subsumed by file or commit
+	// results in the same repository.
+	mergedIndex := newResultSetIndex(merged)
+	results := merged[:0]
+	for _, result := range merged {
+		if repo, ok := result.ToRepository(); ok && (mergedIndex.fileRepos[repo.Name()] || mergedIndex.commitRepos[repo.Name()]) {
 			continue
 		}
-
-		ltmpFileMatch.JLineMatches = append(ltmpFileMatch.JLineMatches, rtmpFileMatch.JLineMatches...)
-		merged = append(merged, ltmp)
+		results = append(results, result)
 	}
-	left.SearchResults = merged
+
+	left.SearchResults = results
 	left.searchResultsCommon.update(right.searchResultsCommon)
 	// for intersect we want the newly computed intersection size.
-	left.searchResultsCommon.resultCount = int32(len(merged))
+	left.searchResultsCommon.resultCount = int32(len(results))
 	return left, nil
 }
 
+// asksForRepos reports whether the search of an and/or operand with the given
+// scope parameters explicitly asks for repository results with type:repo.
+func asksForRepos(scopeParameters []query.Node, operand query.Node) bool {
+	asks := false
+	query.VisitField(append([]query.Node{operand}, scopeParameters...), query.FieldType, func(value string, negated bool) {
+		asks = asks || (value == "repo" && !negated)
+	})
+	return asks
+}
+
 // evaluateAnd performs set intersection on result sets. It collects results for
 // all expressions that are ANDed together by searching for each subexpression
 // and then intersects those results that are in the same repo/file path. To
@@ -747,14 +932,21 @@ func (r *searchResolver) evaluateAnd(ctx context.Context, scopeParameters []quer
 		// Override the value of count, if specified.
 		want, _ = strconv.Atoi(countStr) // Invariant: count is validated.
 	} else {
+		if r.resultLimit > 0 {
+			// Statistics are computed over (almost) every result.
+			want = int(r.resultLimit)
+		}
 		scopeParameters = append(scopeParameters, query.Parameter{
 			Field: "count",
 			Value: strconv.FormatInt(int64(want), 10),
 		})
 	}
 
+	maxResultsForRetry := 20000 // When we retry, cap the max search results we request for each expression if search continues to not be exhaustive.
 	tryCount := want * 1000     // Opportunistic approximation for the number of results to get for an intersection.
-	maxResultsForRetry := 20000 // When we retry, cap the max search results we request for each expression if search continues to not be exhaustive. Alert if exceeded.
+	if tryCount > maxResultsForRetry {
+		tryCount = maxResultsForRetry
+	}
 
 	var exhausted bool
 	for {
@@ -770,6 +962,7 @@ func (r *searchResolver) evaluateAnd(ctx context.Context, scopeParameters []quer
 			return nil, err
 		}
 		exhausted = !result.limitHit
+		resultRepos := asksForRepos(scopeParameters, operands[0])
 		for _, term := range operands[1:] {
 			new, err = r.evaluatePatternExpression(ctx, scopeParameters, term)
 			if err != nil {
@@ -777,10 +970,12 @@ func (r *searchResolver) evaluateAnd(ctx context.Context, scopeParameters []quer
 			}
 			if new != nil {
 				exhausted = exhausted && !new.limitHit
-				result, err = intersect(result, new)
+				newRepos := asksForRepos(scopeParameters, term)
+				result, err = intersect(result, new, resultRepos, newRepos)
 				if err != nil {
 					return nil, err
 				}
+				resultRepos = resultRepos || newRepos
 			}
 		}
 		if exhausted {
@@ -789,12 +984,19 @@ func (r *searchResolver) evaluateAnd(ctx context.Context, scopeParameters []quer
 		if result.searchResultsCommon.resultCount >= int32(want) {
 			break
 		}
+		if tryCount == maxResultsForRetry {
+			// We've capped out what we're willing to do. Return the
+			// results found so far, and alert if there are none.
+			if len(result.SearchResults) == 0 {
+				result.alert = alertForCappedAndExpression()
+			}
+			break
+		}
 		// If the result size set is not big enough, and we haven't
-		// exhausted search on all expressions, double the tryCount and search more.
+		// exhausted search on all expressions, search more.
 		tryCount *= tryCount
 		if tryCount > maxResultsForRetry {
-			// We've capped out what we're willing to do, throw alert.
-			return &SearchResultsResolver{alert: alertForCappedAndExpression()}, nil
+			tryCount = maxResultsForRetry
 		}
 	}
 	result.limitHit = !exhausted
@@ -817,6 +1019,9 @@ func (r *searchResolver) evaluateOr(ctx context.Context, scopeParameters []query
 	})
 	if countStr != "" {
 		wantCount, _ = strconv.Atoi(countStr) // Invariant: count is validated.
+	} else if r.resultLimit > 0 {
+		// Statistics are computed over (almost) every result.
+		wantCount = int(r.resultLimit)
 	}
 
 	result, err := r.evaluatePatternExpression(ctx, scopeParameters, operands[0])
@@ -826,6 +1031,7 @@ func (r *searchResolver) evaluateOr(ctx context.Context, scopeParameters []query
 	if result.searchResultsCommon.resultCount > int32(wantCount) {
 		result.SearchResults = result.SearchResults[:wantCount]
 		result.searchResultsCommon.resultCount = int32(wantCount)
+		result.searchResultsCommon.limitHit = true
 		return result, nil
 	}
 	var new *SearchResultsResolver
@@ -839,6 +1045,7 @@ func (r *searchResolver) evaluateOr(ctx context.Context, scopeParameters []query
 			if result.searchResultsCommon.resultCount > int32(wantCount) {
 				result.SearchResults = result.SearchResults[:wantCount]
 				result.searchResultsCommon.resultCount = int32(wantCount)
+				result.searchResultsCommon.limitHit = true
 				return result, nil
 			}
 		}
@@ -867,6 +1074,17 @@ func (r *searchResolver) evaluateOperator(ctx context.Context, scopeParameters [
 func (r *searchResolver) evaluatePatternExpression(ctx context.Context, scopeParameters []query.Node, node query.Node) (*SearchResultsResolver, error) {
 	switch term := node.(type) {
 	case query.Operator:
+		if term.Kind == query.And && containsParameter(term.Operands) {
+			// An operand of an or-expression with its own scope
+			// parameters, such as (type:file foo) in
+			// "(type:file foo) or (type:commit bar)".
+			parameters, pattern, err := query.PartitionSearchPattern(term.Operands)
+			if err != nil {
+				return nil, err
+			}
+			scoped := append(append([]query.Node{}, scopeParameters...), parameters...)
+			return r.evaluatePatternExpression(ctx, scoped, pattern)
+		}
 		if term.Kind == query.And || term.Kind == query.Or {
 			return r.evaluateOperator(ctx, scopeParameters, term)
 		} else if term.Kind == query.Concat {
@@ -875,7 +1093,13 @@ func (r *searchResolver) evaluatePatternExpression(ctx context.Context, scopePar
 			return r.evaluateLeaf(ctx)
 		}
 	case query.Pattern:
-		q := append(scopeParameters, term)
+		var leaf query.Node = term
+		if term.Negated {
+			// A negated pattern is searched for as a negated content
+			// parameter, which matches files that do not contain it.
+			leaf = query.NegatedPatternToParameter(term)
+		}
+		q := append(scopeParameters, leaf)
 		r.query.(*query.AndOrQuery).Query = q
 		return r.evaluateLeaf(ctx)
 	case query.Parameter:
@@ -886,6 +1110,15 @@ func (r *searchResolver) evaluatePatternExpression(ctx context.Context, scopePar
 	return nil, fmt.Errorf("unrecognized type %s in evaluatePatternExpression", reflect.TypeOf(node).String())
 }
 
+func containsParameter(nodes []query.Node) bool {
+	for _, node := range nodes {
+		if _, ok := node.(query.Parameter); ok {
+			return true
+		}
+	}
+	return false
+}
+
 // evaluate evaluates all expressions of a search query.
 func (r *searchResolver) evaluate(ctx context.Context, q []query.Node) (*SearchResultsResolver, error) {
 	scopeParameters, pattern, err := query.PartitionSearchPattern(q)
@@ -896,15 +1129,35 @@ func (r *searchResolver) evaluate(ctx context.Context, q []query.Node) (*SearchR
 		r.query.(*query.AndOrQuery).Query = scopeParameters
 		return r.evaluateLeaf(ctx)
 	}
-	result, err := r.evaluatePatternExpression(ctx, scopeParameters, pattern)
-	if err != nil {
-		return nil, err
-	}
-	sortResults(result.SearchResults)
-	return result, nil
+	// Results of and/or expressions are only known once every operand has
+	// been evaluated, so they are sent on the stream all at once.
+	return r.withoutStreaming(func() (*SearchResultsResolver, error) {
+		result, err := r.evaluatePatternExpression(ctx, scopeParameters, pattern)
+		if err != nil {
+			return nil, err
+		}
+		// The operands of the expression have different patterns, so symbol
+		// definitions are not used to rank the combined results.
+		rankResults(ctx, result.SearchResults, r.sortBy(), nil)
+		return result, nil
+	})
 }
 
 func (r *searchResolver) Results(ctx context.Context) (*SearchResultsResolver, error) {
+	defer r.searchAdmission().hold()()
+	rr, err := r.results(ctx)
+	if rr != nil {
+		if sp := r.selectPath(); sp != nil {
+			rr.SearchResults = newResultSelector(sp).apply(rr.SearchResults)
+		}
+	}
+	if err == nil && rr != nil {
+		r.recordSearchHistory(ctx, rr.MatchCount())
+	}
+	return rr, err
+}
+
+func (r *searchResolver) results(ctx context.Context) (*SearchResultsResolver, error) {
 	switch q := r.query.(type) {
 	case *query.OrdinaryQuery:
 		return r.evaluateLeaf(ctx)
@@ -996,6 +1249,12 @@ type searchResultsStats struct {
 	once   sync.Once
 	srs    *SearchResultsResolver
 	srsErr error
+
+	// The results of the search without the default result limit, used for
+	// aggregations.
+	exhaustiveOnce   sync.Once
+	exhaustiveSrs    *SearchResultsResolver
+	exhaustiveSrsErr error
 }
 
 func (srs *searchResultsStats) ApproximateResultCount() string { return srs.JApproximateResultCount }
@@ -1039,6 +1298,7 @@ func (r *searchResolver) Stats(ctx context.Context) (stats *searchResultsStats,
 
 	// Calculate value from scratch.
 	searchResultsStatsCounter.WithLabelValues("miss").Inc()
+	defer r.searchAdmission().hold()()
 	attempts := 0
 	var v *SearchResultsResolver
 	for {
@@ -1204,10 +1464,20 @@ func getPatternInfo(q query.QueryInfo, opts *getPatternInfoOptions) (*search.Tex
 
 	languages, _ := q.StringValues(query.FieldLang)
 
+	// Handle -content: (and NOT pattern) filters, which match files that
+	// do not contain the pattern.
+	_, negatedContent := q.StringValues(query.FieldContent)
+	isNegated := len(negatedContent) > 0 && !opts.forceFileSearch
+	if isNegated && len(q.Values(query.FieldDefault)) > 0 {
+		return nil, errors.New("a negated content pattern cannot be combined with other search patterns; use AND NOT in an and/or query instead")
+	}
+
 	patternInfo := &search.TextPatternInfo{
 		IsRegExp:                     isRegExp,
 		IsStructuralPat:              isStructuralPat,
 		IsCaseSensitive:              q.IsCaseSensitive(),
+		IsMultiline:                  isRegExp && q.IsMultiline(),
+		IsNegated:                    isNegated,
 		FileMatchLimit:               opts.fileMatchLimit,
 		Pattern:                      pattern,
 		IncludePatterns:              includePatterns,
@@ -1221,6 +1491,18 @@ func getPatternInfo(q query.QueryInfo, opts *getPatternInfoOptions) (*search.Tex
 	if len(excludePatterns) > 0 {
 		patternInfo.ExcludePattern = unionRegExps(excludePatterns)
 	}
+	if isRegExp {
+		// Literal patterns are quoted, so only regexp patterns can have named
+		// capture groups.
+		if re, err := regexp.Compile(pattern); err == nil {
+			for _, name := range re.SubexpNames() {
+				if name != "" {
+					patternInfo.IncludeCaptureGroups = true
+					break
+				}
+			}
+		}
+	}
 	return patternInfo, nil
 }
 
@@ -1296,9 +1578,24 @@ func (r *searchResolver) determineResultTypes(args search.TextParameters, forceO
 	} else {
 		resultTypes, _ = r.query.StringValues(query.FieldType)
 		if len(resultTypes) == 0 {
-			resultTypes = []string{"file", "path", "repo"}
+			// Only search for the kinds of results which can be selected.
+			switch r.selectPath().Root() {
+			case query.SelectContent:
+				resultTypes = []string{"file"}
+			case query.SelectSymbol:
+				resultTypes = []string{"symbol"}
+			case query.SelectCommit:
+				resultTypes = []string{"diff"}
+			default:
+				resultTypes = []string{"file", "path", "repo"}
+			}
 		}
 	}
+	if args.PatternInfo.IsNegated {
+		// Negated content patterns only match files; there are no
+		// path or repository results for the absence of content.
+		resultTypes = []string{"file"}
+	}
 	for _, resultType := range resultTypes {
 		if resultType == "file" {
 			args.PatternInfo.PatternMatchesContent = true
@@ -1430,6 +1727,12 @@ func (r *searchResolver) doResults(ctx context.Context, forceOnlyResultType stri
 	resultTypes := r.determineResultTypes(args, forceOnlyResultType)
 	tr.LazyPrintf("resultTypes: %v", resultTypes)
 
+	admission := r.searchAdmission()
+	defer admission.hold()()
+	if alert := admission.admit(ctx, &args, resultTypes); alert != nil {
+		return &SearchResultsResolver{alert: alert, start: start}, nil
+	}
+
 	var (
 		requiredWg sync.WaitGroup
 		optionalWg sync.WaitGroup
@@ -1500,6 +1803,7 @@ func (r *searchResolver) doResults(ctx context.Context, forceOnlyResultType stri
 					common.update(*repoCommon)
 					commonMu.Unlock()
 				}
+				r.sendResults(repoResults, repoCommon)
 			})
 		case "symbol":
 			wg := waitGroup(len(resultTypes) == 1)
@@ -1514,6 +1818,7 @@ func (r *searchResolver) doResults(ctx context.Context, forceOnlyResultType stri
 					multiErr = multierror.Append(multiErr, errors.Wrap(err, "symbol search failed"))
 					multiErrMu.Unlock()
 				}
+				var newResults []SearchResultResolver
 				for _, symbolFileMatch := range symbolFileMatches {
 					key := symbolFileMatch.uri
 					fileMatchesMu.Lock()
@@ -1521,6 +1826,7 @@ func (r *searchResolver) doResults(ctx context.Context, forceOnlyResultType stri
 						m.symbols = symbolFileMatch.symbols
 					} else {
 						fileMatches[key] = symbolFileMatch
+						newResults = append(newResults, symbolFileMatch)
 						resultsMu.Lock()
 						results = append(results, symbolFileMatch)
 						resultsMu.Unlock()
@@ -1532,6 +1838,7 @@ func (r *searchResolver) doResults(ctx context.Context, forceOnlyResultType stri
 					common.update(*symbolsCommon)
 					commonMu.Unlock()
 				}
+				r.sendResults(newResults, symbolsCommon)
 			})
 		case "file", "path":
 			if searchedFileContentsOrPaths {
@@ -1567,6 +1874,7 @@ func (r *searchResolver) doResults(ctx context.Context, forceOnlyResultType stri
 						fileCommon.limitHit = false // Ensure we don't display "Show more".
 					}
 				}
+				var newResults []SearchResultResolver
 				for _, r := range fileResults {
 					key := r.uri
 					fileMatchesMu.Lock()
@@ -1577,6 +1885,7 @@ func (r *searchResolver) doResults(ctx context.Context, forceOnlyResultType stri
 						m.JLineMatches = r.JLineMatches
 					} else {
 						fileMatches[key] = r
+						newResults = append(newResults, r)
 						resultsMu.Lock()
 						results = append(results, r)
 						resultsMu.Unlock()
@@ -1588,6 +1897,7 @@ func (r *searchResolver) doResults(ctx context.Context, forceOnlyResultType stri
 					common.update(*fileCommon)
 					commonMu.Unlock()
 				}
+				r.sendResults(newResults, fileCommon)
 			})
 		case "diff":
 			wg := waitGroup(len(resultTypes) == 1)
@@ -1627,6 +1937,7 @@ func (r *searchResolver) doResults(ctx context.Context, forceOnlyResultType stri
 					common.update(*diffCommon)
 					commonMu.Unlock()
 				}
+				r.sendResults(diffResults, diffCommon)
 			})
 		case "commit":
 			wg := waitGroup(len(resultTypes) == 1)
@@ -1667,6 +1978,7 @@ func (r *searchResolver) doResults(ctx context.Context, forceOnlyResultType stri
 					common.update(*commitCommon)
 					commonMu.Unlock()
 				}
+				r.sendResults(commitResults, commitCommon)
 			})
 		case "codemod":
 			wg := waitGroup(true)
@@ -1691,6 +2003,7 @@ func (r *searchResolver) doResults(ctx context.Context, forceOnlyResultType stri
 					common.update(*codemodCommon)
 					commonMu.Unlock()
 				}
+				r.sendResults(codemodResults, codemodCommon)
 			})
 		}
 	}
@@ -1738,7 +2051,7 @@ func (r *searchResolver) doResults(ctx context.Context, forceOnlyResultType stri
 		multiErr = nil
 	}
 
-	sortResults(results)
+	rankResults(ctx, results, r.sortBy(), args.PatternInfo)
 
 	resultsResolver := SearchResultsResolver{
 		start:               start,
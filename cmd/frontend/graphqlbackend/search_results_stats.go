@@ -2,6 +2,10 @@ package graphqlbackend
 
 import (
 	"context"
+
+	"github.com/inconshreveable/log15"
+
+	"github.com/sourcegraph/sourcegraph/internal/search/query"
 )
 
 func (srs *searchResultsStats) getResults(ctx context.Context) (*SearchResultsResolver, error) {
@@ -10,3 +14,44 @@ func (srs *searchResultsStats) getResults(ctx context.Context) (*SearchResultsRe
 	})
 	return srs.srs, srs.srsErr
 }
+
+// maxSearchResultsForStats is the result limit used when statistics must be
+// computed over every result rather than the first page of results.
+const maxSearchResultsForStats = 100000
+
+// getExhaustiveResults is like getResults, except that the search is not
+// limited to the default number of results. Like Results, it evaluates
+// and/or expressions and select:.
+func (srs *searchResultsStats) getExhaustiveResults(ctx context.Context) (*SearchResultsResolver, error) {
+	srs.exhaustiveOnce.Do(func() {
+		srs.exhaustiveSrs, srs.exhaustiveSrsErr = srs.searchExhaustively(ctx)
+	})
+	return srs.exhaustiveSrs, srs.exhaustiveSrsErr
+}
+
+func (srs *searchResultsStats) searchExhaustively(ctx context.Context) (*SearchResultsResolver, error) {
+	// The query is processed again, because evaluating and/or expressions
+	// replaces the query of the resolver that evaluates them. The searches
+	// are admitted on their own, and hold their own search slot.
+	var opts query.ProcessOptions
+	if query.MayContainGlobs(srs.sr.originalQuery) {
+		var err error
+		if opts, err = processOptions(ctx); err != nil {
+			log15.Warn("loading viewer settings for search statistics, processing query without globbing", "error", err)
+		}
+	}
+	q, err := processQuery(srs.sr.originalQuery, srs.sr.patternType, opts)
+	if err != nil {
+		return nil, err
+	}
+	sr := &searchResolver{
+		query:          q,
+		originalQuery:  srs.sr.originalQuery,
+		versionContext: srs.sr.versionContext,
+		patternType:    srs.sr.patternType,
+		zoekt:          srs.sr.zoekt,
+		searcherURLs:   srs.sr.searcherURLs,
+		resultLimit:    maxSearchResultsForStats,
+	}
+	return sr.Results(ctx)
+}
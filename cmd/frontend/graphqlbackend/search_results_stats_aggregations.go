@@ -0,0 +1,149 @@
+package graphqlbackend
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/internal/inventory"
+	"github.com/sourcegraph/sourcegraph/internal/search/query"
+)
+
+// The values of the SearchAggregationGroupBy GraphQL enum.
+const (
+	aggregationGroupByRepo         = "REPO"
+	aggregationGroupByPath         = "PATH"
+	aggregationGroupByLanguage     = "LANGUAGE"
+	aggregationGroupByAuthor       = "AUTHOR"
+	aggregationGroupByCaptureGroup = "CAPTURE_GROUP"
+)
+
+type searchAggregationResolver struct {
+	value string
+	count int32
+}
+
+func (r *searchAggregationResolver) Value() string { return r.value }
+func (r *searchAggregationResolver) Count() int32  { return r.count }
+
+type searchAggregationsResolver struct {
+	nodes    []*searchAggregationResolver
+	limitHit bool
+}
+
+func (r *searchAggregationsResolver) Nodes() []*searchAggregationResolver { return r.nodes }
+func (r *searchAggregationsResolver) LimitHit() bool                      { return r.limitHit }
+
+func (srs *searchResultsStats) Aggregations(ctx context.Context, args *struct{ GroupBy string }) (*searchAggregationsResolver, error) {
+	var pattern *regexp.Regexp
+	if args.GroupBy == aggregationGroupByCaptureGroup {
+		var err error
+		pattern, err = srs.sr.captureGroupPattern()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	srr, err := srs.getExhaustiveResults(ctx)
+	if err != nil {
+		return nil, err
+	}
+	nodes, err := aggregateSearchResults(srr.SearchResults, args.GroupBy, pattern)
+	if err != nil {
+		return nil, err
+	}
+	return &searchAggregationsResolver{nodes: nodes, limitHit: srr.LimitHit()}, nil
+}
+
+// captureGroupPattern returns the search pattern of the query as a regexp
+// with at least one capture group.
+func (r *searchResolver) captureGroupPattern() (*regexp.Regexp, error) {
+	if r.patternType != query.SearchTypeRegex {
+		return nil, errors.New("grouping by capture group requires a regexp search pattern")
+	}
+	p, err := r.getPatternInfo(nil)
+	if err != nil {
+		return nil, err
+	}
+	expr := p.Pattern
+	if !p.IsCaseSensitive {
+		expr = "(?i:" + expr + ")"
+	}
+	pattern, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	if pattern.NumSubexp() == 0 {
+		return nil, errors.New("grouping by capture group requires a search pattern with a capture group, such as `foo\\((\\w+)\\)`")
+	}
+	return pattern, nil
+}
+
+// aggregateSearchResults counts the matches in results by the value of
+// groupBy for each match. Matches which have no value for groupBy, such as
+// file matches when grouping by commit author, are not counted. If groupBy is
+// CAPTURE_GROUP, each line match is counted by the text matched by the first
+// capture group of pattern.
+//
+// The aggregations are ordered by descending count.
+func aggregateSearchResults(results []SearchResultResolver, groupBy string, pattern *regexp.Regexp) ([]*searchAggregationResolver, error) {
+	counts := map[string]int32{}
+	for _, result := range results {
+		switch groupBy {
+		case aggregationGroupByRepo:
+			if fm, ok := result.ToFileMatch(); ok {
+				counts[fm.Repo.Name()] += result.resultCount()
+			} else if repo, ok := result.ToRepository(); ok {
+				counts[repo.Name()] += result.resultCount()
+			} else if c, ok := result.ToCommitSearchResult(); ok {
+				counts[c.commit.repoResolver.Name()] += result.resultCount()
+			}
+
+		case aggregationGroupByPath:
+			if fm, ok := result.ToFileMatch(); ok {
+				counts[fm.JPath] += result.resultCount()
+			}
+
+		case aggregationGroupByLanguage:
+			if fm, ok := result.ToFileMatch(); ok {
+				if language, _ := inventory.GetLanguageByFilename(fm.JPath); language != "" {
+					counts[language] += result.resultCount()
+				}
+			}
+
+		case aggregationGroupByAuthor:
+			if c, ok := result.ToCommitSearchResult(); ok {
+				person := c.commit.author.person
+				counts[fmt.Sprintf("%s <%s>", person.name, person.email)] += result.resultCount()
+			}
+
+		case aggregationGroupByCaptureGroup:
+			if fm, ok := result.ToFileMatch(); ok {
+				for _, lm := range fm.JLineMatches {
+					for _, m := range pattern.FindAllStringSubmatch(lm.JPreview, -1) {
+						if m[1] != "" {
+							counts[m[1]]++
+						}
+					}
+				}
+			}
+
+		default:
+			return nil, fmt.Errorf("unsupported groupBy value %q", groupBy)
+		}
+	}
+
+	aggregations := make([]*searchAggregationResolver, 0, len(counts))
+	for value, count := range counts {
+		aggregations = append(aggregations, &searchAggregationResolver{value: value, count: count})
+	}
+	sort.Slice(aggregations, func(i, j int) bool {
+		if aggregations[i].count != aggregations[j].count {
+			return aggregations[i].count > aggregations[j].count
+		}
+		return aggregations[i].value < aggregations[j].value
+	})
+	return aggregations, nil
+}
@@ -0,0 +1,223 @@
+package graphqlbackend
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/db"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/types"
+	"github.com/sourcegraph/sourcegraph/internal/conf"
+	"github.com/sourcegraph/sourcegraph/internal/search"
+	"github.com/sourcegraph/sourcegraph/schema"
+)
+
+func TestAggregateSearchResults(t *testing.T) {
+	foo := &RepositoryResolver{repo: &types.Repo{ID: 1, Name: "github.com/foo/foo"}}
+	bar := &RepositoryResolver{repo: &types.Repo{ID: 2, Name: "github.com/foo/bar"}}
+	fileMatch := func(repo *RepositoryResolver, path string, lines ...string) *FileMatchResolver {
+		fm := &FileMatchResolver{JPath: path, Repo: repo, MatchCount: len(lines)}
+		for _, line := range lines {
+			fm.JLineMatches = append(fm.JLineMatches, &lineMatch{JPreview: line})
+		}
+		return fm
+	}
+	commit := func(repo *RepositoryResolver, name, email string) *commitSearchResultResolver {
+		return &commitSearchResultResolver{commit: &GitCommitResolver{
+			repoResolver: repo,
+			author:       signatureResolver{person: &personResolver{name: name, email: email}},
+		}}
+	}
+
+	results := []SearchResultResolver{
+		fileMatch(foo, "a.go", "errors.New(x)", "fmt.Errorf(y) fmt.Errorf(z)"),
+		fileMatch(foo, "b.py", "errors.New(x)"),
+		fileMatch(bar, "a.go", "errors.New(y)"),
+		bar,
+		commit(bar, "Alice", "alice@example.com"),
+		commit(foo, "Alice", "alice@example.com"),
+		commit(foo, "Bob", "bob@example.com"),
+	}
+
+	// describe summarizes aggregations as value/count pairs.
+	describe := func(aggregations []*searchAggregationResolver) map[string]int32 {
+		got := map[string]int32{}
+		for _, a := range aggregations {
+			got[a.value] = a.count
+		}
+		return got
+	}
+
+	tests := map[string]map[string]int32{
+		aggregationGroupByRepo:     {"github.com/foo/foo": 5, "github.com/foo/bar": 3},
+		aggregationGroupByPath:     {"a.go": 3, "b.py": 1},
+		aggregationGroupByLanguage: {"Go": 3, "Python": 1},
+		aggregationGroupByAuthor:   {"Alice <alice@example.com>": 2, "Bob <bob@example.com>": 1},
+	}
+	for groupBy, want := range tests {
+		t.Run(groupBy, func(t *testing.T) {
+			got, err := aggregateSearchResults(results, groupBy, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(want, describe(got)); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+
+	t.Run(aggregationGroupByCaptureGroup, func(t *testing.T) {
+		pattern := regexp.MustCompile(`(\w+)\.(?:New|Errorf)\(\w\)`)
+		got, err := aggregateSearchResults(results, aggregationGroupByCaptureGroup, pattern)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Aggregations are ordered by descending count.
+		if diff := cmp.Diff([]*searchAggregationResolver{
+			{value: "errors", count: 3},
+			{value: "fmt", count: 2},
+		}, got, cmp.AllowUnexported(searchAggregationResolver{})); diff != "" {
+			t.Fatal(diff)
+		}
+	})
+}
+
+func TestSearchResultsStatsAggregations(t *testing.T) {
+	conf.Mock(&conf.Unified{SiteConfiguration: schema.SiteConfiguration{
+		ExperimentalFeatures: &schema.ExperimentalFeatures{AndOrQuery: "enabled"},
+	}})
+	defer conf.Mock(nil)
+	mockDecodedViewerFinalSettings = &schema.Settings{}
+	defer func() { mockDecodedViewerFinalSettings = nil }()
+
+	repo := &types.Repo{ID: 1, Name: "repo"}
+	db.Mocks.Repos.List = func(_ context.Context, op db.ReposListOptions) ([]*types.Repo, error) {
+		return []*types.Repo{repo}, nil
+	}
+	db.Mocks.Repos.Count = mockCount
+	defer func() { db.Mocks = db.MockStores{} }()
+	repoResolver := &RepositoryResolver{repo: repo}
+
+	// Each pattern matches a line of its own file.
+	mockSearchFilesInRepos = func(args *search.TextParameters) ([]*FileMatchResolver, *searchResultsCommon, error) {
+		path := args.PatternInfo.Pattern + ".go"
+		return []*FileMatchResolver{{
+			uri:          fileMatchURI(repo.Name, "", path),
+			JPath:        path,
+			JLineMatches: []*lineMatch{{JLineNumber: 1}},
+			MatchCount:   1,
+			Repo:         repoResolver,
+		}}, &searchResultsCommon{repos: []*types.Repo{repo}}, nil
+	}
+	defer func() { mockSearchFilesInRepos = nil }()
+	mockSearchRepositories = func(args *search.TextParameters) ([]SearchResultResolver, *searchResultsCommon, error) {
+		return nil, &searchResultsCommon{}, nil
+	}
+	defer func() { mockSearchRepositories = nil }()
+
+	tests := []struct {
+		query   string
+		groupBy string
+		want    map[string]int32
+	}{
+		// Both operands of an or-expression are counted.
+		{query: "foo or bar", groupBy: aggregationGroupByPath, want: map[string]int32{"foo.go": 1, "bar.go": 1}},
+		// select:repo counts each repository once.
+		{query: "(foo or bar) select:repo", groupBy: aggregationGroupByRepo, want: map[string]int32{"repo": 1}},
+	}
+	for _, test := range tests {
+		t.Run(test.query, func(t *testing.T) {
+			patternType := "regexp"
+			r, err := (&schemaResolver{}).Search(context.Background(), &SearchArgs{
+				Query:       test.query,
+				Version:     "V2",
+				PatternType: &patternType,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			srs := &searchResultsStats{sr: r.(*searchResolver)}
+			aggregations, err := srs.Aggregations(context.Background(), &struct{ GroupBy string }{GroupBy: test.groupBy})
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := map[string]int32{}
+			for _, a := range aggregations.Nodes() {
+				got[a.value] = a.count
+			}
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Error(diff)
+			}
+			if aggregations.LimitHit() {
+				t.Error("got limitHit, want every result counted")
+			}
+		})
+	}
+}
+
+func TestSearchResultsStatsAggregations_cappedAndExpression(t *testing.T) {
+	conf.Mock(&conf.Unified{SiteConfiguration: schema.SiteConfiguration{
+		ExperimentalFeatures: &schema.ExperimentalFeatures{AndOrQuery: "enabled"},
+	}})
+	defer conf.Mock(nil)
+	mockDecodedViewerFinalSettings = &schema.Settings{}
+	defer func() { mockDecodedViewerFinalSettings = nil }()
+
+	repo := &types.Repo{ID: 1, Name: "repo"}
+	db.Mocks.Repos.List = func(_ context.Context, op db.ReposListOptions) ([]*types.Repo, error) {
+		return []*types.Repo{repo}, nil
+	}
+	db.Mocks.Repos.Count = mockCount
+	defer func() { db.Mocks = db.MockStores{} }()
+	repoResolver := &RepositoryResolver{repo: repo}
+
+	// Both patterns match a line of the same file, but the searches are
+	// never exhaustive, so the and-expression can't get as many results as
+	// it wants.
+	searches := 0
+	mockSearchFilesInRepos = func(args *search.TextParameters) ([]*FileMatchResolver, *searchResultsCommon, error) {
+		searches++
+		line := int32(1)
+		if args.PatternInfo.Pattern == "bar" {
+			line = 2
+		}
+		return []*FileMatchResolver{{
+			uri:          fileMatchURI(repo.Name, "", "both.go"),
+			JPath:        "both.go",
+			JLineMatches: []*lineMatch{{JLineNumber: line}},
+			MatchCount:   1,
+			Repo:         repoResolver,
+		}}, &searchResultsCommon{repos: []*types.Repo{repo}, limitHit: true}, nil
+	}
+	defer func() { mockSearchFilesInRepos = nil }()
+
+	patternType := "regexp"
+	r, err := (&schemaResolver{}).Search(context.Background(), &SearchArgs{
+		Query:       "foo and bar",
+		Version:     "V2",
+		PatternType: &patternType,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srs := &searchResultsStats{sr: r.(*searchResolver)}
+	aggregations, err := srs.Aggregations(context.Background(), &struct{ GroupBy string }{GroupBy: aggregationGroupByPath})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]int32{}
+	for _, a := range aggregations.Nodes() {
+		got[a.value] = a.count
+	}
+	if diff := cmp.Diff(map[string]int32{"both.go": 2}, got); diff != "" {
+		t.Error(diff)
+	}
+	if !aggregations.LimitHit() {
+		t.Error("got no limitHit, want limitHit for a capped and-expression")
+	}
+	// The search is not retried beyond the cap.
+	if searches != 2 {
+		t.Errorf("got %d searches, want 2", searches)
+	}
+}
@@ -14,6 +14,7 @@ import (
 	"github.com/sourcegraph/sourcegraph/cmd/frontend/db"
 	"github.com/sourcegraph/sourcegraph/cmd/frontend/types"
 	"github.com/sourcegraph/sourcegraph/internal/api"
+	"github.com/sourcegraph/sourcegraph/internal/conf"
 	"github.com/sourcegraph/sourcegraph/internal/extsvc"
 	"github.com/sourcegraph/sourcegraph/internal/search"
 	searchbackend "github.com/sourcegraph/sourcegraph/internal/search/backend"
@@ -34,7 +35,7 @@ func TestSearchResults(t *testing.T) {
 	limitOffset := &db.LimitOffset{Limit: maxReposToSearch() + 1}
 
 	getResults := func(t *testing.T, query, version string) []string {
-		r, err := (&schemaResolver{}).Search(&SearchArgs{Query: query, Version: version})
+		r, err := (&schemaResolver{}).Search(context.Background(), &SearchArgs{Query: query, Version: version})
 		if err != nil {
 			t.Fatal("Search:", err)
 		}
@@ -245,8 +246,10 @@ func TestSearchResults(t *testing.T) {
 	})
 
 	t.Run("test start time is not null when alert thrown", func(t *testing.T) {
+		mockDecodedViewerFinalSettings = &schema.Settings{}
+		defer func() { mockDecodedViewerFinalSettings = nil }()
 		for _, v := range searchVersions {
-			r, err := (&schemaResolver{}).Search(&SearchArgs{Query: `repo:*`, Version: v})
+			r, err := (&schemaResolver{}).Search(context.Background(), &SearchArgs{Query: `repo:*`, Version: v})
 			if err != nil {
 				t.Fatal("Search:", err)
 			}
@@ -408,12 +411,36 @@ func TestSearchResolver_getPatternInfo(t *testing.T) {
 			IncludePatterns:        []string{"f"},
 			ExcludePattern:         `\.graphql$|\.gql$|\.graphqls$`,
 		},
+		"v(?P<version>[0-9.]+)": {
+			Pattern:                "v(?P<version>[0-9.]+)",
+			IsRegExp:               true,
+			PathPatternsAreRegExps: true,
+			IncludeCaptureGroups:   true,
+		},
+		"foo.*bar multiline:yes": {
+			Pattern:                "foo.*bar",
+			IsRegExp:               true,
+			IsMultiline:            true,
+			PathPatternsAreRegExps: true,
+		},
+		"v([0-9.]+)": {
+			Pattern:                "v([0-9.]+)",
+			IsRegExp:               true,
+			PathPatternsAreRegExps: true,
+		},
 		"p -lang:graphql -file:f": {
 			Pattern:                "p",
 			IsRegExp:               true,
 			PathPatternsAreRegExps: true,
 			ExcludePattern:         `f|(\.graphql$|\.gql$|\.graphqls$)`,
 		},
+		"-content:p file:f": {
+			Pattern:                "p",
+			IsRegExp:               true,
+			IsNegated:              true,
+			PathPatternsAreRegExps: true,
+			IncludePatterns:        []string{"f"},
+		},
 	}
 	for queryStr, want := range tests {
 		t.Run(queryStr, func(t *testing.T) {
@@ -435,6 +462,32 @@ func TestSearchResolver_getPatternInfo(t *testing.T) {
 	}
 }
 
+func TestSearchResolver_getPatternInfo_negatedWithPattern(t *testing.T) {
+	q, err := query.ParseAndCheck("p -content:q")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sr := searchResolver{query: q}
+	if _, err := sr.getPatternInfo(nil); err == nil {
+		t.Error("expected an error for a negated content pattern combined with a search pattern")
+	}
+}
+
+func TestSearchResolver_determineResultTypes_negated(t *testing.T) {
+	q, err := query.ParseAndCheck("-content:p type:path")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sr := searchResolver{query: q}
+	args := search.TextParameters{PatternInfo: &search.TextPatternInfo{IsNegated: true}}
+	if got, want := sr.determineResultTypes(args, ""), []string{"file"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got result types %v, want %v", got, want)
+	}
+	if !args.PatternInfo.PatternMatchesContent || args.PatternInfo.PatternMatchesPath {
+		t.Errorf("got PatternMatchesContent=%v PatternMatchesPath=%v, want true, false", args.PatternInfo.PatternMatchesContent, args.PatternInfo.PatternMatchesPath)
+	}
+}
+
 func TestSearchResolver_DynamicFilters(t *testing.T) {
 	repo := &types.Repo{Name: "testRepo"}
 
@@ -1127,3 +1180,226 @@ func TestSearchResolver_evaluateWarning(t *testing.T) {
 		}
 	})
 }
+
+func TestIntersectUnion(t *testing.T) {
+	repoResolvers := map[string]*RepositoryResolver{}
+	repo := func(name string) *RepositoryResolver {
+		if repoResolvers[name] == nil {
+			repoResolvers[name] = &RepositoryResolver{repo: &types.Repo{Name: api.RepoName(name)}}
+		}
+		return repoResolvers[name]
+	}
+	file := func(repoName, path string, lineNumbers ...int32) *FileMatchResolver {
+		fm := &FileMatchResolver{
+			JPath: path,
+			uri:   fileMatchURI(api.RepoName(repoName), "", path),
+			Repo:  repo(repoName),
+		}
+		for _, n := range lineNumbers {
+			fm.JLineMatches = append(fm.JLineMatches, &lineMatch{JLineNumber: n, JOffsetAndLengths: [][2]int32{{0, 1}}})
+			fm.MatchCount++
+		}
+		return fm
+	}
+	commit := func(repoName, oid string) *commitSearchResultResolver {
+		return &commitSearchResultResolver{commit: &GitCommitResolver{repoResolver: repo(repoName), oid: GitObjectID(oid)}}
+	}
+	// describe returns a string for each result, such as "file a/x.go (2
+	// lines)", in order.
+	describe := func(results []SearchResultResolver) []string {
+		var got []string
+		for _, result := range results {
+			if r, ok := result.ToRepository(); ok {
+				got = append(got, "repo "+r.Name())
+			} else if fm, ok := result.ToFileMatch(); ok {
+				got = append(got, fmt.Sprintf("file %s/%s (%d lines)", fm.Repo.Name(), fm.JPath, len(fm.JLineMatches)))
+			} else if c, ok := result.ToCommitSearchResult(); ok {
+				got = append(got, "commit "+commitResultKey(c))
+			}
+		}
+		return got
+	}
+
+	tests := []struct {
+		name          string
+		left, right   func() []SearchResultResolver
+		leftRepos     bool // whether the left repos were asked for with type:repo
+		rightRepos    bool
+		wantIntersect []string
+		wantUnion     []string
+	}{
+		{
+			name:          "files are combined if they are the same file",
+			left:          func() []SearchResultResolver { return []SearchResultResolver{file("a", "x", 1), file("a", "y", 1)} },
+			right:         func() []SearchResultResolver { return []SearchResultResolver{file("a", "x", 2), file("a", "z", 1)} },
+			wantIntersect: []string{"file a/x (2 lines)"},
+			wantUnion:     []string{"file a/x (2 lines)", "file a/y (1 lines)", "file a/z (1 lines)"},
+		},
+		{
+			name:          "commits are combined if they are the same commit",
+			left:          func() []SearchResultResolver { return []SearchResultResolver{commit("a", "1"), commit("a", "2")} },
+			right:         func() []SearchResultResolver { return []SearchResultResolver{commit("a", "1"), commit("b", "2")} },
+			wantIntersect: []string{"commit a@1"},
+			wantUnion:     []string{"commit a@1", "commit a@2", "commit b@2"},
+		},
+		{
+			name: "repos scope files and commits in them",
+			left: func() []SearchResultResolver { return []SearchResultResolver{repo("a"), repo("b"), repo("c")} },
+			right: func() []SearchResultResolver {
+				return []SearchResultResolver{file("a", "x", 1), commit("b", "1"), file("d", "x", 1)}
+			},
+			leftRepos:     true,
+			wantIntersect: []string{"file a/x (1 lines)", "commit b@1"},
+			wantUnion:     []string{"repo a", "repo b", "repo c", "file a/x (1 lines)", "commit b@1", "file d/x (1 lines)"},
+		},
+		{
+			name:          "repos without type:repo don't scope files in them",
+			left:          func() []SearchResultResolver { return []SearchResultResolver{repo("foo"), file("foo", "x", 1)} },
+			right:         func() []SearchResultResolver { return []SearchResultResolver{file("foo", "y", 1), repo("foo")} },
+			wantIntersect: []string{"repo foo"},
+			wantUnion:     []string{"repo foo", "file foo/x (1 lines)", "file foo/y (1 lines)"},
+		},
+		{
+			name:          "repos are combined if they are the same repo",
+			left:          func() []SearchResultResolver { return []SearchResultResolver{repo("a"), repo("b")} },
+			right:         func() []SearchResultResolver { return []SearchResultResolver{repo("b"), repo("c")} },
+			wantIntersect: []string{"repo b"},
+			wantUnion:     []string{"repo a", "repo b", "repo c"},
+		},
+		{
+			name:          "files and commits are combined by repo",
+			left:          func() []SearchResultResolver { return []SearchResultResolver{file("a", "x", 1), file("b", "x", 1)} },
+			right:         func() []SearchResultResolver { return []SearchResultResolver{commit("a", "1"), commit("c", "1")} },
+			wantIntersect: []string{"file a/x (1 lines)", "commit a@1"},
+			wantUnion:     []string{"file a/x (1 lines)", "file b/x (1 lines)", "commit a@1", "commit c@1"},
+		},
+		{
+			name: "mixed result types",
+			left: func() []SearchResultResolver {
+				return []SearchResultResolver{repo("a"), file("b", "x", 1), commit("c", "1"), file("e", "x", 1)}
+			},
+			right: func() []SearchResultResolver {
+				return []SearchResultResolver{file("a", "y", 1), commit("b", "2"), repo("d"), repo("e"), file("e", "x", 3)}
+			},
+			leftRepos:     true,
+			rightRepos:    true,
+			wantIntersect: []string{"file b/x (1 lines)", "file e/x (2 lines)", "file a/y (1 lines)", "commit b@2"},
+			wantUnion: []string{
+				"repo a", "file b/x (1 lines)", "commit c@1", "file e/x (2 lines)",
+				"file a/y (1 lines)", "commit b@2", "repo d", "repo e",
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := intersect(&SearchResultsResolver{SearchResults: test.left()}, &SearchResultsResolver{SearchResults: test.right()}, test.leftRepos, test.rightRepos)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(test.wantIntersect, describe(got.SearchResults)); diff != "" {
+				t.Errorf("intersect mismatch (-want +got):\n%s", diff)
+			}
+			if got.searchResultsCommon.resultCount != int32(len(test.wantIntersect)) {
+				t.Errorf("got intersect resultCount %d, want %d", got.searchResultsCommon.resultCount, len(test.wantIntersect))
+			}
+
+			got = union(&SearchResultsResolver{SearchResults: test.left()}, &SearchResultsResolver{SearchResults: test.right()})
+			if diff := cmp.Diff(test.wantUnion, describe(got.SearchResults)); diff != "" {
+				t.Errorf("union mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestMergeLineMatches(t *testing.T) {
+	a := []*lineMatch{
+		{JLineNumber: 1, JOffsetAndLengths: [][2]int32{{4, 2}}},
+		{JLineNumber: 3, JOffsetAndLengths: [][2]int32{{0, 1}}},
+	}
+	b := []*lineMatch{
+		{JLineNumber: 2, JOffsetAndLengths: [][2]int32{{0, 1}}},
+		{JLineNumber: 1, JOffsetAndLengths: [][2]int32{{0, 3}, {4, 2}}},
+	}
+	want := []*lineMatch{
+		{JLineNumber: 1, JOffsetAndLengths: [][2]int32{{0, 3}, {4, 2}}},
+		{JLineNumber: 2, JOffsetAndLengths: [][2]int32{{0, 1}}},
+		{JLineNumber: 3, JOffsetAndLengths: [][2]int32{{0, 1}}},
+	}
+	dst := &FileMatchResolver{JLineMatches: a, MatchCount: 2}
+	mergeResult(dst, &FileMatchResolver{JLineMatches: b, MatchCount: 3})
+	if diff := cmp.Diff(want, dst.JLineMatches); diff != "" {
+		t.Errorf("line matches mismatch (-want +got):\n%s", diff)
+	}
+	// The match at offset 4 of line 1 is in both files.
+	if dst.MatchCount != 4 {
+		t.Errorf("got MatchCount %d, want 4", dst.MatchCount)
+	}
+}
+
+func TestSearchResults_scopedOperands(t *testing.T) {
+	conf.Mock(&conf.Unified{SiteConfiguration: schema.SiteConfiguration{
+		ExperimentalFeatures: &schema.ExperimentalFeatures{AndOrQuery: "enabled"},
+	}})
+	defer conf.Mock(nil)
+	mockDecodedViewerFinalSettings = &schema.Settings{}
+	defer func() { mockDecodedViewerFinalSettings = nil }()
+
+	repo := &types.Repo{ID: 1, Name: "repo"}
+	db.Mocks.Repos.List = func(_ context.Context, op db.ReposListOptions) ([]*types.Repo, error) {
+		return []*types.Repo{repo}, nil
+	}
+	db.Mocks.Repos.Count = mockCount
+	defer func() { db.Mocks = db.MockStores{} }()
+	repoResolver := &RepositoryResolver{repo: repo}
+
+	var filePatterns, diffPatterns []string
+	mockSearchFilesInRepos = func(args *search.TextParameters) ([]*FileMatchResolver, *searchResultsCommon, error) {
+		filePatterns = append(filePatterns, args.PatternInfo.Pattern)
+		return []*FileMatchResolver{{
+			uri:          fileMatchURI(repo.Name, "", "x.go"),
+			JPath:        "x.go",
+			JLineMatches: []*lineMatch{{JLineNumber: 1}},
+			Repo:         repoResolver,
+		}}, &searchResultsCommon{repos: []*types.Repo{repo}}, nil
+	}
+	defer func() { mockSearchFilesInRepos = nil }()
+	mockSearchCommitDiffsInRepos = func(args *search.TextParametersForCommitParameters) ([]SearchResultResolver, *searchResultsCommon, error) {
+		diffPatterns = append(diffPatterns, args.PatternInfo.Pattern)
+		return []SearchResultResolver{&commitSearchResultResolver{
+			commit: &GitCommitResolver{repoResolver: repoResolver, oid: "c0ffee"},
+		}}, &searchResultsCommon{repos: []*types.Repo{repo}}, nil
+	}
+	defer func() { mockSearchCommitDiffsInRepos = nil }()
+	mockSearchRepositories = func(args *search.TextParameters) ([]SearchResultResolver, *searchResultsCommon, error) {
+		return nil, &searchResultsCommon{}, nil
+	}
+	defer func() { mockSearchRepositories = nil }()
+
+	patternType := "regexp"
+	r, err := (&schemaResolver{}).Search(context.Background(), &SearchArgs{
+		Query:       `(type:file foo) or (type:diff bar)`,
+		Version:     "V2",
+		PatternType: &patternType,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, err := r.Results(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if alert := results.Alert(); alert != nil {
+		t.Fatalf("got alert %q: %v", alert.Title(), alert.Description())
+	}
+
+	// Each operand is only searched for the result type in its scope.
+	if want := []string{"foo"}; !reflect.DeepEqual(filePatterns, want) {
+		t.Errorf("got file search patterns %q, want %q", filePatterns, want)
+	}
+	if want := []string{"bar"}; !reflect.DeepEqual(diffPatterns, want) {
+		t.Errorf("got diff search patterns %q, want %q", diffPatterns, want)
+	}
+	if got := len(results.SearchResults); got != 2 {
+		t.Errorf("got %d results, want a file and a commit", got)
+	}
+}
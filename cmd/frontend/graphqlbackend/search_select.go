@@ -0,0 +1,190 @@
+package graphqlbackend
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/sourcegraph/sourcegraph/internal/search/query"
+)
+
+// selectPath returns the parsed value of the select: field, or nil if the
+// query does not specify one.
+func (r *searchResolver) selectPath() query.SelectPath {
+	value, _ := r.query.StringValue(query.FieldSelect)
+	if value == "" {
+		return nil
+	}
+	// The value was validated when the query was parsed.
+	sp, _ := query.ParseSelect(value)
+	return sp
+}
+
+// resultSelector projects search results onto the kind of result chosen by
+// the select: field, and drops projected results which were already
+// returned. For example, selecting "repo" turns every file match into the
+// repository containing it, and returns each repository once. It is safe to
+// call from multiple goroutines.
+type resultSelector struct {
+	path query.SelectPath
+
+	mu   sync.Mutex
+	seen map[string]struct{}
+}
+
+func newResultSelector(path query.SelectPath) *resultSelector {
+	return &resultSelector{path: path, seen: make(map[string]struct{})}
+}
+
+// apply returns the projection of results which has not been returned by a
+// previous call to apply.
+func (s *resultSelector) apply(results []SearchResultResolver) []SearchResultResolver {
+	if s == nil {
+		return results
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	selected := make([]SearchResultResolver, 0, len(results))
+	for _, result := range results {
+		key, projected := selectResult(result, s.path)
+		if projected == nil {
+			continue
+		}
+		if _, ok := s.seen[key]; ok {
+			continue
+		}
+		s.seen[key] = struct{}{}
+		selected = append(selected, projected)
+	}
+	return selected
+}
+
+// selectResult returns the projection of result onto path, along with a key
+// identifying the projected result. It returns a nil result if result has no
+// projection onto path.
+func selectResult(result SearchResultResolver, path query.SelectPath) (string, SearchResultResolver) {
+	switch path.Root() {
+	case query.SelectRepository:
+		var repo *RepositoryResolver
+		if r, ok := result.ToRepository(); ok {
+			repo = r
+		} else if fm, ok := result.ToFileMatch(); ok {
+			repo = &RepositoryResolver{repo: fm.Repo.repo, icon: repoIcon}
+			if fm.InputRev != nil {
+				repo.rev = *fm.InputRev
+			}
+		} else if c, ok := result.ToCommitSearchResult(); ok {
+			repo = &RepositoryResolver{repo: c.commit.repoResolver.repo, icon: repoIcon}
+		} else {
+			return "", nil
+		}
+		return string(repo.repo.Name) + "@" + repo.rev, repo
+
+	case query.SelectFile:
+		fm, ok := result.ToFileMatch()
+		if !ok {
+			return "", nil
+		}
+		return fm.uri, &FileMatchResolver{
+			JPath:    fm.JPath,
+			uri:      fm.uri,
+			Repo:     fm.Repo,
+			CommitID: fm.CommitID,
+			InputRev: fm.InputRev,
+		}
+
+	case query.SelectContent:
+		fm, ok := result.ToFileMatch()
+		if !ok || len(fm.JLineMatches) == 0 {
+			return "", nil
+		}
+		if len(fm.symbols) == 0 {
+			return fm.uri, fm
+		}
+		projected := *fm
+		projected.symbols = nil
+		return fm.uri, &projected
+
+	case query.SelectSymbol:
+		fm, ok := result.ToFileMatch()
+		if !ok {
+			return "", nil
+		}
+		symbols := fm.symbols
+		if len(path) > 1 {
+			symbols = nil
+			for _, sym := range fm.symbols {
+				if strings.ToLower(ctagsKindToLSPSymbolKind(sym.symbol.Kind).String()) == path[1] {
+					symbols = append(symbols, sym)
+				}
+			}
+		}
+		if len(symbols) == 0 {
+			return "", nil
+		}
+		projected := *fm
+		projected.JLineMatches = nil
+		projected.MatchCount = 0
+		projected.symbols = symbols
+		return fm.uri, &projected
+
+	case query.SelectCommit:
+		c, ok := result.ToCommitSearchResult()
+		if !ok {
+			return "", nil
+		}
+		if len(path) < 3 {
+			return c.url, c
+		}
+		projected := selectDiffLines(c, path[2])
+		if projected == nil {
+			return "", nil
+		}
+		return c.url, projected
+	}
+	return "", nil
+}
+
+// selectDiffLines returns a copy of the diff search result c with only the
+// highlights on added or removed lines, depending on kind. It returns nil if c
+// has no such highlights.
+func selectDiffLines(c *commitSearchResultResolver, kind string) *commitSearchResultResolver {
+	if c.diffPreview == nil {
+		return nil
+	}
+	prefix := "+"
+	if kind == "removed" {
+		prefix = "-"
+	}
+	lines := strings.Split(c.diffPreview.value, "\n")
+	var highlights []*highlightedRange
+	for _, h := range c.diffPreview.highlights {
+		// Highlight lines are 1-indexed.
+		i := int(h.line) - 1
+		if i < 0 || i >= len(lines) {
+			continue
+		}
+		line := lines[i]
+		// Skip the "--- a/file" and "+++ b/file" headers of a file diff.
+		if !strings.HasPrefix(line, prefix) || strings.HasPrefix(line, prefix+prefix+prefix) {
+			continue
+		}
+		highlights = append(highlights, h)
+	}
+	if len(highlights) == 0 {
+		return nil
+	}
+
+	projected := *c
+	projected.diffPreview = &highlightedString{value: c.diffPreview.value, highlights: highlights}
+
+	// cleanDiffPreview adjusts the highlights it is given, so give it copies.
+	matchHighlights := make([]*highlightedRange, len(highlights))
+	for i, h := range highlights {
+		copied := *h
+		matchHighlights[i] = &copied
+	}
+	body, matchHighlights := cleanDiffPreview(matchHighlights, c.diffPreview.value)
+	projected.matches = []*searchResultMatchResolver{{body: body, highlights: matchHighlights, url: c.url}}
+	return &projected
+}
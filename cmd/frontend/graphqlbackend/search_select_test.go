@@ -0,0 +1,117 @@
+package graphqlbackend
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/types"
+	"github.com/sourcegraph/sourcegraph/internal/search/query"
+	"github.com/sourcegraph/sourcegraph/internal/symbols/protocol"
+)
+
+func TestResultSelector(t *testing.T) {
+	repo := &RepositoryResolver{repo: &types.Repo{ID: 1, Name: "github.com/foo/bar"}}
+	fileMatch := func(path string, lineMatches int, symbolKinds ...string) *FileMatchResolver {
+		fm := &FileMatchResolver{JPath: path, uri: "git://github.com/foo/bar#" + path, Repo: repo, MatchCount: lineMatches}
+		for i := 0; i < lineMatches; i++ {
+			fm.JLineMatches = append(fm.JLineMatches, &lineMatch{JLineNumber: int32(i)})
+		}
+		for _, kind := range symbolKinds {
+			fm.symbols = append(fm.symbols, &searchSymbolResult{symbol: protocol.Symbol{Name: "s", Path: path, Kind: kind}})
+		}
+		return fm
+	}
+	results := []SearchResultResolver{
+		fileMatch("a.go", 2),
+		fileMatch("b.go", 1, "func", "type"),
+		fileMatch("c.go", 1),
+		repo,
+	}
+
+	// describe summarizes results for comparison.
+	describe := func(results []SearchResultResolver) []string {
+		var got []string
+		for _, r := range results {
+			if fm, ok := r.ToFileMatch(); ok {
+				got = append(got, fm.JPath)
+			} else if repo, ok := r.ToRepository(); ok {
+				got = append(got, repo.Name())
+			}
+		}
+		return got
+	}
+
+	cases := []struct {
+		selectValue string
+		want        []string
+		wantCount   int32
+	}{
+		{selectValue: "repo", want: []string{"github.com/foo/bar"}, wantCount: 1},
+		{selectValue: "file", want: []string{"a.go", "b.go", "c.go"}, wantCount: 3},
+		{selectValue: "content", want: []string{"a.go", "b.go", "c.go"}, wantCount: 4},
+		{selectValue: "symbol", want: []string{"b.go"}, wantCount: 2},
+		{selectValue: "symbol.function", want: []string{"b.go"}, wantCount: 1},
+		{selectValue: "symbol.enum", want: nil, wantCount: 0},
+	}
+	for _, c := range cases {
+		t.Run(c.selectValue, func(t *testing.T) {
+			sp, err := query.ParseSelect(c.selectValue)
+			if err != nil {
+				t.Fatal(err)
+			}
+			s := newResultSelector(sp)
+			got := s.apply(results)
+			if diff := cmp.Diff(c.want, describe(got)); diff != "" {
+				t.Fatal(diff)
+			}
+			var count int32
+			for _, r := range got {
+				count += r.resultCount()
+			}
+			if count != c.wantCount {
+				t.Errorf("got result count %d, want %d", count, c.wantCount)
+			}
+
+			// Results which were already selected are not returned again.
+			if again := s.apply(results); len(again) != 0 {
+				t.Errorf("got %v on second apply, want no results", describe(again))
+			}
+		})
+	}
+}
+
+func TestSelectDiffLines(t *testing.T) {
+	raw := "diff --git a/f b/f\n--- a/f\n+++ b/f\n@@ -1,2 +1,2 @@\n-foo old\n+foo new\n"
+	c := &commitSearchResultResolver{
+		url: "/github.com/foo/bar/-/commit/abc",
+		diffPreview: &highlightedString{
+			value: raw,
+			highlights: []*highlightedRange{
+				{line: 5, character: 1, length: 3},
+				{line: 6, character: 1, length: 3},
+			},
+		},
+	}
+
+	added := selectDiffLines(c, "added")
+	if added == nil {
+		t.Fatal("expected a result for added lines")
+	}
+	if diff := cmp.Diff([]*highlightedRange{{line: 6, character: 1, length: 3}}, added.diffPreview.highlights, cmp.AllowUnexported(highlightedRange{})); diff != "" {
+		t.Fatal(diff)
+	}
+	// The highlights of the original result must not be modified.
+	if got := c.diffPreview.highlights[1].line; got != 6 {
+		t.Errorf("original highlight line changed to %d", got)
+	}
+
+	removed := selectDiffLines(c, "removed")
+	if removed == nil || len(removed.diffPreview.highlights) != 1 || removed.diffPreview.highlights[0].line != 5 {
+		t.Fatalf("unexpected result for removed lines: %+v", removed)
+	}
+
+	c.diffPreview.highlights = c.diffPreview.highlights[:1]
+	if got := selectDiffLines(c, "added"); got != nil {
+		t.Fatalf("got %+v, want no result when no added lines match", got)
+	}
+}
@@ -0,0 +1,92 @@
+package graphqlbackend
+
+import (
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/types"
+)
+
+// SearchEvent is a batch of results sent on a search stream, along with
+// information about the repositories the backend that produced them covered.
+type SearchEvent struct {
+	Results []SearchResultResolver
+
+	common searchResultsCommon
+}
+
+// sendResults sends results and the stats of the backend which produced them
+// down the result channel, if the search is streaming. It is safe to call from
+// multiple goroutines.
+func (r *searchResolver) sendResults(results []SearchResultResolver, common *searchResultsCommon) {
+	if r.resultChannel == nil {
+		return
+	}
+	results = r.streamSelector.apply(results)
+	if len(results) == 0 && common == nil {
+		return
+	}
+	ev := SearchEvent{Results: results}
+	if common != nil {
+		ev.common = *common
+	}
+	r.resultChannel <- ev
+}
+
+// withoutStreaming runs f with streaming disabled, and then sends the
+// complete result set f returns. It is used for evaluation strategies which
+// can only decide which results to return once every backend has finished,
+// such as and/or expressions and paginated requests.
+func (r *searchResolver) withoutStreaming(f func() (*SearchResultsResolver, error)) (*SearchResultsResolver, error) {
+	c := r.resultChannel
+	if c == nil {
+		return f()
+	}
+	r.resultChannel = nil
+	result, err := f()
+	r.resultChannel = c
+	if result != nil {
+		r.sendResults(result.SearchResults, &result.searchResultsCommon)
+	}
+	return result, err
+}
+
+// SearchProgress aggregates the events of a search stream into counters
+// describing how far the search has progressed.
+type SearchProgress struct {
+	common     searchResultsCommon
+	matchCount int32
+}
+
+// Update adds the results and stats of ev to p.
+func (p *SearchProgress) Update(ev SearchEvent) {
+	p.common.update(ev.common)
+	for _, r := range ev.Results {
+		p.matchCount += r.resultCount()
+	}
+}
+
+func (p *SearchProgress) MatchCount() int32 { return p.matchCount }
+
+func (p *SearchProgress) LimitHit() bool { return p.common.limitHit }
+
+func (p *SearchProgress) RepositoriesCount() int32 { return dedupCount(&p.common.repos) }
+
+func (p *SearchProgress) RepositoriesSearchedCount() int32 { return dedupCount(&p.common.searched) }
+
+func (p *SearchProgress) IndexedRepositoriesSearchedCount() int32 {
+	return dedupCount(&p.common.indexed)
+}
+
+func (p *SearchProgress) CloningCount() int32 { return dedupCount(&p.common.cloning) }
+
+func (p *SearchProgress) MissingCount() int32 { return dedupCount(&p.common.missing) }
+
+func (p *SearchProgress) TimedoutCount() int32 { return dedupCount(&p.common.timedout) }
+
+// dedupCount deduplicates repos in-place and returns the number of distinct
+// repos. Deduplicating in-place keeps the lists aggregated by SearchProgress
+// from growing with every event.
+func dedupCount(repos *[]*types.Repo) int32 {
+	rs := types.Repos(*repos)
+	dedupSort(&rs)
+	*repos = rs
+	return int32(len(rs))
+}
@@ -0,0 +1,64 @@
+package graphqlbackend
+
+import (
+	"testing"
+
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/types"
+)
+
+func TestSearchProgress(t *testing.T) {
+	foo := &types.Repo{ID: 1, Name: "foo"}
+	bar := &types.Repo{ID: 2, Name: "bar"}
+
+	var p SearchProgress
+	p.Update(SearchEvent{
+		Results: []SearchResultResolver{&RepositoryResolver{repo: foo}},
+		common:  searchResultsCommon{repos: []*types.Repo{foo, bar}, searched: []*types.Repo{foo}},
+	})
+	p.Update(SearchEvent{
+		common: searchResultsCommon{repos: []*types.Repo{foo, bar}, searched: []*types.Repo{bar}, cloning: []*types.Repo{bar}, limitHit: true},
+	})
+
+	if got, want := p.MatchCount(), int32(1); got != want {
+		t.Errorf("MatchCount() = %d, want %d", got, want)
+	}
+	if got, want := p.RepositoriesCount(), int32(2); got != want {
+		t.Errorf("RepositoriesCount() = %d, want %d", got, want)
+	}
+	if got, want := p.RepositoriesSearchedCount(), int32(2); got != want {
+		t.Errorf("RepositoriesSearchedCount() = %d, want %d", got, want)
+	}
+	if got, want := p.CloningCount(), int32(1); got != want {
+		t.Errorf("CloningCount() = %d, want %d", got, want)
+	}
+	if !p.LimitHit() {
+		t.Error("LimitHit() = false, want true")
+	}
+}
+
+func TestWithoutStreaming(t *testing.T) {
+	events := make(chan SearchEvent, 10)
+	r := &searchResolver{resultChannel: events}
+
+	foo := &RepositoryResolver{repo: &types.Repo{ID: 1, Name: "foo"}}
+	_, err := r.withoutStreaming(func() (*SearchResultsResolver, error) {
+		if r.resultChannel != nil {
+			t.Fatal("expected streaming to be disabled")
+		}
+		// Sends while streaming is disabled are dropped.
+		r.sendResults([]SearchResultResolver{foo}, nil)
+		return &SearchResultsResolver{SearchResults: []SearchResultResolver{foo}}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	close(events)
+
+	var got []SearchResultResolver
+	for ev := range events {
+		got = append(got, ev.Results...)
+	}
+	if len(got) != 1 || got[0] != foo {
+		t.Fatalf("got %v, want exactly one result", got)
+	}
+}
@@ -156,6 +156,9 @@ func zoektSearchHEADOnlyFiles(ctx context.Context, args *search.TextParameters,
 	if err != nil {
 		return nil, false, nil, err
 	}
+	if repoBranches := zoektRepoBranches(repos); repoBranches != nil {
+		filePathPatterns = zoektquery.NewAnd(repoBranches, filePathPatterns)
+	}
 
 	// Handle `repohasfile` or `-repohasfile`
 	newRepoSet, err := createNewRepoSetWithRepoHasFileInputs(ctx, args.PatternInfo, args.Zoekt.Client, repoSet)
@@ -237,12 +240,18 @@ func zoektSearchHEADOnlyFiles(ctx context.Context, args *search.TextParameters,
 		if repoResolvers[repoRev.Repo.Name] == nil {
 			repoResolvers[repoRev.Repo.Name] = &RepositoryResolver{repo: repoRev.Repo}
 		}
+		inputRev, commitID := zoektFileRev(repoRev, file)
+		uriRev := ""
+		if len(repoRev.IndexedBranches) > 0 {
+			uriRev = inputRev
+		}
 		matches[i] = &FileMatchResolver{
 			JPath:     file.FileName,
 			JLimitHit: fileLimitHit,
-			uri:       fileMatchURI(repoRev.Repo.Name, "", file.FileName),
+			uri:       fileMatchURI(repoRev.Repo.Name, uriRev, file.FileName),
 			Repo:      repoResolvers[repoRev.Repo.Name],
-			CommitID:  api.CommitID(file.Version),
+			CommitID:  commitID,
+			revs:      zoektFileRevs(repoRev, file),
 		}
 	}
 
@@ -0,0 +1,154 @@
+package graphqlbackend
+
+import (
+	"regexp"
+	"regexp/syntax"
+	"strings"
+
+	"github.com/sourcegraph/sourcegraph/internal/search"
+	"github.com/sourcegraph/sourcegraph/internal/search/query"
+	"github.com/sourcegraph/sourcegraph/internal/symbols/protocol"
+	"github.com/src-d/enry/v2"
+)
+
+// symbolFilters constrains the symbols of a symbol search beyond matching
+// their names against the pattern, as given by the select:symbol.<kind>,
+// symbolparent:, symbolmatch: and lang: fields.
+type symbolFilters struct {
+	kinds           []string // lowercase ctags kinds, empty for any kind
+	parent          string   // empty for any parent
+	languages       []string // languages, such as "Go", empty for any language
+	match           query.SymbolMatch
+	isCaseSensitive bool
+}
+
+func newSymbolFilters(q query.QueryInfo, patternInfo *search.TextPatternInfo) *symbolFilters {
+	f := &symbolFilters{match: query.SymbolMatchRegexp, isCaseSensitive: patternInfo.IsCaseSensitive}
+	for _, value := range patternInfo.Languages {
+		// The symbols service records the language names of ctags, which
+		// are the canonical names of the lang: aliases.
+		if lang, ok := enry.GetLanguageByAlias(value); ok {
+			f.languages = append(f.languages, lang)
+		}
+	}
+	if q == nil {
+		return f
+	}
+	// The values were validated when the query was parsed.
+	if value, _ := q.StringValue(query.FieldSelect); value != "" {
+		if sp, _ := query.ParseSelect(value); sp.Root() == query.SelectSymbol && len(sp) > 1 {
+			f.kinds = lspSymbolKindToCtagsKinds(sp[1])
+		}
+	}
+	f.parent, _ = q.StringValue(query.FieldSymbolParent)
+	if value, _ := q.StringValue(query.FieldSymbolMatch); value != "" {
+		f.match, _ = query.ParseSymbolMatch(value)
+	}
+	return f
+}
+
+// symbolsParameters returns the parameters to search the symbols service
+// for symbols matching pattern and f.
+func (f *symbolFilters) symbolsParameters(pattern string) search.SymbolsParameters {
+	p := search.SymbolsParameters{
+		Query:     pattern,
+		Kinds:     f.kinds,
+		Parent:    f.parent,
+		Languages: f.languages,
+	}
+	switch f.match {
+	case query.SymbolMatchExact:
+		p.Query, p.NameMatch = symbolNameLiteral(pattern), protocol.NameMatchExact
+	case query.SymbolMatchPrefix:
+		p.Query, p.NameMatch = symbolNameLiteral(pattern), protocol.NameMatchPrefix
+	case query.SymbolMatchFuzzy:
+		p.Query, p.NameMatch = symbolNameLiteral(pattern), protocol.NameMatchFuzzy
+	}
+	return p
+}
+
+// zoektArgs returns args with a pattern that matches symbol names the way f
+// requires. Zoekt only matches symbol names against regular expressions.
+func (f *symbolFilters) zoektArgs(args *search.TextParameters) *search.TextParameters {
+	if f.match == query.SymbolMatchRegexp {
+		return args
+	}
+	name := symbolNameLiteral(args.PatternInfo.Pattern)
+	patternInfo := *args.PatternInfo
+	patternInfo.IsRegExp = true
+	switch f.match {
+	case query.SymbolMatchExact:
+		patternInfo.Pattern = "^" + regexp.QuoteMeta(name) + "$"
+	case query.SymbolMatchPrefix:
+		patternInfo.Pattern = "^" + regexp.QuoteMeta(name)
+	case query.SymbolMatchFuzzy:
+		var chars []string
+		for _, r := range name {
+			chars = append(chars, regexp.QuoteMeta(string(r)))
+		}
+		patternInfo.Pattern = strings.Join(chars, ".*")
+	}
+	zoektArgs := *args
+	zoektArgs.PatternInfo = &patternInfo
+	return &zoektArgs
+}
+
+// filterFileMatches removes the symbols which do not match the kinds and
+// parent of f from matches, and the file matches left without symbols. The
+// symbols service filters its results itself, so this is only needed for
+// results from zoekt. Zoekt doesn't know the languages of symbols, but the
+// file patterns of lang: already restrict its results.
+func (f *symbolFilters) filterFileMatches(matches []*FileMatchResolver) []*FileMatchResolver {
+	if len(f.kinds) == 0 && f.parent == "" {
+		return matches
+	}
+	filtered := matches[:0]
+	for _, fm := range matches {
+		symbols := fm.symbols[:0]
+		for _, sym := range fm.symbols {
+			if f.matches(sym.symbol) {
+				symbols = append(symbols, sym)
+			}
+		}
+		fm.symbols = symbols
+		if len(symbols) > 0 {
+			filtered = append(filtered, fm)
+		}
+	}
+	return filtered
+}
+
+func (f *symbolFilters) matches(s protocol.Symbol) bool {
+	if len(f.kinds) > 0 {
+		kind := strings.ToLower(s.Kind)
+		found := false
+		for _, k := range f.kinds {
+			if k == kind {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if f.parent != "" {
+		if f.isCaseSensitive {
+			return s.Parent == f.parent
+		}
+		return strings.EqualFold(s.Parent, f.parent)
+	}
+	return true
+}
+
+// symbolNameLiteral returns the text matched by pattern if it is a literal,
+// such as a quoted or literal search pattern, and pattern itself otherwise.
+// The exact, prefix and fuzzy name matches compare names to text, but search
+// patterns are always regular expressions.
+func symbolNameLiteral(pattern string) string {
+	re, err := syntax.Parse(pattern, syntax.Perl)
+	if err == nil && re.Op == syntax.OpLiteral {
+		return string(re.Rune)
+	}
+	return pattern
+}
@@ -0,0 +1,164 @@
+package graphqlbackend
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/sourcegraph/sourcegraph/internal/search"
+	"github.com/sourcegraph/sourcegraph/internal/search/query"
+	"github.com/sourcegraph/sourcegraph/internal/symbols/protocol"
+)
+
+func TestSymbolFilters_symbolsParameters(t *testing.T) {
+	tests := []struct {
+		query   string
+		pattern string
+		want    search.SymbolsParameters
+	}{
+		{
+			query:   "type:symbol foo",
+			pattern: "foo",
+			want:    search.SymbolsParameters{Query: "foo"},
+		},
+		{
+			query:   "type:symbol select:symbol.method symbolparent:Bar foo",
+			pattern: "foo",
+			want:    search.SymbolsParameters{Query: "foo", Kinds: []string{"method", "methodspec"}, Parent: "Bar"},
+		},
+		{
+			query:   "type:symbol select:symbol foo",
+			pattern: "foo",
+			want:    search.SymbolsParameters{Query: "foo"},
+		},
+		{
+			query:   "type:symbol symbolmatch:exact foo.bar",
+			pattern: `foo\.bar`,
+			want:    search.SymbolsParameters{Query: "foo.bar", NameMatch: protocol.NameMatchExact},
+		},
+		{
+			query:   "type:symbol symbolmatch:prefix foo",
+			pattern: "foo",
+			want:    search.SymbolsParameters{Query: "foo", NameMatch: protocol.NameMatchPrefix},
+		},
+		{
+			query:   "type:symbol symbolmatch:fuzzy nbr",
+			pattern: "nbr",
+			want:    search.SymbolsParameters{Query: "nbr", NameMatch: protocol.NameMatchFuzzy},
+		},
+		{
+			query:   "type:symbol symbolmatch:regexp ^foo",
+			pattern: "^foo",
+			want:    search.SymbolsParameters{Query: "^foo"},
+		},
+		{
+			query:   "type:symbol lang:typescript lang:c++ foo",
+			pattern: "foo",
+			want:    search.SymbolsParameters{Query: "foo", Languages: []string{"TypeScript", "C++"}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.query, func(t *testing.T) {
+			q, err := query.ParseAndCheck(test.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			languages, _ := q.StringValues(query.FieldLang)
+			f := newSymbolFilters(q, &search.TextPatternInfo{Languages: languages})
+			if got := f.symbolsParameters(test.pattern); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestSymbolFilters_zoektArgs(t *testing.T) {
+	tests := []struct {
+		match query.SymbolMatch
+		want  string
+	}{
+		{match: query.SymbolMatchRegexp, want: `foo\.b`},
+		{match: query.SymbolMatchExact, want: `^foo\.b$`},
+		{match: query.SymbolMatchPrefix, want: `^foo\.b`},
+		{match: query.SymbolMatchFuzzy, want: `f.*o.*o.*\..*b`},
+	}
+	for _, test := range tests {
+		t.Run(string(test.match), func(t *testing.T) {
+			args := &search.TextParameters{PatternInfo: &search.TextPatternInfo{Pattern: `foo\.b`}}
+			f := &symbolFilters{match: test.match}
+			got := f.zoektArgs(args)
+			if got.PatternInfo.Pattern != test.want {
+				t.Errorf("got pattern %q, want %q", got.PatternInfo.Pattern, test.want)
+			}
+			if args.PatternInfo.Pattern != `foo\.b` {
+				t.Errorf("args were modified: %q", args.PatternInfo.Pattern)
+			}
+		})
+	}
+}
+
+func TestSymbolFilters_filterFileMatches(t *testing.T) {
+	symbol := func(name, kind, parent string) *searchSymbolResult {
+		return &searchSymbolResult{symbol: protocol.Symbol{Name: name, Kind: kind, Parent: parent}}
+	}
+	names := func(matches []*FileMatchResolver) (names [][]string) {
+		for _, fm := range matches {
+			var fileNames []string
+			for _, sym := range fm.symbols {
+				fileNames = append(fileNames, sym.symbol.Name)
+			}
+			names = append(names, fileNames)
+		}
+		return names
+	}
+	newMatches := func() []*FileMatchResolver {
+		return []*FileMatchResolver{
+			{JPath: "a.go", symbols: []*searchSymbolResult{
+				symbol("Bar", "struct", "a"),
+				symbol("Baz", "method", "Bar"),
+				symbol("qux", "func", "a"),
+			}},
+			{JPath: "b.ts", symbols: []*searchSymbolResult{
+				symbol("Bar", "class", ""),
+				symbol("baz", "method", "bar"),
+			}},
+		}
+	}
+
+	tests := []struct {
+		name    string
+		filters symbolFilters
+		want    [][]string
+	}{
+		{
+			name: "none",
+			want: [][]string{{"Bar", "Baz", "qux"}, {"Bar", "baz"}},
+		},
+		{
+			name:    "kinds",
+			filters: symbolFilters{kinds: lspSymbolKindToCtagsKinds("function")},
+			want:    [][]string{{"qux"}},
+		},
+		{
+			name:    "parent",
+			filters: symbolFilters{parent: "Bar"},
+			want:    [][]string{{"Baz"}, {"baz"}},
+		},
+		{
+			name:    "parent case sensitive",
+			filters: symbolFilters{parent: "Bar", isCaseSensitive: true},
+			want:    [][]string{{"Baz"}},
+		},
+		{
+			name:    "kinds and parent",
+			filters: symbolFilters{kinds: []string{"method"}, parent: "a"},
+			want:    nil,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := names(test.filters.filterFileMatches(newMatches())); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
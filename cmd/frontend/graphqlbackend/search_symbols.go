@@ -17,6 +17,7 @@ import (
 	"github.com/pkg/errors"
 	"github.com/sourcegraph/go-lsp"
 	"github.com/sourcegraph/sourcegraph/cmd/frontend/backend"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/db"
 	"github.com/sourcegraph/sourcegraph/cmd/frontend/types"
 	"github.com/sourcegraph/sourcegraph/internal/api"
 	"github.com/sourcegraph/sourcegraph/internal/conf"
@@ -129,6 +130,19 @@ func searchSymbols(ctx context.Context, args *search.TextParameters, limit int)
 		)
 	}
 
+	filters := newSymbolFilters(args.Query, args.PatternInfo)
+
+	var globalRepos []*search.RepositoryRevisions
+	if conf.GlobalSymbolsEnabled() {
+		globalRepos, searcherRepos, err = globalSymbolsIndexedRepos(ctx, searcherRepos)
+		if err != nil {
+			// Don't hard fail if the global symbol index is not available.
+			tr.LogFields(otlog.String("globalSymbolsErr", err.Error()))
+			log15.Warn("globalSymbolsIndexedRepos failed", "error", err)
+			err = nil
+		}
+	}
+
 	var (
 		run = parallel.NewRun(conf.SearchSymbolsParallelism())
 		mu  sync.Mutex
@@ -160,7 +174,8 @@ func searchSymbols(ctx context.Context, args *search.TextParameters, limit int)
 	run.Acquire()
 	goroutine.Go(func() {
 		defer run.Release()
-		matches, limitHit, reposLimitHit, searchErr := zoektSearchHEAD(ctx, args, zoektRepos, true, time.Since)
+		matches, limitHit, reposLimitHit, searchErr := zoektSearchHEAD(ctx, filters.zoektArgs(args), zoektRepos, true, time.Since)
+		matches = filters.filterFileMatches(matches)
 		mu.Lock()
 		defer mu.Unlock()
 		if ctx.Err() == nil {
@@ -183,6 +198,26 @@ func searchSymbols(ctx context.Context, args *search.TextParameters, limit int)
 		addMatches(matches)
 	})
 
+	if len(globalRepos) > 0 {
+		matches, globalErr := searchGlobalSymbols(ctx, globalRepos, args.PatternInfo, filters, limit)
+		if globalErr != nil {
+			// Fall back to asking the symbols service about each repository,
+			// which also searches for the patterns the index doesn't support.
+			tr.LogFields(otlog.String("globalSymbolsErr", globalErr.Error()))
+			if ctx.Err() == nil && globalErr != db.ErrGlobalSymbolsUnsupportedPattern {
+				log15.Warn("searchGlobalSymbols failed", "error", globalErr)
+			}
+			searcherRepos = append(searcherRepos, globalRepos...)
+		} else {
+			mu.Lock()
+			for _, repo := range globalRepos {
+				common.searched = append(common.searched, repo.Repo)
+			}
+			addMatches(matches)
+			mu.Unlock()
+		}
+	}
+
 	for _, repoRevs := range searcherRepos {
 		repoRevs := repoRevs
 		if ctx.Err() != nil {
@@ -194,7 +229,7 @@ func searchSymbols(ctx context.Context, args *search.TextParameters, limit int)
 		run.Acquire()
 		goroutine.Go(func() {
 			defer run.Release()
-			repoSymbols, repoErr := searchSymbolsInRepo(ctx, repoRevs, args.PatternInfo, args.Query, limit)
+			repoSymbols, repoErr := searchSymbolsInRepo(ctx, repoRevs, args.PatternInfo, filters, limit)
 			if repoErr != nil {
 				tr.LogFields(otlog.String("repo", string(repoRevs.Repo.Name)), otlog.String("repoErr", repoErr.Error()), otlog.Bool("timeout", errcode.IsTimeout(repoErr)), otlog.Bool("temporary", errcode.IsTemporary(repoErr)))
 			}
@@ -251,7 +286,7 @@ func symbolCount(fmrs []*FileMatchResolver) int {
 	return nsym
 }
 
-func searchSymbolsInRepo(ctx context.Context, repoRevs *search.RepositoryRevisions, patternInfo *search.TextPatternInfo, query query.QueryInfo, limit int) (res []*FileMatchResolver, err error) {
+func searchSymbolsInRepo(ctx context.Context, repoRevs *search.RepositoryRevisions, patternInfo *search.TextPatternInfo, filters *symbolFilters, limit int) (res []*FileMatchResolver, err error) {
 	span, ctx := ot.StartSpanFromContext(ctx, "Search symbols in repo")
 	defer func() {
 		if err != nil {
@@ -286,17 +321,124 @@ func searchSymbolsInRepo(ctx context.Context, repoRevs *search.RepositoryRevisio
 		// NOTE: Not all fields are set, for performance.
 	}
 
-	symbols, err := backend.Symbols.ListTags(ctx, search.SymbolsParameters{
-		Repo:            repoRevs.Repo.Name,
-		CommitID:        commitID,
-		Query:           patternInfo.Pattern,
-		IsCaseSensitive: patternInfo.IsCaseSensitive,
-		IsRegExp:        patternInfo.IsRegExp,
-		IncludePatterns: patternInfo.IncludePatterns,
-		ExcludePattern:  patternInfo.ExcludePattern,
-		// Ask for limit + 1 so we can detect whether there are more results than the limit.
-		First: limit + 1,
-	})
+	params := filters.symbolsParameters(patternInfo.Pattern)
+	params.Repo = repoRevs.Repo.Name
+	params.CommitID = commitID
+	params.IsCaseSensitive = patternInfo.IsCaseSensitive
+	params.IsRegExp = patternInfo.IsRegExp
+	params.IncludePatterns = patternInfo.IncludePatterns
+	params.ExcludePattern = patternInfo.ExcludePattern
+	// Ask for limit + 1 so we can detect whether there are more results than the limit.
+	params.First = limit + 1
+	symbols, err := backend.Symbols.ListTags(ctx, params)
+	return symbolFileMatches(symbols, repoResolver, commitResolver, baseURI, inputRev), err
+}
+
+// globalSymbolsIndexedRepos splits repos into the repositories whose default
+// branch is searched and is in the global symbol index, and the others.
+func globalSymbolsIndexedRepos(ctx context.Context, repos []*search.RepositoryRevisions) (indexed, unindexed []*search.RepositoryRevisions, err error) {
+	var (
+		defaultBranch []*search.RepositoryRevisions
+		ids           []api.RepoID
+	)
+	for _, repoRevs := range repos {
+		if revs := repoRevs.RevSpecs(); len(repoRevs.Revs) == 1 && len(revs) == 1 && (revs[0] == "" || revs[0] == "HEAD") {
+			defaultBranch = append(defaultBranch, repoRevs)
+			ids = append(ids, repoRevs.Repo.ID)
+		} else {
+			unindexed = append(unindexed, repoRevs)
+		}
+	}
+	if len(defaultBranch) == 0 {
+		return nil, repos, nil
+	}
+
+	commits, err := db.GlobalSymbols.IndexedCommits(ctx, ids)
+	if err != nil {
+		return nil, repos, err
+	}
+	for _, repoRevs := range defaultBranch {
+		if _, ok := commits[repoRevs.Repo.ID]; ok {
+			indexed = append(indexed, repoRevs)
+		} else {
+			unindexed = append(unindexed, repoRevs)
+		}
+	}
+	return indexed, unindexed, nil
+}
+
+// searchGlobalSymbols searches the default branch of repos, which must all be
+// in the global symbol index, with a single query on the index. The symbols
+// are from the indexed commit, which may be behind the default branch.
+func searchGlobalSymbols(ctx context.Context, repos []*search.RepositoryRevisions, patternInfo *search.TextPatternInfo, filters *symbolFilters, limit int) (res []*FileMatchResolver, err error) {
+	span, ctx := ot.StartSpanFromContext(ctx, "Search global symbols")
+	defer func() {
+		if err != nil {
+			ext.Error.Set(span, true)
+			span.LogFields(otlog.Error(err))
+		}
+		span.Finish()
+	}()
+	span.SetTag("repos", len(repos))
+
+	params := filters.symbolsParameters(patternInfo.Pattern)
+	params.IsCaseSensitive = patternInfo.IsCaseSensitive
+	params.IsRegExp = patternInfo.IsRegExp
+	params.IncludePatterns = patternInfo.IncludePatterns
+	params.ExcludePattern = patternInfo.ExcludePattern
+	// Ask for limit + 1 so we can detect whether there are more results than the limit.
+	params.First = limit + 1
+
+	reposByID := make(map[api.RepoID]*search.RepositoryRevisions, len(repos))
+	ids := make([]api.RepoID, len(repos))
+	for i, repoRevs := range repos {
+		reposByID[repoRevs.Repo.ID] = repoRevs
+		ids[i] = repoRevs.Repo.ID
+	}
+	globalSymbols, err := db.GlobalSymbols.Search(ctx, ids, params)
+	if err != nil {
+		return nil, err
+	}
+
+	// Group the symbols by repository, keeping the order of the index.
+	var (
+		repoIDs       []api.RepoID
+		commitIDs     = make(map[api.RepoID]api.CommitID)
+		symbolsByRepo = make(map[api.RepoID][]protocol.Symbol)
+	)
+	for _, sym := range globalSymbols {
+		if _, ok := symbolsByRepo[sym.RepoID]; !ok {
+			repoIDs = append(repoIDs, sym.RepoID)
+			commitIDs[sym.RepoID] = sym.CommitID
+		}
+		symbolsByRepo[sym.RepoID] = append(symbolsByRepo[sym.RepoID], sym.Symbol)
+	}
+
+	fileMatches := make([]*FileMatchResolver, 0)
+	for _, id := range repoIDs {
+		repoRevs, ok := reposByID[id]
+		if !ok {
+			continue
+		}
+		inputRev := repoRevs.RevSpecs()[0]
+		baseURI, err := gituri.Parse("git://" + string(repoRevs.Repo.Name) + "?" + url.QueryEscape(inputRev))
+		if err != nil {
+			return nil, err
+		}
+		repoResolver := NewRepositoryResolver(repoRevs.Repo)
+		commitResolver := &GitCommitResolver{
+			repoResolver: repoResolver,
+			oid:          GitObjectID(commitIDs[id]),
+			inputRev:     &inputRev,
+			// NOTE: Not all fields are set, for performance.
+		}
+		fileMatches = append(fileMatches, symbolFileMatches(symbolsByRepo[id], repoResolver, commitResolver, baseURI, inputRev)...)
+	}
+	return fileMatches, nil
+}
+
+// symbolFileMatches groups the symbols of a commit by file.
+func symbolFileMatches(symbols []protocol.Symbol, repoResolver *RepositoryResolver, commitResolver *GitCommitResolver, baseURI *gituri.URI, inputRev string) []*FileMatchResolver {
 	fileMatchesByURI := make(map[string]*FileMatchResolver)
 	fileMatches := make([]*FileMatchResolver, 0)
 
@@ -324,7 +466,7 @@ func searchSymbolsInRepo(ctx context.Context, repoRevs *search.RepositoryRevisio
 			fileMatches = append(fileMatches, fileMatch)
 		}
 	}
-	return fileMatches, err
+	return fileMatches
 }
 
 // makeFileMatchURIFromSymbol makes a git://repo?rev#path URI from a symbol
@@ -360,62 +502,93 @@ func ctagsSymbolCharacter(s protocol.Symbol) int {
 	return 0
 }
 
+// lspSymbolKinds maps ctags kinds to LSP symbol kinds. Ctags kinds are
+// determined by the parser and do not (in general) match LSP symbol kinds.
+var lspSymbolKinds = map[string]lsp.SymbolKind{
+	"file":            lsp.SKFile,
+	"module":          lsp.SKModule,
+	"namespace":       lsp.SKNamespace,
+	"package":         lsp.SKPackage,
+	"packagename":     lsp.SKPackage,
+	"subprogspec":     lsp.SKPackage,
+	"class":           lsp.SKClass,
+	"type":            lsp.SKClass,
+	"service":         lsp.SKClass,
+	"typedef":         lsp.SKClass,
+	"union":           lsp.SKClass,
+	"section":         lsp.SKClass,
+	"subtype":         lsp.SKClass,
+	"component":       lsp.SKClass,
+	"method":          lsp.SKMethod,
+	"methodspec":      lsp.SKMethod,
+	"property":        lsp.SKProperty,
+	"field":           lsp.SKField,
+	"member":          lsp.SKField,
+	"anonmember":      lsp.SKField,
+	"recordfield":     lsp.SKField,
+	"constructor":     lsp.SKConstructor,
+	"enum":            lsp.SKEnum,
+	"enumerator":      lsp.SKEnum,
+	"interface":       lsp.SKInterface,
+	"function":        lsp.SKFunction,
+	"func":            lsp.SKFunction,
+	"subroutine":      lsp.SKFunction,
+	"macro":           lsp.SKFunction,
+	"subprogram":      lsp.SKFunction,
+	"procedure":       lsp.SKFunction,
+	"command":         lsp.SKFunction,
+	"singletonmethod": lsp.SKFunction,
+	"variable":        lsp.SKVariable,
+	"var":             lsp.SKVariable,
+	"functionvar":     lsp.SKVariable,
+	"define":          lsp.SKVariable,
+	"alias":           lsp.SKVariable,
+	"val":             lsp.SKVariable,
+	"constant":        lsp.SKConstant,
+	"const":           lsp.SKConstant,
+	"string":          lsp.SKString,
+	"message":         lsp.SKString,
+	"heredoc":         lsp.SKString,
+	"number":          lsp.SKNumber,
+	"bool":            lsp.SKBoolean,
+	"boolean":         lsp.SKBoolean,
+	"array":           lsp.SKArray,
+	"object":          lsp.SKObject,
+	"literal":         lsp.SKObject,
+	"map":             lsp.SKObject,
+	"key":             lsp.SKKey,
+	"label":           lsp.SKKey,
+	"target":          lsp.SKKey,
+	"selector":        lsp.SKKey,
+	"id":              lsp.SKKey,
+	"tag":             lsp.SKKey,
+	"null":            lsp.SKNull,
+	"enum member":     lsp.SKEnumMember,
+	"enumconstant":    lsp.SKEnumMember,
+	"struct":          lsp.SKStruct,
+	"event":           lsp.SKEvent,
+	"operator":        lsp.SKOperator,
+	"type parameter":  lsp.SKTypeParameter,
+	"annotation":      lsp.SKTypeParameter,
+}
+
 func ctagsKindToLSPSymbolKind(kind string) lsp.SymbolKind {
-	// Ctags kinds are determined by the parser and do not (in general) match LSP symbol kinds.
-	switch strings.ToLower(kind) {
-	case "file":
-		return lsp.SKFile
-	case "module":
-		return lsp.SKModule
-	case "namespace":
-		return lsp.SKNamespace
-	case "package", "packagename", "subprogspec":
-		return lsp.SKPackage
-	case "class", "type", "service", "typedef", "union", "section", "subtype", "component":
-		return lsp.SKClass
-	case "method", "methodspec":
-		return lsp.SKMethod
-	case "property":
-		return lsp.SKProperty
-	case "field", "member", "anonmember", "recordfield":
-		return lsp.SKField
-	case "constructor":
-		return lsp.SKConstructor
-	case "enum", "enumerator":
-		return lsp.SKEnum
-	case "interface":
-		return lsp.SKInterface
-	case "function", "func", "subroutine", "macro", "subprogram", "procedure", "command", "singletonmethod":
-		return lsp.SKFunction
-	case "variable", "var", "functionvar", "define", "alias", "val":
-		return lsp.SKVariable
-	case "constant", "const":
-		return lsp.SKConstant
-	case "string", "message", "heredoc":
-		return lsp.SKString
-	case "number":
-		return lsp.SKNumber
-	case "bool", "boolean":
-		return lsp.SKBoolean
-	case "array":
-		return lsp.SKArray
-	case "object", "literal", "map":
-		return lsp.SKObject
-	case "key", "label", "target", "selector", "id", "tag":
-		return lsp.SKKey
-	case "null":
-		return lsp.SKNull
-	case "enum member", "enumconstant":
-		return lsp.SKEnumMember
-	case "struct":
-		return lsp.SKStruct
-	case "event":
-		return lsp.SKEvent
-	case "operator":
-		return lsp.SKOperator
-	case "type parameter", "annotation":
-		return lsp.SKTypeParameter
+	if k, ok := lspSymbolKinds[strings.ToLower(kind)]; ok {
+		return k
 	}
 	log15.Debug("Unknown ctags kind", "kind", kind)
 	return 0
 }
+
+// lspSymbolKindToCtagsKinds returns the (lowercase) ctags kinds of the LSP
+// symbol kind with the given lowercase name, such as "function".
+func lspSymbolKindToCtagsKinds(name string) []string {
+	var kinds []string
+	for kind, k := range lspSymbolKinds {
+		if strings.ToLower(k.String()) == name {
+			kinds = append(kinds, kind)
+		}
+	}
+	sort.Strings(kinds)
+	return kinds
+}
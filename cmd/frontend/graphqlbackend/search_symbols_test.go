@@ -1,16 +1,28 @@
 package graphqlbackend
 
 import (
+	"context"
+	"errors"
 	"reflect"
+	"sort"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/google/go-cmp/cmp"
 
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/backend"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/db"
 	"github.com/sourcegraph/sourcegraph/cmd/frontend/types"
+	"github.com/sourcegraph/sourcegraph/internal/api"
+	"github.com/sourcegraph/sourcegraph/internal/conf"
 	"github.com/sourcegraph/sourcegraph/internal/gituri"
+	"github.com/sourcegraph/sourcegraph/internal/search"
+	searchbackend "github.com/sourcegraph/sourcegraph/internal/search/backend"
+	"github.com/sourcegraph/sourcegraph/internal/search/query"
 	"github.com/sourcegraph/sourcegraph/internal/symbols/protocol"
 	"github.com/sourcegraph/sourcegraph/internal/vcs/git"
+	"github.com/sourcegraph/sourcegraph/schema"
 )
 
 func TestMakeFileMatchURIFromSymbol(t *testing.T) {
@@ -257,3 +269,106 @@ func TestLimitingSymbolResults(t *testing.T) {
 		}
 	})
 }
+
+func TestSearchSymbols_globalSymbols(t *testing.T) {
+	conf.Mock(&conf.Unified{SiteConfiguration: schema.SiteConfiguration{
+		SearchGlobalSymbols: &schema.SearchGlobalSymbols{Enabled: true},
+	}})
+	defer conf.Mock(nil)
+	defer func() {
+		db.Mocks.GlobalSymbols = db.MockGlobalSymbols{}
+		backend.Mocks.Symbols = backend.MockSymbols{}
+		git.ResetMocks()
+	}()
+
+	q, err := query.ParseAndCheck("type:symbol foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	indexed := &types.Repo{ID: 1, Name: "indexed"}
+	unindexed := &types.Repo{ID: 2, Name: "unindexed"}
+	args := &search.TextParameters{
+		PatternInfo: &search.TextPatternInfo{Pattern: "foo", FileMatchLimit: 10},
+		Repos: []*search.RepositoryRevisions{
+			{Repo: indexed, Revs: []search.RevisionSpecifier{{RevSpec: ""}}},
+			{Repo: unindexed, Revs: []search.RevisionSpecifier{{RevSpec: ""}}},
+			{Repo: indexed, Revs: []search.RevisionSpecifier{{RevSpec: "v1"}}},
+		},
+		Query: q,
+		Zoekt: &searchbackend.Zoekt{},
+	}
+
+	db.Mocks.GlobalSymbols.IndexedCommits = func(ctx context.Context, repoIDs []api.RepoID) (map[api.RepoID]api.CommitID, error) {
+		if want := []api.RepoID{1, 2}; !reflect.DeepEqual(repoIDs, want) {
+			t.Errorf("got repositories %v, want the repositories searched at their default branch %v", repoIDs, want)
+		}
+		return map[api.RepoID]api.CommitID{1: "c1"}, nil
+	}
+	db.Mocks.GlobalSymbols.Search = func(ctx context.Context, repoIDs []api.RepoID, params search.SymbolsParameters) ([]*types.GlobalSymbol, error) {
+		if want := []api.RepoID{1}; !reflect.DeepEqual(repoIDs, want) {
+			t.Errorf("got repositories %v, want %v", repoIDs, want)
+		}
+		if params.Query != "foo" || params.First != 11 {
+			t.Errorf("got params %+v", params)
+		}
+		return []*types.GlobalSymbol{
+			{RepoID: 1, CommitID: "c1", Symbol: protocol.Symbol{Name: "foo", Path: "a.go"}},
+			{RepoID: 1, CommitID: "c1", Symbol: protocol.Symbol{Name: "fooBar", Path: "a.go"}},
+		}, nil
+	}
+	git.Mocks.ResolveRevision = func(spec string, opt *git.ResolveRevisionOptions) (api.CommitID, error) {
+		return api.CommitID("resolved-" + spec), nil
+	}
+	var (
+		mu       sync.Mutex
+		searched []string
+	)
+	backend.Mocks.Symbols.ListTags = func(ctx context.Context, params search.SymbolsParameters) ([]protocol.Symbol, error) {
+		mu.Lock()
+		searched = append(searched, string(params.Repo)+"@"+string(params.CommitID))
+		mu.Unlock()
+		return []protocol.Symbol{{Name: "foo", Path: "b.go"}}, nil
+	}
+
+	res, common, err := searchSymbols(context.Background(), args, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(searched)
+	if want := []string{"indexed@resolved-v1", "unindexed@resolved-"}; !reflect.DeepEqual(searched, want) {
+		t.Errorf("searched %q with the symbols service, want %q", searched, want)
+	}
+	if len(common.searched) != 3 {
+		t.Errorf("got %d searched repositories, want 3", len(common.searched))
+	}
+
+	var got []string
+	for _, fm := range res {
+		for _, sym := range fm.symbols {
+			got = append(got, fm.uri+"@"+string(fm.CommitID)+":"+sym.symbol.Name)
+		}
+	}
+	sort.Strings(got)
+	want := []string{
+		"git://indexed#a.go@c1:foo",
+		"git://indexed#a.go@c1:fooBar",
+		"git://indexed?v1#b.go@resolved-v1:foo",
+		"git://unindexed#b.go@resolved-:foo",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	// Searches fall back to the symbols service when the index fails.
+	db.Mocks.GlobalSymbols.Search = func(ctx context.Context, repoIDs []api.RepoID, params search.SymbolsParameters) ([]*types.GlobalSymbol, error) {
+		return nil, errors.New("invalid regular expression")
+	}
+	searched = nil
+	if _, _, err := searchSymbols(context.Background(), args, 10); err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(searched)
+	if want := []string{"indexed@resolved-", "indexed@resolved-v1", "unindexed@resolved-"}; !reflect.DeepEqual(searched, want) {
+		t.Errorf("searched %q with the symbols service, want %q", searched, want)
+	}
+}
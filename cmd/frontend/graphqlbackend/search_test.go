@@ -13,6 +13,7 @@ import (
 	"github.com/graph-gophers/graphql-go"
 	"github.com/sourcegraph/sourcegraph/cmd/frontend/db"
 	"github.com/sourcegraph/sourcegraph/cmd/frontend/types"
+	"github.com/sourcegraph/sourcegraph/internal/actor"
 	"github.com/sourcegraph/sourcegraph/internal/api"
 	"github.com/sourcegraph/sourcegraph/internal/conf"
 	"github.com/sourcegraph/sourcegraph/internal/search"
@@ -540,6 +541,14 @@ func TestVersionContext(t *testing.T) {
 	mockDecodedViewerFinalSettings = &schema.Settings{}
 	defer func() { mockDecodedViewerFinalSettings = nil }()
 
+	// Version contexts in the site configuration are resolved without
+	// looking up search contexts.
+	db.Mocks.SearchContexts.GetBySpec = func(ctx context.Context, namespaceName, name string) (*types.SearchContext, error) {
+		t.Errorf("unexpected search context lookup of %q", name)
+		return nil, db.ErrSearchContextNotFound
+	}
+	defer func() { db.Mocks.SearchContexts = db.MockSearchContexts{} }()
+
 	tcs := []struct {
 		name           string
 		searchQuery    string
@@ -608,7 +617,6 @@ func TestVersionContext(t *testing.T) {
 		versionContext: "multiple-revs",
 		wantReposListOptionsNames: []string{
 			"github.com/sourcegraph/foobar",
-			"github.com/sourcegraph/foobar", // we don't mind listing repos twice
 			"github.com/sourcegraph/bar",
 		},
 		reposGetListNames: []string{
@@ -659,6 +667,213 @@ func TestVersionContext(t *testing.T) {
 	}
 }
 
+func TestSearchContext(t *testing.T) {
+	mockDecodedViewerFinalSettings = &schema.Settings{}
+	defer func() { mockDecodedViewerFinalSettings = nil }()
+
+	alice := int32(1)
+	contexts := map[string]*types.SearchContext{
+		"/ctx":          {ID: 1, Name: "ctx", Public: true},
+		"alice/private": {ID: 2, Name: "private", NamespaceUserID: &alice, NamespaceName: "alice"},
+		"alice/empty":   {ID: 3, Name: "empty", Public: true, NamespaceUserID: &alice, NamespaceName: "alice"},
+	}
+	db.Mocks.SearchContexts.GetBySpec = func(ctx context.Context, namespaceName, name string) (*types.SearchContext, error) {
+		if sc, ok := contexts[namespaceName+"/"+name]; ok {
+			return sc, nil
+		}
+		return nil, db.ErrSearchContextNotFound
+	}
+	db.Mocks.SearchContexts.GetRepositoryRevisions = func(ctx context.Context, id int64) ([]*types.SearchContextRepositoryRevisions, error) {
+		if id != 1 {
+			return nil, nil
+		}
+		return []*types.SearchContextRepositoryRevisions{
+			{RepoID: 1, RepoName: "github.com/sourcegraph/foo", Revisions: []string{"some-branch"}},
+			{RepoID: 2, RepoName: "github.com/sourcegraph/bar", Revisions: []string{"", "v1.0.0"}},
+		}, nil
+	}
+	db.Mocks.Repos.Count = func(ctx context.Context, opt db.ReposListOptions) (int, error) { return 0, nil }
+	// The current user is not alice, and not a site admin.
+	db.Mocks.Users.GetByCurrentAuthUser = func(ctx context.Context) (*types.User, error) {
+		return &types.User{ID: 2, Username: "bob"}, nil
+	}
+	db.Mocks.Users.GetByID = func(ctx context.Context, id int32) (*types.User, error) {
+		return &types.User{ID: id, Username: "alice"}, nil
+	}
+	git.Mocks.ResolveRevision = func(spec string, opt *git.ResolveRevisionOptions) (api.CommitID, error) {
+		return api.CommitID("deadbeef"), nil
+	}
+	defer func() {
+		db.Mocks.SearchContexts = db.MockSearchContexts{}
+		db.Mocks.Repos.Count = nil
+		db.Mocks.Users = db.MockUsers{}
+		git.ResetMocks()
+	}()
+
+	tcs := []struct {
+		name                      string
+		searchQuery               string
+		versionContext            string
+		wantReposListOptionsNames []string
+		reposGetListNames         []string
+		wantResults               []string
+		wantErr                   string
+	}{{
+		name:                      "context: field limits repositories and revisions",
+		searchQuery:               "context:ctx foo",
+		wantReposListOptionsNames: []string{"github.com/sourcegraph/foo", "github.com/sourcegraph/bar"},
+		reposGetListNames:         []string{"github.com/sourcegraph/foo", "github.com/sourcegraph/bar"},
+		wantResults:               []string{"github.com/sourcegraph/foo@some-branch", "github.com/sourcegraph/bar@:v1.0.0"},
+	}, {
+		name:                      "deprecated versionContext argument resolves search contexts",
+		searchQuery:               "foo",
+		versionContext:            "ctx",
+		wantReposListOptionsNames: []string{"github.com/sourcegraph/foo", "github.com/sourcegraph/bar"},
+		reposGetListNames:         []string{"github.com/sourcegraph/foo"},
+		wantResults:               []string{"github.com/sourcegraph/foo@some-branch"},
+	}, {
+		name:                      "revisions in the query take precedence",
+		searchQuery:               "context:ctx repo:foo@v2",
+		wantReposListOptionsNames: []string{"github.com/sourcegraph/foo", "github.com/sourcegraph/bar"},
+		reposGetListNames:         []string{"github.com/sourcegraph/foo"},
+		wantResults:               []string{"github.com/sourcegraph/foo@v2"},
+	}, {
+		name:        "empty search context searches nothing",
+		searchQuery: "context:@alice/empty foo",
+	}, {
+		name:        "private search context of another user is not found",
+		searchQuery: "context:@alice/private foo",
+		wantErr:     `search context "@alice/private" not found`,
+	}, {
+		name:        "unknown search context",
+		searchQuery: "context:nope foo",
+		wantErr:     `search context "nope" not found`,
+	}, {
+		name:        "invalid search context",
+		searchQuery: "context:@alice foo",
+		wantErr:     `invalid search context "@alice": expected @namespace/name`,
+	}}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			qinfo, err := query.ParseAndCheck(tc.searchQuery)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			resolver := searchResolver{query: qinfo}
+			if tc.versionContext != "" {
+				resolver.versionContext = &tc.versionContext
+			}
+
+			db.Mocks.Repos.List = func(ctx context.Context, opts db.ReposListOptions) ([]*types.Repo, error) {
+				if diff := cmp.Diff(tc.wantReposListOptionsNames, opts.Names, cmpopts.EquateEmpty()); diff != "" {
+					t.Fatalf("db.RepostListOptions.Names mismatch (-want, +got):\n%s", diff)
+				}
+				var repos []*types.Repo
+				for _, name := range tc.reposGetListNames {
+					repos = append(repos, &types.Repo{Name: api.RepoName(name)})
+				}
+				return repos, nil
+			}
+			defer func() { db.Mocks.Repos.List = nil }()
+
+			gotResults, _, _, _, err := resolver.resolveRepositories(actor.WithActor(context.Background(), &actor.Actor{UID: 2}), nil)
+			if tc.wantErr != "" {
+				if err == nil || err.Error() != tc.wantErr {
+					t.Fatalf("got error %v, want %q", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, reporev := range gotResults {
+				got = append(got, string(reporev.Repo.Name)+"@"+strings.Join(reporev.RevSpecs(), ":"))
+			}
+
+			if diff := cmp.Diff(tc.wantResults, got, cmpopts.EquateEmpty()); diff != "" {
+				t.Fatalf("mismatch (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestUnmarshalSearchContextRepositoryRevisions(t *testing.T) {
+	got, err := unmarshalSearchContextRepositoryRevisions([]searchContextRepositoryRevisionsInput{
+		{RepositoryID: MarshalRepositoryID(1), Revisions: []string{"main", "v1"}},
+		{RepositoryID: MarshalRepositoryID(2)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A repository without revisions is searched at its default branch.
+	want := []*types.SearchContextRepositoryRevisions{
+		{RepoID: 1, Revisions: []string{"main", "v1"}},
+		{RepoID: 2, Revisions: []string{""}},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestRepoMetadataPredicates(t *testing.T) {
+	mockDecodedViewerFinalSettings = &schema.Settings{}
+	defer func() { mockDecodedViewerFinalSettings = nil }()
+	db.Mocks.Repos.Count = func(ctx context.Context, opt db.ReposListOptions) (int, error) { return 0, nil }
+	defer func() { db.Mocks.Repos.Count = nil }()
+
+	tcs := []struct {
+		searchQuery         string
+		wantIncludePatterns []string
+		wantExcludePattern  string
+		wantMetadataFilters []db.RepoMetadataFilter
+	}{{
+		searchQuery:         "repo:has.topic(payments) foo",
+		wantMetadataFilters: []db.RepoMetadataFilter{{Kind: db.RepoMetadataTopic, Value: "payments"}},
+	}, {
+		searchQuery:         "repo:^github\\.com/ repo:has.label(backend) -repo:has.project(OPS) -repo:legacy foo",
+		wantIncludePatterns: []string{"^github\\.com/"},
+		wantExcludePattern:  "legacy",
+		wantMetadataFilters: []db.RepoMetadataFilter{
+			{Kind: db.RepoMetadataLabel, Value: "backend"},
+			{Kind: db.RepoMetadataProject, Value: "OPS", Negated: true},
+		},
+	}}
+	for _, tc := range tcs {
+		t.Run(tc.searchQuery, func(t *testing.T) {
+			qinfo, err := query.ParseAndCheck(tc.searchQuery)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			calledList := false
+			db.Mocks.Repos.List = func(ctx context.Context, opts db.ReposListOptions) ([]*types.Repo, error) {
+				calledList = true
+				if diff := cmp.Diff(tc.wantIncludePatterns, opts.IncludePatterns, cmpopts.EquateEmpty()); diff != "" {
+					t.Errorf("IncludePatterns mismatch (-want, +got):\n%s", diff)
+				}
+				if opts.ExcludePattern != tc.wantExcludePattern {
+					t.Errorf("got ExcludePattern %q, want %q", opts.ExcludePattern, tc.wantExcludePattern)
+				}
+				if diff := cmp.Diff(tc.wantMetadataFilters, opts.MetadataFilters, cmpopts.EquateEmpty()); diff != "" {
+					t.Errorf("MetadataFilters mismatch (-want, +got):\n%s", diff)
+				}
+				return nil, nil
+			}
+			defer func() { db.Mocks.Repos.List = nil }()
+
+			resolver := searchResolver{query: qinfo}
+			if _, _, _, _, err := resolver.resolveRepositories(context.Background(), nil); err != nil {
+				t.Fatal(err)
+			}
+			if !calledList {
+				t.Error("expected db.Repos.List to be called")
+			}
+		})
+	}
+}
+
 func TestComputeExcludedRepositories(t *testing.T) {
 	cases := []struct {
 		Name              string
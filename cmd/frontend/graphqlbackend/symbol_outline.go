@@ -0,0 +1,230 @@
+package graphqlbackend
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sourcegraph/go-lsp"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/backend"
+	"github.com/sourcegraph/sourcegraph/internal/api"
+	"github.com/sourcegraph/sourcegraph/internal/gituri"
+	"github.com/sourcegraph/sourcegraph/internal/symbols/protocol"
+)
+
+// maxSymbolOutlineSymbols is the maximum number of symbols in the outline of
+// a file. Larger files have no outline, because an outline missing some
+// symbols would also miss the parents of others.
+const maxSymbolOutlineSymbols = 100000
+
+// SymbolOutline returns the symbols of the blob as a tree, built from the
+// parents of the symbols that the symbols service extracts.
+func (r *GitTreeEntryResolver) SymbolOutline(ctx context.Context) ([]*symbolOutlineNodeResolver, error) {
+	ctx, done := context.WithTimeout(ctx, 5*time.Second)
+	defer done()
+
+	// Ask for one more symbol than the maximum to detect larger files.
+	symbols, err := backend.Symbols.List(ctx, protocol.ListArgs{
+		Repo:     r.commit.repoResolver.repo.Name,
+		CommitID: api.CommitID(r.commit.oid),
+		Path:     r.Path(),
+		First:    maxSymbolOutlineSymbols + 1,
+	})
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, errors.New("processing symbols is taking longer than expected. Try again in a while")
+		}
+		return nil, err
+	}
+	if len(symbols) > maxSymbolOutlineSymbols {
+		return nil, fmt.Errorf("the file has more than %d symbols, which is too many to outline", maxSymbolOutlineSymbols)
+	}
+	baseURI, err := gituri.Parse("git://" + string(r.commit.repoResolver.repo.Name) + "?" + string(r.commit.oid))
+	if err != nil {
+		return nil, err
+	}
+
+	var toResolvers func(nodes []*symbolOutlineNode) []*symbolOutlineNodeResolver
+	toResolvers = func(nodes []*symbolOutlineNode) []*symbolOutlineNodeResolver {
+		resolvers := make([]*symbolOutlineNodeResolver, len(nodes))
+		for i, node := range nodes {
+			resolvers[i] = &symbolOutlineNodeResolver{
+				symbol:   toSymbolResolver(node.symbol, baseURI, strings.ToLower(node.symbol.Language), r.commit),
+				lspRange: symbolOutlineRange(node.symbol),
+				children: toResolvers(node.children),
+			}
+		}
+		return resolvers
+	}
+	return toResolvers(buildSymbolOutline(symbols)), nil
+}
+
+// symbolOutlineNode is a symbol in the outline of a file, with the symbols
+// defined in it.
+type symbolOutlineNode struct {
+	symbol   protocol.Symbol
+	children []*symbolOutlineNode
+}
+
+// buildSymbolOutline arranges the symbols of a file in a tree by their
+// parents, in the order of their lines. The parent of a symbol is a symbol
+// named after the last component of its Parent (ctags scopes can be
+// qualified, like "Foo.bar"), of its ParentKind if the file has any such
+// symbol. Of these, it is the innermost one whose scope is open on the line of
+// the symbol, or else the closest one before it, or else the first one after
+// it. Symbols whose parent is not in the file, like the package of a Go type,
+// are at the root.
+//
+// The parents are assigned in a single pass over the symbols, with a stack of
+// the scopes open on the current line. A symbol whose end is unknown is open
+// until the scope that contains it ends.
+func buildSymbolOutline(symbols []protocol.Symbol) []*symbolOutlineNode {
+	symbols = append([]protocol.Symbol(nil), symbols...)
+	sort.SliceStable(symbols, func(i, j int) bool { return symbols[i].Line < symbols[j].Line })
+
+	type scopeKey struct{ name, kind string }
+	keys := func(sym protocol.Symbol) []scopeKey {
+		if sym.Kind == "" {
+			return []scopeKey{{name: sym.Name}}
+		}
+		return []scopeKey{{name: sym.Name}, {name: sym.Name, kind: sym.Kind}}
+	}
+	all := make(map[scopeKey][]int) // the symbols of each key, in order
+	for i, sym := range symbols {
+		for _, k := range keys(sym) {
+			all[k] = append(all[k], i)
+		}
+	}
+	parentKey := func(sym protocol.Symbol) scopeKey {
+		k := scopeKey{name: lastScopeComponent(sym.Parent), kind: sym.ParentKind}
+		if k.kind == "" || len(all[k]) == 0 {
+			k.kind = ""
+		}
+		return k
+	}
+
+	parents := make([]int, len(symbols))
+	var (
+		stack []int                       // the open scopes, innermost last
+		ends  = make([]int, len(symbols)) // the last line of each open scope
+		open  = make(map[scopeKey][]int)  // the open scopes of each key, innermost last
+		last  = make(map[scopeKey]int)    // the last symbol of each key so far
+		later []int                       // the symbols whose parent comes after them
+	)
+	for i, sym := range symbols {
+		for len(stack) > 0 && ends[stack[len(stack)-1]] < sym.Line {
+			closed := symbols[stack[len(stack)-1]]
+			stack = stack[:len(stack)-1]
+			for _, k := range keys(closed) {
+				open[k] = open[k][:len(open[k])-1]
+			}
+		}
+
+		parents[i] = -1
+		if sym.Parent != "" {
+			k := parentKey(sym)
+			if s := open[k]; len(s) > 0 {
+				parents[i] = s[len(s)-1]
+			} else if p, ok := last[k]; ok {
+				parents[i] = p
+			} else if len(all[k]) > 0 {
+				later = append(later, i)
+			}
+		}
+
+		ends[i] = math.MaxInt32
+		if sym.EndLine >= sym.Line {
+			ends[i] = sym.EndLine
+		}
+		if len(stack) > 0 && ends[stack[len(stack)-1]] < ends[i] {
+			ends[i] = ends[stack[len(stack)-1]]
+		}
+		stack = append(stack, i)
+		for _, k := range keys(sym) {
+			open[k] = append(open[k], i)
+			last[k] = i
+		}
+	}
+
+	// The parents assigned so far come before their children, so they form a
+	// forest. Link the symbols whose parent comes after them, unless that
+	// would make a symbol its own ancestor, tracking the root of the tree of
+	// each symbol with a union-find.
+	treeRoots := append([]int(nil), parents...)
+	treeRoot := func(i int) int {
+		r := i
+		for treeRoots[r] >= 0 {
+			r = treeRoots[r]
+		}
+		for i != r {
+			i, treeRoots[i] = treeRoots[i], r
+		}
+		return r
+	}
+	for _, i := range later {
+		for _, c := range all[parentKey(symbols[i])] {
+			if c != i {
+				if treeRoot(c) != i {
+					parents[i] = c
+					treeRoots[i] = c
+				}
+				break
+			}
+		}
+	}
+
+	nodes := make([]*symbolOutlineNode, len(symbols))
+	for i, sym := range symbols {
+		nodes[i] = &symbolOutlineNode{symbol: sym}
+	}
+	var roots []*symbolOutlineNode
+	for i, node := range nodes {
+		if p := parents[i]; p >= 0 {
+			nodes[p].children = append(nodes[p].children, node)
+		} else {
+			roots = append(roots, node)
+		}
+	}
+	return roots
+}
+
+// lastScopeComponent returns the name of the innermost scope of a qualified
+// ctags scope, such as "bar" for "Foo.bar" or "Foo::bar".
+func lastScopeComponent(scope string) string {
+	if i := strings.LastIndex(scope, "::"); i >= 0 {
+		scope = scope[i+len("::"):]
+	}
+	if i := strings.LastIndex(scope, "."); i >= 0 {
+		scope = scope[i+len("."):]
+	}
+	return scope
+}
+
+// symbolOutlineRange returns the range of the whole definition of a symbol,
+// from the start of its first line to the end of its last line, or the range
+// of its name if its end is unknown.
+func symbolOutlineRange(s protocol.Symbol) lsp.Range {
+	if s.EndLine < s.Line {
+		return symbolRange(s)
+	}
+	return lsp.Range{
+		Start: lsp.Position{Line: s.Line - 1},
+		End:   lsp.Position{Line: s.EndLine},
+	}
+}
+
+type symbolOutlineNodeResolver struct {
+	symbol   *symbolResolver
+	lspRange lsp.Range
+	children []*symbolOutlineNodeResolver
+}
+
+func (r *symbolOutlineNodeResolver) Symbol() *symbolResolver { return r.symbol }
+
+func (r *symbolOutlineNodeResolver) Range() *rangeResolver { return &rangeResolver{r.lspRange} }
+
+func (r *symbolOutlineNodeResolver) Children() []*symbolOutlineNodeResolver { return r.children }
@@ -0,0 +1,219 @@
+package graphqlbackend
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sourcegraph/go-lsp"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/backend"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/types"
+	"github.com/sourcegraph/sourcegraph/internal/symbols/protocol"
+)
+
+// formatSymbolOutline formats an outline as one line per symbol, indented by
+// depth.
+func formatSymbolOutline(nodes []*symbolOutlineNode) string {
+	var b strings.Builder
+	var format func(nodes []*symbolOutlineNode, depth int)
+	format = func(nodes []*symbolOutlineNode, depth int) {
+		for _, node := range nodes {
+			fmt.Fprintf(&b, "%s%s %s\n", strings.Repeat("  ", depth), node.symbol.Kind, node.symbol.Name)
+			format(node.children, depth+1)
+		}
+	}
+	format(nodes, 0)
+	return b.String()
+}
+
+func TestBuildSymbolOutline(t *testing.T) {
+	tests := []struct {
+		name    string
+		symbols []protocol.Symbol
+		want    string
+	}{
+		{
+			name: "ctags scopes",
+			symbols: []protocol.Symbol{
+				{Name: "Foo", Line: 1, EndLine: 12, Kind: "class"},
+				{Name: "__init__", Line: 2, EndLine: 3, Kind: "member", Parent: "Foo", ParentKind: "class"},
+				{Name: "bar", Line: 5, EndLine: 9, Kind: "member", Parent: "Foo", ParentKind: "class"},
+				{Name: "helper", Line: 6, EndLine: 7, Kind: "function", Parent: "Foo.bar", ParentKind: "member"},
+				{Name: "baz", Line: 11, EndLine: 12, Kind: "member", Parent: "Foo", ParentKind: "class"},
+				{Name: "main", Line: 14, EndLine: 15, Kind: "function"},
+			},
+			want: `class Foo
+  member __init__
+  member bar
+    function helper
+  member baz
+function main
+`,
+		},
+		{
+			name: "same names",
+			symbols: []protocol.Symbol{
+				{Name: "A", Line: 1, EndLine: 3, Kind: "class"},
+				{Name: "f", Line: 2, EndLine: 2, Kind: "method", Parent: "A", ParentKind: "class"},
+				{Name: "A", Line: 5, EndLine: 8, Kind: "class"},
+				{Name: "A", Line: 6, EndLine: 6, Kind: "method", Parent: "A", ParentKind: "class"},
+				{Name: "g", Line: 7, EndLine: 7, Kind: "method", Parent: "A", ParentKind: "class"},
+			},
+			want: `class A
+  method f
+class A
+  method A
+  method g
+`,
+		},
+		{
+			name: "unknown ends",
+			symbols: []protocol.Symbol{
+				{Name: "T", Line: 1, Kind: "class"},
+				{Name: "T", Line: 2, Kind: "method", Parent: "T"},
+				{Name: "m", Line: 3, Kind: "method", Parent: "T", ParentKind: "class"},
+			},
+			want: `class T
+  method T
+  method m
+`,
+		},
+		{
+			name: "go",
+			symbols: []protocol.Symbol{
+				{Name: "foo", Line: 1, Kind: "package"},
+				{Name: "Method", Line: 3, EndLine: 3, Kind: "method", Parent: "T", ParentKind: "type"},
+				{Name: "T", Line: 5, EndLine: 7, Kind: "struct", Parent: "foo", ParentKind: "package"},
+				{Name: "X", Line: 6, EndLine: 6, Kind: "field", Parent: "T", ParentKind: "struct"},
+				{Name: "F", Line: 9, EndLine: 9, Kind: "func", Parent: "foo", ParentKind: "package"},
+			},
+			want: `package foo
+  struct T
+    method Method
+    field X
+  func F
+`,
+		},
+		{
+			name: "parent not in file",
+			symbols: []protocol.Symbol{
+				{Name: "m", Line: 1, Kind: "method", Parent: "Other"},
+			},
+			want: "method m\n",
+		},
+		{
+			name: "cycle",
+			symbols: []protocol.Symbol{
+				{Name: "a", Line: 1, Kind: "function", Parent: "b"},
+				{Name: "b", Line: 2, Kind: "function", Parent: "a"},
+			},
+			want: `function a
+  function b
+`,
+		},
+		{
+			name: "closed scopes",
+			symbols: []protocol.Symbol{
+				{Name: "x", Line: 1, EndLine: 10, Kind: "function"},
+				{Name: "x", Line: 2, EndLine: 3, Kind: "function", Parent: "x"},
+				{Name: "y", Line: 5, Kind: "variable", Parent: "x"},
+				{Name: "z", Line: 12, Kind: "variable", Parent: "x"},
+			},
+			want: `function x
+  function x
+    variable z
+  variable y
+`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := formatSymbolOutline(buildSymbolOutline(test.symbols)); got != test.want {
+				t.Errorf("got outline\n%s\nwant\n%s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestBuildSymbolOutline_manySymbols(t *testing.T) {
+	// Symbols with the same names as their parents, nested and not, are
+	// outlined in about linear time.
+	nested := make([]protocol.Symbol, maxSymbolOutlineSymbols)
+	unknownEnds := make([]protocol.Symbol, maxSymbolOutlineSymbols)
+	for i := range nested {
+		nested[i] = protocol.Symbol{Name: "x", Line: i + 1, EndLine: 2*maxSymbolOutlineSymbols - i, Kind: "function", Parent: "x"}
+		unknownEnds[i] = protocol.Symbol{Name: "x", Line: i + 1, Kind: "function", Parent: "x"}
+	}
+	depth := 0
+	for nodes := buildSymbolOutline(nested); len(nodes) > 0; nodes = nodes[0].children {
+		depth++
+	}
+	if depth != maxSymbolOutlineSymbols {
+		t.Errorf("got depth %d, want %d", depth, maxSymbolOutlineSymbols)
+	}
+	if roots := buildSymbolOutline(unknownEnds); len(roots) != 1 {
+		t.Errorf("got %d roots, want 1", len(roots))
+	}
+}
+
+func TestSymbolOutlineRange(t *testing.T) {
+	got := symbolOutlineRange(protocol.Symbol{Name: "Foo", Line: 3, EndLine: 5, Pattern: "/^class Foo:$/"})
+	if want := (lsp.Range{Start: lsp.Position{Line: 2}, End: lsp.Position{Line: 5}}); got != want {
+		t.Errorf("got range %+v, want %+v", got, want)
+	}
+
+	got = symbolOutlineRange(protocol.Symbol{Name: "Foo", Line: 3, Pattern: "/^class Foo:$/"})
+	if want := (lsp.Range{Start: lsp.Position{Line: 2, Character: 6}, End: lsp.Position{Line: 2, Character: 9}}); got != want {
+		t.Errorf("got range %+v for an unknown end, want %+v", got, want)
+	}
+}
+
+func TestGitTreeEntryResolver_SymbolOutline(t *testing.T) {
+	defer func() { backend.Mocks.Symbols = backend.MockSymbols{} }()
+	symbols := []protocol.Symbol{
+		{Name: "Foo", Path: "a/b.py", Line: 1, EndLine: 3, Kind: "class", Language: "Python"},
+		{Name: "bar", Path: "a/b.py", Line: 2, EndLine: 3, Kind: "method", Language: "Python", Parent: "Foo", ParentKind: "class"},
+	}
+	backend.Mocks.Symbols.List = func(ctx context.Context, args protocol.ListArgs) ([]protocol.Symbol, error) {
+		want := protocol.ListArgs{
+			Repo:     "repo",
+			CommitID: "c1",
+			Path:     "a/b.py",
+			First:    maxSymbolOutlineSymbols + 1,
+		}
+		if !reflect.DeepEqual(args, want) {
+			t.Errorf("got args %+v, want %+v", args, want)
+		}
+		return symbols, nil
+	}
+
+	entry := &GitTreeEntryResolver{
+		commit: &GitCommitResolver{
+			repoResolver: &RepositoryResolver{repo: &types.Repo{ID: 1, Name: "repo"}},
+			oid:          "c1",
+		},
+		stat: CreateFileInfo("a/b.py", false),
+	}
+	nodes, err := entry.SymbolOutline(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(nodes) != 1 || nodes[0].Symbol().Name() != "Foo" || len(nodes[0].Children()) != 1 || nodes[0].Children()[0].Symbol().Name() != "bar" {
+		t.Fatalf("got outline %+v, want Foo containing bar", nodes)
+	}
+	if got, want := nodes[0].Range().lspRange, (lsp.Range{End: lsp.Position{Line: 3}}); got != want {
+		t.Errorf("got range %+v, want %+v", got, want)
+	}
+	if got := nodes[0].Children()[0].Symbol().Kind(); got != "METHOD" {
+		t.Errorf("got kind %q, want METHOD", got)
+	}
+
+	// Outlines missing symbols would be wrong, so files with too many
+	// symbols have none.
+	symbols = make([]protocol.Symbol, maxSymbolOutlineSymbols+1)
+	if _, err := entry.SymbolOutline(context.Background()); err == nil {
+		t.Error("got no error for a file with too many symbols")
+	}
+}
@@ -76,6 +76,10 @@ type FileMatchResolver struct {
 	// preserve the original revision specifier from the user instead of navigating them to the
 	// absolute commit ID when they select a result.
 	InputRev *string
+	// revs are the revspecs of the revisions the match appears in, when the
+	// repository is searched at several revisions. InputRev is the first of
+	// them.
+	revs []string
 }
 
 func (fm *FileMatchResolver) Equal(other *FileMatchResolver) bool {
@@ -113,6 +117,13 @@ func (fm *FileMatchResolver) RevSpec() *gitRevSpec {
 	}
 }
 
+func (fm *FileMatchResolver) RevSpecs() []string {
+	if fm.revs == nil {
+		return []string{}
+	}
+	return fm.revs
+}
+
 func (fm *FileMatchResolver) Resource() string {
 	return fm.uri
 }
@@ -157,10 +168,11 @@ func (fm *FileMatchResolver) resultCount() int32 {
 
 // lineMatch is the struct used by vscode to receive search results for a line
 type lineMatch struct {
-	JPreview          string     `json:"Preview"`
-	JOffsetAndLengths [][2]int32 `json:"OffsetAndLengths"`
-	JLineNumber       int32      `json:"LineNumber"`
-	JLimitHit         bool       `json:"LimitHit"`
+	JPreview          string          `json:"Preview"`
+	JOffsetAndLengths [][2]int32      `json:"OffsetAndLengths"`
+	JLineNumber       int32           `json:"LineNumber"`
+	JLimitHit         bool            `json:"LimitHit"`
+	JCaptureGroups    []*captureGroup `json:"CaptureGroups"`
 }
 
 func (lm *lineMatch) Preview() string {
@@ -183,6 +195,26 @@ func (lm *lineMatch) LimitHit() bool {
 	return lm.JLimitHit
 }
 
+func (lm *lineMatch) CaptureGroups() []*captureGroup {
+	if lm.JCaptureGroups == nil {
+		return []*captureGroup{}
+	}
+	return lm.JCaptureGroups
+}
+
+// captureGroup is the span of a named regexp capture group in a line match.
+type captureGroup struct {
+	JName   string `json:"Name"`
+	JValue  string `json:"Value"`
+	JOffset int32  `json:"Offset"`
+	JLength int32  `json:"Length"`
+}
+
+func (g *captureGroup) Name() string  { return g.JName }
+func (g *captureGroup) Value() string { return g.JValue }
+func (g *captureGroup) Offset() int32 { return g.JOffset }
+func (g *captureGroup) Length() int32 { return g.JLength }
+
 var mockTextSearch func(ctx context.Context, repo gitserver.Repo, commit api.CommitID, p *search.TextPatternInfo, fetchTimeout time.Duration) (matches []*FileMatchResolver, limitHit bool, err error)
 
 // textSearch searches repo@commit with p.
@@ -226,9 +258,18 @@ func textSearch(ctx context.Context, searcherURLs *endpoint.Map, repo gitserver.
 	if p.IsWordMatch {
 		q.Set("IsWordMatch", "true")
 	}
+	if p.IncludeCaptureGroups {
+		q.Set("IncludeCaptureGroups", "true")
+	}
 	if p.IsCaseSensitive {
 		q.Set("IsCaseSensitive", "true")
 	}
+	if p.IsMultiline {
+		q.Set("IsMultiline", "true")
+	}
+	if p.IsNegated {
+		q.Set("IsNegated", "true")
+	}
 	if p.PathPatternsAreRegExps {
 		q.Set("PathPatternsAreRegExps", "true")
 	}
@@ -399,6 +440,75 @@ func searchFilesInRepo(ctx context.Context, searcherURLs *endpoint.Map, repo *ty
 	return matches, limitHit, err
 }
 
+// maxRefGlobRevisions is the maximum number of revisions the ref globs of a
+// repository, such as in repo:foo@*refs/heads/release-*, are expanded to for
+// text search.
+const maxRefGlobRevisions = 50
+
+// groupRevSpecsByCommit groups the revSpecs of repo that resolve to the same
+// commit, so that each commit is searched once.
+func groupRevSpecsByCommit(ctx context.Context, repo gitserver.Repo, revSpecs []string) [][]string {
+	groups := make([][]string, 0, len(revSpecs))
+	commitGroup := make(map[api.CommitID]int, len(revSpecs))
+	for _, rev := range revSpecs {
+		commit, err := git.ResolveRevision(ctx, repo, nil, rev, &git.ResolveRevisionOptions{NoEnsureRevision: true})
+		if err != nil {
+			// Searching the revision reports the error.
+			groups = append(groups, []string{rev})
+			continue
+		}
+		if i, ok := commitGroup[commit]; ok {
+			groups[i] = append(groups[i], rev)
+			continue
+		}
+		commitGroup[commit] = len(groups)
+		groups = append(groups, []string{rev})
+	}
+	return groups
+}
+
+// revisionMatches collects the matches of a repository searched at several
+// revisions. Its methods must be called with the lock of the search held.
+type revisionMatches struct {
+	pending int                    // the number of revisions still being searched
+	matches [][]*FileMatchResolver // the matches of each revision
+}
+
+// add records the matches of the i-th revision. Once all revisions are
+// searched, it returns the matches of all of them, merged by
+// mergeRevisionMatches.
+func (r *revisionMatches) add(i int, matches []*FileMatchResolver) []*FileMatchResolver {
+	r.matches[i] = matches
+	r.pending--
+	if r.pending > 0 {
+		return nil
+	}
+	return mergeRevisionMatches(r.matches)
+}
+
+// mergeRevisionMatches merges the matches of a repository at several
+// revisions, in the order of the revisions. A file whose matches are the same
+// at several revisions, such as a file that is identical at those revisions,
+// is reported once with all of the revisions it is in.
+func mergeRevisionMatches(matches [][]*FileMatchResolver) []*FileMatchResolver {
+	var merged []*FileMatchResolver
+	byPath := make(map[string][]*FileMatchResolver)
+	for _, revMatches := range matches {
+	next:
+		for _, fm := range revMatches {
+			for _, other := range byPath[fm.JPath] {
+				if other.JLimitHit == fm.JLimitHit && reflect.DeepEqual(other.JLineMatches, fm.JLineMatches) {
+					other.revs = append(other.revs, fm.revs...)
+					continue next
+				}
+			}
+			byPath[fm.JPath] = append(byPath[fm.JPath], fm)
+			merged = append(merged, fm)
+		}
+	}
+	return merged
+}
+
 // repoShouldBeSearched determines whether a repository should be searched in, based on whether the repository
 // fits in the subset of repositories specified in the query's `repohasfile` and `-repohasfile` flags if they exist.
 func repoShouldBeSearched(ctx context.Context, searcherURLs *endpoint.Map, searchPattern *search.TextPatternInfo, gitserverRepo gitserver.Repo, commit api.CommitID, fetchTimeout time.Duration) (shouldBeSearched bool, err error) {
@@ -624,7 +734,26 @@ func searchFilesInRepos(ctx context.Context, args *search.TextParameters) (res [
 				return errMultipleRevsNotSupported
 			}
 
-			for _, rev := range revSpecs {
+			if len(revSpecs) > maxRefGlobRevisions && len(repoAllRevs.RevSpecs()) != len(repoAllRevs.Revs) {
+				// The ref globs match too many refs to search them all.
+				tr.LazyPrintf("%s: searching %d of %d revisions", repoAllRevs.Repo.Name, maxRefGlobRevisions, len(revSpecs))
+				revSpecs = revSpecs[:maxRefGlobRevisions]
+				mu.Lock()
+				common.partial[repoAllRevs.Repo.Name] = struct{}{}
+				common.limitHit = true
+				mu.Unlock()
+			}
+
+			// Search each commit once, and report each match once for
+			// all of the revisions it is in.
+			revGroups := [][]string{revSpecs}
+			var repoMatches *revisionMatches
+			if len(revSpecs) >= 2 {
+				revGroups = groupRevSpecsByCommit(ctx, repoAllRevs.GitserverRepo(), revSpecs)
+				repoMatches = &revisionMatches{pending: len(revGroups), matches: make([][]*FileMatchResolver, len(revGroups))}
+			}
+
+			for revGroupIndex, revGroup := range revGroups {
 				// Only reason acquire can fail is if ctx is cancelled. So we can stop
 				// looping through searcherRepos.
 				limitCtx, limitDone, acquireErr := textSearchLimiter.Acquire(ctx)
@@ -633,7 +762,9 @@ func searchFilesInRepos(ctx context.Context, args *search.TextParameters) (res [
 				}
 
 				// Make a new repoRev for just the operation of searching this revspec.
+				rev := revGroup[0]
 				repoRev := &search.RepositoryRevisions{Repo: repoAllRevs.Repo, Revs: []search.RevisionSpecifier{{RevSpec: rev}}}
+				revGroupIndex, revGroup := revGroupIndex, revGroup
 
 				args := *args
 				if args.PatternInfo.IsStructuralPat && searcherReposFilteredFiles != nil {
@@ -652,6 +783,11 @@ func searchFilesInRepos(ctx context.Context, args *search.TextParameters) (res [
 					defer done()
 
 					matches, repoLimitHit, err := searchFilesInRepo(ctx, args.SearcherURLs, repoRev.Repo, repoRev.GitserverRepo(), repoRev.RevSpecs()[0], args.PatternInfo, fetchTimeout)
+					if repoMatches != nil {
+						for _, fm := range matches {
+							fm.revs = append([]string(nil), revGroup...)
+						}
+					}
 					if err != nil {
 						tr.LogFields(otlog.String("repo", string(repoRev.Repo.Name)), otlog.Error(err), otlog.Bool("timeout", errcode.IsTimeout(err)), otlog.Bool("temporary", errcode.IsTemporary(err)))
 						log15.Warn("searchFilesInRepo failed", "error", err, "repo", repoRev.Repo.Name)
@@ -681,6 +817,11 @@ func searchFilesInRepos(ctx context.Context, args *search.TextParameters) (res [
 							cancel()
 						}
 					}
+					if repoMatches != nil {
+						// Report the matches of the repository once all of
+						// its revisions are searched.
+						matches = repoMatches.add(revGroupIndex, matches)
+					}
 					addMatches(matches)
 				}(limitCtx, limitDone) // ends the Go routine for a call to searcher for a repo
 			} // ends the for loop iterating over repo's revs
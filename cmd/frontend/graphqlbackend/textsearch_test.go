@@ -2,8 +2,11 @@ package graphqlbackend
 
 import (
 	"context"
+	"fmt"
 	"reflect"
 	"sort"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -117,7 +120,9 @@ func TestSearchFilesInRepos_multipleRevsPerRepo(t *testing.T) {
 		case "foo":
 			return []*FileMatchResolver{
 				{
-					uri: "git://" + string(repoName) + "?" + rev + "#" + "main.go",
+					JPath:        "main.go",
+					JLineMatches: []*lineMatch{{JPreview: rev}},
+					uri:          "git://" + string(repoName) + "?" + rev + "#" + "main.go",
 				},
 			}, false, nil
 		default:
@@ -126,6 +131,11 @@ func TestSearchFilesInRepos_multipleRevsPerRepo(t *testing.T) {
 	}
 	defer func() { mockSearchFilesInRepo = nil }()
 
+	git.Mocks.ResolveRevision = func(spec string, opt *git.ResolveRevisionOptions) (api.CommitID, error) {
+		return api.CommitID("commit-" + spec), nil
+	}
+	defer git.ResetMocks()
+
 	trueVal := true
 	conf.Mock(&conf.Unified{SiteConfiguration: schema.SiteConfiguration{
 		ExperimentalFeatures: &schema.ExperimentalFeatures{SearchMultipleRevisionsPerRepository: &trueVal},
@@ -173,6 +183,162 @@ func TestSearchFilesInRepos_multipleRevsPerRepo(t *testing.T) {
 	}
 }
 
+func TestSearchFilesInRepos_multipleRevsPerRepoDedupe(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		searched []string
+	)
+	mockSearchFilesInRepo = func(ctx context.Context, repo *types.Repo, gitserverRepo gitserver.Repo, rev string, info *search.TextPatternInfo, fetchTimeout time.Duration) (matches []*FileMatchResolver, limitHit bool, err error) {
+		mu.Lock()
+		searched = append(searched, rev)
+		mu.Unlock()
+		preview := "foo"
+		if rev == "branch4" {
+			preview = "foo changed"
+		}
+		return []*FileMatchResolver{
+			{
+				JPath:        "main.go",
+				JLineMatches: []*lineMatch{{JPreview: preview}},
+				uri:          "git://" + string(repo.Name) + "?" + rev + "#" + "main.go",
+			},
+		}, false, nil
+	}
+	defer func() { mockSearchFilesInRepo = nil }()
+
+	commits := map[string]api.CommitID{
+		"master":   "c1",
+		"mybranch": "c1",
+		"branch3":  "c2",
+		"branch4":  "c3",
+	}
+	git.Mocks.ResolveRevision = func(spec string, opt *git.ResolveRevisionOptions) (api.CommitID, error) {
+		return commits[spec], nil
+	}
+	defer git.ResetMocks()
+
+	trueVal := true
+	conf.Mock(&conf.Unified{SiteConfiguration: schema.SiteConfiguration{
+		ExperimentalFeatures: &schema.ExperimentalFeatures{SearchMultipleRevisionsPerRepository: &trueVal},
+	}})
+	defer conf.Mock(nil)
+
+	q, err := query.ParseAndCheck("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	args := &search.TextParameters{
+		PatternInfo: &search.TextPatternInfo{
+			FileMatchLimit: defaultMaxSearchResults,
+			Pattern:        "foo",
+		},
+		Repos:        makeRepositoryRevisions("foo@master:mybranch:*refs/heads/branch*"),
+		Query:        q,
+		Zoekt:        &searchbackend.Zoekt{Client: &fakeSearcher{repos: &zoekt.RepoList{}}},
+		SearcherURLs: endpoint.Static("test"),
+	}
+	args.Repos[0].ListRefs = func(context.Context, gitserver.Repo) ([]git.Ref, error) {
+		return []git.Ref{{Name: "refs/heads/branch4"}, {Name: "refs/heads/branch3"}}, nil
+	}
+	results, _, err := searchFilesInRepos(context.Background(), args)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sort.Strings(searched)
+	if want := []string{"branch3", "branch4", "master"}; !reflect.DeepEqual(searched, want) {
+		t.Errorf("searched revisions %v, want %v", searched, want)
+	}
+
+	got := make([][]string, len(results))
+	for i, result := range results {
+		got[i] = result.RevSpecs()
+	}
+	want := [][]string{
+		{"master", "mybranch", "branch3"},
+		{"branch4"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got revspecs %v, want %v", got, want)
+	}
+}
+
+func TestSearchFilesInRepos_refGlobLimit(t *testing.T) {
+	var searched int32
+	mockSearchFilesInRepo = func(ctx context.Context, repo *types.Repo, gitserverRepo gitserver.Repo, rev string, info *search.TextPatternInfo, fetchTimeout time.Duration) (matches []*FileMatchResolver, limitHit bool, err error) {
+		atomic.AddInt32(&searched, 1)
+		return nil, false, nil
+	}
+	defer func() { mockSearchFilesInRepo = nil }()
+
+	git.Mocks.ResolveRevision = func(spec string, opt *git.ResolveRevisionOptions) (api.CommitID, error) {
+		return api.CommitID("commit-" + spec), nil
+	}
+	defer git.ResetMocks()
+
+	trueVal := true
+	conf.Mock(&conf.Unified{SiteConfiguration: schema.SiteConfiguration{
+		ExperimentalFeatures: &schema.ExperimentalFeatures{SearchMultipleRevisionsPerRepository: &trueVal},
+	}})
+	defer conf.Mock(nil)
+
+	q, err := query.ParseAndCheck("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	args := &search.TextParameters{
+		PatternInfo: &search.TextPatternInfo{
+			FileMatchLimit: defaultMaxSearchResults,
+			Pattern:        "foo",
+		},
+		Repos:        makeRepositoryRevisions("foo@*refs/heads/release-*"),
+		Query:        q,
+		Zoekt:        &searchbackend.Zoekt{Client: &fakeSearcher{repos: &zoekt.RepoList{}}},
+		SearcherURLs: endpoint.Static("test"),
+	}
+	args.Repos[0].ListRefs = func(context.Context, gitserver.Repo) ([]git.Ref, error) {
+		refs := make([]git.Ref, maxRefGlobRevisions+10)
+		for i := range refs {
+			refs[i] = git.Ref{Name: fmt.Sprintf("refs/heads/release-%d", i)}
+		}
+		return refs, nil
+	}
+	_, common, err := searchFilesInRepos(context.Background(), args)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if searched != maxRefGlobRevisions {
+		t.Errorf("searched %d revisions, want %d", searched, maxRefGlobRevisions)
+	}
+	if !common.limitHit {
+		t.Error("want limitHit")
+	}
+	if _, ok := common.partial["foo"]; !ok {
+		t.Errorf("want foo to be partially searched, got %v", common.partial)
+	}
+}
+
+func TestMergeRevisionMatches(t *testing.T) {
+	fm := func(path, preview string, revs ...string) *FileMatchResolver {
+		return &FileMatchResolver{JPath: path, JLineMatches: []*lineMatch{{JPreview: preview}}, revs: revs}
+	}
+	got := mergeRevisionMatches([][]*FileMatchResolver{
+		{fm("a.go", "x", "v1"), fm("b.go", "y", "v1")},
+		{fm("a.go", "x", "v2"), fm("b.go", "z", "v2")},
+		{fm("c.go", "x", "v3"), fm("b.go", "z", "v3")},
+	})
+	want := []*FileMatchResolver{
+		fm("a.go", "x", "v1", "v2"),
+		fm("b.go", "y", "v1"),
+		fm("b.go", "z", "v2", "v3"),
+		fm("c.go", "x", "v3"),
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
 func TestRepoShouldBeSearched(t *testing.T) {
 	mockTextSearch = func(ctx context.Context, repo gitserver.Repo, commit api.CommitID, p *search.TextPatternInfo, fetchTimeout time.Duration) (matches []*FileMatchResolver, limitHit bool, err error) {
 		repoName := repo.Name
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"math"
 	"net/url"
+	"regexp"
 	"regexp/syntax"
 	"strings"
 	"time"
@@ -14,6 +15,7 @@ import (
 	zoektquery "github.com/google/zoekt/query"
 	"github.com/pkg/errors"
 	"github.com/sourcegraph/sourcegraph/internal/api"
+	"github.com/sourcegraph/sourcegraph/internal/conf"
 	"github.com/sourcegraph/sourcegraph/internal/gituri"
 	"github.com/sourcegraph/sourcegraph/internal/search"
 	searchbackend "github.com/sourcegraph/sourcegraph/internal/search/backend"
@@ -96,6 +98,9 @@ func zoektSearchHEAD(ctx context.Context, args *search.TextParameters, repos []*
 	if err != nil {
 		return nil, false, nil, err
 	}
+	if repoBranches := zoektRepoBranches(repos); repoBranches != nil {
+		queryExceptRepos = zoektquery.NewAnd(repoBranches, queryExceptRepos)
+	}
 	finalQuery := zoektquery.NewAnd(repoSet, queryExceptRepos)
 
 	tr, ctx := trace.New(ctx, "zoekt.Search", fmt.Sprintf("%d %+v", len(repoSet.Set), finalQuery.String()))
@@ -107,6 +112,23 @@ func zoektSearchHEAD(ctx context.Context, args *search.TextParameters, repos []*
 		tr.Finish()
 	}()
 
+	// Zoekt does not report submatches, so the capture groups of each match
+	// are found by matching the pattern against the matched line again.
+	var captureRe *regexp.Regexp
+	if args.PatternInfo.IncludeCaptureGroups && !isSymbol {
+		expr := args.PatternInfo.Pattern
+		if args.PatternInfo.IsMultiline {
+			expr = "(?s:" + expr + ")"
+		}
+		if !args.PatternInfo.IsCaseSensitive {
+			expr = "(?i:" + expr + ")"
+		}
+		captureRe, err = regexp.Compile(expr)
+		if err != nil {
+			return nil, false, nil, err
+		}
+	}
+
 	k := zoektResultCountFactor(len(repos), args.PatternInfo)
 	searchOpts := zoektSearchOpts(k, args.PatternInfo)
 
@@ -204,7 +226,13 @@ func zoektSearchHEAD(ctx context.Context, args *search.TextParameters, repos []*
 		if repoResolvers[repoRev.Repo.Name] == nil {
 			repoResolvers[repoRev.Repo.Name] = &RepositoryResolver{repo: repoRev.Repo}
 		}
-		inputRev := repoRev.RevSpecs()[0]
+		inputRev, commitID := zoektFileRev(repoRev, file)
+		uriRev := ""
+		if len(repoRev.IndexedBranches) > 0 {
+			// Several branches of this repository are indexed, so the
+			// URI must say which one the file is in.
+			uriRev = inputRev
+		}
 		baseURI := &gituri.URI{URL: url.URL{Scheme: "git://", Host: string(repoRev.Repo.Name), RawQuery: "?" + url.QueryEscape(inputRev)}}
 		lines := make([]*lineMatch, 0, len(file.LineMatches))
 		symbols := []*searchSymbolResult{}
@@ -222,7 +250,7 @@ func zoektSearchHEAD(ctx context.Context, args *search.TextParameters, repos []*
 					if isSymbol && m.SymbolInfo != nil {
 						commit := &GitCommitResolver{
 							repoResolver: repoResolvers[repoRev.Repo.Name],
-							oid:          GitObjectID(file.Version),
+							oid:          GitObjectID(commitID),
 							inputRev:     &inputRev,
 						}
 
@@ -243,11 +271,18 @@ func zoektSearchHEAD(ctx context.Context, args *search.TextParameters, repos []*
 				}
 				if !isSymbol {
 					matchCount += len(offsets)
-					lines = append(lines, &lineMatch{
+					lm := &lineMatch{
 						JPreview:          string(l.Line),
 						JLineNumber:       int32(l.LineNumber - 1),
 						JOffsetAndLengths: offsets,
-					})
+					}
+					if captureRe != nil {
+						lm.JCaptureGroups = zoektCaptureGroups(captureRe, l.Line, l.LineFragments)
+					}
+					// Zoekt returns a match which spans several lines as a
+					// single line match, but line matches must not contain
+					// newlines.
+					lines = append(lines, splitLineMatch(lm)...)
 				}
 			}
 		}
@@ -256,16 +291,105 @@ func zoektSearchHEAD(ctx context.Context, args *search.TextParameters, repos []*
 			JLineMatches: lines,
 			JLimitHit:    fileLimitHit,
 			MatchCount:   matchCount, // We do not use resp.MatchCount because it counts the number of lines matched, not the number of fragments.
-			uri:          fileMatchURI(repoRev.Repo.Name, "", file.FileName),
+			uri:          fileMatchURI(repoRev.Repo.Name, uriRev, file.FileName),
 			symbols:      symbols,
 			Repo:         repoResolvers[repoRev.Repo.Name],
-			CommitID:     api.CommitID(file.Version),
+			CommitID:     commitID,
+			revs:         zoektFileRevs(repoRev, file),
 		}
 	}
 
 	return matches, limitHit, reposLimitHit, nil
 }
 
+// splitLineMatch splits a line match whose preview contains newlines into one
+// line match per line with a match, like searcher does. A match that spans
+// several lines is split into a match on each line. As in searcher, the part
+// of a match on a line includes the newline ending the line.
+func splitLineMatch(lm *lineMatch) []*lineMatch {
+	if !strings.Contains(lm.JPreview, "\n") {
+		return []*lineMatch{lm}
+	}
+
+	var split []*lineMatch
+	var start int32 // the offset in lm.JPreview of the current line, in characters
+	for i, line := range strings.Split(strings.TrimSuffix(lm.JPreview, "\n"), "\n") {
+		end := start + int32(utf8.RuneCountInString(line)) // the offset of the newline ending the line
+		l := &lineMatch{
+			JPreview:    line,
+			JLineNumber: lm.JLineNumber + int32(i),
+			JLimitHit:   lm.JLimitHit,
+		}
+		for _, ol := range lm.JOffsetAndLengths {
+			from, to := ol[0], ol[0]+ol[1]
+			if ol[1] == 0 {
+				// Empty matches are on the line they occur at.
+				if from >= start && from <= end {
+					l.JOffsetAndLengths = append(l.JOffsetAndLengths, [2]int32{from - start, 0})
+				}
+				continue
+			}
+			if from < start {
+				from = start
+			}
+			if to > end+1 {
+				to = end + 1
+			}
+			if from < to {
+				l.JOffsetAndLengths = append(l.JOffsetAndLengths, [2]int32{from - start, to - from})
+			}
+		}
+		for _, g := range lm.JCaptureGroups {
+			if g.JOffset >= start && g.JOffset <= end {
+				length := g.JLength
+				if g.JOffset+length > end {
+					length = end - g.JOffset
+				}
+				l.JCaptureGroups = append(l.JCaptureGroups, &captureGroup{
+					JName:   g.JName,
+					JValue:  g.JValue,
+					JOffset: g.JOffset - start,
+					JLength: length,
+				})
+			}
+		}
+		if len(l.JOffsetAndLengths) > 0 {
+			split = append(split, l)
+		}
+		start = end + 1
+	}
+	return split
+}
+
+// zoektCaptureGroups returns the named capture groups of re in the matches of
+// a line whose start is at the offset of one of the fragments.
+func zoektCaptureGroups(re *regexp.Regexp, line []byte, fragments []zoekt.LineFragmentMatch) []*captureGroup {
+	starts := make(map[int]bool, len(fragments))
+	for _, f := range fragments {
+		starts[f.LineOffset] = true
+	}
+	names := re.SubexpNames()
+	var groups []*captureGroup
+	for _, m := range re.FindAllSubmatchIndex(line, -1) {
+		if !starts[m[0]] {
+			continue
+		}
+		for i, name := range names {
+			if name == "" || m[2*i] < 0 {
+				continue
+			}
+			start, end := m[2*i], m[2*i+1]
+			groups = append(groups, &captureGroup{
+				JName:   name,
+				JValue:  string(line[start:end]),
+				JOffset: int32(utf8.RuneCount(line[:start])),
+				JLength: int32(utf8.RuneCount(line[start:end])),
+			})
+		}
+	}
+	return groups
+}
+
 // createNewRepoSetWithRepoHasFileInputs mutates repoSet such that it accounts
 // for the `repohasfile` and `-repohasfile` flags that may have been passed in
 // the query. As a convenience it returns the mutated RepoSet.
@@ -355,13 +479,28 @@ func noOpAnyChar(re *syntax.Regexp) {
 	}
 }
 
-func parseRe(pattern string, filenameOnly bool, queryIsCaseSensitive bool) (zoektquery.Q, error) {
+func setOpAnyChar(re *syntax.Regexp) {
+	if re.Op == syntax.OpAnyCharNotNL {
+		re.Op = syntax.OpAnyChar
+	}
+	for _, s := range re.Sub {
+		setOpAnyChar(s)
+	}
+}
+
+// parseRe returns a zoekt query for the regexp pattern. Unless multiline is
+// true, . does not match newlines.
+func parseRe(pattern string, filenameOnly, queryIsCaseSensitive, multiline bool) (zoektquery.Q, error) {
 	// these are the flags used by zoekt, which differ to searcher.
 	re, err := syntax.Parse(pattern, syntax.ClassNL|syntax.PerlX|syntax.UnicodeGroups)
 	if err != nil {
 		return nil, err
 	}
-	noOpAnyChar(re)
+	if multiline {
+		setOpAnyChar(re)
+	} else {
+		noOpAnyChar(re)
+	}
 	// zoekt decides to use its literal optimization at the query parser
 	// level, so we check if our regex can just be a literal.
 	if re.Op == syntax.OpLiteral {
@@ -381,7 +520,7 @@ func parseRe(pattern string, filenameOnly bool, queryIsCaseSensitive bool) (zoek
 }
 
 func fileRe(pattern string, queryIsCaseSensitive bool) (zoektquery.Q, error) {
-	return parseRe(pattern, true, queryIsCaseSensitive)
+	return parseRe(pattern, true, queryIsCaseSensitive, false)
 }
 
 func queryToZoektQuery(query *search.TextPatternInfo, isSymbol bool) (zoektquery.Q, error) {
@@ -391,7 +530,7 @@ func queryToZoektQuery(query *search.TextPatternInfo, isSymbol bool) (zoektquery
 	var err error
 	if query.IsRegExp {
 		fileNameOnly := query.PatternMatchesPath && !query.PatternMatchesContent
-		q, err = parseRe(query.Pattern, fileNameOnly, query.IsCaseSensitive)
+		q, err = parseRe(query.Pattern, fileNameOnly, query.IsCaseSensitive, query.IsMultiline && !isSymbol)
 		if err != nil {
 			return nil, err
 		}
@@ -409,6 +548,13 @@ func queryToZoektQuery(query *search.TextPatternInfo, isSymbol bool) (zoektquery
 		q = &zoektquery.Symbol{
 			Expr: q,
 		}
+	} else if query.IsNegated {
+		q = &zoektquery.Not{Child: q}
+		// Zoekt doesn't index the content of binary files and files over
+		// its size limit, so it can't tell whether they contain the pattern.
+		for _, lang := range []string{"binary", "skipped"} {
+			and = append(and, &zoektquery.Not{Child: &zoektquery.Language{Language: lang}})
+		}
 	}
 
 	and = append(and, q)
@@ -460,15 +606,7 @@ func zoektSingleIndexedRepo(ctx context.Context, z *searchbackend.Zoekt, rev *se
 	indexed = []*search.RepositoryRevisions{}
 	unindexed = []*search.RepositoryRevisions{}
 
-	ctx, cancel := context.WithTimeout(ctx, time.Second)
-	defer cancel()
-	if len(rev.RevSpecs()) >= 2 || len(rev.RevSpecs()) != len(rev.Revs) {
-		// Zoekt only indexes 1 rev per repository, so it will not have the full results for the
-		// query on repositories for which multiple revs are searched.
-		return indexed, append(unindexed, rev), nil
-	}
-
-	set, err := z.ListAll(ctx)
+	set, err := zoektListAll(ctx, z)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -478,26 +616,156 @@ func zoektSingleIndexedRepo(ctx context.Context, z *searchbackend.Zoekt, rev *se
 		return indexed, append(unindexed, rev), nil
 	}
 
-	if len(rev.Revs) == 1 {
-		revSpecToSearch := rev.Revs[0].RevSpec
-		if len(revSpecToSearch) > 0 && len(revSpecToSearch) < 4 {
-			// revSpecToSearch is nonempty but shorter than the
-			// minimum 4 chars expected for a short SHA. It can't
-			// match a commit, maybe it refers to a one-character
-			// branch name.
-			return indexed, append(unindexed, rev), nil
-		}
-		if revSpecToSearch == "" || revSpecToSearch == "HEAD" {
-			return append(indexed, rev), unindexed, nil
+	if indexedRev, ok := zoektIndexedRevisions(ctx, repo, rev); ok {
+		return append(indexed, indexedRev), unindexed, nil
+	}
+	return indexed, append(unindexed, rev), nil
+}
+
+// zoektIndexedRevisions returns rev if all of its revisions are branches of
+// repo indexed by Zoekt, and false if rev must be searched without the index.
+// Ref globs, such as in repo:foo@release-*, are expanded to the branches they
+// match. If repo has branches besides the default branch indexed, a copy of
+// rev with IndexedBranches set is returned.
+func zoektIndexedRevisions(ctx context.Context, repo *zoekt.Repository, rev *search.RepositoryRevisions) (*search.RepositoryRevisions, bool) {
+	if len(rev.Revs) == 0 {
+		return nil, false
+	}
+	if isDefaultRevisions(rev) {
+		// The default branch is always indexed, so the common case
+		// doesn't need gitserver to resolve it.
+		if len(repo.Branches) < 2 {
+			return rev, true
 		}
 		for _, branch := range repo.Branches {
-			if branch.Name == revSpecToSearch || strings.HasPrefix(branch.Version, revSpecToSearch) {
-				return append(indexed, rev), unindexed, nil
+			if branch.Name == "HEAD" {
+				indexedRev := *rev
+				indexedRev.IndexedBranches = []zoekt.RepositoryBranch{branch}
+				return &indexedRev, true
 			}
 		}
 	}
 
-	return indexed, append(unindexed, rev), nil
+	revSpecs := rev.RevSpecs()
+	if len(revSpecs) != len(rev.Revs) {
+		if len(repo.Branches) < 2 {
+			// Only the default branch is indexed, which ref globs
+			// can't be resolved to without asking gitserver.
+			return nil, false
+		}
+		var err error
+		revSpecs, err = rev.ExpandedRevSpecs(ctx)
+		if err != nil || len(revSpecs) == 0 || len(revSpecs) > maxRefGlobRevisions {
+			return nil, false
+		}
+	}
+
+	branches, ok := searchbackend.ResolveBranches(repo, revSpecs)
+	if !ok || (len(branches) >= 2 && !conf.SearchMultipleRevisionsPerRepository()) {
+		return nil, false
+	}
+	if len(repo.Branches) < 2 {
+		return rev, true
+	}
+
+	// Zoekt matches branch names by substring, so a branch whose name is
+	// part of the name of another indexed branch can't be searched without
+	// the other branch.
+	requested := make(map[string]bool, len(branches))
+	for _, branch := range branches {
+		requested[branch.Name] = true
+	}
+	for _, branch := range branches {
+		if branch.Name == "HEAD" {
+			continue
+		}
+		for _, other := range repo.Branches {
+			if !requested[other.Name] && strings.Contains(other.Name, branch.Name) {
+				return nil, false
+			}
+		}
+	}
+
+	indexedRev := *rev
+	indexedRev.IndexedBranches = branches
+	return &indexedRev, true
+}
+
+// zoektRepoBranches returns a query restricting the search of repos to their
+// IndexedBranches, or nil if none of repos have IndexedBranches set.
+func zoektRepoBranches(repos []*search.RepositoryRevisions) zoektquery.Q {
+	set := make(map[string][]string, len(repos))
+	restricted := false
+	for _, repoRev := range repos {
+		if len(repoRev.IndexedBranches) == 0 {
+			set[string(repoRev.Repo.Name)] = []string{"HEAD"}
+			continue
+		}
+		restricted = true
+		branches := make([]string, len(repoRev.IndexedBranches))
+		for i, branch := range repoRev.IndexedBranches {
+			branches[i] = branch.Name
+		}
+		set[string(repoRev.Repo.Name)] = branches
+	}
+	if !restricted {
+		return nil
+	}
+	return &zoektquery.RepoBranches{Set: set}
+}
+
+// zoektFileRev returns the revision a file match of Zoekt is reported at, and
+// the commit it was indexed at. That is the revision the user specified, or
+// the indexed branch the file is in if several branches are searched.
+func zoektFileRev(repoRev *search.RepositoryRevisions, file zoekt.FileMatch) (string, api.CommitID) {
+	revSpecs := repoRev.RevSpecs()
+	if len(repoRev.IndexedBranches) == 0 {
+		return revSpecs[0], api.CommitID(file.Version)
+	}
+
+	// Zoekt reports the branches the file is in, and the commit of the
+	// first of them.
+	branch := repoRev.IndexedBranches[0]
+	for _, b := range repoRev.IndexedBranches {
+		if b.Version == file.Version {
+			branch = b
+			break
+		}
+	}
+outer:
+	for _, name := range file.Branches {
+		for _, b := range repoRev.IndexedBranches {
+			if b.Name == name {
+				branch = b
+				break outer
+			}
+		}
+	}
+	if len(revSpecs) == 1 && len(revSpecs) == len(repoRev.Revs) {
+		return revSpecs[0], api.CommitID(branch.Version)
+	}
+	if branch.Name == "HEAD" {
+		return "", api.CommitID(branch.Version)
+	}
+	return branch.Name, api.CommitID(branch.Version)
+}
+
+// zoektFileRevs returns the revisions of repoRev a file match of Zoekt is in,
+// if repoRev is searched at several indexed branches.
+func zoektFileRevs(repoRev *search.RepositoryRevisions, file zoekt.FileMatch) []string {
+	if len(repoRev.IndexedBranches) < 2 {
+		return nil
+	}
+	var revs []string
+	for _, name := range file.Branches {
+		for _, b := range repoRev.IndexedBranches {
+			if b.Name == name {
+				revs = append(revs, name)
+				break
+			}
+		}
+	}
+	return revs
 }
 
 // zoektIndexedRepos splits the input repo list into two parts: (1) the
@@ -509,44 +777,46 @@ func zoektIndexedRepos(ctx context.Context, z *searchbackend.Zoekt, revs []*sear
 		return zoektSingleIndexedRepo(ctx, z, revs[0], filter)
 	}
 
-	count := 0
-	for _, r := range revs {
-		if len(r.Revs) > 0 && r.Revs[0].RevSpec == "" {
-			count++
-		}
-	}
-
-	// Return early if we don't need to querying zoekt
-	if count == 0 {
-		return nil, revs, nil
-	}
-
-	ctx, cancel := context.WithTimeout(ctx, time.Second)
-	defer cancel()
-	set, err := z.ListAll(ctx)
+	set, err := zoektListAll(ctx, z)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	indexed = make([]*search.RepositoryRevisions, 0, count)
-	unindexed = make([]*search.RepositoryRevisions, 0, len(revs)-count)
+	indexed = make([]*search.RepositoryRevisions, 0, len(revs))
+	unindexed = make([]*search.RepositoryRevisions, 0)
 
 	for _, rev := range revs {
-		if len(rev.RevSpecs()) >= 2 || len(rev.RevSpecs()) != len(rev.Revs) {
-			// Zoekt only indexes 1 rev per repository, so it will not have the full results for the
-			// query on repositories for which multiple revs are searched.
+		repo, ok := set[strings.ToLower(string(rev.Repo.Name))]
+		if !ok || (filter != nil && !filter(repo)) {
 			unindexed = append(unindexed, rev)
 			continue
 		}
 
-		repo, ok := set[strings.ToLower(string(rev.Repo.Name))]
-		if !ok || (filter != nil && !filter(repo)) {
+		indexedRev, ok := zoektIndexedRevisions(ctx, repo, rev)
+		if !ok {
 			unindexed = append(unindexed, rev)
 			continue
 		}
-
-		indexed = append(indexed, rev)
+		indexed = append(indexed, indexedRev)
 	}
 
 	return indexed, unindexed, nil
 }
+
+// zoektListAll returns the repositories indexed by Zoekt. It gives up after a
+// second, so that searches don't wait for a slow index.
+func zoektListAll(ctx context.Context, z *searchbackend.Zoekt) (map[string]*zoekt.Repository, error) {
+	ctx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	return z.ListAll(ctx)
+}
+
+// isDefaultRevisions reports whether rev only searches the default branch.
+func isDefaultRevisions(rev *search.RepositoryRevisions) bool {
+	for _, r := range rev.Revs {
+		if r.RefGlob != "" || r.ExcludeRefGlob != "" || (r.RevSpec != "" && r.RevSpec != "HEAD") {
+			return false
+		}
+	}
+	return true
+}
@@ -21,12 +21,15 @@ import (
 	"github.com/sourcegraph/sourcegraph/cmd/frontend/db"
 	"github.com/sourcegraph/sourcegraph/cmd/frontend/types"
 	"github.com/sourcegraph/sourcegraph/internal/api"
+	"github.com/sourcegraph/sourcegraph/internal/conf"
 	"github.com/sourcegraph/sourcegraph/internal/db/dbconn"
 	"github.com/sourcegraph/sourcegraph/internal/db/dbtesting"
 	"github.com/sourcegraph/sourcegraph/internal/extsvc"
+	"github.com/sourcegraph/sourcegraph/internal/gitserver"
 	"github.com/sourcegraph/sourcegraph/internal/search"
 	searchbackend "github.com/sourcegraph/sourcegraph/internal/search/backend"
 	"github.com/sourcegraph/sourcegraph/internal/search/query"
+	"github.com/sourcegraph/sourcegraph/internal/vcs/git"
 	"github.com/sourcegraph/sourcegraph/schema"
 )
 
@@ -296,6 +299,11 @@ func TestZoektIndexedRepos(t *testing.T) {
 				Repo: r.Repo,
 				Revs: r.Revs,
 			}
+			if r.Repo.Name == "foo/indexed-three" {
+				// Several branches are indexed, so the search is
+				// restricted to the default branch.
+				rev.IndexedBranches = zoektRepoList.Repos[2].Repository.Branches[:1]
+			}
 			indexed = append(indexed, rev)
 		}
 		return indexed
@@ -616,6 +624,29 @@ func TestZoektResultCountFactor(t *testing.T) {
 	}
 }
 
+func TestZoektCaptureGroups(t *testing.T) {
+	re := regexp.MustCompile(`(?i:(?P<module>[a-z]+/[a-z]+) (v)(?P<version>[0-9.]+))`)
+	line := []byte("é a/b v1.2.3 C/D v0.1")
+	fragments := []zoekt.LineFragmentMatch{
+		{LineOffset: 3, MatchLength: 10},
+		{LineOffset: 14, MatchLength: 8},
+	}
+	want := []*captureGroup{
+		{JName: "module", JValue: "a/b", JOffset: 2, JLength: 3},
+		{JName: "version", JValue: "1.2.3", JOffset: 7, JLength: 5},
+		{JName: "module", JValue: "C/D", JOffset: 13, JLength: 3},
+		{JName: "version", JValue: "0.1", JOffset: 18, JLength: 3},
+	}
+	if diff := cmp.Diff(want, zoektCaptureGroups(re, line, fragments)); diff != "" {
+		t.Errorf("capture groups mismatch (-want +got):\n%s", diff)
+	}
+
+	// Matches which zoekt did not report are skipped.
+	if diff := cmp.Diff(want[2:], zoektCaptureGroups(re, line, fragments[1:])); diff != "" {
+		t.Errorf("capture groups mismatch (-want +got):\n%s", diff)
+	}
+}
+
 func TestQueryToZoektQuery(t *testing.T) {
 	cases := []struct {
 		Name    string
@@ -702,6 +733,20 @@ func TestQueryToZoektQuery(t *testing.T) {
 			},
 			Query: `f:test`,
 		},
+		{
+			Name: "negated",
+			Pattern: &search.TextPatternInfo{
+				IsRegExp:                     true,
+				IsCaseSensitive:              false,
+				IsNegated:                    true,
+				Pattern:                      "license header",
+				IncludePatterns:              []string{`^service/`},
+				PathPatternsAreRegExps:       true,
+				PathPatternsAreCaseSensitive: false,
+				PatternMatchesContent:        true,
+			},
+			Query: `-lang:binary -lang:skipped -"license header" case:no f:^service/`,
+		},
 	}
 	for _, tt := range cases {
 		t.Run(tt.Name, func(t *testing.T) {
@@ -720,6 +765,66 @@ func TestQueryToZoektQuery(t *testing.T) {
 	}
 }
 
+func TestQueryToZoektQuery_Multiline(t *testing.T) {
+	for _, multiline := range []bool{false, true} {
+		p := &search.TextPatternInfo{
+			IsRegExp:               true,
+			IsMultiline:            multiline,
+			Pattern:                "foo.*bar",
+			PathPatternsAreRegExps: true,
+			PatternMatchesContent:  true,
+		}
+		q, err := queryToZoektQuery(p, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		re, ok := q.(*zoektquery.Regexp)
+		if !ok {
+			t.Fatalf("got query %s, want a regexp query", q)
+		}
+		if got, want := regexp.MustCompile(re.Regexp.String()).MatchString("foo\nbar"), multiline; got != want {
+			t.Errorf("multiline %v: got match across lines %v, want %v", multiline, got, want)
+		}
+	}
+}
+
+func TestSplitLineMatch(t *testing.T) {
+	lm := &lineMatch{
+		JPreview:          "func a() {\n\treturn\n}\nfunc b() {}",
+		JLineNumber:       4,
+		JOffsetAndLengths: [][2]int32{{0, 20}, {21, 11}},
+		JCaptureGroups: []*captureGroup{
+			{JName: "body", JValue: "\n\treturn\n", JOffset: 10, JLength: 9},
+			{JName: "name", JValue: "b", JOffset: 26, JLength: 1},
+		},
+	}
+	want := []*lineMatch{
+		{
+			JPreview:          "func a() {",
+			JLineNumber:       4,
+			JOffsetAndLengths: [][2]int32{{0, 11}},
+			JCaptureGroups:    []*captureGroup{{JName: "body", JValue: "\n\treturn\n", JOffset: 10, JLength: 0}},
+		},
+		{JPreview: "\treturn", JLineNumber: 5, JOffsetAndLengths: [][2]int32{{0, 8}}},
+		{JPreview: "}", JLineNumber: 6, JOffsetAndLengths: [][2]int32{{0, 1}}},
+		{
+			JPreview:          "func b() {}",
+			JLineNumber:       7,
+			JOffsetAndLengths: [][2]int32{{0, 11}},
+			JCaptureGroups:    []*captureGroup{{JName: "name", JValue: "b", JOffset: 5, JLength: 1}},
+		},
+	}
+	if diff := cmp.Diff(want, splitLineMatch(lm)); diff != "" {
+		t.Errorf("line matches mismatch (-want +got):\n%s", diff)
+	}
+
+	// Line matches without newlines are returned as is.
+	single := &lineMatch{JPreview: "func b() {}", JOffsetAndLengths: [][2]int32{{0, 4}}}
+	if got := splitLineMatch(single); len(got) != 1 || got[0] != single {
+		t.Errorf("got %+v, want the line match unchanged", got)
+	}
+}
+
 func queryEqual(a, b zoektquery.Q) bool {
 	sortChildren := func(q zoektquery.Q) zoektquery.Q {
 		switch s := q.(type) {
@@ -1019,27 +1124,26 @@ func zoektRPC(s zoekt.Searcher) (zoekt.Searcher, func()) {
 }
 
 func TestZoektSingleIndexedRepo(t *testing.T) {
-	repoRev := func(revSpec string) *search.RepositoryRevisions {
-		return &search.RepositoryRevisions{
+	head := zoekt.RepositoryBranch{Name: "HEAD", Version: "df3f4e499698e48152b39cd655d8901eaf583fa5"}
+	notHead := zoekt.RepositoryBranch{Name: "NOT-HEAD", Version: "8ec975423738fe7851676083ebf660a062ed1578"}
+	repoRev := func(revSpecs ...string) *search.RepositoryRevisions {
+		r := &search.RepositoryRevisions{
 			Repo: &types.Repo{ID: api.RepoID(0), Name: "test/repo"},
-			Revs: []search.RevisionSpecifier{
-				{RevSpec: revSpec},
-			},
 		}
+		for _, revSpec := range revSpecs {
+			r.Revs = append(r.Revs, search.RevisionSpecifier{RevSpec: revSpec})
+		}
+		return r
+	}
+	indexedRepoRev := func(revSpec string, branches ...zoekt.RepositoryBranch) *search.RepositoryRevisions {
+		r := repoRev(revSpec)
+		r.IndexedBranches = branches
+		return r
 	}
 	zoektRepos := []*zoekt.RepoListEntry{{
 		Repository: zoekt.Repository{
-			Name: "test/repo",
-			Branches: []zoekt.RepositoryBranch{
-				{
-					Name:    "HEAD",
-					Version: "df3f4e499698e48152b39cd655d8901eaf583fa5",
-				},
-				{
-					Name:    "NOT-HEAD",
-					Version: "8ec975423738fe7851676083ebf660a062ed1578",
-				},
-			},
+			Name:     "test/repo",
+			Branches: []zoekt.RepositoryBranch{head, notHead},
 		},
 	}}
 	z := &searchbackend.Zoekt{
@@ -1049,44 +1153,60 @@ func TestZoektSingleIndexedRepo(t *testing.T) {
 		DisableCache: true,
 	}
 	cases := []struct {
-		rev           string
+		rev           *search.RepositoryRevisions
 		wantIndexed   []*search.RepositoryRevisions
 		wantUnindexed []*search.RepositoryRevisions
 	}{
 		{
-			rev:           "",
-			wantIndexed:   []*search.RepositoryRevisions{repoRev("")},
+			rev:           repoRev(""),
+			wantIndexed:   []*search.RepositoryRevisions{indexedRepoRev("", head)},
 			wantUnindexed: []*search.RepositoryRevisions{},
 		},
 		{
-			rev:           "HEAD",
-			wantIndexed:   []*search.RepositoryRevisions{repoRev("HEAD")},
+			rev:           repoRev("HEAD"),
+			wantIndexed:   []*search.RepositoryRevisions{indexedRepoRev("HEAD", head)},
 			wantUnindexed: []*search.RepositoryRevisions{},
 		},
 		{
-			rev:           "df3f4e499698e48152b39cd655d8901eaf583fa5",
-			wantIndexed:   []*search.RepositoryRevisions{repoRev("df3f4e499698e48152b39cd655d8901eaf583fa5")},
+			rev:           repoRev("df3f4e499698e48152b39cd655d8901eaf583fa5"),
+			wantIndexed:   []*search.RepositoryRevisions{indexedRepoRev("df3f4e499698e48152b39cd655d8901eaf583fa5", head)},
 			wantUnindexed: []*search.RepositoryRevisions{},
 		},
 		{
-			rev:           "df3f4e",
-			wantIndexed:   []*search.RepositoryRevisions{repoRev("df3f4e")},
+			rev:           repoRev("df3f4e"),
+			wantIndexed:   []*search.RepositoryRevisions{indexedRepoRev("df3f4e", head)},
 			wantUnindexed: []*search.RepositoryRevisions{},
 		},
 		{
-			rev:           "d",
+			rev:           repoRev("d"),
 			wantIndexed:   []*search.RepositoryRevisions{},
 			wantUnindexed: []*search.RepositoryRevisions{repoRev("d")},
 		},
 		{
-			rev:           "HEAD^1",
+			rev:           repoRev("HEAD^1"),
 			wantIndexed:   []*search.RepositoryRevisions{},
 			wantUnindexed: []*search.RepositoryRevisions{repoRev("HEAD^1")},
 		},
 		{
-			rev:           "8ec975423738fe7851676083ebf660a062ed1578",
+			rev:           repoRev("8ec975423738fe7851676083ebf660a062ed1578"),
+			wantUnindexed: []*search.RepositoryRevisions{},
+			wantIndexed:   []*search.RepositoryRevisions{indexedRepoRev("8ec975423738fe7851676083ebf660a062ed1578", notHead)},
+		},
+		{
+			rev:           repoRev("NOT-HEAD"),
 			wantUnindexed: []*search.RepositoryRevisions{},
-			wantIndexed:   []*search.RepositoryRevisions{repoRev("8ec975423738fe7851676083ebf660a062ed1578")},
+			wantIndexed:   []*search.RepositoryRevisions{indexedRepoRev("NOT-HEAD", notHead)},
+		},
+		{
+			rev:           repoRev("refs/heads/NOT-HEAD"),
+			wantUnindexed: []*search.RepositoryRevisions{},
+			wantIndexed:   []*search.RepositoryRevisions{indexedRepoRev("refs/heads/NOT-HEAD", notHead)},
+		},
+		{
+			// Searching multiple revisions is not enabled.
+			rev:           repoRev("HEAD", "NOT-HEAD"),
+			wantIndexed:   []*search.RepositoryRevisions{},
+			wantUnindexed: []*search.RepositoryRevisions{repoRev("HEAD", "NOT-HEAD")},
 		},
 	}
 
@@ -1096,7 +1216,7 @@ func TestZoektSingleIndexedRepo(t *testing.T) {
 
 	for _, tt := range cases {
 		filter := func(*zoekt.Repository) bool { return true }
-		indexed, unindexed, err := zoektSingleIndexedRepo(context.Background(), z, repoRev(tt.rev), filter)
+		indexed, unindexed, err := zoektSingleIndexedRepo(context.Background(), z, tt.rev, filter)
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -1113,3 +1233,185 @@ func TestZoektSingleIndexedRepo(t *testing.T) {
 		}
 	}
 }
+
+func TestZoektIndexedRevisions(t *testing.T) {
+	repo := &zoekt.Repository{
+		Name: "test/repo",
+		Branches: []zoekt.RepositoryBranch{
+			{Name: "HEAD", Version: "aaaaaaaa"},
+			{Name: "release-1", Version: "bbbbbbbb"},
+			{Name: "release-2", Version: "cccccccc"},
+			{Name: "release-20", Version: "dddddddd"},
+		},
+	}
+	listRefs := func(context.Context, gitserver.Repo) ([]git.Ref, error) {
+		return []git.Ref{
+			{Name: "refs/heads/master", CommitID: "aaaaaaaa"},
+			{Name: "refs/heads/release-1", CommitID: "bbbbbbbb"},
+			{Name: "refs/heads/release-2", CommitID: "cccccccc"},
+			{Name: "refs/heads/release-20", CommitID: "dddddddd"},
+			{Name: "refs/heads/unindexed-1", CommitID: "eeeeeeee"},
+		}, nil
+	}
+	repoRev := func(spec string) *search.RepositoryRevisions {
+		_, revs := search.ParseRepositoryRevisions("test/repo@" + spec)
+		return &search.RepositoryRevisions{
+			Repo:     &types.Repo{Name: "test/repo"},
+			Revs:     revs,
+			ListRefs: listRefs,
+		}
+	}
+
+	multipleRevs := true
+	conf.Mock(&conf.Unified{SiteConfiguration: schema.SiteConfiguration{
+		ExperimentalFeatures: &schema.ExperimentalFeatures{SearchMultipleRevisionsPerRepository: &multipleRevs},
+	}})
+	defer conf.Mock(nil)
+
+	cases := []struct {
+		spec string
+		want []string // indexed branches, nil if unindexed
+	}{
+		{spec: "release-1", want: []string{"release-1"}},
+		{spec: "release-1:HEAD", want: []string{"release-1", "HEAD"}},
+		{spec: "release-*", want: []string{"release-1", "release-2", "release-20"}},
+		{spec: "*refs/heads/release-*:*!refs/heads/release-2*", want: []string{"release-1"}},
+		// release-2 is part of the name of release-20, which Zoekt
+		// can't tell apart.
+		{spec: "release-2", want: nil},
+		{spec: "release-2:release-20", want: []string{"release-2", "release-20"}},
+		{spec: "unindexed-*", want: nil},
+		{spec: "nomatch-*", want: nil},
+	}
+	t.Run("default branch", func(t *testing.T) {
+		for _, spec := range []string{"", "HEAD", ":HEAD"} {
+			rev := repoRev(spec)
+			rev.ListRefs = func(context.Context, gitserver.Repo) ([]git.Ref, error) {
+				t.Fatal("ListRefs called for the default branch")
+				return nil, nil
+			}
+			indexedRev, ok := zoektIndexedRevisions(context.Background(), repo, rev)
+			if !ok || len(indexedRev.IndexedBranches) != 1 || indexedRev.IndexedBranches[0] != repo.Branches[0] {
+				t.Errorf("%q: got %v, %v, want the search restricted to HEAD", spec, indexedRev, ok)
+			}
+		}
+	})
+
+	for _, c := range cases {
+		t.Run(c.spec, func(t *testing.T) {
+			indexedRev, ok := zoektIndexedRevisions(context.Background(), repo, repoRev(c.spec))
+			if !ok {
+				if c.want != nil {
+					t.Fatalf("got unindexed, want %v", c.want)
+				}
+				return
+			}
+			var got []string
+			for _, b := range indexedRev.IndexedBranches {
+				got = append(got, b.Name)
+			}
+			sort.Strings(got)
+			sort.Strings(c.want)
+			if diff := cmp.Diff(c.want, got); diff != "" {
+				t.Errorf("mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestZoektFileRev(t *testing.T) {
+	head := zoekt.RepositoryBranch{Name: "HEAD", Version: "aaaaaaaa"}
+	release := zoekt.RepositoryBranch{Name: "release-1", Version: "bbbbbbbb"}
+	_, globRevs := search.ParseRepositoryRevisions("test/repo@release-*:HEAD")
+
+	cases := []struct {
+		name       string
+		repoRev    *search.RepositoryRevisions
+		file       zoekt.FileMatch
+		wantRev    string
+		wantCommit api.CommitID
+	}{{
+		name:       "default branch",
+		repoRev:    &search.RepositoryRevisions{Revs: []search.RevisionSpecifier{{RevSpec: ""}}},
+		file:       zoekt.FileMatch{Version: "aaaaaaaa"},
+		wantRev:    "",
+		wantCommit: "aaaaaaaa",
+	}, {
+		name: "branch",
+		repoRev: &search.RepositoryRevisions{
+			Revs:            []search.RevisionSpecifier{{RevSpec: "refs/heads/release-1"}},
+			IndexedBranches: []zoekt.RepositoryBranch{release},
+		},
+		file:       zoekt.FileMatch{Version: "aaaaaaaa", Branches: []string{"release-1"}},
+		wantRev:    "refs/heads/release-1",
+		wantCommit: "bbbbbbbb",
+	}, {
+		name: "glob",
+		repoRev: &search.RepositoryRevisions{
+			Revs:            globRevs,
+			IndexedBranches: []zoekt.RepositoryBranch{head, release},
+		},
+		file:       zoekt.FileMatch{Version: "aaaaaaaa", Branches: []string{"release-1"}},
+		wantRev:    "release-1",
+		wantCommit: "bbbbbbbb",
+	}, {
+		name: "glob default branch",
+		repoRev: &search.RepositoryRevisions{
+			Revs:            globRevs,
+			IndexedBranches: []zoekt.RepositoryBranch{release, head},
+		},
+		file:       zoekt.FileMatch{Version: "aaaaaaaa", Branches: []string{"HEAD"}},
+		wantRev:    "",
+		wantCommit: "aaaaaaaa",
+	}}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			rev, commit := zoektFileRev(c.repoRev, c.file)
+			if rev != c.wantRev || commit != c.wantCommit {
+				t.Errorf("got (%q, %q), want (%q, %q)", rev, commit, c.wantRev, c.wantCommit)
+			}
+		})
+	}
+}
+
+func TestZoektFileRevs(t *testing.T) {
+	head := zoekt.RepositoryBranch{Name: "HEAD", Version: "aaaaaaaa"}
+	release1 := zoekt.RepositoryBranch{Name: "release-1", Version: "bbbbbbbb"}
+	release2 := zoekt.RepositoryBranch{Name: "release-2", Version: "cccccccc"}
+	_, globRevs := search.ParseRepositoryRevisions("test/repo@release-*")
+
+	// Zoekt indexes a file that is the same in several branches once, and
+	// reports all of them.
+	file := zoekt.FileMatch{Version: "bbbbbbbb", Branches: []string{"release-1", "release-2"}}
+
+	repoRev := &search.RepositoryRevisions{Revs: globRevs, IndexedBranches: []zoekt.RepositoryBranch{release1, release2}}
+	if got, want := zoektFileRevs(repoRev, file), []string{"release-1", "release-2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	repoRev = &search.RepositoryRevisions{Revs: globRevs, IndexedBranches: []zoekt.RepositoryBranch{head, release2}}
+	if got, want := zoektFileRevs(repoRev, file), []string{"release-2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	repoRev = &search.RepositoryRevisions{Revs: globRevs, IndexedBranches: []zoekt.RepositoryBranch{release1}}
+	if got := zoektFileRevs(repoRev, file); got != nil {
+		t.Errorf("got %v, want no revisions for a single branch", got)
+	}
+}
+
+func TestZoektRepoBranches(t *testing.T) {
+	repos := makeRepositoryRevisions("foo/a", "foo/b@release-1")
+	if q := zoektRepoBranches(repos); q != nil {
+		t.Errorf("got %s, want no branch restriction", q)
+	}
+
+	repos[1].IndexedBranches = []zoekt.RepositoryBranch{{Name: "release-1", Version: "deadbeef"}}
+	want := &zoektquery.RepoBranches{Set: map[string][]string{
+		"foo/a": {"HEAD"},
+		"foo/b": {"release-1"},
+	}}
+	if diff := cmp.Diff(want, zoektRepoBranches(repos)); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+}
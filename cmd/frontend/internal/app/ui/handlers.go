@@ -24,6 +24,7 @@ import (
 	"github.com/sourcegraph/sourcegraph/cmd/frontend/types"
 	"github.com/sourcegraph/sourcegraph/internal/actor"
 	"github.com/sourcegraph/sourcegraph/internal/api"
+	"github.com/sourcegraph/sourcegraph/internal/archive"
 	"github.com/sourcegraph/sourcegraph/internal/conf"
 	"github.com/sourcegraph/sourcegraph/internal/env"
 	"github.com/sourcegraph/sourcegraph/internal/errcode"
@@ -281,7 +282,10 @@ func redirectTreeOrBlob(routeName, path string, common *Common, w http.ResponseW
 	if err != nil {
 		return false, err
 	}
-	stat, err := git.Stat(r.Context(), *cachedRepo, common.CommitID, path)
+	// The files in archives, at paths like lib/foo.jar!/com/x/Y.java, are
+	// blobs if the archive is.
+	statPath, _, inArchive := archive.SplitPath(path)
+	stat, err := git.Stat(r.Context(), *cachedRepo, common.CommitID, statPath)
 	if err != nil {
 		if os.IsNotExist(err) {
 			serveError(w, r, err, http.StatusNotFound)
@@ -289,6 +293,10 @@ func redirectTreeOrBlob(routeName, path string, common *Common, w http.ResponseW
 		}
 		return false, err
 	}
+	if inArchive && stat.Mode().IsDir() {
+		serveError(w, r, &os.PathError{Op: "stat", Path: path, Err: os.ErrNotExist}, http.StatusNotFound)
+		return true, nil
+	}
 	expectedDir := routeName == routeTree
 	if stat.Mode().IsDir() != expectedDir {
 		target := "/" + string(common.Repo.Name) + common.Rev + "/-/"
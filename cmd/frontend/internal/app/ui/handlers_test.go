@@ -295,6 +295,37 @@ func TestRedirectTreeOrBlob(t *testing.T) {
 			expStatusCode: http.StatusTemporaryRedirect,
 			expLocation:   "/github.com/user/repo@master/-/tree/some/dir",
 		},
+		// "/github.com/user/repo/-/blob/lib/foo.jar!/com/x/Y.java" is served
+		{
+			name:  "serve blob in archive",
+			route: routeBlob,
+			path:  "/lib/foo.jar!/com/x/Y.java",
+			common: &Common{
+				Repo: &types.Repo{
+					Name: "github.com/user/repo",
+				},
+				CommitID: "eca7e807356b887ee24b7a7497973bbfc5688dac",
+			},
+			mockStat:      &util.FileInfo{}, // The archive, not a directory
+			expHandled:    false,
+			expStatusCode: http.StatusOK,
+		},
+		// "/github.com/user/repo/-/tree/lib/foo.jar!/com/x/Y.java" -> "/github.com/user/repo/-/blob/lib/foo.jar!/com/x/Y.java"
+		{
+			name:  "redirct tree in archive to blob",
+			route: routeTree,
+			path:  "/lib/foo.jar!/com/x/Y.java",
+			common: &Common{
+				Repo: &types.Repo{
+					Name: "github.com/user/repo",
+				},
+				CommitID: "eca7e807356b887ee24b7a7497973bbfc5688dac",
+			},
+			mockStat:      &util.FileInfo{}, // The archive, not a directory
+			expHandled:    true,
+			expStatusCode: http.StatusTemporaryRedirect,
+			expLocation:   "/github.com/user/repo/-/blob/lib/foo.jar%21/com/x/Y.java",
+		},
 
 		// "/github.com/user/repo/-/tree" -> "/github.com/user/repo"
 		{
@@ -0,0 +1,22 @@
+package bg
+
+import (
+	"context"
+	"time"
+
+	"github.com/inconshreveable/log15"
+
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/db"
+	"github.com/sourcegraph/sourcegraph/internal/conf"
+)
+
+// DeleteOldSearchHistoryInPostgres deletes the search history entries older
+// than the search.history.retentionDays site setting.
+func DeleteOldSearchHistoryInPostgres(ctx context.Context) {
+	for {
+		if err := db.SearchHistory.DeleteOlderThan(ctx, conf.SearchHistoryRetentionDays()); err != nil {
+			log15.Error("deleting expired rows from search_history table", "error", err)
+		}
+		time.Sleep(time.Hour)
+	}
+}
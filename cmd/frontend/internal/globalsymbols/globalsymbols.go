@@ -0,0 +1,214 @@
+// Package globalsymbols maintains the global symbol index, which holds the
+// symbols of the default branch of every repository, with the symbols
+// extracted by the symbols service.
+package globalsymbols
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/inconshreveable/log15"
+	"github.com/neelance/parallel"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/backend"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/db"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/types"
+	"github.com/sourcegraph/sourcegraph/internal/actor"
+	"github.com/sourcegraph/sourcegraph/internal/api"
+	"github.com/sourcegraph/sourcegraph/internal/conf"
+	"github.com/sourcegraph/sourcegraph/internal/gitserver"
+	"github.com/sourcegraph/sourcegraph/internal/symbols/protocol"
+	"github.com/sourcegraph/sourcegraph/internal/vcs/git"
+)
+
+const (
+	// interval is the time between two passes over all repositories.
+	interval = 10 * time.Minute
+
+	// pageSize is the number of repositories listed at once.
+	pageSize = 500
+
+	// updateTimeout is the maximum time to update the symbols of one
+	// repository.
+	updateTimeout = 5 * time.Minute
+
+	// concurrency is the number of repositories updated at the same time.
+	concurrency = 4
+)
+
+// errTooManySymbols is returned when a repository has more symbols than the
+// index allows per repository.
+var errTooManySymbols = errors.New("too many symbols")
+
+// Run updates the index every interval until ctx is done, while the index is
+// enabled in the site configuration.
+func Run(ctx context.Context) {
+	// Repositories are listed regardless of the permissions of any user.
+	ctx = actor.WithActor(ctx, &actor.Actor{Internal: true})
+	seen := map[api.RepoID]time.Time{}
+	for ctx.Err() == nil {
+		if conf.GlobalSymbolsEnabled() {
+			if err := updateAll(ctx, seen); err != nil {
+				log15.Error("globalsymbols: updating index", "error", err)
+			}
+		}
+		time.Sleep(interval)
+	}
+}
+
+// updateAll indexes the default branch of every repository whose default
+// branch changed since it was last indexed.
+//
+// seen holds the time of the last change of each repository whose HEAD was
+// handled by a previous pass. Repositories which have not changed since are
+// skipped without resolving their HEAD, and seen is updated with the
+// repositories handled by this pass.
+func updateAll(ctx context.Context, seen map[api.RepoID]time.Time) error {
+	for offset := 0; ; offset += pageSize {
+		repos, err := db.Repos.List(ctx, db.ReposListOptions{LimitOffset: &db.LimitOffset{Limit: pageSize, Offset: offset}})
+		if err != nil {
+			return err
+		}
+		if len(repos) == 0 {
+			return nil
+		}
+
+		changed, err := lastChanged(ctx, repos)
+		if err != nil {
+			// Resolve the HEAD of every repository instead.
+			log15.Warn("globalsymbols: getting the last change of repositories", "error", err)
+		}
+		var stale []*types.Repo
+		for _, repo := range repos {
+			if t, ok := changed[repo.ID]; !ok || !t.Equal(seen[repo.ID]) {
+				stale = append(stale, repo)
+			}
+		}
+		if len(stale) == 0 {
+			continue
+		}
+
+		ids := make([]api.RepoID, len(stale))
+		for i, repo := range stale {
+			ids[i] = repo.ID
+		}
+		indexed, err := db.GlobalSymbols.IndexedCommits(ctx, ids)
+		if err != nil {
+			return err
+		}
+
+		var mu sync.Mutex
+		run := parallel.NewRun(concurrency)
+		for _, repo := range stale {
+			if ctx.Err() != nil || !conf.GlobalSymbolsEnabled() {
+				break
+			}
+			run.Acquire()
+			go func(repo *types.Repo) {
+				defer run.Release()
+				if update(ctx, repo, indexed[repo.ID]) {
+					if t, ok := changed[repo.ID]; ok {
+						mu.Lock()
+						seen[repo.ID] = t
+						mu.Unlock()
+					}
+				}
+			}(repo)
+		}
+		run.Wait()
+		if ctx.Err() != nil || !conf.GlobalSymbolsEnabled() {
+			return ctx.Err()
+		}
+	}
+}
+
+// lastChanged returns the time of the most recent change of the refs of each
+// of the given repositories, for the repositories gitserver knows it for. It
+// is replaced in tests.
+var lastChanged = func(ctx context.Context, repos []*types.Repo) (map[api.RepoID]time.Time, error) {
+	names := make([]api.RepoName, len(repos))
+	for i, repo := range repos {
+		names[i] = repo.Name
+	}
+	// RepoInfo returns the information it got along with an error.
+	info, err := gitserver.DefaultClient.RepoInfo(ctx, names...)
+	if info == nil {
+		return nil, err
+	}
+	changed := make(map[api.RepoID]time.Time, len(repos))
+	for _, repo := range repos {
+		if ri := info.Results[repo.Name]; ri != nil && ri.LastChanged != nil {
+			changed[repo.ID] = *ri.LastChanged
+		}
+	}
+	return changed, err
+}
+
+// resolveHEAD returns the commit of the default branch of a repository. It
+// is replaced in tests.
+var resolveHEAD = func(ctx context.Context, repo *types.Repo) (api.CommitID, error) {
+	return git.ResolveRevision(ctx, gitserver.Repo{Name: repo.Name}, nil, "HEAD", &git.ResolveRevisionOptions{NoEnsureRevision: true})
+}
+
+// update indexes the default branch of repo unless it is already indexed.
+// Repositories with too many symbols are removed from the index, so that
+// they are searched with the symbols service. It reports whether the HEAD of
+// repo is handled, and needs no update until the repository changes.
+func update(ctx context.Context, repo *types.Repo, indexed api.CommitID) (done bool) {
+	commitID, err := resolveHEAD(ctx, repo)
+	if err != nil {
+		// The repository may be empty or not cloned yet.
+		log15.Debug("globalsymbols: resolving HEAD", "repo", repo.Name, "error", err)
+		return false
+	}
+	if commitID == indexed {
+		return true
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, updateTimeout)
+	defer cancel()
+
+	max := conf.GlobalSymbolsMaxSymbolsPerRepo()
+	tooMany := false
+	updated, err := db.GlobalSymbols.Update(ctx, repo.ID, commitID, func(ctx context.Context) ([]protocol.Symbol, error) {
+		symbols, err := backend.Symbols.List(ctx, protocol.ListArgs{Repo: repo.Name, CommitID: commitID, First: max + 1})
+		if err != nil {
+			return nil, err
+		}
+		if len(symbols) > max {
+			tooMany = true
+			return nil, errTooManySymbols
+		}
+		return symbols, nil
+	})
+	switch {
+	case tooMany:
+		updates.WithLabelValues("too_many_symbols").Inc()
+		if err := db.GlobalSymbols.Delete(ctx, repo.ID); err != nil {
+			log15.Error("globalsymbols: deleting repository", "repo", repo.Name, "error", err)
+			return false
+		}
+		return true
+	case err != nil:
+		updates.WithLabelValues("error").Inc()
+		log15.Warn("globalsymbols: updating repository", "repo", repo.Name, "commit", commitID, "error", err)
+		return false
+	case updated:
+		updates.WithLabelValues("success").Inc()
+	}
+	// The repository was not updated if another process was updating it at
+	// the same time, so check it again on the next pass.
+	return updated
+}
+
+var updates = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "src_global_symbols_updates_total",
+	Help: "The number of updates of the symbols of a repository in the global symbol index, by result.",
+}, []string{"result"})
+
+func init() {
+	prometheus.MustRegister(updates)
+}
@@ -0,0 +1,141 @@
+package globalsymbols
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/backend"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/db"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/types"
+	"github.com/sourcegraph/sourcegraph/internal/api"
+	"github.com/sourcegraph/sourcegraph/internal/conf"
+	"github.com/sourcegraph/sourcegraph/internal/symbols/protocol"
+	"github.com/sourcegraph/sourcegraph/schema"
+)
+
+func TestUpdateAll(t *testing.T) {
+	conf.Mock(&conf.Unified{SiteConfiguration: schema.SiteConfiguration{
+		SearchGlobalSymbols: &schema.SearchGlobalSymbols{Enabled: true, MaxSymbolsPerRepo: 2},
+	}})
+	defer conf.Mock(nil)
+	defer func() {
+		db.Mocks = db.MockStores{}
+		backend.Mocks = backend.MockServices{}
+	}()
+
+	repos := []*types.Repo{
+		{ID: 1, Name: "unchanged"},
+		{ID: 2, Name: "changed"},
+		{ID: 3, Name: "new"},
+		{ID: 4, Name: "large"},
+		{ID: 5, Name: "empty"},
+	}
+	heads := map[api.RepoName]api.CommitID{
+		"unchanged": "c1",
+		"changed":   "c2",
+		"new":       "c3",
+		"large":     "c4",
+	}
+	symbols := map[api.RepoName][]protocol.Symbol{
+		"changed": {{Name: "a"}},
+		"new":     {{Name: "b"}, {Name: "c"}},
+		"large":   {{Name: "d"}, {Name: "e"}, {Name: "f"}},
+	}
+
+	db.Mocks.Repos.List = func(ctx context.Context, opt db.ReposListOptions) ([]*types.Repo, error) {
+		if opt.Offset >= len(repos) {
+			return nil, nil
+		}
+		return repos[opt.Offset:], nil
+	}
+	db.Mocks.GlobalSymbols.IndexedCommits = func(ctx context.Context, repoIDs []api.RepoID) (map[api.RepoID]api.CommitID, error) {
+		return map[api.RepoID]api.CommitID{1: "c1", 2: "c0", 4: "c0"}, nil
+	}
+	// The empty repository has never changed.
+	changed := map[api.RepoID]time.Time{1: time.Unix(1, 0), 2: time.Unix(2, 0), 3: time.Unix(3, 0), 4: time.Unix(4, 0)}
+	origLastChanged := lastChanged
+	defer func() { lastChanged = origLastChanged }()
+	lastChanged = func(ctx context.Context, repos []*types.Repo) (map[api.RepoID]time.Time, error) {
+		return changed, nil
+	}
+
+	// Repositories are updated concurrently.
+	var mu sync.Mutex
+	var resolved []api.RepoName
+	origResolveHEAD := resolveHEAD
+	defer func() { resolveHEAD = origResolveHEAD }()
+	resolveHEAD = func(ctx context.Context, repo *types.Repo) (api.CommitID, error) {
+		mu.Lock()
+		resolved = append(resolved, repo.Name)
+		mu.Unlock()
+		if commitID, ok := heads[repo.Name]; ok {
+			return commitID, nil
+		}
+		return "", errors.New("empty repository")
+	}
+	backend.Mocks.Symbols.List = func(ctx context.Context, args protocol.ListArgs) ([]protocol.Symbol, error) {
+		if args.CommitID != heads[args.Repo] {
+			t.Errorf("listed %s@%s, want the commit of HEAD", args.Repo, args.CommitID)
+		}
+		if args.First != 3 {
+			t.Errorf("got First %d, want one more than the maximum number of symbols", args.First)
+		}
+		return symbols[args.Repo], nil
+	}
+
+	got := map[api.RepoID][]protocol.Symbol{}
+	db.Mocks.GlobalSymbols.Update = func(ctx context.Context, repoID api.RepoID, commitID api.CommitID, fetch func(context.Context) ([]protocol.Symbol, error)) (bool, error) {
+		symbols, err := fetch(ctx)
+		if err != nil {
+			return false, err
+		}
+		mu.Lock()
+		got[repoID] = symbols
+		mu.Unlock()
+		return true, nil
+	}
+	var deleted []api.RepoID
+	db.Mocks.GlobalSymbols.Delete = func(ctx context.Context, repoID api.RepoID) error {
+		mu.Lock()
+		deleted = append(deleted, repoID)
+		mu.Unlock()
+		return nil
+	}
+
+	seen := map[api.RepoID]time.Time{}
+	if err := updateAll(context.Background(), seen); err != nil {
+		t.Fatal(err)
+	}
+	want := map[api.RepoID][]protocol.Symbol{
+		2: symbols["changed"],
+		3: symbols["new"],
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got updates %v, want %v", got, want)
+	}
+	if want := []api.RepoID{4}; !reflect.DeepEqual(deleted, want) {
+		t.Errorf("got deleted %v, want %v", deleted, want)
+	}
+
+	// Repositories which have not changed since the last pass are skipped,
+	// including the large one which is not in the index.
+	resolved = nil
+	changed[2] = time.Unix(5, 0)
+	heads["changed"] = "c5"
+	got = map[api.RepoID][]protocol.Symbol{}
+	if err := updateAll(context.Background(), seen); err != nil {
+		t.Fatal(err)
+	}
+	sort.Slice(resolved, func(i, j int) bool { return resolved[i] < resolved[j] })
+	if want := []api.RepoName{"changed", "empty"}; !reflect.DeepEqual(resolved, want) {
+		t.Errorf("got resolved %v, want %v", resolved, want)
+	}
+	if want := map[api.RepoID][]protocol.Symbol{2: symbols["changed"]}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got updates %v, want %v", got, want)
+	}
+}
@@ -58,6 +58,10 @@ func NewHandler(m *mux.Router, schema *graphql.Schema, githubWebhook, bitbucketS
 	}
 
 	m.Get(apirouter.GraphQL).Handler(trace.TraceRoute(handler(serveGraphQL(schema))))
+	m.Get(apirouter.SearchStream).Handler(trace.TraceRoute(newSearchStreamHandler()))
+	m.Get(apirouter.SearchExportCreate).Handler(trace.TraceRoute(handler(serveSearchExportCreate)))
+	m.Get(apirouter.SearchExportStatus).Handler(trace.TraceRoute(handler(serveSearchExportStatus)))
+	m.Get(apirouter.SearchExportDownload).Handler(trace.TraceRoute(handler(serveSearchExportDownload)))
 
 	// Return the minimum src-cli version that's compatible with this instance
 	m.Get(apirouter.SrcCliVersion).Handler(trace.TraceRoute(handler(srcCliVersionServe)))
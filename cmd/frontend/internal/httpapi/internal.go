@@ -22,6 +22,7 @@ import (
 	"github.com/sourcegraph/sourcegraph/internal/gitserver"
 	"github.com/sourcegraph/sourcegraph/internal/gitserver/protocol"
 	"github.com/sourcegraph/sourcegraph/internal/jsonc"
+	searchbackend "github.com/sourcegraph/sourcegraph/internal/search/backend"
 	"github.com/sourcegraph/sourcegraph/internal/txemail"
 	"github.com/sourcegraph/sourcegraph/internal/vcs/git"
 )
@@ -138,14 +139,21 @@ func serveConfiguration(w http.ResponseWriter, r *http.Request) error {
 // Additionally, it only cares about certain search specific settings so this
 // search specific endpoint is used rather than serving the entire site settings
 // from /.internal/configuration.
+//
+// If the repo query parameter is set, Branches lists the branches to index
+// for that repository, starting with HEAD.
 func serveSearchConfiguration(w http.ResponseWriter, r *http.Request) error {
 	opts := struct {
 		LargeFiles []string
 		Symbols    bool
+		Branches   []string `json:",omitempty"`
 	}{
 		LargeFiles: conf.Get().SearchLargeFiles,
 		Symbols:    conf.SymbolIndexEnabled(),
 	}
+	if repo := r.URL.Query().Get("repo"); repo != "" {
+		opts.Branches = searchbackend.IndexBranches(conf.Get().SearchIndexBranches, repo)
+	}
 	err := json.NewEncoder(w).Encode(opts)
 	if err != nil {
 		return errors.Wrap(err, "encode")
@@ -15,6 +15,12 @@ const (
 
 	Registry = "registry"
 
+	SearchStream = "search.stream"
+
+	SearchExportCreate   = "search.export.create"
+	SearchExportStatus   = "search.export.status"
+	SearchExportDownload = "search.export.download"
+
 	RepoShield  = "repo.shield"
 	RepoRefresh = "repo.refresh"
 	Telemetry   = "telemetry"
@@ -69,6 +75,10 @@ func New(base *mux.Router) *mux.Router {
 	base.Path("/lsif/upload").Methods("POST").Name(LSIFUpload)
 	base.Path("/src-cli/version").Methods("GET").Name(SrcCliVersion)
 	base.Path("/src-cli/{rest:.*}").Methods("GET").Name(SrcCliDownload)
+	base.Path("/search/stream").Methods("GET").Name(SearchStream)
+	base.Path("/search/export").Methods("POST").Name(SearchExportCreate)
+	base.Path("/search/export/{ID:[0-9]+}").Methods("GET").Name(SearchExportStatus)
+	base.Path("/search/export/{ID:[0-9]+}/download").Methods("GET").Name(SearchExportDownload)
 
 	// repo contains routes that are NOT specific to a revision. In these routes, the URL may not contain a revspec after the repo (that is, no "github.com/foo/bar@myrevspec").
 	repoPath := `/repos/` + routevar.Repo
@@ -0,0 +1,135 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/backend"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/db"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/internal/searchexport"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/types"
+	"github.com/sourcegraph/sourcegraph/internal/actor"
+	"github.com/sourcegraph/sourcegraph/internal/errcode"
+)
+
+// searchExportJSON is the status of a search export.
+type searchExportJSON struct {
+	ID             int64      `json:"id"`
+	Query          string     `json:"query"`
+	Format         string     `json:"format"`
+	State          string     `json:"state"`
+	FailureMessage *string    `json:"failureMessage,omitempty"`
+	ResultCount    int32      `json:"resultCount"`
+	CreatedAt      time.Time  `json:"createdAt"`
+	StartedAt      *time.Time `json:"startedAt,omitempty"`
+	FinishedAt     *time.Time `json:"finishedAt,omitempty"`
+}
+
+func toSearchExportJSON(e *types.SearchExport) *searchExportJSON {
+	return &searchExportJSON{
+		ID:             e.ID,
+		Query:          e.Query,
+		Format:         e.Format,
+		State:          e.State,
+		FailureMessage: e.FailureMessage,
+		ResultCount:    e.ResultCount,
+		CreatedAt:      e.CreatedAt,
+		StartedAt:      e.StartedAt,
+		FinishedAt:     e.FinishedAt,
+	}
+}
+
+// serveSearchExportCreate queues an export of every result of the search
+// given by the q (query), v (version), t (pattern type) and format ("csv" or
+// "jsonl") form values. It responds with the status of the new export.
+func serveSearchExportCreate(w http.ResponseWriter, r *http.Request) error {
+	a := actor.FromContext(r.Context())
+	if !a.IsAuthenticated() {
+		return &errcode.HTTPErr{Status: http.StatusUnauthorized, Err: errors.New("must be signed in to export search results")}
+	}
+
+	export := &types.SearchExport{
+		UserID:      a.UID,
+		Query:       r.FormValue("q"),
+		Version:     r.FormValue("v"),
+		PatternType: r.FormValue("t"),
+		Format:      r.FormValue("format"),
+	}
+	if export.Query == "" {
+		return &errcode.HTTPErr{Status: http.StatusBadRequest, Err: errors.New("no query found")}
+	}
+	if export.Version == "" {
+		export.Version = "V2"
+	}
+	if export.PatternType == "" {
+		export.PatternType = "literal"
+	}
+	if export.Format == "" {
+		export.Format = searchexport.FormatCSV
+	}
+	if export.Format != searchexport.FormatCSV && export.Format != searchexport.FormatJSONL {
+		return &errcode.HTTPErr{Status: http.StatusBadRequest, Err: fmt.Errorf("unsupported format %q", export.Format)}
+	}
+	query, err := searchexport.PrepareQuery(export.Query, export.PatternType)
+	if err != nil {
+		return &errcode.HTTPErr{Status: http.StatusBadRequest, Err: err}
+	}
+	export.Query = query
+
+	export, err = db.SearchExports.Create(r.Context(), export)
+	if err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusCreated)
+	return json.NewEncoder(w).Encode(toSearchExportJSON(export))
+}
+
+// serveSearchExportStatus responds with the status of a search export.
+func serveSearchExportStatus(w http.ResponseWriter, r *http.Request) error {
+	export, err := getSearchExport(r)
+	if err != nil {
+		return err
+	}
+	return json.NewEncoder(w).Encode(toSearchExportJSON(export))
+}
+
+// serveSearchExportDownload responds with the exported results of a completed
+// search export.
+func serveSearchExportDownload(w http.ResponseWriter, r *http.Request) error {
+	export, err := getSearchExport(r)
+	if err != nil {
+		return err
+	}
+	if export.State != db.SearchExportCompleted {
+		return &errcode.HTTPErr{Status: http.StatusConflict, Err: fmt.Errorf("search export is %s, not completed", export.State)}
+	}
+	w.Header().Set("Content-Type", searchexport.ContentType(export.Format))
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="search-export-%d.%s"`, export.ID, export.Format))
+	return db.SearchExports.WriteData(r.Context(), export.ID, w)
+}
+
+// getSearchExport returns the search export with the ID in the route, if the
+// current user may access it.
+func getSearchExport(r *http.Request) (*types.SearchExport, error) {
+	id, err := strconv.ParseInt(mux.Vars(r)["ID"], 10, 64)
+	if err != nil {
+		return nil, &errcode.HTTPErr{Status: http.StatusBadRequest, Err: err}
+	}
+	export, err := db.SearchExports.GetByID(r.Context(), id)
+	if err == db.ErrSearchExportNotFound {
+		return nil, &errcode.HTTPErr{Status: http.StatusNotFound, Err: err}
+	} else if err != nil {
+		return nil, err
+	}
+	// Only the user who requested an export and site admins may access it.
+	if err := backend.CheckSiteAdminOrSameUser(r.Context(), export.UserID); err != nil {
+		return nil, &errcode.HTTPErr{Status: http.StatusForbidden, Err: err}
+	}
+	return export, nil
+}
@@ -0,0 +1,50 @@
+package httpapi
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sourcegraph/sourcegraph/internal/actor"
+	"github.com/sourcegraph/sourcegraph/internal/errcode"
+)
+
+func TestServeSearchExportCreate_Invalid(t *testing.T) {
+	tests := map[string]struct {
+		url        string
+		actor      *actor.Actor
+		wantStatus int
+	}{
+		"unauthenticated": {
+			url:        "/search/export?q=secret",
+			actor:      &actor.Actor{},
+			wantStatus: http.StatusUnauthorized,
+		},
+		"no query": {
+			url:        "/search/export",
+			actor:      &actor.Actor{UID: 1},
+			wantStatus: http.StatusBadRequest,
+		},
+		"unsupported result type": {
+			url:        "/search/export?q=secret+type:commit",
+			actor:      &actor.Actor{UID: 1},
+			wantStatus: http.StatusBadRequest,
+		},
+		"unsupported format": {
+			url:        "/search/export?q=secret&format=xml",
+			actor:      &actor.Actor{UID: 1},
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", test.url, nil)
+			req = req.WithContext(actor.WithActor(context.Background(), test.actor))
+			err := serveSearchExportCreate(httptest.NewRecorder(), req)
+			if got := errcode.HTTP(err); got != test.wantStatus {
+				t.Fatalf("got status %d (error %v), want %d", got, err, test.wantStatus)
+			}
+		})
+	}
+}
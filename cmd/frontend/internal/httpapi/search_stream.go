@@ -0,0 +1,321 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/inconshreveable/log15"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/graphqlbackend"
+	"github.com/sourcegraph/sourcegraph/internal/trace"
+)
+
+// searchStreamResolver is the subset of graphqlbackend.SearchImplementer used
+// by the streaming search endpoint.
+type searchStreamResolver interface {
+	Results(context.Context) (*graphqlbackend.SearchResultsResolver, error)
+}
+
+func newSearchStreamResolver(ctx context.Context, args *graphqlbackend.SearchArgs) (searchStreamResolver, error) {
+	return graphqlbackend.NewSearchImplementer(ctx, args)
+}
+
+// searchStreamHandler serves search results as server-sent events. Results
+// are written as soon as a search backend produces them, instead of once the
+// whole search has completed.
+//
+// The following events are written, each with a JSON payload:
+//
+//   - filematches:   a list of file matches
+//   - repomatches:   a list of repository name matches
+//   - commitmatches: a list of commit and diff matches
+//   - progress:      counters describing how far the search has progressed
+//   - alert:         an alert for the search, e.g. because the query is invalid
+//   - error:         an error which caused the search to fail
+//   - done:          the last event of the stream
+type searchStreamHandler struct {
+	// newSearchResolver is newSearchStreamResolver, but can be replaced in tests.
+	newSearchResolver func(context.Context, *graphqlbackend.SearchArgs) (searchStreamResolver, error)
+
+	// progressInterval is the minimum time between two progress events.
+	progressInterval time.Duration
+}
+
+func newSearchStreamHandler() http.Handler {
+	return &searchStreamHandler{
+		newSearchResolver: newSearchStreamResolver,
+		progressInterval:  500 * time.Millisecond,
+	}
+}
+
+func (h *searchStreamHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	args, err := parseSearchStreamArgs(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx := trace.WithGraphQLRequestName(r.Context(), "SearchStream")
+	ctx = trace.WithRequestSource(ctx, guessSource(r))
+
+	events := make(chan graphqlbackend.SearchEvent)
+	args.Stream = events
+
+	resolver, err := h.newSearchResolver(ctx, args)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	// Disable response buffering in nginx, which would otherwise hold back
+	// events until the search has completed.
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	var (
+		final    *graphqlbackend.SearchResultsResolver
+		finalErr error
+	)
+	go func() {
+		defer close(events)
+		final, finalErr = resolver.Results(ctx)
+	}()
+
+	ew := &eventWriter{w: w, flusher: flusher}
+	start := time.Now()
+	progress := &graphqlbackend.SearchProgress{}
+	lastProgress := start
+
+	// We must read from events until it is closed, even if the client has
+	// gone away, since the search blocks until its events are received.
+	for ev := range events {
+		progress.Update(ev)
+		writeSearchResults(ew, ev.Results)
+		if time.Since(lastProgress) >= h.progressInterval {
+			lastProgress = time.Now()
+			ew.event("progress", searchProgressJSON(progress, start, false))
+		}
+	}
+
+	if finalErr != nil {
+		ew.event("error", &eventError{Message: finalErr.Error()})
+	} else if final != nil {
+		if alert := final.Alert(); alert != nil {
+			a := &eventAlert{Title: alert.Title()}
+			if d := alert.Description(); d != nil {
+				a.Description = *d
+			}
+			if pqs := alert.ProposedQueries(); pqs != nil {
+				for _, pq := range *pqs {
+					var description string
+					if d := pq.Description(); d != nil {
+						description = *d
+					}
+					a.ProposedQueries = append(a.ProposedQueries, eventProposedQuery{
+						Description: description,
+						Query:       pq.Query(),
+					})
+				}
+			}
+			ew.event("alert", a)
+		}
+	}
+	ew.event("progress", searchProgressJSON(progress, start, true))
+	ew.event("done", map[string]interface{}{})
+
+	if ew.err != nil && r.Context().Err() == nil {
+		log15.Warn("failed to write search stream", "error", ew.err)
+	}
+}
+
+func parseSearchStreamArgs(r *http.Request) (*graphqlbackend.SearchArgs, error) {
+	q := r.URL.Query()
+	args := &graphqlbackend.SearchArgs{
+		Query:   q.Get("q"),
+		Version: q.Get("v"),
+		// Only searches typed in the search UI are recorded, which it
+		// requests with h=1.
+		RecordHistory: q.Get("h") == "1",
+	}
+	if args.Query == "" {
+		return nil, fmt.Errorf("no query found")
+	}
+	if args.Version == "" {
+		args.Version = "V2"
+	}
+	if t := q.Get("t"); t != "" {
+		args.PatternType = &t
+	}
+	if vc := q.Get("vc"); vc != "" {
+		args.VersionContext = &vc
+	}
+	return args, nil
+}
+
+// writeSearchResults writes one event for every kind of result in results.
+func writeSearchResults(ew *eventWriter, results []graphqlbackend.SearchResultResolver) {
+	var (
+		fileMatches   []*eventFileMatch
+		repoMatches   []*eventRepoMatch
+		commitMatches []*eventCommitMatch
+	)
+	for _, result := range results {
+		if fm, ok := result.ToFileMatch(); ok {
+			fileMatches = append(fileMatches, fromFileMatch(fm))
+		} else if repo, ok := result.ToRepository(); ok {
+			repoMatches = append(repoMatches, &eventRepoMatch{Repository: repo.Name()})
+		} else if commit, ok := result.ToCommitSearchResult(); ok {
+			commitMatches = append(commitMatches, &eventCommitMatch{
+				Label:  commit.Label().Text(),
+				URL:    commit.URL(),
+				Detail: commit.Detail().Text(),
+			})
+		}
+	}
+	if len(fileMatches) > 0 {
+		ew.event("filematches", fileMatches)
+	}
+	if len(repoMatches) > 0 {
+		ew.event("repomatches", repoMatches)
+	}
+	if len(commitMatches) > 0 {
+		ew.event("commitmatches", commitMatches)
+	}
+}
+
+func fromFileMatch(fm *graphqlbackend.FileMatchResolver) *eventFileMatch {
+	lineMatches := make([]eventLineMatch, 0, len(fm.LineMatches()))
+	for _, lm := range fm.LineMatches() {
+		lineMatches = append(lineMatches, eventLineMatch{
+			Line:             lm.Preview(),
+			LineNumber:       lm.LineNumber(),
+			OffsetAndLengths: lm.OffsetAndLengths(),
+		})
+	}
+
+	var branches []string
+	if revs := fm.RevSpecs(); len(revs) > 0 {
+		branches = revs
+	} else if fm.InputRev != nil && *fm.InputRev != "" {
+		branches = []string{*fm.InputRev}
+	}
+
+	return &eventFileMatch{
+		Path:        fm.JPath,
+		Repository:  fm.Repo.Name(),
+		Branches:    branches,
+		Version:     string(fm.CommitID),
+		LineMatches: lineMatches,
+	}
+}
+
+func searchProgressJSON(p *graphqlbackend.SearchProgress, start time.Time, done bool) *eventProgress {
+	return &eventProgress{
+		Done:                        done,
+		MatchCount:                  p.MatchCount(),
+		LimitHit:                    p.LimitHit(),
+		RepositoriesCount:           p.RepositoriesCount(),
+		RepositoriesSearched:        p.RepositoriesSearchedCount(),
+		IndexedRepositoriesSearched: p.IndexedRepositoriesSearchedCount(),
+		Cloning:                     p.CloningCount(),
+		Missing:                     p.MissingCount(),
+		Timedout:                    p.TimedoutCount(),
+		DurationMs:                  time.Since(start).Milliseconds(),
+	}
+}
+
+// eventFileMatch is a file match in the filematches event.
+type eventFileMatch struct {
+	Path        string           `json:"path"`
+	Repository  string           `json:"repository"`
+	Branches    []string         `json:"branches,omitempty"`
+	Version     string           `json:"version,omitempty"`
+	LineMatches []eventLineMatch `json:"lineMatches"`
+}
+
+// eventLineMatch is a line match of an eventFileMatch.
+type eventLineMatch struct {
+	Line             string    `json:"line"`
+	LineNumber       int32     `json:"lineNumber"`
+	OffsetAndLengths [][]int32 `json:"offsetAndLengths"`
+}
+
+// eventRepoMatch is a repository name match in the repomatches event.
+type eventRepoMatch struct {
+	Repository string `json:"repository"`
+}
+
+// eventCommitMatch is a commit or diff match in the commitmatches event.
+type eventCommitMatch struct {
+	Label  string `json:"label"`
+	URL    string `json:"url"`
+	Detail string `json:"detail"`
+}
+
+// eventProgress is the payload of the progress event.
+type eventProgress struct {
+	Done                        bool  `json:"done"`
+	MatchCount                  int32 `json:"matchCount"`
+	LimitHit                    bool  `json:"limitHit"`
+	RepositoriesCount           int32 `json:"repositoriesCount"`
+	RepositoriesSearched        int32 `json:"repositoriesSearched"`
+	IndexedRepositoriesSearched int32 `json:"indexedRepositoriesSearched"`
+	Cloning                     int32 `json:"cloning"`
+	Missing                     int32 `json:"missing"`
+	Timedout                    int32 `json:"timedout"`
+	DurationMs                  int64 `json:"durationMs"`
+}
+
+// eventAlert is the payload of the alert event.
+type eventAlert struct {
+	Title           string               `json:"title"`
+	Description     string               `json:"description,omitempty"`
+	ProposedQueries []eventProposedQuery `json:"proposedQueries,omitempty"`
+}
+
+// eventProposedQuery is a query proposed by an eventAlert.
+type eventProposedQuery struct {
+	Description string `json:"description,omitempty"`
+	Query       string `json:"query"`
+}
+
+// eventError is the payload of the error event.
+type eventError struct {
+	Message string `json:"message"`
+}
+
+// eventWriter writes server-sent events. After the first failed write all
+// further writes are skipped, and the error is recorded in err.
+type eventWriter struct {
+	w       http.ResponseWriter
+	flusher http.Flusher
+	err     error
+}
+
+func (e *eventWriter) event(name string, data interface{}) {
+	if e.err != nil {
+		return
+	}
+	b, err := json.Marshal(data)
+	if err != nil {
+		e.err = err
+		return
+	}
+	if _, err := fmt.Fprintf(e.w, "event: %s\ndata: %s\n\n", name, b); err != nil {
+		e.err = err
+		return
+	}
+	e.flusher.Flush()
+}
@@ -0,0 +1,84 @@
+package httpapi
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/graphqlbackend"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/types"
+)
+
+type fakeSearchStreamResolver struct {
+	stream  chan<- graphqlbackend.SearchEvent
+	results []graphqlbackend.SearchResultResolver
+}
+
+func (r *fakeSearchStreamResolver) Results(ctx context.Context) (*graphqlbackend.SearchResultsResolver, error) {
+	for _, result := range r.results {
+		r.stream <- graphqlbackend.SearchEvent{Results: []graphqlbackend.SearchResultResolver{result}}
+	}
+	return &graphqlbackend.SearchResultsResolver{SearchResults: r.results}, nil
+}
+
+func TestSearchStream(t *testing.T) {
+	var gotArgs *graphqlbackend.SearchArgs
+	h := &searchStreamHandler{
+		newSearchResolver: func(_ context.Context, args *graphqlbackend.SearchArgs) (searchStreamResolver, error) {
+			gotArgs = args
+			return &fakeSearchStreamResolver{
+				stream: args.Stream,
+				results: []graphqlbackend.SearchResultResolver{
+					graphqlbackend.NewRepositoryResolver(&types.Repo{ID: 1, Name: "github.com/foo/bar"}),
+					graphqlbackend.NewRepositoryResolver(&types.Repo{ID: 2, Name: "github.com/foo/baz"}),
+				},
+			}, nil
+		},
+		// Only the final progress event should be written.
+		progressInterval: time.Hour,
+	}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/search/stream?q=repo:foo&t=regexp", nil))
+
+	if rec.Code != 200 {
+		t.Fatalf("got status %d, want 200", rec.Code)
+	}
+	if got, want := rec.Header().Get("Content-Type"), "text/event-stream"; got != want {
+		t.Errorf("got Content-Type %q, want %q", got, want)
+	}
+	if gotArgs.Query != "repo:foo" || gotArgs.Version != "V2" || gotArgs.PatternType == nil || *gotArgs.PatternType != "regexp" {
+		t.Errorf("unexpected search args: %+v", gotArgs)
+	}
+
+	want := strings.Join([]string{
+		"event: repomatches",
+		`data: [{"repository":"github.com/foo/bar"}]`,
+		"",
+		"event: repomatches",
+		`data: [{"repository":"github.com/foo/baz"}]`,
+		"",
+	}, "\n")
+	body := rec.Body.String()
+	if !strings.HasPrefix(body, want) {
+		t.Fatalf("unexpected events\ngot:\n%s\nwant prefix:\n%s", body, want)
+	}
+	for _, want := range []string{
+		"event: progress\ndata: {\"done\":true,\"matchCount\":2,",
+		"event: done\ndata: {}\n\n",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("missing %q in events:\n%s", want, body)
+		}
+	}
+}
+
+func TestSearchStream_NoQuery(t *testing.T) {
+	rec := httptest.NewRecorder()
+	newSearchStreamHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/search/stream", nil))
+	if rec.Code != 400 {
+		t.Fatalf("got status %d, want 400", rec.Code)
+	}
+}
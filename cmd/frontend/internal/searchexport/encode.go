@@ -0,0 +1,106 @@
+package searchexport
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/graphqlbackend"
+)
+
+// Formats supported by search exports.
+const (
+	FormatCSV   = "csv"
+	FormatJSONL = "jsonl"
+)
+
+// ContentType returns the MIME type of exports in the given format.
+func ContentType(format string) string {
+	if format == FormatJSONL {
+		return "application/x-ndjson"
+	}
+	return "text/csv"
+}
+
+// row is one exported match. File matches are exported as one row per
+// matching line, or a single row without a line if only the path matched.
+type row struct {
+	Type       string `json:"type"` // "file", or "repo" for queries with select:repo
+	Repository string `json:"repository"`
+	Commit     string `json:"commit,omitempty"`
+	Path       string `json:"path,omitempty"`
+	LineNumber int32  `json:"lineNumber,omitempty"` // 1-based
+	Preview    string `json:"preview,omitempty"`
+}
+
+var csvHeader = []string{"type", "repository", "commit", "path", "lineNumber", "preview"}
+
+func resultRows(result graphqlbackend.SearchResultResolver) []row {
+	if fm, ok := result.ToFileMatch(); ok {
+		file := row{Type: "file", Repository: fm.Repo.Name(), Commit: string(fm.CommitID), Path: fm.JPath}
+		lineMatches := fm.LineMatches()
+		if len(lineMatches) == 0 {
+			return []row{file}
+		}
+		rows := make([]row, 0, len(lineMatches))
+		for _, lm := range lineMatches {
+			r := file
+			r.LineNumber = lm.LineNumber() + 1
+			r.Preview = lm.Preview()
+			rows = append(rows, r)
+		}
+		return rows
+	}
+	if repo, ok := result.ToRepository(); ok {
+		return []row{{Type: "repo", Repository: repo.Name()}}
+	}
+	return nil
+}
+
+// encoder writes rows in the format of an export.
+type encoder interface {
+	Encode(row) error
+	// Close writes any buffered data. It does not close the underlying writer.
+	Close() error
+}
+
+func newEncoder(format string, w io.Writer) (encoder, error) {
+	switch format {
+	case FormatCSV:
+		cw := csv.NewWriter(w)
+		if err := cw.Write(csvHeader); err != nil {
+			return nil, err
+		}
+		return &csvEncoder{w: cw}, nil
+	case FormatJSONL:
+		return &jsonlEncoder{enc: json.NewEncoder(w)}, nil
+	}
+	return nil, fmt.Errorf("unsupported search export format %q", format)
+}
+
+type csvEncoder struct {
+	w *csv.Writer
+}
+
+func (e *csvEncoder) Encode(r row) error {
+	var lineNumber string
+	if r.LineNumber > 0 {
+		lineNumber = strconv.Itoa(int(r.LineNumber))
+	}
+	return e.w.Write([]string{r.Type, r.Repository, r.Commit, r.Path, lineNumber, r.Preview})
+}
+
+func (e *csvEncoder) Close() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+type jsonlEncoder struct {
+	enc *json.Encoder
+}
+
+func (e *jsonlEncoder) Encode(r row) error { return e.enc.Encode(r) }
+
+func (e *jsonlEncoder) Close() error { return nil }
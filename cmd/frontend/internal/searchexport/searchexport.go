@@ -0,0 +1,240 @@
+// Package searchexport runs search exports: background jobs which page
+// through every result of a search and store them as a CSV or JSON lines file.
+package searchexport
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/inconshreveable/log15"
+	"github.com/pkg/errors"
+
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/db"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/graphqlbackend"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/types"
+	"github.com/sourcegraph/sourcegraph/internal/actor"
+	"github.com/sourcegraph/sourcegraph/internal/search/query"
+)
+
+const (
+	// pageSize is the number of results requested per page of the
+	// paginated search API.
+	pageSize = 5000
+
+	// maxRows is the maximum number of rows in an export. Exports with more
+	// rows fail, so that a partial export is never mistaken for a complete
+	// one.
+	maxRows = 1000000
+
+	// chunkSize is the size of the chunks in which the exported data is
+	// stored, so that an export is never held in memory as a whole.
+	chunkSize = 1 << 20
+
+	// retentionDays is the number of days after which finished exports are
+	// deleted.
+	retentionDays = 7
+
+	// heartbeatInterval is how often the heartbeat of the export being
+	// processed is updated.
+	heartbeatInterval = 30 * time.Second
+
+	// stalledAge is the age of the last heartbeat after which an export that
+	// is processing is assumed to be abandoned by a stopped frontend.
+	stalledAge = 5 * time.Minute
+)
+
+// Run processes queued search exports one at a time until ctx is done.
+func Run(ctx context.Context) {
+	lastCleanup := time.Time{}
+	for ctx.Err() == nil {
+		// Exports which were processing on a frontend that stopped will never
+		// finish, so start them over. Exports processing on other replicas
+		// have a recent heartbeat and are left alone.
+		if err := db.SearchExports.ResetStalled(ctx, stalledAge); err != nil {
+			log15.Error("searchexport: resetting stalled exports", "error", err)
+		}
+
+		if time.Since(lastCleanup) > time.Hour {
+			lastCleanup = time.Now()
+			if err := db.SearchExports.DeleteOlderThan(ctx, retentionDays); err != nil {
+				log15.Error("searchexport: deleting expired exports", "error", err)
+			}
+		}
+
+		export, err := db.SearchExports.Dequeue(ctx)
+		if err != nil {
+			log15.Error("searchexport: dequeueing export", "error", err)
+		}
+		if export == nil {
+			time.Sleep(5 * time.Second)
+			continue
+		}
+		process(ctx, export)
+	}
+}
+
+func process(ctx context.Context, export *types.SearchExport) {
+	heartbeatCtx, stopHeartbeat := context.WithCancel(ctx)
+	go heartbeat(heartbeatCtx, export.ID)
+
+	w := &chunkWriter{ctx: ctx, id: export.ID}
+	n, err := write(ctx, export, w)
+	if err == nil {
+		err = w.Flush()
+	}
+	stopHeartbeat()
+	if err != nil {
+		log15.Warn("searchexport: export failed", "id", export.ID, "error", err)
+		err = db.SearchExports.MarkErrored(ctx, export.ID, err.Error())
+	} else {
+		err = db.SearchExports.MarkCompleted(ctx, export.ID, n)
+	}
+	if err != nil {
+		log15.Error("searchexport: recording export result", "id", export.ID, "error", err)
+	}
+}
+
+// heartbeat updates the heartbeat of the export with the given ID until ctx
+// is done, so that other frontends don't reset it.
+func heartbeat(ctx context.Context, id int64) {
+	ticker := time.NewTicker(heartbeatInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := db.SearchExports.Heartbeat(ctx, id); err != nil && ctx.Err() == nil {
+				log15.Error("searchexport: updating heartbeat", "id", id, "error", err)
+			}
+		}
+	}
+}
+
+// chunkWriter stores the data written to it as the chunks of the exported
+// data of the export with the given ID.
+type chunkWriter struct {
+	ctx context.Context
+	id  int64
+	seq int32
+	buf bytes.Buffer
+}
+
+func (w *chunkWriter) Write(p []byte) (int, error) {
+	n, _ := w.buf.Write(p)
+	if w.buf.Len() >= chunkSize {
+		if err := w.Flush(); err != nil {
+			return 0, err
+		}
+	}
+	return n, nil
+}
+
+// Flush stores the data written since the last stored chunk as a chunk.
+func (w *chunkWriter) Flush() error {
+	if w.buf.Len() == 0 {
+		return nil
+	}
+	if err := db.SearchExports.AppendChunk(w.ctx, w.id, w.seq, w.buf.Bytes()); err != nil {
+		return err
+	}
+	w.seq++
+	w.buf.Reset()
+	return nil
+}
+
+// PrepareQuery returns the query that an export of the results of q runs, or
+// an error if q can't be exported. Exports page through the results with the
+// paginated search API, which only returns file content matches, so queries
+// for other types of results are rejected and type:file is added to queries
+// without a type: filter.
+func PrepareQuery(q, patternType string) (string, error) {
+	parse := query.ParseAndOr
+	if patternType == "literal" {
+		parse = query.ParseLiteralSearch
+	}
+	nodes, err := parse(q)
+	if err != nil {
+		return "", err
+	}
+	hasType := false
+	var invalid []string
+	query.VisitField(query.SubstituteAliases(nodes), query.FieldType, func(value string, negated bool) {
+		hasType = true
+		if value != "file" {
+			invalid = append(invalid, "type:"+value)
+		}
+	})
+	if len(invalid) > 0 {
+		return "", fmt.Errorf("search exports only support file content matches (type:file), not %s", strings.Join(invalid, " "))
+	}
+	if !hasType {
+		q += " type:file"
+	}
+	return q, nil
+}
+
+// write runs the search of export to completion as the user who requested
+// it, and writes every result to w. It returns the number of rows written.
+func write(ctx context.Context, export *types.SearchExport, w io.Writer) (int32, error) {
+	// Search as the user who requested the export, so that they only see
+	// results from repositories they have access to.
+	ctx = actor.WithActor(ctx, actor.FromUser(export.UserID))
+
+	enc, err := newEncoder(export.Format, w)
+	if err != nil {
+		return 0, err
+	}
+
+	var (
+		rows   int32
+		first  = int32(pageSize)
+		cursor *string
+	)
+	for {
+		search, err := graphqlbackend.NewSearchImplementer(ctx, &graphqlbackend.SearchArgs{
+			Version:     export.Version,
+			PatternType: &export.PatternType,
+			Query:       export.Query,
+			First:       &first,
+			After:       cursor,
+		})
+		if err != nil {
+			return 0, err
+		}
+		results, err := search.Results(ctx)
+		if err != nil {
+			return 0, err
+		}
+		if alert := results.Alert(); alert != nil && len(results.SearchResults) == 0 {
+			msg := alert.Title()
+			if d := alert.Description(); d != nil {
+				msg += ": " + *d
+			}
+			return 0, errors.New(msg)
+		}
+
+		for _, result := range results.SearchResults {
+			for _, r := range resultRows(result) {
+				if rows >= maxRows {
+					return 0, fmt.Errorf("the search has more than %d results, narrow the query to export it", maxRows)
+				}
+				if err := enc.Encode(r); err != nil {
+					return 0, err
+				}
+				rows++
+			}
+		}
+
+		pageInfo := results.PageInfo()
+		if !pageInfo.HasNextPage() {
+			break
+		}
+		cursor = pageInfo.EndCursor()
+	}
+	return rows, enc.Close()
+}
@@ -0,0 +1,175 @@
+package searchexport
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/zoekt"
+	zoektquery "github.com/google/zoekt/query"
+
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/db"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/graphqlbackend"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/types"
+	"github.com/sourcegraph/sourcegraph/internal/actor"
+	"github.com/sourcegraph/sourcegraph/internal/api"
+	"github.com/sourcegraph/sourcegraph/internal/search"
+)
+
+// fakeZoekt is an index with the repositories in repos, whose searches
+// return result.
+type fakeZoekt struct {
+	repos  *zoekt.RepoList
+	result *zoekt.SearchResult
+
+	// Default all unimplemented zoekt.Searcher methods to panic.
+	zoekt.Searcher
+}
+
+func (z *fakeZoekt) Search(ctx context.Context, q zoektquery.Q, opts *zoekt.SearchOptions) (*zoekt.SearchResult, error) {
+	return z.result, nil
+}
+
+func (z *fakeZoekt) List(ctx context.Context, q zoektquery.Q) (*zoekt.RepoList, error) {
+	return z.repos, nil
+}
+
+func (z *fakeZoekt) String() string { return "fakeZoekt" }
+
+// fakeExtensionRegistry allows all extensions, so that the default settings
+// can be computed.
+type fakeExtensionRegistry struct {
+	// Default all unimplemented methods to panic.
+	graphqlbackend.ExtensionRegistryResolver
+}
+
+func (fakeExtensionRegistry) FilterRemoteExtensions(ids []string) []string { return ids }
+
+func TestWrite(t *testing.T) {
+	repo := &types.Repo{ID: 1, Name: "github.com/foo/bar"}
+	var gotActors []*actor.Actor
+	db.Mocks.Repos.List = func(ctx context.Context, opt db.ReposListOptions) ([]*types.Repo, error) {
+		gotActors = append(gotActors, actor.FromContext(ctx))
+		return []*types.Repo{repo}, nil
+	}
+	db.Mocks.Repos.Get = func(ctx context.Context, id api.RepoID) (*types.Repo, error) {
+		return repo, nil
+	}
+	db.Mocks.Repos.Count = func(ctx context.Context, opt db.ReposListOptions) (int, error) {
+		return 1, nil
+	}
+	db.Mocks.Users.GetByID = func(ctx context.Context, id int32) (*types.User, error) {
+		return &types.User{ID: id}, nil
+	}
+	db.Mocks.Settings.GetLatest = func(ctx context.Context, subject api.SettingsSubject) (*api.Settings, error) {
+		return nil, nil
+	}
+	defer func() { db.Mocks = db.MockStores{} }()
+
+	graphqlbackend.ExtensionRegistry = fakeExtensionRegistry{}
+	defer func() { graphqlbackend.ExtensionRegistry = nil }()
+
+	indexed := search.Indexed()
+	indexed.Client = &fakeZoekt{
+		repos: &zoekt.RepoList{Repos: []*zoekt.RepoListEntry{{
+			Repository: zoekt.Repository{
+				Name:     string(repo.Name),
+				Branches: []zoekt.RepositoryBranch{{Name: "HEAD", Version: "deadbeef"}},
+			},
+		}}},
+		result: &zoekt.SearchResult{Files: []zoekt.FileMatch{{
+			FileName:   "a/b.go",
+			Repository: string(repo.Name),
+			Version:    "deadbeef",
+			LineMatches: []zoekt.LineMatch{{
+				Line:          []byte("var secret = 1"),
+				LineNumber:    3,
+				LineFragments: []zoekt.LineFragmentMatch{{LineOffset: 4, MatchLength: 6}},
+			}},
+		}}},
+	}
+	indexed.DisableCache = true
+	defer func() { indexed.Client = nil }()
+
+	tests := []struct {
+		query string
+		want  map[string]string
+	}{
+		{
+			query: "secret",
+			want: map[string]string{
+				FormatCSV: "type,repository,commit,path,lineNumber,preview\n" +
+					"file,github.com/foo/bar,deadbeef,a/b.go,3,var secret = 1\n",
+				FormatJSONL: `{"type":"file","repository":"github.com/foo/bar","commit":"deadbeef","path":"a/b.go","lineNumber":3,"preview":"var secret = 1"}` + "\n",
+			},
+		},
+		{
+			query: "secret select:repo",
+			want: map[string]string{
+				FormatCSV: "type,repository,commit,path,lineNumber,preview\n" +
+					"repo,github.com/foo/bar,,,,\n",
+				FormatJSONL: `{"type":"repo","repository":"github.com/foo/bar"}` + "\n",
+			},
+		},
+	}
+	for _, test := range tests {
+		q, err := PrepareQuery(test.query, "literal")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for format, want := range test.want {
+			t.Run(test.query+" "+format, func(t *testing.T) {
+				gotActors = nil
+				export := &types.SearchExport{UserID: 7, Query: q, Version: "V2", PatternType: "literal", Format: format}
+				var buf bytes.Buffer
+				n, err := write(context.Background(), export, &buf)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if n != 1 {
+					t.Errorf("got %d rows, want 1", n)
+				}
+				if diff := cmp.Diff(want, buf.String()); diff != "" {
+					t.Fatal(diff)
+				}
+				for _, a := range gotActors {
+					if a.UID != 7 {
+						t.Errorf("searched as user %d, want 7", a.UID)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestWrite_UnsupportedFormat(t *testing.T) {
+	var buf bytes.Buffer
+	if _, err := write(context.Background(), &types.SearchExport{Format: "xml"}, &buf); err == nil {
+		t.Fatal("expected an error for an unsupported format")
+	}
+}
+
+func TestPrepareQuery(t *testing.T) {
+	tests := []struct {
+		query   string
+		want    string
+		wantErr string
+	}{
+		{query: "secret", want: "secret type:file"},
+		{query: "secret type:file", want: "secret type:file"},
+		{query: "secret repo:foo", want: "secret repo:foo type:file"},
+		{query: "secret type:repo", wantErr: "search exports only support file content matches (type:file), not type:repo"},
+		{query: "secret type:file type:commit", wantErr: "search exports only support file content matches (type:file), not type:commit"},
+	}
+	for _, test := range tests {
+		got, err := PrepareQuery(test.query, "literal")
+		var gotErr string
+		if err != nil {
+			gotErr = err.Error()
+		}
+		if got != test.want || gotErr != test.wantErr {
+			t.Errorf("PrepareQuery(%q) = %q, %q, want %q, %q", test.query, got, gotErr, test.want, test.wantErr)
+		}
+	}
+}
@@ -6,6 +6,7 @@ import (
 
 	"github.com/sourcegraph/sourcegraph/internal/api"
 	"github.com/sourcegraph/sourcegraph/internal/extsvc"
+	"github.com/sourcegraph/sourcegraph/internal/symbols/protocol"
 )
 
 // RepoFields are lazy loaded data fields on a Repo (from the DB).
@@ -361,6 +362,70 @@ type SurveyResponse struct {
 	CreatedAt time.Time
 }
 
+// SearchExport is a background job which runs a search to completion and
+// stores every result in a downloadable file.
+type SearchExport struct {
+	ID             int64
+	UserID         int32
+	Query          string
+	Version        string
+	PatternType    string
+	Format         string // "csv" or "jsonl"
+	State          string // "queued", "processing", "completed" or "errored"
+	FailureMessage *string
+	ResultCount    int32
+	CreatedAt      time.Time
+	StartedAt      *time.Time
+	FinishedAt     *time.Time
+}
+
+// SearchHistoryEntry is a search query run by a user.
+type SearchHistoryEntry struct {
+	ID             int64
+	UserID         int32
+	Query          string
+	PatternType    string
+	VersionContext string
+	ResultCount    int32
+	CreatedAt      time.Time
+}
+
+// SearchContext is a named set of repositories and revisions that a search
+// can be scoped to with the context: field.
+type SearchContext struct {
+	ID              int64
+	Name            string
+	Description     string
+	Public          bool
+	NamespaceUserID *int32 // if non-nil, the owner is this user. NamespaceUserID/NamespaceOrgID are mutually exclusive.
+	NamespaceOrgID  *int32 // if non-nil, the owner is this organization. NamespaceUserID/NamespaceOrgID are mutually exclusive.
+	NamespaceName   string // the username or org name of the owner, empty for instance-wide contexts.
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+}
+
+// RepoGroup is a named group of repositories which can be searched with the
+// repogroup: field. A repository belongs to the group if it is listed in
+// Repositories, if its name matches Pattern, or if it has one of Topics.
+type RepoGroup struct {
+	ID           int32
+	Name         string
+	Description  string
+	Repositories []string
+	Pattern      string // a regexp matching repository names, empty if none
+	Topics       []string
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+// SearchContextRepositoryRevisions is a repository and the revisions of it
+// that belong to a search context.
+type SearchContextRepositoryRevisions struct {
+	RepoID    api.RepoID
+	RepoName  api.RepoName
+	Revisions []string
+}
+
 type Event struct {
 	ID              int32
 	Name            string
@@ -372,3 +437,11 @@ type Event struct {
 	Version         string
 	Timestamp       time.Time
 }
+
+// GlobalSymbol is a symbol in the global symbol index, which holds the
+// symbols of the default branch of repositories.
+type GlobalSymbol struct {
+	RepoID   api.RepoID
+	CommitID api.CommitID // the indexed commit of the default branch
+	protocol.Symbol
+}
@@ -67,6 +67,16 @@ type PatternInfo struct {
 	// when finding matches.
 	IsCaseSensitive bool
 
+	// IsMultiline if true will make . match newlines, so that a match may
+	// span several lines without spelling out the newlines. It only applies
+	// when IsRegExp is true.
+	IsMultiline bool
+
+	// IsNegated if true will return the files whose contents do not match
+	// the pattern, without line matches. It does not apply to structural
+	// search.
+	IsNegated bool
+
 	// ExcludePattern is a pattern that may not match the returned files' paths.
 	// eg '**/node_modules'
 	ExcludePattern string
@@ -103,6 +113,11 @@ type PatternInfo struct {
 	// Languages is the languages passed via the lang filters (e.g., "lang:c")
 	Languages []string
 
+	// IncludeCaptureGroups is whether LineMatch.CaptureGroups is populated
+	// with the named capture groups of Pattern. It only applies when IsRegExp
+	// is true.
+	IncludeCaptureGroups bool
+
 	// CombyRule is a rule that constrains matching for structural search. It only applies when IsStructuralPat is true.
 	CombyRule string
 }
@@ -125,12 +140,21 @@ func (p *PatternInfo) String() string {
 	if p.IsCaseSensitive {
 		args = append(args, "case")
 	}
+	if p.IsMultiline {
+		args = append(args, "multiline")
+	}
+	if p.IsNegated {
+		args = append(args, "negated")
+	}
 	if !p.PatternMatchesContent {
 		args = append(args, "nocontent")
 	}
 	if !p.PatternMatchesPath {
 		args = append(args, "nopath")
 	}
+	if p.IncludeCaptureGroups {
+		args = append(args, "captures")
+	}
 	if p.FileMatchLimit > 0 {
 		args = append(args, fmt.Sprintf("filematchlimit:%d", p.FileMatchLimit))
 	}
@@ -193,4 +217,24 @@ type LineMatch struct {
 
 	// LimitHit is true if OffsetAndLengths may not include all OffsetAndLengths.
 	LimitHit bool
+
+	// CaptureGroups are the named capture groups of the matches on the line,
+	// if PatternInfo.IncludeCaptureGroups is true.
+	CaptureGroups []CaptureGroup `json:",omitempty"`
+}
+
+// CaptureGroup is the span of a named capture group in a match.
+type CaptureGroup struct {
+	// Name is the name of the capture group, e.g. "v" for (?P<v>[0-9.]+).
+	Name string
+
+	// Value is the text matched by the capture group. It may span several
+	// lines.
+	Value string
+
+	// Offset and Length are the span of the capture group in the line's
+	// Preview. They are measured in characters, not bytes. If Value spans
+	// several lines, Length only covers the part on this line.
+	Offset int
+	Length int
 }
@@ -61,6 +61,14 @@ type readerGrep struct {
 	// re. It is the output of the longestLiteral function. It is only set if
 	// the regex has an empty LiteralPrefix.
 	literalSubstring []byte
+
+	// captureGroups is true if the spans of the named capture groups of re
+	// are returned with each match.
+	captureGroups bool
+
+	// isNegated is true if the files that do not match re are returned,
+	// without line matches.
+	isNegated bool
 }
 
 // compile returns a readerGrep for matching p.
@@ -81,6 +89,11 @@ func compile(p *protocol.PatternInfo) (*readerGrep, error) {
 			// We don't do the search line by line, therefore we want the
 			// regex engine to consider newlines for anchors (^$).
 			expr = "(?m:" + expr + ")"
+			if p.IsMultiline {
+				// Let . match newlines too, so that patterns such as
+				// "func.*{.*}" can match across lines.
+				expr = "(?s:" + expr + ")"
+			}
 		}
 		if !p.IsCaseSensitive {
 			// We don't just use (?i) because regexp library doesn't seem
@@ -126,9 +139,20 @@ func compile(p *protocol.PatternInfo) (*readerGrep, error) {
 		ignoreCase:       !p.IsCaseSensitive,
 		matchPath:        matchPath,
 		literalSubstring: literalSubstring,
+		captureGroups:    p.IsRegExp && p.IncludeCaptureGroups && re != nil && hasNamedGroups(re) && !p.IsNegated,
+		isNegated:        p.IsNegated,
 	}, nil
 }
 
+func hasNamedGroups(re *regexp.Regexp) bool {
+	for _, name := range re.SubexpNames() {
+		if name != "" {
+			return true
+		}
+	}
+	return false
+}
+
 // Copy returns a copied version of rg that is safe to use from another
 // goroutine.
 func (rg *readerGrep) Copy() *readerGrep {
@@ -137,6 +161,8 @@ func (rg *readerGrep) Copy() *readerGrep {
 		ignoreCase:       rg.ignoreCase,
 		matchPath:        rg.matchPath,
 		literalSubstring: rg.literalSubstring,
+		captureGroups:    rg.captureGroups,
+		isNegated:        rg.isNegated,
 	}
 }
 
@@ -185,7 +211,12 @@ func (rg *readerGrep) Find(zf *store.ZipFile, f *store.SrcFile) (matches []proto
 		return nil, false, nil
 	}
 
-	locs := rg.re.FindAllIndex(fileMatchBuf, maxLineMatches+1)
+	var locs [][]int
+	if rg.captureGroups {
+		locs = rg.re.FindAllSubmatchIndex(fileMatchBuf, maxLineMatches+1)
+	} else {
+		locs = rg.re.FindAllIndex(fileMatchBuf, maxLineMatches+1)
+	}
 	lastStart := 0
 	lastLineNumber := 0
 	lastMatchIndex := 0
@@ -216,7 +247,11 @@ func (rg *readerGrep) Find(zf *store.ZipFile, f *store.SrcFile) (matches []proto
 
 		lastMatchIndex = matchIndex
 		lastLineNumber = lineNumber
+		first := len(matches)
 		matches = appendMatches(matches, fileBuf[lineStart:lineEnd], fileMatchBuf[lineStart:lineEnd], lineNumber, start-lineStart, end-lineStart)
+		if rg.captureGroups {
+			addCaptureGroups(matches[first:], rg.re.SubexpNames(), fileBuf, lineStart, match)
+		}
 
 		if len(matches) > maxLineMatches {
 			matches = matches[:maxLineMatches]
@@ -283,6 +318,39 @@ func appendMatches(matches []protocol.LineMatch, fileBuf []byte, matchLineBuf []
 	return matches
 }
 
+// addCaptureGroups adds the named capture groups of match to lineMatches, the
+// line matches for the lines of match. lineStart is the index in fileBuf of
+// the first line of match.
+func addCaptureGroups(lineMatches []protocol.LineMatch, names []string, fileBuf []byte, lineStart int, match []int) {
+	for i, name := range names {
+		start, end := match[2*i], match[2*i+1]
+		if name == "" || start < 0 {
+			continue
+		}
+
+		// Find the line the capture group starts on.
+		line := bytes.Count(fileBuf[lineStart:start], []byte{'\n'})
+		if line >= len(lineMatches) {
+			continue
+		}
+		groupLineStart := lineStart
+		if idx := bytes.LastIndexByte(fileBuf[lineStart:start], '\n'); idx >= 0 {
+			groupLineStart = lineStart + idx + 1
+		}
+		groupLineEnd := end
+		if idx := bytes.IndexByte(fileBuf[start:end], '\n'); idx >= 0 {
+			groupLineEnd = start + idx
+		}
+
+		lineMatches[line].CaptureGroups = append(lineMatches[line].CaptureGroups, protocol.CaptureGroup{
+			Name:   name,
+			Value:  string(fileBuf[start:end]),
+			Offset: utf8.RuneCount(fileBuf[groupLineStart:start]),
+			Length: utf8.RuneCount(fileBuf[start:groupLineEnd]),
+		})
+	}
+}
+
 // FindZip is a convenience function to run Find on f.
 func (rg *readerGrep) FindZip(zf *store.ZipFile, f *store.SrcFile) (protocol.FileMatch, error) {
 	lm, limitHit, err := rg.Find(zf, f)
@@ -341,7 +409,7 @@ func regexSearch(ctx context.Context, rg *readerGrep, zf *store.ZipFile, fileMat
 		// Fast path for only matching file paths (or with a nil pattern, which matches all files,
 		// so is effectively matching only on file paths).
 		for _, f := range files {
-			if rg.matchPath.MatchPath(f.Name) && rg.matchString(f.Name) {
+			if rg.matchPath.MatchPath(f.Name) && rg.matchString(f.Name) != rg.isNegated {
 				if len(matches) < fileMatchLimit {
 					matches = append(matches, protocol.FileMatch{Path: f.Name})
 				} else {
@@ -411,6 +479,14 @@ func regexSearch(ctx context.Context, rg *readerGrep, zf *store.ZipFile, fileMat
 						fm.Path = f.Name
 					}
 				}
+				if rg.isNegated {
+					// The file matches if neither its contents nor
+					// (when searched) its path match. The content of
+					// binary and large files is not stored, so they
+					// can't be known not to contain the pattern.
+					match = !match && !f.ContentSkipped
+					fm = protocol.FileMatch{Path: f.Name}
+				}
 				if match {
 					matchesmu.Lock()
 					if len(matches) < fileMatchLimit {
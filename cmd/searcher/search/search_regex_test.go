@@ -595,3 +595,127 @@ func TestRegexSearch(t *testing.T) {
 		})
 	}
 }
+
+func TestCaptureGroups(t *testing.T) {
+	zipData, err := testutil.CreateZip(map[string]string{
+		"go.mod": "module x\n\nrequire (\n\tgithub.com/a/b v1.2.3\n\tgithub.com/c/d v0.1.0\n)\n",
+		"deps":   "dep \"héllo\" = \"1.0\"\n",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	zf, err := store.MockZipFile(zipData)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		pattern string
+		want    map[string][][]protocol.CaptureGroup // path -> line match -> capture groups
+	}{
+		{
+			name:    "named groups",
+			pattern: `(?P<module>github\.com/\w+/\w+) (v)(?P<version>[0-9.]+)`,
+			want: map[string][][]protocol.CaptureGroup{
+				"go.mod": {
+					{
+						{Name: "module", Value: "github.com/a/b", Offset: 1, Length: 14},
+						{Name: "version", Value: "1.2.3", Offset: 17, Length: 5},
+					},
+					{
+						{Name: "module", Value: "github.com/c/d", Offset: 1, Length: 14},
+						{Name: "version", Value: "0.1.0", Offset: 17, Length: 5},
+					},
+				},
+			},
+		},
+		{
+			name:    "offsets are in characters and case is preserved",
+			pattern: `DEP "(?P<name>[^"]+)"`,
+			want: map[string][][]protocol.CaptureGroup{
+				"deps": {{{Name: "name", Value: "héllo", Offset: 5, Length: 5}}},
+			},
+		},
+		{
+			name:    "group spanning lines is reported on its first line",
+			pattern: `require \((?P<body>\s+github)`,
+			want: map[string][][]protocol.CaptureGroup{
+				"go.mod": {{{Name: "body", Value: "\n\tgithub", Offset: 9, Length: 0}}, nil},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rg, err := compile(&protocol.PatternInfo{Pattern: test.pattern, IsRegExp: true, IncludeCaptureGroups: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			fileMatches, _, err := regexSearch(context.Background(), rg, zf, 10, true, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := map[string][][]protocol.CaptureGroup{}
+			for _, fm := range fileMatches {
+				for _, lm := range fm.LineMatches {
+					got[fm.Path] = append(got[fm.Path], lm.CaptureGroups)
+				}
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got capture groups %+v, want %+v", got, test.want)
+			}
+		})
+	}
+
+	// Capture groups are only returned when requested.
+	rg, err := compile(&protocol.PatternInfo{Pattern: `(?P<v>v[0-9.]+)`, IsRegExp: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fileMatches, _, err := regexSearch(context.Background(), rg, zf, 10, true, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, fm := range fileMatches {
+		for _, lm := range fm.LineMatches {
+			if lm.CaptureGroups != nil {
+				t.Fatalf("got capture groups %+v, want none", lm.CaptureGroups)
+			}
+		}
+	}
+}
+
+func TestMultiline(t *testing.T) {
+	zipData, err := testutil.CreateZip(map[string]string{
+		"a.go": "x\nfunc a() {\n\treturn\n}\nfunc b() {}\n",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	zf, err := store.MockZipFile(zipData)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rg, err := compile(&protocol.PatternInfo{Pattern: `func \w+\(\) \{.*?\}`, IsRegExp: true, IsMultiline: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fileMatches, _, err := regexSearch(context.Background(), rg, zf, 10, true, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fileMatches) != 1 {
+		t.Fatalf("got %d file matches, want 1", len(fileMatches))
+	}
+	want := []protocol.LineMatch{
+		// The part of a match on a line includes the newline ending it.
+		{Preview: "func a() {", LineNumber: 1, OffsetAndLengths: [][2]int{{0, 11}}},
+		{Preview: "\treturn", LineNumber: 2, OffsetAndLengths: [][2]int{{0, 8}}},
+		{Preview: "}", LineNumber: 3, OffsetAndLengths: [][2]int{{0, 1}}},
+		{Preview: "func b() {}", LineNumber: 4, OffsetAndLengths: [][2]int{{0, 11}}},
+	}
+	if got := fileMatches[0].LineMatches; !reflect.DeepEqual(got, want) {
+		t.Errorf("got line matches %+v, want %+v", got, want)
+	}
+}
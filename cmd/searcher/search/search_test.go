@@ -2,6 +2,7 @@ package search_test
 
 import (
 	"archive/tar"
+	"archive/zip"
 	"bytes"
 	"context"
 	"encoding/json"
@@ -21,9 +22,11 @@ import (
 	"github.com/sourcegraph/sourcegraph/cmd/searcher/protocol"
 	"github.com/sourcegraph/sourcegraph/cmd/searcher/search"
 	"github.com/sourcegraph/sourcegraph/internal/api"
+	"github.com/sourcegraph/sourcegraph/internal/conf"
 	"github.com/sourcegraph/sourcegraph/internal/gitserver"
 	"github.com/sourcegraph/sourcegraph/internal/store"
 	"github.com/sourcegraph/sourcegraph/internal/testutil"
+	"github.com/sourcegraph/sourcegraph/schema"
 )
 
 func TestSearch(t *testing.T) {
@@ -180,6 +183,36 @@ main.go:7:}
 `},
 
 		{protocol.PatternInfo{Pattern: "^$", IsRegExp: true}, ``},
+
+		{protocol.PatternInfo{Pattern: `func main\(\) {.*}`, IsRegExp: true}, ``},
+		{protocol.PatternInfo{Pattern: `func main\(\) {.*}`, IsRegExp: true, IsMultiline: true}, `
+main.go:5:func main() {
+main.go:6:	fmt.Println("Hello world")
+main.go:7:}
+`},
+		{protocol.PatternInfo{Pattern: `FUNC MAIN\(\) {.*}`, IsRegExp: true, IsMultiline: true}, `
+main.go:5:func main() {
+main.go:6:	fmt.Println("Hello world")
+main.go:7:}
+`},
+		{protocol.PatternInfo{Pattern: `^import.*^func`, IsRegExp: true, IsMultiline: true}, `
+main.go:3:import "fmt"
+main.go:4:
+main.go:5:func main() {
+`},
+
+		// Binary files, like milton.png, are never known not to contain
+		// the pattern.
+		{protocol.PatternInfo{Pattern: "world", IsNegated: true}, `
+abc.txt
+`},
+		{protocol.PatternInfo{Pattern: "^package ", IsRegExp: true, IsNegated: true, IncludePatterns: []string{`\.(go|md)$`}, PathPatternsAreRegExps: true}, `
+README.md
+`},
+		{protocol.PatternInfo{Pattern: "main", IsNegated: true, PatternMatchesPath: true}, `
+README.md
+abc.txt
+`},
 	}
 
 	store, cleanup, err := newStore(files)
@@ -225,6 +258,59 @@ main.go:7:}
 	}
 }
 
+func TestSearch_archives(t *testing.T) {
+	conf.Mock(&conf.Unified{SiteConfiguration: schema.SiteConfiguration{
+		SearchArchives: &schema.SearchArchives{Enabled: true},
+	}})
+	defer conf.Mock(nil)
+
+	jar := new(bytes.Buffer)
+	zw := zip.NewWriter(jar)
+	w, err := zw.Create("com/x/Y.java")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.WriteString(w, "class Y {\n  String hello = \"Hello world\";\n}\n"); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	store, cleanup, err := newStore(map[string]string{
+		"README.md":   "Hello world",
+		"lib/foo.jar": jar.String(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup()
+	ts := httptest.NewServer(&search.Service{Store: store})
+	defer ts.Close()
+
+	req := protocol.Request{
+		Repo:   "foo",
+		URL:    "u",
+		Commit: "deadbeefdeadbeefdeadbeefdeadbeefdeadbeef",
+		PatternInfo: protocol.PatternInfo{
+			Pattern:               "world",
+			PatternMatchesContent: true,
+		},
+		FetchTimeout: "2000ms",
+	}
+	m, err := doSearch(ts.URL, &req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Sort(sortByPath(m))
+	want := `README.md:1:Hello world
+lib/foo.jar!/com/x/Y.java:2:  String hello = "Hello world";
+`
+	if got := toString(m); got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
 func TestSearch_badrequest(t *testing.T) {
 	cases := []protocol.Request{
 		// Bad regexp
@@ -311,6 +397,18 @@ func TestSearch_badrequest(t *testing.T) {
 			},
 		},
 
+		// Negated structural search
+		{
+			Repo:   "foo",
+			URL:    "u",
+			Commit: "deadbeefdeadbeefdeadbeefdeadbeefdeadbeef",
+			PatternInfo: protocol.PatternInfo{
+				Pattern:         "test",
+				IsStructuralPat: true,
+				IsNegated:       true,
+			},
+		},
+
 		// Bad exclude regexp
 		{
 			Repo:   "foo",
@@ -360,12 +458,18 @@ func doSearch(u string, p *protocol.Request) ([]protocol.FileMatch, error) {
 	if p.IsStructuralPat {
 		form.Set("IsStructuralPat", "true")
 	}
+	if p.IsNegated {
+		form.Set("IsNegated", "true")
+	}
 	if p.IsWordMatch {
 		form.Set("IsWordMatch", "true")
 	}
 	if p.IsCaseSensitive {
 		form.Set("IsCaseSensitive", "true")
 	}
+	if p.IsMultiline {
+		form.Set("IsMultiline", "true")
+	}
 	if p.PathPatternsAreRegExps {
 		form.Set("PathPatternsAreRegExps", "true")
 	}
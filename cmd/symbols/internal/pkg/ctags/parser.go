@@ -26,6 +26,14 @@ type Entry struct {
 	Pattern    string
 	Signature  string
 
+	// EndLine is the last line of the definition of the entry, such as the
+	// closing brace of a class, or 0 if it is unknown.
+	EndLine int
+
+	// Extractor is the name of the symbol extractor that produced the entry,
+	// such as "ctags" or "go/parser". It is set by package parsers.
+	Extractor string
+
 	FileLimited bool
 }
 
@@ -219,6 +227,7 @@ func (p *ctagsProcess) Parse(name string, content []byte) (entries []Entry, err
 			Name:        rep.Name,
 			Path:        rep.Path,
 			Line:        rep.Line,
+			EndLine:     rep.End,
 			Kind:        rep.Kind,
 			Language:    rep.Language,
 			Parent:      rep.Scope,
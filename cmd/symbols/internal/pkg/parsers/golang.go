@@ -0,0 +1,158 @@
+package parsers
+
+import (
+	"bytes"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"strings"
+
+	"github.com/sourcegraph/sourcegraph/cmd/symbols/internal/pkg/ctags"
+)
+
+func init() {
+	Register("Go", "go/parser", func() (SymbolParser, error) { return goParser{}, nil })
+}
+
+// goParser extracts the symbols of Go files with go/parser. Unlike ctags, it
+// records the receiver type of methods as their parent, and the full
+// signatures of functions.
+type goParser struct{}
+
+func (goParser) Parse(path string, content []byte) ([]ctags.Entry, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, path, content, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	lines := bytes.Split(content, []byte("\n"))
+	pkg := file.Name.Name
+	var entries []ctags.Entry
+	// add adds the symbol ident, whose definition is node (or nil if it has
+	// no end, like the package).
+	add := func(ident *ast.Ident, node ast.Node, kind, parent, parentKind, signature string) {
+		if ident == nil || ident.Name == "_" {
+			return
+		}
+		line := fset.Position(ident.Pos()).Line
+		var endLine int
+		if node != nil {
+			endLine = fset.Position(node.End()).Line
+		}
+		entries = append(entries, ctags.Entry{
+			Name:       ident.Name,
+			Path:       path,
+			Line:       line,
+			EndLine:    endLine,
+			Kind:       kind,
+			Language:   "Go",
+			Parent:     parent,
+			ParentKind: parentKind,
+			Pattern:    goPattern(lines, line),
+			Signature:  signature,
+		})
+	}
+	signature := func(typ *ast.FuncType) string {
+		var buf bytes.Buffer
+		if err := printer.Fprint(&buf, fset, typ); err != nil {
+			return ""
+		}
+		return strings.TrimPrefix(buf.String(), "func")
+	}
+
+	add(file.Name, nil, "package", "", "", "")
+	for _, decl := range file.Decls {
+		switch decl := decl.(type) {
+		case *ast.FuncDecl:
+			if decl.Recv != nil && len(decl.Recv.List) > 0 {
+				add(decl.Name, decl, "method", receiverTypeName(decl.Recv.List[0].Type), "type", signature(decl.Type))
+			} else {
+				add(decl.Name, decl, "func", pkg, "package", signature(decl.Type))
+			}
+
+		case *ast.GenDecl:
+			for _, spec := range decl.Specs {
+				switch spec := spec.(type) {
+				case *ast.ValueSpec:
+					kind := "variable"
+					if decl.Tok == token.CONST {
+						kind = "constant"
+					}
+					for _, name := range spec.Names {
+						add(name, spec, kind, pkg, "package", "")
+					}
+
+				case *ast.TypeSpec:
+					switch typ := spec.Type.(type) {
+					case *ast.StructType:
+						add(spec.Name, spec, "struct", pkg, "package", "")
+						for _, field := range typ.Fields.List {
+							if len(field.Names) == 0 {
+								add(embeddedTypeName(field.Type), field, "field", spec.Name.Name, "struct", "")
+							}
+							for _, name := range field.Names {
+								add(name, field, "field", spec.Name.Name, "struct", "")
+							}
+						}
+					case *ast.InterfaceType:
+						add(spec.Name, spec, "interface", pkg, "package", "")
+						for _, method := range typ.Methods.List {
+							funcType, ok := method.Type.(*ast.FuncType)
+							if !ok {
+								continue // embedded interface
+							}
+							for _, name := range method.Names {
+								add(name, method, "method", spec.Name.Name, "interface", signature(funcType))
+							}
+						}
+					default:
+						add(spec.Name, spec, "type", pkg, "package", "")
+					}
+				}
+			}
+		}
+	}
+	return entries, nil
+}
+
+func (goParser) Close() {}
+
+// receiverTypeName returns the name of the type of a method receiver, such
+// as T for *T.
+func receiverTypeName(expr ast.Expr) string {
+	if ident := embeddedTypeName(expr); ident != nil {
+		return ident.Name
+	}
+	return ""
+}
+
+// embeddedTypeName returns the name of the type expr, such as T for *T or
+// pkg.T, or nil if it is not a named type.
+func embeddedTypeName(expr ast.Expr) *ast.Ident {
+	switch expr := expr.(type) {
+	case *ast.Ident:
+		return expr
+	case *ast.StarExpr:
+		return embeddedTypeName(expr.X)
+	case *ast.SelectorExpr:
+		return expr.Sel
+	case *ast.IndexExpr:
+		return embeddedTypeName(expr.X)
+	case *ast.ParenExpr:
+		return embeddedTypeName(expr.X)
+	}
+	return nil
+}
+
+// goPattern returns a ctags-style search pattern for the line (1-based) of
+// lines.
+func goPattern(lines [][]byte, line int) string {
+	if line < 1 || line > len(lines) {
+		return ""
+	}
+	text := strings.TrimSuffix(string(lines[line-1]), "\r")
+	text = strings.NewReplacer(`\`, `\\`, `/`, `\/`).Replace(text)
+	return "/^" + text + "$/"
+}
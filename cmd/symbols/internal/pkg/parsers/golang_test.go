@@ -0,0 +1,63 @@
+package parsers
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/sourcegraph/sourcegraph/cmd/symbols/internal/pkg/ctags"
+)
+
+func TestGoParser(t *testing.T) {
+	src := `package foo
+
+import "io"
+
+const A, _ = 1, 2
+
+var b = "/"
+
+type T struct {
+	io.Reader
+	X, y int
+}
+
+type I interface {
+	io.Closer
+	M(n int) (string, error)
+}
+
+type S = []string
+
+func (t *T) Method(s S) error { return nil }
+
+func F(a, b int) {}
+`
+	got, err := goParser{}.Parse("foo/foo.go", []byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry := func(name string, line, endLine int, kind, parent, parentKind, signature, pattern string) ctags.Entry {
+		return ctags.Entry{Name: name, Path: "foo/foo.go", Line: line, EndLine: endLine, Kind: kind, Language: "Go", Parent: parent, ParentKind: parentKind, Signature: signature, Pattern: pattern}
+	}
+	want := []ctags.Entry{
+		entry("foo", 1, 0, "package", "", "", "", `/^package foo$/`),
+		entry("A", 5, 5, "constant", "foo", "package", "", `/^const A, _ = 1, 2$/`),
+		entry("b", 7, 7, "variable", "foo", "package", "", `/^var b = "\/"$/`),
+		entry("T", 9, 12, "struct", "foo", "package", "", `/^type T struct {$/`),
+		entry("Reader", 10, 10, "field", "T", "struct", "", "/^\tio.Reader$/"),
+		entry("X", 11, 11, "field", "T", "struct", "", "/^\tX, y int$/"),
+		entry("y", 11, 11, "field", "T", "struct", "", "/^\tX, y int$/"),
+		entry("I", 14, 17, "interface", "foo", "package", "", `/^type I interface {$/`),
+		entry("M", 16, 16, "method", "I", "interface", "(n int) (string, error)", "/^\tM(n int) (string, error)$/"),
+		entry("S", 19, 19, "type", "foo", "package", "", `/^type S = []string$/`),
+		entry("Method", 21, 21, "method", "T", "type", "(s S) error", `/^func (t *T) Method(s S) error { return nil }$/`),
+		entry("F", 23, 23, "func", "foo", "package", "(a, b int)", `/^func F(a, b int) {}$/`),
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got entries\n%+v\nwant\n%+v", got, want)
+	}
+
+	if _, err := (goParser{}).Parse("bad.go", []byte("package foo\nfunc (")); err == nil {
+		t.Error("got no error for a file with syntax errors")
+	}
+}
@@ -0,0 +1,132 @@
+// Package parsers extracts symbols from files with the symbol extractor
+// registered for their language, falling back to universal-ctags.
+package parsers
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/inconshreveable/log15"
+	"github.com/sourcegraph/sourcegraph/cmd/symbols/internal/pkg/ctags"
+	"github.com/src-d/enry/v2"
+)
+
+// CtagsExtractor is the name of the universal-ctags extractor, which parses
+// files of all languages without a registered extractor.
+const CtagsExtractor = "ctags"
+
+// SymbolParser extracts the symbols of files. A ctags.Parser is a
+// SymbolParser.
+type SymbolParser interface {
+	Parse(path string, content []byte) ([]ctags.Entry, error)
+	Close()
+}
+
+type extractor struct {
+	name      string
+	newParser func() (SymbolParser, error)
+}
+
+var (
+	registryMu sync.Mutex
+	registry   = map[string]extractor{} // by language
+)
+
+// Register registers the symbol extractor name for files of language, as
+// named by enry (for example "Go"). newParser is called to create a parser
+// the first time a file of language is parsed by a parser returned by New.
+//
+// Register panics if an extractor is already registered for language.
+func Register(language, name string, newParser func() (SymbolParser, error)) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if e, ok := registry[language]; ok {
+		panic(fmt.Sprintf("parsers: extractor %q already registered for %s", e.name, language))
+	}
+	registry[language] = extractor{name: name, newParser: newParser}
+}
+
+// Languages returns the languages with a registered extractor.
+func Languages() []string {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	languages := make([]string, 0, len(registry))
+	for language := range registry {
+		languages = append(languages, language)
+	}
+	sort.Strings(languages)
+	return languages
+}
+
+func lookup(language string) (extractor, bool) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	e, ok := registry[language]
+	return e, ok
+}
+
+// New returns a SymbolParser that parses each file with the extractor
+// registered for its language, and with the parser created by newFallback
+// (usually ctags.New) otherwise. Files that the registered extractor fails to
+// parse are parsed with the fallback too. The Extractor of each entry is set
+// to the name of the extractor that produced it.
+func New(newFallback func() (ctags.Parser, error)) (SymbolParser, error) {
+	fallback, err := newFallback()
+	if err != nil {
+		return nil, err
+	}
+	return &multiParser{fallback: fallback, parsers: map[string]SymbolParser{}}, nil
+}
+
+// multiParser is not safe for concurrent use, like ctags.Parser.
+type multiParser struct {
+	fallback SymbolParser
+	parsers  map[string]SymbolParser // by language, created on first use
+}
+
+func (p *multiParser) Parse(path string, content []byte) ([]ctags.Entry, error) {
+	if language, safe := enry.GetLanguageByExtension(path); safe {
+		if e, ok := lookup(language); ok {
+			entries, err := p.parseWith(e, language, path, content)
+			if err == nil {
+				return withExtractor(entries, e.name), nil
+			}
+			log15.Debug("Symbol extractor failed, falling back to ctags.", "extractor", e.name, "path", path, "error", err)
+		}
+	}
+	entries, err := p.fallback.Parse(path, content)
+	if err != nil {
+		return nil, err
+	}
+	return withExtractor(entries, CtagsExtractor), nil
+}
+
+func (p *multiParser) parseWith(e extractor, language, path string, content []byte) ([]ctags.Entry, error) {
+	sp, ok := p.parsers[language]
+	if !ok {
+		var err error
+		sp, err = e.newParser()
+		if err != nil {
+			return nil, err
+		}
+		p.parsers[language] = sp
+	}
+	return sp.Parse(path, content)
+}
+
+func (p *multiParser) Close() {
+	p.fallback.Close()
+	for _, sp := range p.parsers {
+		sp.Close()
+	}
+}
+
+func withExtractor(entries []ctags.Entry, name string) []ctags.Entry {
+	for i := range entries {
+		if entries[i].Extractor == "" {
+			entries[i].Extractor = name
+		}
+	}
+	return entries
+}
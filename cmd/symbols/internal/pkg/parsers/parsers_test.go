@@ -0,0 +1,70 @@
+package parsers
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/sourcegraph/sourcegraph/cmd/symbols/internal/pkg/ctags"
+)
+
+func TestNew(t *testing.T) {
+	got, err := New(func() (ctags.Parser, error) { return fakeParser{}, nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer got.Close()
+
+	tests := map[string]struct {
+		content string
+		want    []ctags.Entry
+	}{
+		"a.go": {
+			content: "package a",
+			want:    []ctags.Entry{{Name: "a", Path: "a.go", Line: 1, Kind: "package", Language: "Go", Pattern: "/^package a$/", Extractor: "go/parser"}},
+		},
+		// Go files with syntax errors are parsed by ctags.
+		"b.go": {
+			content: "package b\nfunc (",
+			want:    []ctags.Entry{{Name: "fake", Path: "b.go", Extractor: CtagsExtractor}},
+		},
+		"c.js": {
+			content: "var c",
+			want:    []ctags.Entry{{Name: "fake", Path: "c.js", Extractor: CtagsExtractor}},
+		},
+	}
+	for path, test := range tests {
+		entries, err := got.Parse(path, []byte(test.content))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(entries, test.want) {
+			t.Errorf("%s: got %+v, want %+v", path, entries, test.want)
+		}
+	}
+
+	if _, err := New(func() (ctags.Parser, error) { return nil, errors.New("no ctags") }); err == nil {
+		t.Error("got no error when the fallback parser can't be created")
+	}
+}
+
+func TestRegister(t *testing.T) {
+	if got, want := Languages(), []string{"Go"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got languages %q, want %q", got, want)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("registering a second Go extractor did not panic")
+		}
+	}()
+	Register("Go", "other", func() (SymbolParser, error) { return fakeParser{}, nil })
+}
+
+type fakeParser struct{}
+
+func (fakeParser) Parse(path string, content []byte) ([]ctags.Entry, error) {
+	return []ctags.Entry{{Name: "fake", Path: path}}, nil
+}
+
+func (fakeParser) Close() {}
@@ -0,0 +1,259 @@
+package symbols
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/inconshreveable/log15"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sourcegraph/sourcegraph/internal/api"
+	"github.com/sourcegraph/sourcegraph/internal/gitserver"
+	"github.com/sourcegraph/sourcegraph/internal/symbols/protocol"
+)
+
+const (
+	// maxAncestorsToCheck is the number of ancestors of a commit checked for
+	// a database to derive the commit's database from.
+	maxAncestorsToCheck = 100
+
+	// maxChangedFiles is the maximum number of files changed since the
+	// ancestor for which the database is derived from the ancestor's. When
+	// more files changed, parsing all files is about as fast.
+	maxChangedFiles = 1000
+)
+
+var (
+	errNoIndexedAncestor = errors.New("no ancestor commit has a symbols database")
+	errTooManyChanges    = errors.New("too many files changed since the ancestor commit")
+)
+
+// Changes are the paths of the files changed between two commits. A renamed
+// file is deleted at its old path and added at its new path.
+type Changes struct {
+	Added    []string
+	Modified []string
+	Deleted  []string
+}
+
+// writeSymbolsToNewDB writes the symbols of repo@commitID to the blank database
+// file dbFile. If possible, it derives them from the database of the nearest
+// ancestor of commitID in the cache. Otherwise it parses all files.
+func (s *Service) writeSymbolsToNewDB(ctx context.Context, dbFile string, repoName api.RepoName, commitID api.CommitID) error {
+	if s.FetchTarPaths != nil && s.ListAncestors != nil && s.GitDiff != nil {
+		err := s.writeChangedSymbolsToNewDB(ctx, dbFile, repoName, commitID)
+		if err == nil {
+			incrementalIndexes.WithLabelValues("success").Inc()
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err == errNoIndexedAncestor {
+			incrementalIndexes.WithLabelValues("no_ancestor").Inc()
+		} else {
+			incrementalIndexes.WithLabelValues("fallback").Inc()
+			log15.Warn("Failed to derive symbols from an ancestor commit, parsing all files.", "repo", repoName, "commitID", commitID, "error", err)
+		}
+		// Start over with a blank database.
+		if err := os.Truncate(dbFile, 0); err != nil {
+			return err
+		}
+	}
+	return s.writeAllSymbolsToNewDB(ctx, dbFile, repoName, commitID)
+}
+
+// writeChangedSymbolsToNewDB copies the database of the nearest ancestor of
+// repo@commitID in the cache to dbFile, and updates it with the symbols of the
+// files changed since the ancestor.
+func (s *Service) writeChangedSymbolsToNewDB(ctx context.Context, dbFile string, repoName api.RepoName, commitID api.CommitID) error {
+	ancestors, err := s.ListAncestors(ctx, repoName, commitID, maxAncestorsToCheck)
+	if err != nil {
+		return errors.Wrap(err, "ListAncestors")
+	}
+	var ancestor api.CommitID
+	var ancestorDB io.ReadCloser
+	for _, a := range ancestors {
+		f, err := s.cache.OpenIfExists(symbolsDBKey(repoName, a))
+		if err == nil {
+			ancestor, ancestorDB = a, f
+			break
+		}
+		if !os.IsNotExist(err) {
+			return err
+		}
+	}
+	if ancestorDB == nil {
+		return errNoIndexedAncestor
+	}
+	defer ancestorDB.Close()
+
+	changes, err := s.GitDiff(ctx, repoName, ancestor, commitID)
+	if err != nil {
+		return errors.Wrap(err, "GitDiff")
+	}
+	changed := append(append([]string{}, changes.Added...), changes.Modified...)
+	if len(changed)+len(changes.Deleted) > maxChangedFiles {
+		return errTooManyChanges
+	}
+
+	f, err := os.OpenFile(dbFile, os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, ancestorDB)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return errors.Wrap(err, "copying ancestor database")
+	}
+
+	db, err := sqlx.Open("sqlite3_with_pcre", dbFile)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	tx, err := db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	deleteStatement, err := tx.Preparex(`DELETE FROM symbols WHERE path = ?`)
+	if err != nil {
+		return err
+	}
+	for _, paths := range [][]string{changes.Deleted, changed} {
+		for _, path := range paths {
+			if _, err := deleteStatement.Exec(path); err != nil {
+				return err
+			}
+		}
+	}
+
+	if len(changed) > 0 {
+		insertStatement, err := prepareInsertSymbol(tx)
+		if err != nil {
+			return err
+		}
+		err = s.parseUncached(ctx, repoName, commitID, changed, func(symbol protocol.Symbol) error {
+			symbolInDBValue := symbolToSymbolInDB(symbol)
+			_, err := insertStatement.Exec(&symbolInDBValue)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GitDiff returns the files changed between the commits ancestor and commit of
+// repo, using gitserver.
+func GitDiff(ctx context.Context, repo api.RepoName, ancestor, commit api.CommitID) (Changes, error) {
+	if err := checkCommitID(ancestor); err != nil {
+		return Changes{}, err
+	}
+	if err := checkCommitID(commit); err != nil {
+		return Changes{}, err
+	}
+	cmd := gitserver.DefaultClient.Command("git", "diff", "-z", "--name-status", "--find-renames", string(ancestor), string(commit), "--")
+	cmd.Repo = gitserver.Repo{Name: repo}
+	out, err := cmd.Output(ctx)
+	if err != nil {
+		return Changes{}, errors.WithMessage(err, fmt.Sprintf("git command %v failed", cmd.Args))
+	}
+	return parseGitDiffNameStatus(out)
+}
+
+// ListAncestors returns up to n ancestors of the commit of repo, most recent
+// first, using gitserver.
+func ListAncestors(ctx context.Context, repo api.RepoName, commit api.CommitID, n int) ([]api.CommitID, error) {
+	if err := checkCommitID(commit); err != nil {
+		return nil, err
+	}
+	cmd := gitserver.DefaultClient.Command("git", "rev-list", "--skip=1", "--max-count="+strconv.Itoa(n), string(commit), "--")
+	cmd.Repo = gitserver.Repo{Name: repo}
+	out, err := cmd.Output(ctx)
+	if err != nil {
+		return nil, errors.WithMessage(err, fmt.Sprintf("git command %v failed", cmd.Args))
+	}
+	var ancestors []api.CommitID
+	for _, line := range strings.Fields(string(out)) {
+		ancestors = append(ancestors, api.CommitID(line))
+	}
+	return ancestors, nil
+}
+
+// checkCommitID returns an error if commit would be interpreted as a flag by
+// git.
+func checkCommitID(commit api.CommitID) error {
+	if commit == "" || strings.HasPrefix(string(commit), "-") {
+		return fmt.Errorf("invalid commit ID %q", commit)
+	}
+	return nil
+}
+
+// parseGitDiffNameStatus parses the output of git diff -z --name-status.
+func parseGitDiffNameStatus(out []byte) (Changes, error) {
+	var changes Changes
+	if len(out) == 0 {
+		return changes, nil
+	}
+	fields := bytes.Split(bytes.TrimSuffix(out, []byte{0}), []byte{0})
+	for i := 0; i < len(fields); {
+		status := string(fields[i])
+		if status == "" {
+			return Changes{}, fmt.Errorf("invalid git diff output: empty status at field %d", i)
+		}
+		// Renames and copies (R and C, followed by a similarity score) are
+		// followed by the source and destination paths, other statuses by a
+		// single path.
+		numPaths := 1
+		if status[0] == 'R' || status[0] == 'C' {
+			numPaths = 2
+		}
+		if i+numPaths >= len(fields) {
+			return Changes{}, fmt.Errorf("invalid git diff output: missing path for status %q", status)
+		}
+		paths := fields[i+1 : i+1+numPaths]
+		i += 1 + numPaths
+
+		switch status[0] {
+		case 'A':
+			changes.Added = append(changes.Added, string(paths[0]))
+		case 'M', 'T':
+			changes.Modified = append(changes.Modified, string(paths[0]))
+		case 'D':
+			changes.Deleted = append(changes.Deleted, string(paths[0]))
+		case 'R':
+			changes.Deleted = append(changes.Deleted, string(paths[0]))
+			changes.Added = append(changes.Added, string(paths[1]))
+		case 'C':
+			changes.Added = append(changes.Added, string(paths[1]))
+		default:
+			// Unmerged (U) and unknown (X) files don't occur between
+			// commits, but parse them conservatively as modified.
+			changes.Modified = append(changes.Modified, string(paths[0]))
+		}
+	}
+	return changes, nil
+}
+
+var incrementalIndexes = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "symbols_store_incremental_indexes",
+	Help: "The total number of attempts to derive a symbols database from the database of an ancestor commit, by result (success, no_ancestor, fallback).",
+}, []string{"result"})
+
+func init() {
+	prometheus.MustRegister(incrementalIndexes)
+}
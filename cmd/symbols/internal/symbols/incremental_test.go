@@ -0,0 +1,145 @@
+package symbols
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/sourcegraph/sourcegraph/cmd/symbols/internal/pkg/ctags"
+	"github.com/sourcegraph/sourcegraph/cmd/symbols/internal/pkg/parsers"
+	"github.com/sourcegraph/sourcegraph/internal/api"
+	"github.com/sourcegraph/sourcegraph/internal/gitserver"
+	"github.com/sourcegraph/sourcegraph/internal/symbols/protocol"
+)
+
+func TestParseGitDiffNameStatus(t *testing.T) {
+	out := "M\x00a.go\x00R087\x00old/b.go\x00new/b.go\x00D\x00c.go\x00A\x00d e.go\x00C100\x00f.go\x00g.go\x00T\x00h\x00"
+	got, err := parseGitDiffNameStatus([]byte(out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Changes{
+		Added:    []string{"new/b.go", "d e.go", "g.go"},
+		Modified: []string{"a.go", "h"},
+		Deleted:  []string{"old/b.go", "c.go"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	if got, err := parseGitDiffNameStatus(nil); err != nil || !reflect.DeepEqual(got, Changes{}) {
+		t.Errorf("got %+v and error %v for empty output, want no changes", got, err)
+	}
+	if _, err := parseGitDiffNameStatus([]byte("R100\x00a.go\x00")); err == nil {
+		t.Error("got no error for a rename without a destination path")
+	}
+}
+
+func TestService_incremental(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { os.RemoveAll(tmpDir) }()
+
+	commits := map[api.CommitID]map[string]string{
+		"c1": {"a.js": "a1", "b.js": "b", "c.js": "c"},
+		// c2 modifies a.js, renames b.js to d.js, deletes c.js and adds e.js.
+		"c2": {"a.js": "a2", "d.js": "b", "e.js": "e"},
+		"c3": {"a.js": "a3", "d.js": "b", "e.js": "e"},
+	}
+	ancestors := map[api.CommitID][]api.CommitID{
+		"c2": {"c1"},
+		"c3": {"c2", "c1"},
+	}
+	diffs := map[string]string{
+		"c1..c2": "M\x00a.js\x00R100\x00b.js\x00d.js\x00D\x00c.js\x00A\x00e.js\x00",
+	}
+
+	var fetched []string
+	service := Service{
+		FetchTar: func(ctx context.Context, repo gitserver.Repo, commit api.CommitID) (io.ReadCloser, error) {
+			fetched = append(fetched, string(commit))
+			return createTar(commits[commit])
+		},
+		FetchTarPaths: func(ctx context.Context, repo gitserver.Repo, commit api.CommitID, paths []string) (io.ReadCloser, error) {
+			fetched = append(fetched, string(commit)+":"+strings.Join(paths, ","))
+			files := map[string]string{}
+			for _, path := range paths {
+				files[path] = commits[commit][path]
+			}
+			return createTar(files)
+		},
+		ListAncestors: func(ctx context.Context, repo api.RepoName, commit api.CommitID, n int) ([]api.CommitID, error) {
+			return ancestors[commit], nil
+		},
+		GitDiff: func(ctx context.Context, repo api.RepoName, ancestor, commit api.CommitID) (Changes, error) {
+			out, ok := diffs[string(ancestor)+".."+string(commit)]
+			if !ok {
+				return Changes{}, errors.New("bad object")
+			}
+			return parseGitDiffNameStatus([]byte(out))
+		},
+		NewParser: func() (parsers.SymbolParser, error) {
+			return contentParser{}, nil
+		},
+		Path: tmpDir,
+	}
+	if err := service.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	search := func(commit api.CommitID) []string {
+		result, err := service.search(context.Background(), protocol.SearchArgs{Repo: "r", CommitID: commit, First: 10})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var symbols []string
+		for _, s := range result.Symbols {
+			symbols = append(symbols, s.Path+":"+s.Name)
+		}
+		sort.Strings(symbols)
+		return symbols
+	}
+
+	tests := []struct {
+		commit      api.CommitID
+		wantSymbols []string
+		wantFetched []string
+	}{
+		// c1 has no indexed ancestors, so all its files are parsed.
+		{"c1", []string{"a.js:a1", "b.js:b", "c.js:c"}, []string{"c1"}},
+		// c2 is derived from c1, parsing only the added, renamed and modified files.
+		{"c2", []string{"a.js:a2", "d.js:b", "e.js:e"}, []string{"c2:d.js,e.js,a.js"}},
+		// The diff from c2 to c3 fails, so all files of c3 are parsed.
+		{"c3", []string{"a.js:a3", "d.js:b", "e.js:e"}, []string{"c3"}},
+	}
+	for _, test := range tests {
+		fetched = nil
+		if got := search(test.commit); !reflect.DeepEqual(got, test.wantSymbols) {
+			t.Errorf("%s: got symbols %q, want %q", test.commit, got, test.wantSymbols)
+		}
+		if !reflect.DeepEqual(fetched, test.wantFetched) {
+			t.Errorf("%s: got fetches %q, want %q", test.commit, fetched, test.wantFetched)
+		}
+	}
+}
+
+// contentParser returns a symbol for each word of a file.
+type contentParser struct{}
+
+func (contentParser) Parse(name string, content []byte) ([]ctags.Entry, error) {
+	var entries []ctags.Entry
+	for _, word := range strings.Fields(string(content)) {
+		entries = append(entries, ctags.Entry{Name: word, Path: name})
+	}
+	return entries, nil
+}
+
+func (contentParser) Close() {}
@@ -0,0 +1,92 @@
+package symbols
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/inconshreveable/log15"
+	"github.com/jmoiron/sqlx"
+	"github.com/keegancsmith/sqlf"
+	"github.com/opentracing/opentracing-go/ext"
+	otlog "github.com/opentracing/opentracing-go/log"
+	"github.com/sourcegraph/sourcegraph/internal/symbols/protocol"
+	"github.com/sourcegraph/sourcegraph/internal/trace/ot"
+)
+
+// maxListFirst is the maximum number of symbols returned by a list request.
+const maxListFirst = 1000000
+
+func (s *Service) handleList(w http.ResponseWriter, r *http.Request) {
+	var args protocol.ListArgs
+	if err := json.NewDecoder(r.Body).Decode(&args); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	result, err := s.list(r.Context(), args)
+	if err != nil {
+		if err == context.Canceled && r.Context().Err() == context.Canceled {
+			return // client went away
+		}
+		log15.Error("Symbol list failed", "args", args, "error", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// list returns the symbols of a commit (or of one file of it), ordered by
+// path and line. It is used to copy the symbols of default branches to the
+// global symbol index of the frontend, and to build the outlines of files.
+func (s *Service) list(ctx context.Context, args protocol.ListArgs) (result *protocol.SearchResult, err error) {
+	ctx, cancel := context.WithTimeout(ctx, 60*time.Second)
+	defer cancel()
+
+	span, ctx := ot.StartSpanFromContext(ctx, "list")
+	span.SetTag("repo", args.Repo)
+	span.SetTag("commitID", args.CommitID)
+	span.SetTag("path", args.Path)
+	span.SetTag("first", args.First)
+	defer func() {
+		if err != nil {
+			ext.Error.Set(span, true)
+			span.LogFields(otlog.Error(err))
+		}
+		span.Finish()
+	}()
+
+	dbFile, err := s.getDBFile(ctx, protocol.SearchArgs{Repo: args.Repo, CommitID: args.CommitID})
+	if err != nil {
+		return nil, err
+	}
+	db, err := sqlx.Open("sqlite3_with_pcre", dbFile)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	if args.First <= 0 || args.First > maxListFirst {
+		args.First = maxListFirst
+	}
+	where := sqlf.Sprintf("TRUE")
+	if args.Path != "" {
+		where = sqlf.Sprintf("path = %s", args.Path)
+	}
+	q := sqlf.Sprintf("SELECT * FROM symbols WHERE %s ORDER BY path, line LIMIT %s", where, args.First)
+	var symbolsInDB []symbolInDB
+	if err := db.SelectContext(ctx, &symbolsInDB, q.Query(sqlf.PostgresBindVar), q.Args()...); err != nil {
+		return nil, err
+	}
+
+	result = &protocol.SearchResult{Symbols: make([]protocol.Symbol, 0, len(symbolsInDB))}
+	for _, symbolInDB := range symbolsInDB {
+		result.Symbols = append(result.Symbols, symbolInDBToSymbol(symbolInDB))
+	}
+	return result, nil
+}
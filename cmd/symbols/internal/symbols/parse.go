@@ -13,6 +13,7 @@ import (
 	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/sourcegraph/sourcegraph/cmd/symbols/internal/pkg/ctags"
+	"github.com/sourcegraph/sourcegraph/cmd/symbols/internal/pkg/parsers"
 	"github.com/sourcegraph/sourcegraph/internal/api"
 	"github.com/sourcegraph/sourcegraph/internal/symbols/protocol"
 	"github.com/sourcegraph/sourcegraph/internal/trace/ot"
@@ -26,7 +27,7 @@ func (s *Service) startParsers() error {
 		n = runtime.GOMAXPROCS(0)
 	}
 
-	s.parsers = make(chan ctags.Parser, n)
+	s.parsers = make(chan parsers.SymbolParser, n)
 	for i := 0; i < n; i++ {
 		parser, err := s.NewParser()
 		if err != nil {
@@ -37,7 +38,9 @@ func (s *Service) startParsers() error {
 	return nil
 }
 
-func (s *Service) parseUncached(ctx context.Context, repo api.RepoName, commitID api.CommitID, callback func(symbol protocol.Symbol) error) (err error) {
+// parseUncached parses the files of repo@commitID, and calls callback with each
+// symbol found. If paths is nonempty, only those files are parsed.
+func (s *Service) parseUncached(ctx context.Context, repo api.RepoName, commitID api.CommitID, paths []string, callback func(symbol protocol.Symbol) error) (err error) {
 	span, ctx := ot.StartSpanFromContext(ctx, "parseUncached")
 	defer func() {
 		if err != nil {
@@ -50,7 +53,7 @@ func (s *Service) parseUncached(ctx context.Context, repo api.RepoName, commitID
 	span.SetTag("commit", string(commitID))
 
 	tr := nettrace.New("parseUncached", string(repo))
-	tr.LazyPrintf("commitID: %s", commitID)
+	tr.LazyPrintf("commitID: %s paths: %d", commitID, len(paths))
 
 	totalSymbols := 0
 	defer func() {
@@ -63,7 +66,7 @@ func (s *Service) parseUncached(ctx context.Context, repo api.RepoName, commitID
 	}()
 
 	tr.LazyPrintf("fetch")
-	parseRequests, errChan, err := s.fetchRepositoryArchive(ctx, repo, commitID)
+	parseRequests, errChan, err := s.fetchRepositoryArchive(ctx, repo, commitID, paths)
 	tr.LazyPrintf("fetch (returned chans)")
 	if err != nil {
 		return err
@@ -180,12 +183,14 @@ func entryToSymbol(e ctags.Entry) protocol.Symbol {
 		Name:        e.Name,
 		Path:        e.Path,
 		Line:        e.Line,
+		EndLine:     e.EndLine,
 		Kind:        e.Kind,
 		Language:    e.Language,
 		Parent:      e.Parent,
 		ParentKind:  e.ParentKind,
 		Signature:   e.Signature,
 		Pattern:     e.Pattern,
+		Extractor:   e.Extractor,
 		FileLimited: e.FileLimited,
 	}
 }
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"regexp"
 	"regexp/syntax"
 	"strings"
 	"time"
@@ -97,8 +98,8 @@ func (s *Service) search(ctx context.Context, args protocol.SearchArgs) (result
 // specified in `args`. If the database doesn't already exist in the disk cache,
 // it will create a new one and write all the symbols into it.
 func (s *Service) getDBFile(ctx context.Context, args protocol.SearchArgs) (string, error) {
-	diskcacheFile, err := s.cache.OpenWithPath(ctx, fmt.Sprintf("%d-%s@%s", symbolsDBVersion, args.Repo, args.CommitID), func(fetcherCtx context.Context, tempDBFile string) error {
-		err := s.writeAllSymbolsToNewDB(fetcherCtx, tempDBFile, args.Repo, args.CommitID)
+	diskcacheFile, err := s.cache.OpenWithPath(ctx, symbolsDBKey(args.Repo, args.CommitID), func(fetcherCtx context.Context, tempDBFile string) error {
+		err := s.writeSymbolsToNewDB(fetcherCtx, tempDBFile, args.Repo, args.CommitID)
 		if err != nil {
 			if err == context.Canceled {
 				log15.Error("Unable to parse repository symbols within the context", "repo", args.Repo, "commit", args.CommitID, "query", args.Query)
@@ -133,6 +134,34 @@ func isLiteralEquality(expr string) (ok bool, lit string, err error) {
 	return true, string(r.Sub[1].Rune), nil
 }
 
+// escapeGlob escapes the characters of s that are special in SQLite GLOB
+// patterns.
+func escapeGlob(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch r {
+		case '*', '?', '[', ']':
+			b.WriteString("[" + string(r) + "]")
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// fuzzyRegexp returns a regexp matching names that contain the characters of
+// s in order. The empty string matches all names.
+func fuzzyRegexp(s string) string {
+	if s == "" {
+		return ""
+	}
+	chars := make([]string, 0, len(s))
+	for _, r := range s {
+		chars = append(chars, regexp.QuoteMeta(string(r)))
+	}
+	return strings.Join(chars, ".*")
+}
+
 func filterSymbols(ctx context.Context, db *sqlx.DB, args protocol.SearchArgs) (res []protocol.Symbol, err error) {
 	span, _ := ot.StartSpanFromContext(ctx, "filterSymbols")
 	defer func() {
@@ -183,18 +212,62 @@ func filterSymbols(ctx context.Context, db *sqlx.DB, args protocol.SearchArgs) (
 		return newConditions
 	}
 
+	// makeInCondition matches the values of column case-insensitively
+	// against values.
+	makeInCondition := func(column string, values []string) []*sqlf.Query {
+		if len(values) == 0 {
+			return nil
+		}
+		items := make([]*sqlf.Query, len(values))
+		for i, value := range values {
+			items[i] = sqlf.Sprintf("%s", strings.ToLower(value))
+		}
+		return []*sqlf.Query{sqlf.Sprintf("lower("+column+") IN (%s)", sqlf.Join(items, ", "))}
+	}
+
 	var conditions []*sqlf.Query
-	conditions = append(conditions, makeCondition("name", args.Query)...)
+	order := sqlf.Sprintf("")
+	switch args.NameMatch {
+	case protocol.NameMatchRegexp:
+		conditions = append(conditions, makeCondition("name", args.Query)...)
+	case protocol.NameMatchExact:
+		if args.Query != "" {
+			conditions = append(conditions, makeCondition("name", "^"+regexp.QuoteMeta(args.Query)+"$")...)
+		}
+	case protocol.NameMatchPrefix:
+		// GLOB is case sensitive, and uses the index on the column for
+		// prefixes.
+		if args.IsCaseSensitive {
+			conditions = append(conditions, sqlf.Sprintf("name GLOB %s", escapeGlob(args.Query)+"*"))
+		} else {
+			conditions = append(conditions, sqlf.Sprintf("namelowercase GLOB %s", escapeGlob(strings.ToLower(args.Query))+"*"))
+		}
+	case protocol.NameMatchFuzzy:
+		conditions = append(conditions, makeCondition("name", fuzzyRegexp(args.Query))...)
+		// Prefer the closest matches.
+		order = sqlf.Sprintf("ORDER BY length(name), name")
+	default:
+		return nil, fmt.Errorf("invalid NameMatch %q", args.NameMatch)
+	}
 	for _, includePattern := range args.IncludePatterns {
 		conditions = append(conditions, makeCondition("path", includePattern)...)
 	}
 	conditions = append(conditions, negateAll(makeCondition("path", args.ExcludePattern))...)
+	conditions = append(conditions, makeInCondition("kind", args.Kinds)...)
+	conditions = append(conditions, makeInCondition("language", args.Languages)...)
+	if args.Parent != "" {
+		if args.IsCaseSensitive {
+			conditions = append(conditions, sqlf.Sprintf("parent = %s", args.Parent))
+		} else {
+			conditions = append(conditions, makeInCondition("parent", []string{args.Parent})...)
+		}
+	}
 
 	var sqlQuery *sqlf.Query
 	if len(conditions) == 0 {
-		sqlQuery = sqlf.Sprintf("SELECT * FROM symbols LIMIT %s", args.First)
+		sqlQuery = sqlf.Sprintf("SELECT * FROM symbols %s LIMIT %s", order, args.First)
 	} else {
-		sqlQuery = sqlf.Sprintf("SELECT * FROM symbols WHERE %s LIMIT %s", sqlf.Join(conditions, "AND"), args.First)
+		sqlQuery = sqlf.Sprintf("SELECT * FROM symbols WHERE %s %s LIMIT %s", sqlf.Join(conditions, "AND"), order, args.First)
 	}
 
 	var symbolsInDB []symbolInDB
@@ -215,7 +288,13 @@ func filterSymbols(ctx context.Context, db *sqlx.DB, args protocol.SearchArgs) (
 // filenames to prevent a newer version of the symbols service from attempting
 // to read from a database created by an older (and likely incompatible) symbols
 // service. Increment this when you change the database schema.
-const symbolsDBVersion = 3
+const symbolsDBVersion = 5
+
+// symbolsDBKey returns the disk cache key of the symbols database of
+// repo@commitID.
+func symbolsDBKey(repo api.RepoName, commitID api.CommitID) string {
+	return fmt.Sprintf("%d-%s@%s", symbolsDBVersion, repo, commitID)
+}
 
 // symbolInDB is the same as `protocol.Symbol`, but with two additional columns:
 // namelowercase and pathlowercase, which enable indexed case insensitive
@@ -226,12 +305,14 @@ type symbolInDB struct {
 	Path          string
 	PathLowercase string // derived from `Path`
 	Line          int
+	EndLine       int
 	Kind          string
 	Language      string
 	Parent        string
 	ParentKind    string
 	Signature     string
 	Pattern       string
+	Extractor     string
 
 	FileLimited bool
 }
@@ -243,12 +324,14 @@ func symbolToSymbolInDB(symbol protocol.Symbol) symbolInDB {
 		Path:          symbol.Path,
 		PathLowercase: strings.ToLower(symbol.Path),
 		Line:          symbol.Line,
+		EndLine:       symbol.EndLine,
 		Kind:          symbol.Kind,
 		Language:      symbol.Language,
 		Parent:        symbol.Parent,
 		ParentKind:    symbol.ParentKind,
 		Signature:     symbol.Signature,
 		Pattern:       symbol.Pattern,
+		Extractor:     symbol.Extractor,
 
 		FileLimited: symbol.FileLimited,
 	}
@@ -259,12 +342,14 @@ func symbolInDBToSymbol(symbolInDB symbolInDB) protocol.Symbol {
 		Name:       symbolInDB.Name,
 		Path:       symbolInDB.Path,
 		Line:       symbolInDB.Line,
+		EndLine:    symbolInDB.EndLine,
 		Kind:       symbolInDB.Kind,
 		Language:   symbolInDB.Language,
 		Parent:     symbolInDB.Parent,
 		ParentKind: symbolInDB.ParentKind,
 		Signature:  symbolInDB.Signature,
 		Pattern:    symbolInDB.Pattern,
+		Extractor:  symbolInDB.Extractor,
 
 		FileLimited: symbolInDB.FileLimited,
 	}
@@ -295,12 +380,14 @@ func (s *Service) writeAllSymbolsToNewDB(ctx context.Context, dbFile string, rep
 			path VARCHAR(4096) NOT NULL,
 			pathlowercase VARCHAR(4096) NOT NULL,
 			line INT NOT NULL,
+			endline INT NOT NULL,
 			kind VARCHAR(255) NOT NULL,
 			language VARCHAR(255) NOT NULL,
 			parent VARCHAR(255) NOT NULL,
 			parentkind VARCHAR(255) NOT NULL,
 			signature VARCHAR(255) NOT NULL,
 			pattern VARCHAR(255) NOT NULL,
+			extractor VARCHAR(255) NOT NULL,
 			filelimited BOOLEAN NOT NULL
 		)`)
 	if err != nil {
@@ -328,16 +415,12 @@ func (s *Service) writeAllSymbolsToNewDB(ctx context.Context, dbFile string, rep
 		return err
 	}
 
-	insertStatement, err := tx.PrepareNamed(
-		fmt.Sprintf(
-			"INSERT INTO symbols %s VALUES %s",
-			"( name,  namelowercase,  path,  pathlowercase,  line,  kind,  language,  parent,  parentkind,  signature,  pattern,  filelimited)",
-			"(:name, :namelowercase, :path, :pathlowercase, :line, :kind, :language, :parent, :parentkind, :signature, :pattern, :filelimited)"))
+	insertStatement, err := prepareInsertSymbol(tx)
 	if err != nil {
 		return err
 	}
 
-	err = s.parseUncached(ctx, repoName, commitID, func(symbol protocol.Symbol) error {
+	err = s.parseUncached(ctx, repoName, commitID, nil, func(symbol protocol.Symbol) error {
 		symbolInDBValue := symbolToSymbolInDB(symbol)
 		_, err := insertStatement.Exec(&symbolInDBValue)
 		return err
@@ -353,3 +436,13 @@ func (s *Service) writeAllSymbolsToNewDB(ctx context.Context, dbFile string, rep
 
 	return nil
 }
+
+// prepareInsertSymbol prepares the statement to insert a symbolInDB into the
+// symbols table.
+func prepareInsertSymbol(tx *sqlx.Tx) (*sqlx.NamedStmt, error) {
+	return tx.PrepareNamed(
+		fmt.Sprintf(
+			"INSERT INTO symbols %s VALUES %s",
+			"( name,  namelowercase,  path,  pathlowercase,  line,  endline,  kind,  language,  parent,  parentkind,  signature,  pattern,  extractor,  filelimited)",
+			"(:name, :namelowercase, :path, :pathlowercase, :line, :endline, :kind, :language, :parent, :parentkind, :signature, :pattern, :extractor, :filelimited)"))
+}
@@ -3,13 +3,19 @@ package symbols
 import (
 	"context"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 	"path"
+	"reflect"
+	"sort"
 	"testing"
 
 	"github.com/inconshreveable/log15"
 	"github.com/sourcegraph/sourcegraph/cmd/symbols/internal/pkg/ctags"
+	"github.com/sourcegraph/sourcegraph/cmd/symbols/internal/pkg/parsers"
+	"github.com/sourcegraph/sourcegraph/internal/api"
+	"github.com/sourcegraph/sourcegraph/internal/gitserver"
 	"github.com/sourcegraph/sourcegraph/internal/sqliteutil"
 	"github.com/sourcegraph/sourcegraph/internal/symbols/protocol"
 	"github.com/sourcegraph/sourcegraph/internal/testutil"
@@ -22,7 +28,7 @@ func BenchmarkSearch(b *testing.B) {
 
 	service := Service{
 		FetchTar: testutil.FetchTarFromGithub,
-		NewParser: func() (ctags.Parser, error) {
+		NewParser: func() (parsers.SymbolParser, error) {
 			return ctags.New()
 		},
 		Path: "/tmp/symbols-cache",
@@ -86,3 +92,109 @@ func BenchmarkSearch(b *testing.B) {
 		runQueryTest(test)
 	}
 }
+
+func TestFilterSymbols(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	entries := []ctags.Entry{
+		{Name: "Bar", Path: "foo.go", Kind: "struct", Language: "Go", Parent: "foo", ParentKind: "package"},
+		{Name: "Baz", Path: "foo.go", Kind: "method", Language: "Go", Parent: "Bar", ParentKind: "type"},
+		{Name: "NewBar", Path: "foo.go", Kind: "func", Language: "Go", Parent: "foo", ParentKind: "package"},
+		{Name: "newBarImpl", Path: "foo.go", Kind: "func", Language: "Go", Parent: "foo", ParentKind: "package"},
+		{Name: "Bar", Path: "bar.ts", Kind: "class", Language: "TypeScript"},
+		{Name: "baz", Path: "bar.ts", Kind: "method", Language: "TypeScript", Parent: "Bar", ParentKind: "class"},
+		{Name: "a*b", Path: "bar.ts", Kind: "property", Language: "TypeScript", Parent: "Bar", ParentKind: "class"},
+	}
+	service := Service{
+		FetchTar: func(ctx context.Context, repo gitserver.Repo, commit api.CommitID) (io.ReadCloser, error) {
+			return createTar(map[string]string{"foo.go": "package foo"})
+		},
+		NewParser: func() (parsers.SymbolParser, error) {
+			return entriesParser(entries), nil
+		},
+		Path: tmpDir,
+	}
+	if err := service.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]struct {
+		args protocol.SearchArgs
+		want []string
+	}{
+		"kind": {
+			args: protocol.SearchArgs{Kinds: []string{"FUNC", "class"}},
+			want: []string{"bar.ts:Bar", "foo.go:NewBar", "foo.go:newBarImpl"},
+		},
+		"parent": {
+			args: protocol.SearchArgs{Parent: "bar", Kinds: []string{"method"}},
+			want: []string{"bar.ts:baz", "foo.go:Baz"},
+		},
+		"parent case sensitive": {
+			args: protocol.SearchArgs{Parent: "bar", IsCaseSensitive: true},
+			want: nil,
+		},
+		"language": {
+			args: protocol.SearchArgs{Languages: []string{"typescript"}, Query: "^ba"},
+			want: []string{"bar.ts:Bar", "bar.ts:baz"},
+		},
+		"exact": {
+			args: protocol.SearchArgs{Query: "bar", NameMatch: protocol.NameMatchExact},
+			want: []string{"bar.ts:Bar", "foo.go:Bar"},
+		},
+		"exact special characters": {
+			args: protocol.SearchArgs{Query: "a*b", NameMatch: protocol.NameMatchExact},
+			want: []string{"bar.ts:a*b"},
+		},
+		"prefix": {
+			args: protocol.SearchArgs{Query: "new", NameMatch: protocol.NameMatchPrefix},
+			want: []string{"foo.go:NewBar", "foo.go:newBarImpl"},
+		},
+		"prefix case sensitive": {
+			args: protocol.SearchArgs{Query: "new", NameMatch: protocol.NameMatchPrefix, IsCaseSensitive: true},
+			want: []string{"foo.go:newBarImpl"},
+		},
+		"prefix special characters": {
+			args: protocol.SearchArgs{Query: "a*", NameMatch: protocol.NameMatchPrefix},
+			want: []string{"bar.ts:a*b"},
+		},
+		"fuzzy": {
+			args: protocol.SearchArgs{Query: "nbr", NameMatch: protocol.NameMatchFuzzy},
+			want: []string{"foo.go:NewBar", "foo.go:newBarImpl"},
+		},
+	}
+	for label, test := range tests {
+		t.Run(label, func(t *testing.T) {
+			test.args.First = 10
+			result, err := service.search(context.Background(), test.args)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, s := range result.Symbols {
+				got = append(got, s.Path+":"+s.Name)
+			}
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+
+	if _, err := service.search(context.Background(), protocol.SearchArgs{NameMatch: "soundex"}); err == nil {
+		t.Error("got no error for an invalid NameMatch")
+	}
+}
+
+// entriesParser returns its entries for every file.
+type entriesParser []ctags.Entry
+
+func (p entriesParser) Parse(name string, content []byte) ([]ctags.Entry, error) {
+	return p, nil
+}
+
+func (entriesParser) Close() {}
@@ -9,7 +9,7 @@ import (
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
-	"github.com/sourcegraph/sourcegraph/cmd/symbols/internal/pkg/ctags"
+	"github.com/sourcegraph/sourcegraph/cmd/symbols/internal/pkg/parsers"
 	"github.com/sourcegraph/sourcegraph/internal/api"
 	"github.com/sourcegraph/sourcegraph/internal/diskcache"
 	"github.com/sourcegraph/sourcegraph/internal/gitserver"
@@ -26,7 +26,22 @@ type Service struct {
 	// to FetchTar. It defaults to 15.
 	MaxConcurrentFetchTar int
 
-	NewParser func() (ctags.Parser, error)
+	// FetchTarPaths is like FetchTar, but only includes the files at the specified paths.
+	FetchTarPaths func(context.Context, gitserver.Repo, api.CommitID, []string) (io.ReadCloser, error)
+
+	// ListAncestors returns up to n ancestors of a commit, most recent first.
+	ListAncestors func(ctx context.Context, repo api.RepoName, commit api.CommitID, n int) ([]api.CommitID, error)
+
+	// GitDiff returns the files changed between an ancestor commit and a
+	// commit.
+	//
+	// If FetchTarPaths, ListAncestors and GitDiff are set, the database of a
+	// commit is derived from the database of its nearest ancestor in the cache
+	// by parsing only the changed files. Otherwise all files are parsed.
+	GitDiff func(ctx context.Context, repo api.RepoName, ancestor, commit api.CommitID) (Changes, error)
+
+	// NewParser returns a new parser to extract symbols with.
+	NewParser func() (parsers.SymbolParser, error)
 
 	// NumParserProcesses is the maximum number of ctags parser child processes to run.
 	NumParserProcesses int
@@ -48,7 +63,7 @@ type Service struct {
 	fetchSem chan int
 
 	// pool of ctags parser child processes
-	parsers chan ctags.Parser
+	parsers chan parsers.SymbolParser
 }
 
 // Start must be called before any requests are handled.
@@ -81,6 +96,7 @@ func (s *Service) Handler() http.Handler {
 	mux := http.NewServeMux()
 
 	mux.HandleFunc("/search", s.handleSearch)
+	mux.HandleFunc("/list", s.handleList)
 	mux.HandleFunc("/healthz", s.handleHealthCheck)
 
 	return mux
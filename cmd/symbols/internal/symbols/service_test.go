@@ -12,6 +12,7 @@ import (
 	"testing"
 
 	"github.com/sourcegraph/sourcegraph/cmd/symbols/internal/pkg/ctags"
+	"github.com/sourcegraph/sourcegraph/cmd/symbols/internal/pkg/parsers"
 	"github.com/sourcegraph/sourcegraph/internal/api"
 	"github.com/sourcegraph/sourcegraph/internal/gitserver"
 	"github.com/sourcegraph/sourcegraph/internal/search"
@@ -22,6 +23,7 @@ import (
 
 func init() {
 	sqliteutil.SetLocalLibpath()
+	sqliteutil.MustRegisterSqlite3WithPcre()
 }
 
 func TestIsLiteralEquality(t *testing.T) {
@@ -57,8 +59,6 @@ func TestIsLiteralEquality(t *testing.T) {
 }
 
 func TestService(t *testing.T) {
-	sqliteutil.MustRegisterSqlite3WithPcre()
-
 	tmpDir, err := ioutil.TempDir("", "")
 	if err != nil {
 		t.Fatal(err)
@@ -70,7 +70,7 @@ func TestService(t *testing.T) {
 		FetchTar: func(ctx context.Context, repo gitserver.Repo, commit api.CommitID) (io.ReadCloser, error) {
 			return createTar(files)
 		},
-		NewParser: func() (ctags.Parser, error) {
+		NewParser: func() (parsers.SymbolParser, error) {
 			return mockParser{"x", "y"}, nil
 		},
 		Path: tmpDir,
@@ -141,6 +141,27 @@ func TestService(t *testing.T) {
 			}
 		})
 	}
+
+	t.Run("list", func(t *testing.T) {
+		result, err := client.List(context.Background(), protocol.ListArgs{First: 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := protocol.SearchResult{Symbols: []protocol.Symbol{x}}
+		if !reflect.DeepEqual(*result, want) {
+			t.Errorf("got %+v, want %+v", *result, want)
+		}
+
+		for path, want := range map[string][]protocol.Symbol{"a.js": {x, y}, "b.js": {}} {
+			result, err := client.List(context.Background(), protocol.ListArgs{Path: path})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(result.Symbols, want) {
+				t.Errorf("got symbols %+v of %s, want %+v", result.Symbols, path, want)
+			}
+		}
+	})
 }
 
 func createTar(files map[string]string) (io.ReadCloser, error) {
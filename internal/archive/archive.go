@@ -0,0 +1,149 @@
+// Package archive reads the files in the archives of a repository, such as
+// .jar, .zip and .tar.gz files. Searches report the files in an archive at
+// paths like lib/foo.jar!/com/x/Y.java.
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// PathSeparator separates the path of an archive from the path of a file in
+// it, as in lib/foo.jar!/com/x/Y.java.
+const PathSeparator = "!/"
+
+// Format is the format of an archive.
+type Format int
+
+const (
+	NotArchive Format = iota
+	Zip
+	Tar
+	TarGz
+)
+
+// FormatOf returns the format of the archive at path, determined by its
+// extension.
+func FormatOf(path string) Format {
+	path = strings.ToLower(path)
+	switch {
+	case strings.HasSuffix(path, ".zip"), strings.HasSuffix(path, ".jar"), strings.HasSuffix(path, ".war"), strings.HasSuffix(path, ".ear"):
+		return Zip
+	case strings.HasSuffix(path, ".tar"):
+		return Tar
+	case strings.HasSuffix(path, ".tar.gz"), strings.HasSuffix(path, ".tgz"):
+		return TarGz
+	}
+	return NotArchive
+}
+
+// SplitPath splits path into the path of the outermost archive and the path
+// of the file in it, which may itself be in an archive in the archive. ok is
+// false if path is not the path of a file in an archive.
+func SplitPath(path string) (archivePath, name string, ok bool) {
+	i := strings.Index(path, PathSeparator)
+	if i < 0 {
+		return path, "", false
+	}
+	return path[:i], path[i+len(PathSeparator):], true
+}
+
+// Walk calls fn for each regular file in the archive data, with its name,
+// size and content.
+func Walk(format Format, data []byte, fn func(name string, size int64, r io.Reader) error) error {
+	switch format {
+	case Zip:
+		zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			return err
+		}
+		for _, f := range zr.File {
+			if !f.Mode().IsRegular() {
+				continue
+			}
+			rc, err := f.Open()
+			if err != nil {
+				return err
+			}
+			err = fn(f.Name, int64(f.UncompressedSize64), rc)
+			rc.Close()
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case TarGz:
+		gr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return err
+		}
+		defer gr.Close()
+		return walkTar(tar.NewReader(gr), fn)
+
+	case Tar:
+		return walkTar(tar.NewReader(bytes.NewReader(data)), fn)
+	}
+	return errors.Errorf("unknown archive format %d", format)
+}
+
+func walkTar(tr *tar.Reader, fn func(name string, size int64, r io.Reader) error) error {
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if hdr.Typeflag != tar.TypeReg && hdr.Typeflag != tar.TypeRegA {
+			continue
+		}
+		if err := fn(hdr.Name, hdr.Size, tr); err != nil {
+			return err
+		}
+	}
+}
+
+// errFound stops the walk of an archive once the file read is found.
+var errFound = errors.New("found")
+
+// ReadFile returns the content of the file name in the archive data at path.
+// name may be in an archive in the archive, as in inner.zip!/a.txt. The
+// error satisfies os.IsNotExist if there is no such file.
+func ReadFile(path string, data []byte, name string) ([]byte, error) {
+	notExist := &os.PathError{Op: "open", Path: path + PathSeparator + name, Err: os.ErrNotExist}
+	for _, member := range strings.Split(name, PathSeparator) {
+		format := FormatOf(path)
+		if format == NotArchive {
+			return nil, notExist
+		}
+		var content []byte
+		err := Walk(format, data, func(file string, size int64, r io.Reader) error {
+			if file != member {
+				return nil
+			}
+			var err error
+			if content, err = ioutil.ReadAll(r); err != nil {
+				return err
+			}
+			return errFound
+		})
+		if err == nil {
+			return nil, notExist
+		}
+		if err != errFound {
+			return nil, errors.Wrapf(err, "reading archive %s", path)
+		}
+		path, data = path+PathSeparator+member, content
+	}
+	return data, nil
+}
@@ -0,0 +1,130 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"os"
+	"testing"
+)
+
+func TestFormatOf(t *testing.T) {
+	tests := map[string]Format{
+		"a.zip":       Zip,
+		"lib/A.JAR":   Zip,
+		"a.war":       Zip,
+		"a.tar":       Tar,
+		"a.tar.gz":    TarGz,
+		"a.tgz":       TarGz,
+		"a.gz":        NotArchive,
+		"zip":         NotArchive,
+		"a.jar!/b.go": NotArchive,
+	}
+	for path, want := range tests {
+		if got := FormatOf(path); got != want {
+			t.Errorf("%s: got %v, want %v", path, got, want)
+		}
+	}
+}
+
+func TestSplitPath(t *testing.T) {
+	tests := []struct {
+		path, archivePath, name string
+		ok                      bool
+	}{
+		{"a/b.go", "a/b.go", "", false},
+		{"lib/foo.jar!/com/x/Y.java", "lib/foo.jar", "com/x/Y.java", true},
+		{"a.zip!/inner.zip!/z.txt", "a.zip", "inner.zip!/z.txt", true},
+	}
+	for _, test := range tests {
+		archivePath, name, ok := SplitPath(test.path)
+		if archivePath != test.archivePath || name != test.name || ok != test.ok {
+			t.Errorf("%s: got (%q, %q, %v), want (%q, %q, %v)", test.path, archivePath, name, ok, test.archivePath, test.name, test.ok)
+		}
+	}
+}
+
+func TestReadFile(t *testing.T) {
+	inner := tarGzArchiveOf(t, map[string]string{"deep/z.txt": "z"})
+	jar := zipArchiveOf(t, map[string]string{
+		"com/x/Y.java": "class Y {}",
+		"inner.tgz":    inner,
+	})
+
+	tests := []struct {
+		name     string
+		want     string
+		notFound bool
+	}{
+		{name: "com/x/Y.java", want: "class Y {}"},
+		{name: "inner.tgz!/deep/z.txt", want: "z"},
+		{name: "com/x/Z.java", notFound: true},
+		{name: "inner.tgz!/deep/missing.txt", notFound: true},
+		{name: "com/x/Y.java!/a.txt", notFound: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := ReadFile("lib/foo.jar", []byte(jar), test.name)
+			if test.notFound {
+				if !os.IsNotExist(err) {
+					t.Fatalf("got error %v, want not found", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+
+	if _, err := ReadFile("lib/broken.zip", []byte("not a zip"), "a.txt"); err == nil || os.IsNotExist(err) {
+		t.Errorf("got error %v reading a broken archive, want a read error", err)
+	}
+}
+
+func zipArchiveOf(t *testing.T, files map[string]string) string {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func tarGzArchiveOf(t *testing.T, files map[string]string) string {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for name, content := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0600, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	var gzBuf bytes.Buffer
+	gw := gzip.NewWriter(&gzBuf)
+	if _, err := gw.Write(buf.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+	if err := gw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return gzBuf.String()
+}
@@ -281,6 +281,40 @@ func BrandName() string {
 	return branding.BrandName
 }
 
+// SearchHistoryEnabled reports whether the search queries of signed-in users
+// are recorded in their search history.
+func SearchHistoryEnabled() bool {
+	if v := Get().SearchHistoryEnabled; v != nil {
+		return *v
+	}
+	return true
+}
+
+// SearchHistoryRetentionDays returns the number of days search history
+// entries are kept.
+func SearchHistoryRetentionDays() int {
+	if v := Get().SearchHistoryRetentionDays; v > 0 {
+		return v
+	}
+	return 30
+}
+
+// GlobalSymbolsEnabled reports whether the global symbol index of default
+// branches is maintained and searched.
+func GlobalSymbolsEnabled() bool {
+	c := Get().SearchGlobalSymbols
+	return c != nil && c.Enabled
+}
+
+// GlobalSymbolsMaxSymbolsPerRepo returns the maximum number of symbols of a
+// repository in the global symbol index.
+func GlobalSymbolsMaxSymbolsPerRepo() int {
+	if c := Get().SearchGlobalSymbols; c != nil && c.MaxSymbolsPerRepo > 0 {
+		return c.MaxSymbolsPerRepo
+	}
+	return 100000
+}
+
 // SearchSymbolsParallelism returns 20, or the site config
 // "debug.search.symbolsParallelism" value if configured.
 func SearchSymbolsParallelism() int {
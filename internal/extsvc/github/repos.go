@@ -35,6 +35,30 @@ type Repository struct {
 	IsFork           bool   // whether the repository is a fork of another repository
 	IsArchived       bool   // whether the repository is archived on the code host
 	ViewerPermission string // ADMIN, WRITE, READ, or empty if unknown. Only the graphql api populates this. https://developer.github.com/v4/enum/repositorypermission/
+
+	// Topics are the topics of the repository. Only the REST API populates this.
+	Topics []string `json:",omitempty"`
+	// StargazerCount is the number of users who starred the repository.
+	StargazerCount int `json:",omitempty"`
+}
+
+// UnmarshalJSON decodes a repository of the REST or GraphQL API, or a
+// repository stored as JSON. The GraphQL API returns the number of stargazers
+// as the total count of the stargazers connection.
+func (r *Repository) UnmarshalJSON(data []byte) error {
+	type repository Repository
+	var v struct {
+		repository
+		Stargazers *struct{ TotalCount int }
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*r = Repository(v.repository)
+	if v.Stargazers != nil {
+		r.StargazerCount = v.Stargazers.TotalCount
+	}
+	return nil
 }
 
 // repositoryFieldsGraphQLFragment returns a GraphQL fragment that contains the fields needed to populate the
@@ -52,6 +76,7 @@ fragment RepositoryFields on Repository {
 	isFork
 	isArchived
 	viewerPermission
+	stargazers { totalCount }
 }
 	`
 	}
@@ -68,6 +93,7 @@ fragment RepositoryFields on Repository {
 	isPrivate
 	isFork
 	isArchived
+	stargazers { totalCount }
 }
 	`
 }
@@ -228,6 +254,8 @@ type restRepository struct {
 	Fork        bool
 	Archived    bool
 	Permissions restRepositoryPermissions `json:"permissions"`
+	Topics      []string                  `json:"topics"` // requires the mercy preview media type
+	Stargazers  int                       `json:"stargazers_count"`
 }
 
 // getRepositoryFromAPI attempts to fetch a repository from the GitHub API without use of the redis cache.
@@ -249,7 +277,7 @@ func (c *Client) getRepositoryFromAPI(ctx context.Context, owner, name string) (
 // convertRestRepo converts repo information returned by the rest API
 // to a standard format.
 func convertRestRepo(restRepo restRepository) *Repository {
-	return &Repository{
+	repo := &Repository{
 		ID:               restRepo.ID,
 		DatabaseID:       restRepo.DatabaseID,
 		NameWithOwner:    restRepo.FullName,
@@ -259,7 +287,12 @@ func convertRestRepo(restRepo restRepository) *Repository {
 		IsFork:           restRepo.Fork,
 		IsArchived:       restRepo.Archived,
 		ViewerPermission: convertRestRepoPermissions(restRepo.Permissions),
+		StargazerCount:   restRepo.Stargazers,
+	}
+	if len(restRepo.Topics) > 0 {
+		repo.Topics = restRepo.Topics
 	}
+	return repo
 }
 
 // convertRestRepoPermissions converts repo information returned by the rest API
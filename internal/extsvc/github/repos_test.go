@@ -371,14 +371,16 @@ func TestClient_ListOrgRepositories(t *testing.T) {
     "full_name": "o/r",
     "description": "d",
     "html_url": "https://github.example.com/o/r",
-    "fork": true
+    "fork": true,
+    "topics": ["payments", "go"]
   },
   {
     "node_id": "j",
     "full_name": "o/b",
     "description": "c",
     "html_url": "https://github.example.com/o/b",
-    "fork": false
+    "fork": false,
+    "topics": []
   }
 ]
 `}
@@ -391,6 +393,7 @@ func TestClient_ListOrgRepositories(t *testing.T) {
 			Description:   "d",
 			URL:           "https://github.example.com/o/r",
 			IsFork:        true,
+			Topics:        []string{"payments", "go"},
 		},
 		{
 			ID:            "j",
@@ -426,7 +429,7 @@ func repoListsAreEqual(a []*Repository, b []*Repository) bool {
 		return false
 	}
 	for i := 0; i < len(a); i++ {
-		if *a[i] != *b[i] {
+		if !reflect.DeepEqual(a[i], b[i]) {
 			return false
 		}
 	}
@@ -663,6 +666,7 @@ func TestClient_GetReposByNameWithOwner(t *testing.T) {
 		IsFork:           false,
 		IsArchived:       true,
 		ViewerPermission: "ADMIN",
+		StargazerCount:   42,
 	}
 
 	testCases := []struct {
@@ -697,7 +701,8 @@ func TestClient_GetReposByNameWithOwner(t *testing.T) {
       "isPrivate": true,
       "isFork": false,
       "isArchived": true,
-      "viewerPermission": "ADMIN"
+      "viewerPermission": "ADMIN",
+      "stargazers": {"totalCount": 42}
     }
   }
 }
@@ -27,6 +27,8 @@ type Project struct {
 	Visibility        Visibility     `json:"visibility"`                    // "private", "internal", or "public"
 	ForkedFromProject *ProjectCommon `json:"forked_from_project,omitempty"` // If non-nil, the project from which this project was forked
 	Archived          bool           `json:"archived"`
+	TagList           []string       `json:"tag_list,omitempty"` // the project's topics
+	StarCount         int            `json:"star_count,omitempty"`
 }
 
 type ProjectCommon struct {
@@ -0,0 +1,88 @@
+package backend
+
+import (
+	"strings"
+
+	"github.com/google/zoekt"
+)
+
+// maxIndexedBranches is the maximum number of branches Zoekt indexes for a
+// repository, including HEAD. Zoekt records the branches a file is in as a
+// 64-bit mask.
+const maxIndexedBranches = 64
+
+// IndexBranches returns the branches Zoekt should index for repo given the
+// search.index.branches site configuration: HEAD, followed by the configured
+// branches of repo.
+func IndexBranches(config map[string][]string, repo string) []string {
+	configured, ok := config[repo]
+	if !ok {
+		for name, branches := range config {
+			if strings.EqualFold(name, repo) {
+				configured = branches
+				break
+			}
+		}
+	}
+
+	branches := []string{"HEAD"}
+	seen := map[string]bool{"HEAD": true}
+	for _, branch := range configured {
+		branch = strings.TrimPrefix(branch, "refs/heads/")
+		if branch == "" || seen[branch] {
+			continue
+		}
+		if len(branches) == maxIndexedBranches {
+			break
+		}
+		seen[branch] = true
+		branches = append(branches, branch)
+	}
+	return branches
+}
+
+// ResolveBranches returns the branches of repo indexed by Zoekt that revSpecs
+// refer to. ok is false if one of revSpecs is not indexed, in which case the
+// revisions must be searched without the index.
+//
+// A revspec refers to an indexed branch if it is empty or HEAD (the default
+// branch), the name of the branch with or without the refs/heads/ prefix, or
+// a prefix of at least 4 characters of the commit the branch was indexed at.
+func ResolveBranches(repo *zoekt.Repository, revSpecs []string) (branches []zoekt.RepositoryBranch, ok bool) {
+	seen := map[string]bool{}
+	for _, revSpec := range revSpecs {
+		branch, ok := resolveBranch(repo, revSpec)
+		if !ok {
+			return nil, false
+		}
+		if !seen[branch.Name] {
+			seen[branch.Name] = true
+			branches = append(branches, branch)
+		}
+	}
+	return branches, true
+}
+
+func resolveBranch(repo *zoekt.Repository, revSpec string) (zoekt.RepositoryBranch, bool) {
+	if revSpec == "" {
+		revSpec = "HEAD"
+	}
+	name := strings.TrimPrefix(revSpec, "refs/heads/")
+	for _, branch := range repo.Branches {
+		if branch.Name == name {
+			return branch, true
+		}
+	}
+	if len(revSpec) < 4 {
+		// revSpec is shorter than the minimum 4 chars expected for a
+		// short SHA. It can't match a commit, maybe it refers to a
+		// one-character branch name.
+		return zoekt.RepositoryBranch{}, false
+	}
+	for _, branch := range repo.Branches {
+		if strings.HasPrefix(branch.Version, revSpec) {
+			return branch, true
+		}
+	}
+	return zoekt.RepositoryBranch{}, false
+}
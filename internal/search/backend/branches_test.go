@@ -0,0 +1,68 @@
+package backend
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/zoekt"
+)
+
+func TestIndexBranches(t *testing.T) {
+	many := make([]string, 100)
+	for i := range many {
+		many[i] = fmt.Sprintf("b%d", i)
+	}
+	config := map[string][]string{
+		"github.com/foo/bar": {"release-1", "refs/heads/release-2", "release-1", "HEAD", ""},
+		"github.com/foo/Baz": {"develop"},
+		"github.com/foo/big": many,
+	}
+
+	cases := []struct {
+		repo string
+		want []string
+	}{
+		{repo: "github.com/foo/bar", want: []string{"HEAD", "release-1", "release-2"}},
+		{repo: "github.com/foo/baz", want: []string{"HEAD", "develop"}},
+		{repo: "github.com/foo/other", want: []string{"HEAD"}},
+		{repo: "github.com/foo/big", want: append([]string{"HEAD"}, many[:63]...)},
+	}
+	for _, c := range cases {
+		got := IndexBranches(config, c.repo)
+		if diff := cmp.Diff(c.want, got); diff != "" {
+			t.Errorf("%s mismatch (-want +got):\n%s", c.repo, diff)
+		}
+	}
+}
+
+func TestResolveBranches(t *testing.T) {
+	head := zoekt.RepositoryBranch{Name: "HEAD", Version: "df3f4e499698e48152b39cd655d8901eaf583fa5"}
+	release := zoekt.RepositoryBranch{Name: "release-1", Version: "8ec975423738fe7851676083ebf660a062ed1578"}
+	repo := &zoekt.Repository{Name: "foo", Branches: []zoekt.RepositoryBranch{head, release}}
+
+	cases := []struct {
+		revSpecs []string
+		want     []zoekt.RepositoryBranch
+		wantOK   bool
+	}{
+		{revSpecs: []string{""}, want: []zoekt.RepositoryBranch{head}, wantOK: true},
+		{revSpecs: []string{"HEAD", ""}, want: []zoekt.RepositoryBranch{head}, wantOK: true},
+		{revSpecs: []string{"release-1"}, want: []zoekt.RepositoryBranch{release}, wantOK: true},
+		{revSpecs: []string{"refs/heads/release-1", "df3f4e"}, want: []zoekt.RepositoryBranch{release, head}, wantOK: true},
+		{revSpecs: []string{"8ec975423738fe7851676083ebf660a062ed1578"}, want: []zoekt.RepositoryBranch{release}, wantOK: true},
+		{revSpecs: []string{"8ec"}, wantOK: false},
+		{revSpecs: []string{"release-1", "release-2"}, wantOK: false},
+		{revSpecs: []string{"HEAD~1"}, wantOK: false},
+	}
+	for _, c := range cases {
+		got, ok := ResolveBranches(repo, c.revSpecs)
+		if ok != c.wantOK {
+			t.Errorf("%q: got ok %v, want %v", c.revSpecs, ok, c.wantOK)
+			continue
+		}
+		if diff := cmp.Diff(c.want, got); ok && diff != "" {
+			t.Errorf("%q mismatch (-want +got):\n%s", c.revSpecs, diff)
+		}
+	}
+}
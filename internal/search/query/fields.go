@@ -36,4 +36,10 @@ var allFields = map[string]struct{}{
 	FieldTimeout:            empty,
 	FieldReplace:            empty,
 	FieldCombyRule:          empty,
+	FieldSelect:             empty,
+	FieldMultiline:          empty,
+	FieldContext:            empty,
+	FieldSort:               empty,
+	FieldSymbolParent:       empty,
+	FieldSymbolMatch:        empty,
 }
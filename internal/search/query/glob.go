@@ -0,0 +1,276 @@
+package query
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/sourcegraph/sourcegraph/internal/search/query/syntax"
+)
+
+// globFields are the fields whose values are globs when globbing is enabled.
+var globFields = map[string]struct{}{
+	FieldRepo:        {},
+	FieldFile:        {},
+	FieldRepoHasFile: {},
+}
+
+// globToRegexp translates a glob to the regular expression matching the same
+// file paths or repository names:
+//
+//	Glob    Matches
+//	*       any sequence of characters except /
+//	**      any sequence of characters, and **/ any sequence of path
+//	        components, including none
+//	?       any character except /
+//	[a-z]   a character in the class, [!a-z] one not in the class
+//	{a,b}   any of the comma-separated globs
+//	\c      the character c
+//
+// A glob without any of these matches anywhere, like the equivalent regular
+// expression, so that repo:acme keeps matching github.com/acme/api. Any other
+// glob matches whole path components: *.go matches a/b.go but not a/b.gob. A
+// glob starting with / matches at the start of the path.
+func globToRegexp(glob string) (string, error) {
+	anchored := strings.HasPrefix(glob, "/")
+	body, _, wildcards, err := translateGlob(strings.TrimPrefix(glob, "/"), false)
+	if err != nil {
+		return "", fmt.Errorf("invalid glob %q: %s", glob, err)
+	}
+	switch {
+	case anchored && wildcards:
+		return "^" + body + "(/|$)", nil
+	case anchored:
+		return "^" + body, nil
+	case wildcards:
+		return "(^|/)" + body + "(/|$)", nil
+	}
+	return body, nil
+}
+
+// translateGlob translates glob to a regular expression without anchors and
+// reports whether glob contains wildcards. If inBraces is true, glob follows
+// the "{" or "," of a brace expression, and the translation stops at the ","
+// or "}" that ends the alternative. It returns the number of bytes of glob
+// translated.
+func translateGlob(glob string, inBraces bool) (re string, n int, wildcards bool, err error) {
+	var b strings.Builder
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch {
+		case c == '\\':
+			if i+1 == len(glob) {
+				return "", 0, false, errors.New("trailing backslash")
+			}
+			i++
+			b.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		case c == '*' && strings.HasPrefix(glob[i:], "**/"):
+			wildcards = true
+			b.WriteString("(.*/)?")
+			i += 2
+		case c == '*' && strings.HasPrefix(glob[i:], "**"):
+			wildcards = true
+			b.WriteString(".*")
+			i++
+		case c == '*':
+			wildcards = true
+			b.WriteString("[^/]*")
+		case c == '?':
+			wildcards = true
+			b.WriteString("[^/]")
+		case c == '[':
+			class, n, err := translateGlobClass(glob[i:])
+			if err != nil {
+				return "", 0, false, err
+			}
+			wildcards = true
+			b.WriteString(class)
+			i += n - 1
+		case c == '{' && strings.HasPrefix(glob[i:], "{}"):
+			b.WriteString(`\{\}`)
+			i++
+		case c == '{':
+			alternatives, n, err := translateGlobBraces(glob[i+1:])
+			if err != nil {
+				return "", 0, false, err
+			}
+			wildcards = true
+			b.WriteString("(" + strings.Join(alternatives, "|") + ")")
+			i += n
+		case inBraces && (c == ',' || c == '}'):
+			return b.String(), i, wildcards, nil
+		default:
+			b.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		}
+	}
+	if inBraces {
+		return "", 0, false, errors.New("unterminated {")
+	}
+	return b.String(), len(glob), wildcards, nil
+}
+
+// translateGlobBraces translates the comma-separated alternatives of a brace
+// expression. glob starts after the opening "{". It returns the number of
+// bytes of glob translated, including the closing "}".
+func translateGlobBraces(glob string) ([]string, int, error) {
+	var alternatives []string
+	for i := 0; ; i++ {
+		re, n, _, err := translateGlob(glob[i:], true)
+		if err != nil {
+			return nil, 0, err
+		}
+		alternatives = append(alternatives, re)
+		i += n
+		if glob[i] == '}' {
+			return alternatives, i + 1, nil
+		}
+	}
+}
+
+// translateGlobClass translates the character class at the start of glob. It
+// returns the number of bytes of glob consumed. Like "*", classes never
+// match "/".
+func translateGlobClass(glob string) (string, int, error) {
+	var b strings.Builder
+	b.WriteString("[")
+	i := 1
+	if i < len(glob) && (glob[i] == '!' || glob[i] == '^') {
+		b.WriteString("^/")
+		i++
+	}
+	start := i
+	for ; i < len(glob); i++ {
+		c := glob[i]
+		switch {
+		case c == ']' && i > start:
+			b.WriteString("]")
+			return b.String(), i + 1, nil
+		case c == '\\':
+			if i+1 == len(glob) {
+				return "", 0, errors.New("trailing backslash")
+			}
+			i++
+			b.WriteString(`\` + glob[i:i+1])
+		case c == '-' && i > start && i+1 < len(glob) && glob[i+1] != ']':
+			b.WriteByte('-')
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9', c >= 0x80:
+			b.WriteByte(c)
+		default:
+			// Escape punctuation, which may have a special meaning in a
+			// regular expression character class, such as "[" or "^".
+			b.WriteString(`\` + glob[i:i+1])
+		}
+	}
+	return "", 0, errors.New("unterminated [")
+}
+
+// globValueToRegexp translates the value of a field in globFields to a
+// regular expression. The revisions of repo: values and repo: predicates are
+// not globs and are left as is.
+func globValueToRegexp(field, value string) (string, error) {
+	if field != FieldRepo {
+		return globToRegexp(value)
+	}
+	if looksLikeRepoPredicate(value) {
+		return value, nil
+	}
+	name, rev := value, ""
+	if i := strings.IndexByte(value, '@'); i >= 0 {
+		name, rev = value[:i], value[i:]
+	}
+	re, err := globToRegexp(name)
+	return re + rev, err
+}
+
+// MayContainGlobs reports whether a query may contain values of fields in
+// globFields that mean something else as globs than as regular expressions,
+// such as repo:*/api. Queries that can't be parsed may contain globs.
+func MayContainGlobs(input string) bool {
+	parseTree, err := Parse(input)
+	if err != nil {
+		return true
+	}
+	for _, expr := range parseTree {
+		field := expr.Field
+		if resolved, ok := conf.FieldAliases[field]; ok {
+			field = resolved
+		}
+		if _, ok := globFields[field]; !ok {
+			continue
+		}
+		value := expr.Value
+		if expr.ValueType == syntax.TokenQuoted {
+			if value, err = unquoteValue(value); err != nil {
+				return true
+			}
+		}
+		if re, err := globValueToRegexp(field, value); err != nil || re != value {
+			return true
+		}
+	}
+	return false
+}
+
+// substituteGlobsInParseTree replaces the glob values of the expressions in
+// globFields with the equivalent regular expressions.
+func substituteGlobsInParseTree(parseTree syntax.ParseTree) error {
+	for _, expr := range parseTree {
+		field := expr.Field
+		if resolved, ok := conf.FieldAliases[field]; ok {
+			field = resolved
+		}
+		if _, ok := globFields[field]; !ok {
+			continue
+		}
+		switch expr.ValueType {
+		case syntax.TokenLiteral:
+			re, err := globValueToRegexp(field, expr.Value)
+			if err != nil {
+				return &ValidationError{Msg: err.Error()}
+			}
+			expr.Value = re
+		case syntax.TokenQuoted:
+			value, err := unquoteValue(expr.Value)
+			if err != nil {
+				// The typechecker reports invalid quoted values.
+				continue
+			}
+			re, err := globValueToRegexp(field, value)
+			if err != nil {
+				return &ValidationError{Msg: err.Error()}
+			}
+			expr.Value = strconv.Quote(re)
+		}
+	}
+	return nil
+}
+
+// unquoteValue unquotes a quoted query value, which like in the typechecker
+// may be quoted with double or single quotes.
+func unquoteValue(s string) (string, error) {
+	if len(s) >= 2 && s[0] == '\'' && s[len(s)-1] == '\'' {
+		s = `"` + strings.ReplaceAll(s[1:len(s)-1], `"`, `\"`) + `"`
+	}
+	return strconv.Unquote(s)
+}
+
+// substituteGlobs replaces the glob values of the parameters in globFields
+// with the equivalent regular expressions.
+func substituteGlobs(nodes []Node) ([]Node, error) {
+	var err error
+	nodes = MapParameter(nodes, func(field, value string, negated bool) Node {
+		if _, ok := globFields[field]; !ok || err != nil {
+			return Parameter{Field: field, Value: value, Negated: negated}
+		}
+		var re string
+		re, err = globValueToRegexp(field, value)
+		if err != nil {
+			err = &ValidationError{Msg: err.Error()}
+			return Parameter{Field: field, Value: value, Negated: negated}
+		}
+		return Parameter{Field: field, Value: re, Negated: negated}
+	})
+	return nodes, err
+}
@@ -0,0 +1,242 @@
+package query
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestGlobToRegexp(t *testing.T) {
+	cases := []struct {
+		glob string
+		want string
+	}{
+		{glob: ``, want: ``},
+		{glob: `acme`, want: `acme`},
+		{glob: `github.com/acme`, want: `github\.com/acme`},
+		{glob: `/cmd`, want: `^cmd`},
+		{glob: `*.go`, want: `(^|/)[^/]*\.go(/|$)`},
+		{glob: `**/*.proto`, want: `(^|/)(.*/)?[^/]*\.proto(/|$)`},
+		{glob: `services/*/api`, want: `(^|/)services/[^/]*/api(/|$)`},
+		{glob: `/cmd/**`, want: `^cmd/.*(/|$)`},
+		{glob: `a**b`, want: `(^|/)a.*b(/|$)`},
+		{glob: `a?c`, want: `(^|/)a[^/]c(/|$)`},
+		{glob: `[a-c].md`, want: `(^|/)[a-c]\.md(/|$)`},
+		{glob: `[!a-c].md`, want: `(^|/)[^/a-c]\.md(/|$)`},
+		{glob: `[^a]`, want: `(^|/)[^/a](/|$)`},
+		{glob: `[]a]`, want: `(^|/)[\]a](/|$)`},
+		{glob: `[a-]`, want: `(^|/)[a\-](/|$)`},
+		{glob: `*.{js,jsx}`, want: `(^|/)[^/]*\.(js|jsx)(/|$)`},
+		{glob: `{a,{b,c}d}`, want: `(^|/)(a|(b|c)d)(/|$)`},
+		{glob: `{,x}y`, want: `(^|/)(|x)y(/|$)`},
+		{glob: `a{}b`, want: `a\{\}b`},
+		{glob: `a,b}`, want: `a,b\}`},
+		{glob: `a\*b`, want: `a\*b`},
+		{glob: `a\\b`, want: `a\\b`},
+	}
+	for _, c := range cases {
+		got, err := globToRegexp(c.glob)
+		if err != nil {
+			t.Errorf("globToRegexp(%q): %s", c.glob, err)
+			continue
+		}
+		if got != c.want {
+			t.Errorf("globToRegexp(%q) = %q, want %q", c.glob, got, c.want)
+		}
+		if _, err := regexp.Compile(got); err != nil {
+			t.Errorf("globToRegexp(%q) = %q, which does not compile: %s", c.glob, got, err)
+		}
+	}
+
+	for glob, wantErr := range map[string]string{
+		`a\`:     `invalid glob "a\\": trailing backslash`,
+		`[ab`:    `invalid glob "[ab": unterminated [`,
+		`[a\`:    `invalid glob "[a\\": trailing backslash`,
+		`{a,b`:   `invalid glob "{a,b": unterminated {`,
+		`{a,{b}`: `invalid glob "{a,{b}": unterminated {`,
+	} {
+		if _, err := globToRegexp(glob); err == nil || err.Error() != wantErr {
+			t.Errorf("globToRegexp(%q) returned error %v, want %q", glob, err, wantErr)
+		}
+	}
+}
+
+func TestMayContainGlobs(t *testing.T) {
+	cases := map[string]bool{
+		`foo`:                   false,
+		`repo:acme foo`:         false,
+		`r:acme/api@v1 lang:go`: false,
+		`file:"a b" foo`:        false,
+		`repo:*/api foo`:        true,
+		`f:*.go foo`:            true,
+		`repohasfile:/go.mod`:   true,
+		`repo:github.com/acme`:  true,
+		`repo:"**/api"`:         true,
+		`repo:(`:                true,
+	}
+	for input, want := range cases {
+		if got := MayContainGlobs(input); got != want {
+			t.Errorf("MayContainGlobs(%q) = %v, want %v", input, got, want)
+		}
+	}
+}
+
+func TestGlobToRegexp_matches(t *testing.T) {
+	cases := []struct {
+		glob         string
+		matches, not []string
+	}{
+		{
+			glob:    `*.go`,
+			matches: []string{"main.go", "cmd/main.go"},
+			not:     []string{"main.gob", "main_go", "main.go.txt"},
+		},
+		{
+			glob:    `**/*.proto`,
+			matches: []string{"a.proto", "api/v1/a.proto"},
+			not:     []string{"a.protox", "a_proto"},
+		},
+		{
+			glob:    `/cmd/*/main.go`,
+			matches: []string{"cmd/frontend/main.go"},
+			not:     []string{"x/cmd/frontend/main.go", "cmd/a/b/main.go"},
+		},
+		{
+			glob:    `services/*/api`,
+			matches: []string{"github.com/acme/services/billing/api", "services/x/api/v1"},
+			not:     []string{"github.com/acme/services/billing/apis", "services/a/b/api"},
+		},
+		{
+			glob:    `acme`,
+			matches: []string{"github.com/acme/api", "github.com/acmeinc/api"},
+			not:     []string{"github.com/ACMX/api"},
+		},
+		{
+			glob:    `[!.]*`,
+			matches: []string{"a/b", "README"},
+			not:     []string{".git", ".env"},
+		},
+	}
+	for _, c := range cases {
+		re, err := globToRegexp(c.glob)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rx := regexp.MustCompile(re)
+		for _, s := range c.matches {
+			if !rx.MatchString(s) {
+				t.Errorf("glob %q (%s) does not match %q", c.glob, re, s)
+			}
+		}
+		for _, s := range c.not {
+			if rx.MatchString(s) {
+				t.Errorf("glob %q (%s) matches %q", c.glob, re, s)
+			}
+		}
+	}
+}
+
+// TestGlobToRegexp_escaping checks that every ASCII punctuation character
+// that is not a glob wildcard matches only itself, outside and inside of
+// character classes, and that escaped wildcards match only themselves.
+func TestGlobToRegexp_escaping(t *testing.T) {
+	var punctuation []byte
+	for c := byte(0x21); c < 0x7f; c++ {
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9') {
+			punctuation = append(punctuation, c)
+		}
+	}
+
+	for _, c := range punctuation {
+		s := "x" + string(c) + "y"
+
+		var glob string
+		switch c {
+		case '*', '?', '[', '{', '\\':
+			glob = `x\` + string(c) + "y"
+		default:
+			glob = s
+		}
+		assertGlobMatchesOnly(t, glob, s)
+
+		// Any character can be escaped.
+		assertGlobMatchesOnly(t, `x\`+string(c)+"y", s)
+
+		// In a character class, punctuation other than "]" and "\" (and "!"
+		// or "^" at the start, and "-" between characters) is literal.
+		switch c {
+		case ']', '\\':
+			assertGlobMatchesOnly(t, `x[\`+string(c)+"]y", s)
+		case '!', '^':
+			assertGlobMatchesOnly(t, "x[a"+string(c)+"]y", s)
+		default:
+			assertGlobMatchesOnly(t, "x["+string(c)+"]y", s)
+		}
+	}
+}
+
+func assertGlobMatchesOnly(t *testing.T, glob, s string) {
+	t.Helper()
+	re, err := globToRegexp(glob)
+	if err != nil {
+		t.Errorf("globToRegexp(%q): %s", glob, err)
+		return
+	}
+	rx, err := regexp.Compile(re)
+	if err != nil {
+		t.Errorf("globToRegexp(%q) = %q, which does not compile: %s", glob, re, err)
+		return
+	}
+	if !rx.MatchString(s) {
+		t.Errorf("glob %q (%s) does not match %q", glob, re, s)
+	}
+	// A string that differs only in the escaped character must not match.
+	replacement := "_"
+	if s[1] == '_' {
+		replacement = "-"
+	}
+	if other := s[:1] + replacement + s[2:]; rx.MatchString(other) {
+		t.Errorf("glob %q (%s) matches %q", glob, re, other)
+	}
+}
+
+func TestProcessWithGlobbing(t *testing.T) {
+	q, err := ProcessWithOptions(`file:*.go -file:**/testdata/** repo:acme/*@v1 repo:has.topic(x) f:"my dir/*"`, SearchTypeRegex, ProcessOptions{Globbing: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	files, notFiles := q.RegexpPatterns(FieldFile)
+	repos, _ := q.RegexpPatterns(FieldRepo)
+	got := map[string][]string{"file": files, "-file": notFiles, "repo": repos}
+	want := map[string][]string{
+		"file":  {`(^|/)[^/]*\.go(/|$)`, `(^|/)my dir/[^/]*(/|$)`},
+		"-file": {`(^|/)(.*/)?testdata/.*(/|$)`},
+		"repo":  {`(^|/)acme/[^/]*(/|$)@v1`, `has.topic(x)`},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("mismatch (-want, +got):\n%s", diff)
+	}
+
+	if _, err := ProcessWithOptions(`file:{a,b`, SearchTypeRegex, ProcessOptions{Globbing: true}); err == nil {
+		t.Error("expected an error for an invalid glob")
+	}
+	if _, err := ProcessWithOptions(`file:*.go`, SearchTypeRegex, ProcessOptions{}); err == nil {
+		t.Error("expected an error for a glob without globbing")
+	}
+}
+
+func TestProcessAndOrWithGlobbing(t *testing.T) {
+	q, err := ProcessAndOrWithOptions(`(foo or bar) file:*.go -repo:acme/*`, ProcessOptions{Globbing: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	files, _ := q.RegexpPatterns(FieldFile)
+	_, notRepos := q.RegexpPatterns(FieldRepo)
+	if diff := cmp.Diff([]string{`(^|/)[^/]*\.go(/|$)`}, files); diff != "" {
+		t.Errorf("file mismatch (-want, +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{`(^|/)acme/[^/]*(/|$)`}, notRepos); diff != "" {
+		t.Errorf("repo mismatch (-want, +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,606 @@
+package query
+
+import (
+	"fmt"
+	"regexp"
+	rxsyntax "regexp/syntax"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/sourcegraph/sourcegraph/internal/lazyregexp"
+	"github.com/sourcegraph/sourcegraph/internal/search/query/syntax"
+	"github.com/sourcegraph/sourcegraph/internal/search/query/types"
+)
+
+// DiagnosticSeverity is the severity of a problem found in a query.
+type DiagnosticSeverity string
+
+const (
+	// SeverityError is the severity of problems that prevent a query from
+	// running.
+	SeverityError DiagnosticSeverity = "error"
+	// SeverityWarning is the severity of likely mistakes in a valid query.
+	SeverityWarning DiagnosticSeverity = "warning"
+)
+
+// Codes identifying the kinds of problems found by Lint.
+const (
+	DiagnosticInvalidQuery          = "invalid-query"
+	DiagnosticUnbalancedParentheses = "unbalanced-parentheses"
+	DiagnosticNeverMatches          = "never-matches"
+	DiagnosticGlobPattern           = "glob-pattern"
+	DiagnosticRedundantRepo         = "redundant-repo"
+)
+
+// Range is a range [Start, End) of byte offsets in a query.
+type Range struct {
+	Start, End int
+}
+
+// Fix is a rewritten query that addresses the problem of a diagnostic.
+type Fix struct {
+	Description string
+	Query       string
+}
+
+// Diagnostic describes a problem found in a query.
+type Diagnostic struct {
+	Severity DiagnosticSeverity
+	Code     string // one of the Diagnostic* codes
+	Message  string
+	Range    Range
+	Fixes    []Fix
+}
+
+// Lint checks a query of the given search type for problems that are either
+// errors only reported tersely by Process or likely mistakes in a valid
+// query. Diagnostics are ordered by their position in the query.
+func Lint(input string, searchType SearchType, opts ProcessOptions) []Diagnostic {
+	var diagnostics []Diagnostic
+	if searchType != SearchTypeLiteral {
+		diagnostics = append(diagnostics, lintParentheses(input, searchType)...)
+	}
+
+	exprs := lintExprs(input, opts)
+	for _, e := range exprs {
+		if (e.field == FieldFile || e.field == FieldRepoHasFile) && !opts.Globbing {
+			if d, ok := lintGlob(input, e); ok {
+				diagnostics = append(diagnostics, d)
+				continue
+			}
+		}
+		if d, ok := lintNeverMatches(input, e, searchType); ok {
+			diagnostics = append(diagnostics, d)
+		}
+	}
+	diagnostics = append(diagnostics, lintRedundantRepos(input, exprs)...)
+
+	sort.SliceStable(diagnostics, func(i, j int) bool {
+		return diagnostics[i].Range.Start < diagnostics[j].Range.Start
+	})
+	return diagnostics
+}
+
+// ErrorDiagnostic returns a diagnostic for an error returned when processing
+// a query of the given search type. Literal queries are rewritten before they
+// are processed, so the positions of their errors do not refer to input and
+// their diagnostics apply to the whole query.
+func ErrorDiagnostic(input string, err error, searchType SearchType) Diagnostic {
+	d := Diagnostic{
+		Severity: SeverityError,
+		Code:     DiagnosticInvalidQuery,
+		Message:  err.Error(),
+		Range:    Range{Start: 0, End: len(input)},
+	}
+	pos := -1
+	switch e := err.(type) {
+	case *syntax.ParseError:
+		d.Message = e.Msg
+		pos = e.Pos
+		d.Fixes = quotedFixes(input)
+	case *types.TypeError:
+		d.Message = e.Err.Error()
+		pos = e.Pos
+		if _, ok := e.Err.(*rxsyntax.Error); ok {
+			d.Fixes = quotedFixes(input)
+		}
+	case *ValidationError:
+		d.Message = e.Msg
+	}
+	if pos < 0 || searchType == SearchTypeLiteral {
+		return d
+	}
+	d.Range = Range{Start: pos, End: pos + 1}
+	for _, e := range lintExprs(input, ProcessOptions{}) {
+		if e.expr.Pos == pos {
+			d.Range = e.rng
+		}
+	}
+	if d.Range.End > len(input) {
+		d.Range.End = len(input)
+	}
+	return d
+}
+
+// quotedFixes proposes quoting the parts of input that cannot be parsed, and
+// quoting all of input, to search for them literally.
+func quotedFixes(input string) []Fix {
+	var fixes []Fix
+	if q := syntax.ParseAllowingErrors(input).WithErrorsQuoted().String(); q != input {
+		fixes = append(fixes, Fix{Description: "Quote the invalid parts of the query", Query: q})
+	}
+	return append(fixes, Fix{Description: "Quote the whole query", Query: strconv.Quote(input)})
+}
+
+// lintExpr is an expression of a query with its resolved field and range.
+type lintExpr struct {
+	expr  *syntax.Expr
+	field string // the field name, with aliases resolved
+	rng   Range  // the range of the expression, including its negation
+	value Range  // the range of the raw value of the expression
+	glob  bool   // the value is a glob
+}
+
+func lintExprs(input string, opts ProcessOptions) []lintExpr {
+	var exprs []lintExpr
+	for _, expr := range syntax.ParseAllowingErrors(input) {
+		field := strings.ToLower(expr.Field)
+		if resolved, ok := conf.FieldAliases[field]; ok {
+			field = resolved
+		}
+		e := lintExpr{expr: expr, field: field}
+		if _, ok := globFields[field]; ok && opts.Globbing && expr.ValueType != syntax.TokenPattern {
+			e.glob = true
+		}
+		e.rng = Range{Start: expr.Pos, End: expr.Pos + len(expr.String())}
+		if expr.Not {
+			// Pos is the position of the expression after its "-".
+			e.rng.End--
+			e.rng.Start--
+		}
+		e.value = Range{Start: expr.Pos, End: e.rng.End}
+		if expr.Field != "" {
+			e.value.Start += len(expr.Field) + 1
+		}
+		if e.rng.Start < 0 || e.rng.End > len(input) {
+			continue
+		}
+		exprs = append(exprs, e)
+	}
+	return exprs
+}
+
+// regexpValue returns the regular expression that the value of e is
+// interpreted as, if any.
+func (e lintExpr) regexpValue(searchType SearchType) (string, bool) {
+	if e.field == FieldDefault && searchType != SearchTypeRegex {
+		return "", false
+	}
+	fieldType, ok := conf.FieldTypes[e.field]
+	if !ok {
+		return "", false
+	}
+	var value string
+	switch e.expr.ValueType {
+	case syntax.TokenLiteral, syntax.TokenPattern:
+		value, ok = e.expr.Value, fieldType.Literal == types.RegexpType
+	case syntax.TokenQuoted:
+		var err error
+		value, err = unquoteValue(e.expr.Value)
+		ok = err == nil && fieldType.Quoted == types.RegexpType
+	default:
+		return "", false
+	}
+	if ok && e.glob {
+		re, err := globValueToRegexp(e.field, value)
+		return re, err == nil
+	}
+	return value, ok
+}
+
+// replaceRange returns input with the range r replaced by s.
+func replaceRange(input string, r Range, s string) string {
+	return input[:r.Start] + s + input[r.End:]
+}
+
+// removeRange returns input without the range r and the whitespace that
+// separated it from the rest of the query.
+func removeRange(input string, r Range) string {
+	before := strings.TrimRightFunc(input[:r.Start], unicode.IsSpace)
+	after := strings.TrimLeftFunc(input[r.End:], unicode.IsSpace)
+	if before == "" || after == "" {
+		return before + after
+	}
+	return before + " " + after
+}
+
+// lintParentheses reports parentheses without a matching parenthesis.
+// Parentheses in quoted values, in character classes and escaped ones do not
+// need a match.
+func lintParentheses(input string, searchType SearchType) []Diagnostic {
+	var (
+		open        []int // offsets of the unmatched opening parentheses
+		unmatched   []int
+		inClass     bool
+		tokenStart  = true
+		quote       byte
+		inQuote     bool
+		escapeNext  bool
+		classOpened int
+	)
+	for i := 0; i < len(input); i++ {
+		c := input[i]
+		switch {
+		case escapeNext:
+			escapeNext = false
+		case c == '\\':
+			escapeNext = true
+		case inQuote:
+			if c == quote {
+				inQuote = false
+			}
+		case inClass:
+			// A "]" right after "[" or "[^" is a literal.
+			if c == ']' && i > classOpened+1 && !(i == classOpened+2 && input[classOpened+1] == '^') {
+				inClass = false
+			}
+		case tokenStart && (c == '"' || c == '\''):
+			inQuote, quote = true, c
+		case c == '[':
+			inClass, classOpened = true, i
+		case c == '(':
+			open = append(open, i)
+		case c == ')':
+			if len(open) == 0 {
+				unmatched = append(unmatched, i)
+			} else {
+				open = open[:len(open)-1]
+			}
+		}
+		tokenStart = c == ' ' || c == '\t' || c == '\n' || c == ':' || c == '-'
+	}
+	unmatched = append(unmatched, open...)
+	sort.Ints(unmatched)
+
+	diagnostics := make([]Diagnostic, 0, len(unmatched))
+	for _, i := range unmatched {
+		d := Diagnostic{
+			Severity: SeverityError,
+			Code:     DiagnosticUnbalancedParentheses,
+			Message:  "unmatched closing parenthesis",
+			Range:    Range{Start: i, End: i + 1},
+		}
+		if input[i] == '(' {
+			d.Message = "unmatched opening parenthesis"
+		}
+		if searchType == SearchTypeRegex {
+			d.Fixes = []Fix{{
+				Description: "Escape the parenthesis to match it literally",
+				Query:       replaceRange(input, d.Range, `\`+input[i:i+1]),
+			}}
+		} else {
+			d.Fixes = []Fix{{
+				Description: "Remove the parenthesis",
+				Query:       replaceRange(input, d.Range, ""),
+			}}
+		}
+		diagnostics = append(diagnostics, d)
+	}
+	return diagnostics
+}
+
+// lintNeverMatches reports regular expressions that cannot match anything.
+func lintNeverMatches(input string, e lintExpr, searchType SearchType) (Diagnostic, bool) {
+	value, ok := e.regexpValue(searchType)
+	if !ok {
+		return Diagnostic{}, false
+	}
+	re, err := rxsyntax.Parse(value, rxsyntax.Perl)
+	if err != nil || !neverMatches(re) {
+		return Diagnostic{}, false
+	}
+	message := fmt.Sprintf("the regular expression %q can never match", value)
+	if e.expr.Not {
+		message += ", so it excludes nothing"
+	} else {
+		message += ", so the query has no results"
+	}
+	return Diagnostic{
+		Severity: SeverityWarning,
+		Code:     DiagnosticNeverMatches,
+		Message:  message,
+		Range:    e.rng,
+		Fixes: []Fix{{
+			Description: "Remove " + input[e.rng.Start:e.rng.End],
+			Query:       removeRange(input, e.rng),
+		}},
+	}, true
+}
+
+// neverMatches reports whether no input matches re. It recognizes empty
+// character classes and characters that must be matched after the end or
+// before the beginning of a line. It may report false for other regular
+// expressions that never match.
+func neverMatches(re *rxsyntax.Regexp) bool {
+	switch re.Op {
+	case rxsyntax.OpNoMatch:
+		return true
+	case rxsyntax.OpCharClass:
+		return len(re.Rune) == 0
+	case rxsyntax.OpCapture, rxsyntax.OpPlus:
+		return neverMatches(re.Sub[0])
+	case rxsyntax.OpRepeat:
+		return re.Min > 0 && neverMatches(re.Sub[0])
+	case rxsyntax.OpAlternate:
+		for _, sub := range re.Sub {
+			if !neverMatches(sub) {
+				return false
+			}
+		}
+		return true
+	case rxsyntax.OpConcat:
+		for _, sub := range re.Sub {
+			if neverMatches(sub) {
+				return true
+			}
+		}
+		return consumesAcrossAnchor(re.Sub, true) || consumesAcrossAnchor(re.Sub, false)
+	}
+	return false
+}
+
+// consumesAcrossAnchor reports whether the concatenation of subs must match a
+// character other than a newline after an end of line anchor (forward) or
+// before a beginning of line anchor (!forward).
+func consumesAcrossAnchor(subs []*rxsyntax.Regexp, forward bool) bool {
+	anchored := false
+	for i := range subs {
+		sub := subs[i]
+		if !forward {
+			sub = subs[len(subs)-1-i]
+		}
+		switch sub.Op {
+		case rxsyntax.OpEndLine, rxsyntax.OpEndText:
+			if forward {
+				anchored = true
+			}
+			continue
+		case rxsyntax.OpBeginLine, rxsyntax.OpBeginText:
+			if !forward {
+				anchored = true
+			}
+			continue
+		}
+		canBeEmpty, noNewline := edge(sub, forward)
+		if !noNewline {
+			anchored = false
+			continue
+		}
+		if anchored && !canBeEmpty {
+			return true
+		}
+	}
+	return false
+}
+
+// edge reports whether re can match the empty string, and whether every
+// non-empty match of re starts (forward) or ends (!forward) with a character
+// other than a newline.
+func edge(re *rxsyntax.Regexp, forward bool) (canBeEmpty, noNewline bool) {
+	switch re.Op {
+	case rxsyntax.OpLiteral:
+		if len(re.Rune) == 0 {
+			return true, true
+		}
+		r := re.Rune[0]
+		if !forward {
+			r = re.Rune[len(re.Rune)-1]
+		}
+		return false, r != '\n'
+	case rxsyntax.OpCharClass:
+		for i := 0; i+1 < len(re.Rune); i += 2 {
+			if re.Rune[i] <= '\n' && '\n' <= re.Rune[i+1] {
+				return false, false
+			}
+		}
+		return false, true
+	case rxsyntax.OpAnyCharNotNL, rxsyntax.OpNoMatch:
+		return false, true
+	case rxsyntax.OpAnyChar:
+		return false, false
+	case rxsyntax.OpEmptyMatch, rxsyntax.OpBeginLine, rxsyntax.OpEndLine, rxsyntax.OpBeginText,
+		rxsyntax.OpEndText, rxsyntax.OpWordBoundary, rxsyntax.OpNoWordBoundary:
+		return true, true
+	case rxsyntax.OpCapture:
+		return edge(re.Sub[0], forward)
+	case rxsyntax.OpStar, rxsyntax.OpQuest:
+		_, noNewline := edge(re.Sub[0], forward)
+		return true, noNewline
+	case rxsyntax.OpPlus:
+		return edge(re.Sub[0], forward)
+	case rxsyntax.OpRepeat:
+		canBeEmpty, noNewline := edge(re.Sub[0], forward)
+		return canBeEmpty || re.Min == 0, noNewline
+	case rxsyntax.OpConcat:
+		noNewline = true
+		for i := range re.Sub {
+			sub := re.Sub[i]
+			if !forward {
+				sub = re.Sub[len(re.Sub)-1-i]
+			}
+			subEmpty, subNoNewline := edge(sub, forward)
+			noNewline = noNewline && subNoNewline
+			if !subEmpty {
+				return false, noNewline
+			}
+		}
+		return true, noNewline
+	case rxsyntax.OpAlternate:
+		noNewline = true
+		for _, sub := range re.Sub {
+			subEmpty, subNoNewline := edge(sub, forward)
+			canBeEmpty = canBeEmpty || subEmpty
+			noNewline = noNewline && subNoNewline
+		}
+		return canBeEmpty, noNewline
+	}
+	return true, false
+}
+
+// globLikePattern matches file: values that were likely meant as globs
+// rather than regular expressions: a leading "*", "**", a "*" right after a
+// path separator, and brace alternatives like "{ts,tsx}".
+var globLikePattern = lazyregexp.New(`^\*|\*\*|/\*|\{[^{}]*,[^{}]*\}`)
+
+// lintGlob reports file: values that look like globs and proposes the
+// equivalent regular expression.
+func lintGlob(input string, e lintExpr) (Diagnostic, bool) {
+	if e.expr.ValueType != syntax.TokenLiteral || !globLikePattern.MatchString(e.expr.Value) {
+		return Diagnostic{}, false
+	}
+	d := Diagnostic{
+		Severity: SeverityWarning,
+		Code:     DiagnosticGlobPattern,
+		Message:  fmt.Sprintf("%s: values are regular expressions, but %q looks like a glob; enable the search.globbing setting to use globs", e.field, e.expr.Value),
+		Range:    e.value,
+	}
+	if _, err := regexp.Compile(e.expr.Value); err != nil {
+		d.Severity = SeverityError
+		d.Message = fmt.Sprintf("%s: values are regular expressions, and %q is a glob rather than a valid regular expression; enable the search.globbing setting to use globs", e.field, e.expr.Value)
+	}
+	if re, err := globToRegexp(e.expr.Value); err == nil {
+		d.Fixes = []Fix{{
+			Description: "Use the regular expression " + re,
+			Query:       replaceRange(input, e.value, re),
+		}}
+	}
+	return d, true
+}
+
+// lintRedundantRepos reports repo: values that do not change which
+// repositories are searched: duplicates, values that match every repository,
+// and literal values implied by another literal value. For example, in
+// "repo:acme repo:acme/api", any repository matching acme/api matches acme.
+func lintRedundantRepos(input string, exprs []lintExpr) []Diagnostic {
+	var repos []lintExpr
+	for _, e := range exprs {
+		if e.field != FieldRepo || e.expr.ValueType != syntax.TokenLiteral || looksLikeRepoPredicate(e.expr.Value) {
+			continue
+		}
+		repos = append(repos, e)
+	}
+
+	var diagnostics []Diagnostic
+	redundant := func(e lintExpr, message string) {
+		diagnostics = append(diagnostics, Diagnostic{
+			Severity: SeverityWarning,
+			Code:     DiagnosticRedundantRepo,
+			Message:  message,
+			Range:    e.rng,
+			Fixes: []Fix{{
+				Description: "Remove " + input[e.rng.Start:e.rng.End],
+				Query:       removeRange(input, e.rng),
+			}},
+		})
+	}
+	patterns := make([]string, len(repos))
+	for i, e := range repos {
+		patterns[i], _ = e.regexpValue(SearchTypeRegex)
+	}
+
+outer:
+	for i, e := range repos {
+		if !e.expr.Not && matchesEverything(patterns[i]) {
+			redundant(e, fmt.Sprintf("%s matches every repository", input[e.rng.Start:e.rng.End]))
+			continue
+		}
+		for j, other := range repos {
+			if i == j || e.expr.Not != other.expr.Not {
+				continue
+			}
+			if strings.EqualFold(e.expr.Value, other.expr.Value) {
+				if j < i {
+					redundant(e, fmt.Sprintf("duplicate of %s", input[other.rng.Start:other.rng.End]))
+					continue outer
+				}
+				continue
+			}
+			// Repositories must match all repo: values, so a value implied by
+			// another is redundant. Repositories must match none of the -repo:
+			// values, so a value that implies another is redundant.
+			if !e.expr.Not && impliesLiteral(patterns[j], patterns[i]) {
+				redundant(e, fmt.Sprintf("redundant because repositories matching %s also match %s", input[other.rng.Start:other.rng.End], input[e.rng.Start:e.rng.End]))
+				continue outer
+			}
+			if e.expr.Not && impliesLiteral(patterns[i], patterns[j]) {
+				redundant(e, fmt.Sprintf("redundant because %s already excludes these repositories", input[other.rng.Start:other.rng.End]))
+				continue outer
+			}
+		}
+	}
+	return diagnostics
+}
+
+// impliesLiteral reports whether every name matching pattern also matches
+// literal, a regular expression matching a literal string, ignoring case. It
+// only recognizes literals that are part of a sequence of characters required
+// by pattern.
+func impliesLiteral(pattern, literal string) bool {
+	lit, err := rxsyntax.Parse(literal, rxsyntax.Perl)
+	if err != nil || lit.Op != rxsyntax.OpLiteral {
+		return false
+	}
+	re, err := rxsyntax.Parse(pattern, rxsyntax.Perl)
+	if err != nil {
+		return false
+	}
+	subs := []*rxsyntax.Regexp{re}
+	if re.Op == rxsyntax.OpConcat {
+		subs = re.Sub
+	}
+	for _, sub := range subs {
+		if sub.Op == rxsyntax.OpLiteral && strings.Contains(strings.ToLower(string(sub.Rune)), strings.ToLower(string(lit.Rune))) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesEverything reports whether the regular expression matches every
+// repository name.
+func matchesEverything(pattern string) bool {
+	re, err := rxsyntax.Parse(pattern, rxsyntax.Perl)
+	if err != nil {
+		return false
+	}
+	subs := []*rxsyntax.Regexp{re}
+	if re.Op == rxsyntax.OpConcat {
+		subs = re.Sub
+	}
+	var rest []*rxsyntax.Regexp
+	for _, sub := range subs {
+		switch sub.Op {
+		case rxsyntax.OpBeginText, rxsyntax.OpBeginLine, rxsyntax.OpEndText, rxsyntax.OpEndLine:
+		default:
+			rest = append(rest, sub)
+		}
+	}
+	switch {
+	case len(rest) == 0:
+		return true
+	case len(rest) > 1:
+		return false
+	}
+	switch sub := rest[0]; sub.Op {
+	case rxsyntax.OpEmptyMatch:
+		return true
+	case rxsyntax.OpStar:
+		return sub.Sub[0].Op == rxsyntax.OpAnyChar || sub.Sub[0].Op == rxsyntax.OpAnyCharNotNL
+	case rxsyntax.OpAnyChar, rxsyntax.OpAnyCharNotNL:
+		// Every repository name has at least one character.
+		return len(subs) == 1
+	}
+	return false
+}
@@ -0,0 +1,240 @@
+package query
+
+import (
+	"errors"
+	rxsyntax "regexp/syntax"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestLint(t *testing.T) {
+	cases := []struct {
+		name       string
+		input      string
+		searchType SearchType
+		opts       ProcessOptions
+		want       []Diagnostic
+	}{
+		{
+			name:       "no problems",
+			input:      `repo:acme file:\.go$ foo(bar)`,
+			searchType: SearchTypeRegex,
+		},
+		{
+			name:       "unmatched opening parenthesis",
+			input:      `foo(bar`,
+			searchType: SearchTypeRegex,
+			want: []Diagnostic{{
+				Severity: SeverityError,
+				Code:     DiagnosticUnbalancedParentheses,
+				Message:  "unmatched opening parenthesis",
+				Range:    Range{Start: 3, End: 4},
+				Fixes:    []Fix{{Description: "Escape the parenthesis to match it literally", Query: `foo\(bar`}},
+			}},
+		},
+		{
+			name:       "unmatched closing parenthesis in structural search",
+			input:      `foo(:[x]))`,
+			searchType: SearchTypeStructural,
+			want: []Diagnostic{{
+				Severity: SeverityError,
+				Code:     DiagnosticUnbalancedParentheses,
+				Message:  "unmatched closing parenthesis",
+				Range:    Range{Start: 9, End: 10},
+				Fixes:    []Fix{{Description: "Remove the parenthesis", Query: `foo(:[x])`}},
+			}},
+		},
+		{
+			name:       "parentheses that need no match",
+			input:      `\( [(] "(" file:a[)]b`,
+			searchType: SearchTypeRegex,
+		},
+		{
+			name:       "parentheses in literal search",
+			input:      `foo(`,
+			searchType: SearchTypeLiteral,
+		},
+		{
+			name:       "never matches",
+			input:      `foo$bar repo:acme`,
+			searchType: SearchTypeRegex,
+			want: []Diagnostic{{
+				Severity: SeverityWarning,
+				Code:     DiagnosticNeverMatches,
+				Message:  `the regular expression "foo$bar" can never match, so the query has no results`,
+				Range:    Range{Start: 0, End: 7},
+				Fixes:    []Fix{{Description: "Remove foo$bar", Query: `repo:acme`}},
+			}},
+		},
+		{
+			name:       "negated never matches",
+			input:      `x -file:a^b`,
+			searchType: SearchTypeLiteral,
+			want: []Diagnostic{{
+				Severity: SeverityWarning,
+				Code:     DiagnosticNeverMatches,
+				Message:  `the regular expression "a^b" can never match, so it excludes nothing`,
+				Range:    Range{Start: 2, End: 11},
+				Fixes:    []Fix{{Description: "Remove -file:a^b", Query: `x`}},
+			}},
+		},
+		{
+			name:       "patterns are not regexps in literal search",
+			input:      `foo$bar`,
+			searchType: SearchTypeLiteral,
+		},
+		{
+			name:       "glob that is not a valid regexp",
+			input:      `file:*.go foo`,
+			searchType: SearchTypeLiteral,
+			want: []Diagnostic{{
+				Severity: SeverityError,
+				Code:     DiagnosticGlobPattern,
+				Message:  `file: values are regular expressions, and "*.go" is a glob rather than a valid regular expression; enable the search.globbing setting to use globs`,
+				Range:    Range{Start: 5, End: 9},
+				Fixes:    []Fix{{Description: `Use the regular expression (^|/)[^/]*\.go(/|$)`, Query: `file:(^|/)[^/]*\.go(/|$) foo`}},
+			}},
+		},
+		{
+			name:       "glob that is a valid regexp",
+			input:      `f:src/*.{ts,tsx}`,
+			searchType: SearchTypeRegex,
+			want: []Diagnostic{{
+				Severity: SeverityWarning,
+				Code:     DiagnosticGlobPattern,
+				Message:  `file: values are regular expressions, but "src/*.{ts,tsx}" looks like a glob; enable the search.globbing setting to use globs`,
+				Range:    Range{Start: 2, End: 16},
+				Fixes:    []Fix{{Description: `Use the regular expression (^|/)src/[^/]*\.(ts|tsx)(/|$)`, Query: `f:(^|/)src/[^/]*\.(ts|tsx)(/|$)`}},
+			}},
+		},
+		{
+			name:       "globs with globbing",
+			input:      `file:*.go repo:acme/* repo:acme foo`,
+			searchType: SearchTypeLiteral,
+			opts:       ProcessOptions{Globbing: true},
+			want: []Diagnostic{{
+				Severity: SeverityWarning,
+				Code:     DiagnosticRedundantRepo,
+				Message:  "redundant because repositories matching repo:acme/* also match repo:acme",
+				Range:    Range{Start: 22, End: 31},
+				Fixes:    []Fix{{Description: "Remove repo:acme", Query: `file:*.go repo:acme/* foo`}},
+			}},
+		},
+		{
+			name:       "duplicate repo",
+			input:      `repo:acme foo r:ACME`,
+			searchType: SearchTypeRegex,
+			want: []Diagnostic{{
+				Severity: SeverityWarning,
+				Code:     DiagnosticRedundantRepo,
+				Message:  "duplicate of repo:acme",
+				Range:    Range{Start: 14, End: 20},
+				Fixes:    []Fix{{Description: "Remove r:ACME", Query: `repo:acme foo`}},
+			}},
+		},
+		{
+			name:       "repo implied by another",
+			input:      `repo:acme repo:github\.com/acme/api`,
+			searchType: SearchTypeRegex,
+			want: []Diagnostic{{
+				Severity: SeverityWarning,
+				Code:     DiagnosticRedundantRepo,
+				Message:  `redundant because repositories matching repo:github\.com/acme/api also match repo:acme`,
+				Range:    Range{Start: 0, End: 9},
+				Fixes:    []Fix{{Description: "Remove repo:acme", Query: `repo:github\.com/acme/api`}},
+			}},
+		},
+		{
+			name:       "excluded repo implied by another",
+			input:      `-repo:acme/api -repo:acme`,
+			searchType: SearchTypeRegex,
+			want: []Diagnostic{{
+				Severity: SeverityWarning,
+				Code:     DiagnosticRedundantRepo,
+				Message:  "redundant because -repo:acme already excludes these repositories",
+				Range:    Range{Start: 0, End: 14},
+				Fixes:    []Fix{{Description: "Remove -repo:acme/api", Query: `-repo:acme`}},
+			}},
+		},
+		{
+			name:       "repo matching everything",
+			input:      `repo:.* foo`,
+			searchType: SearchTypeRegex,
+			want: []Diagnostic{{
+				Severity: SeverityWarning,
+				Code:     DiagnosticRedundantRepo,
+				Message:  "repo:.* matches every repository",
+				Range:    Range{Start: 0, End: 7},
+				Fixes:    []Fix{{Description: "Remove repo:.*", Query: `foo`}},
+			}},
+		},
+		{
+			name:       "repos that are not redundant",
+			input:      `repo:acme -repo:acme/api repo:has.topic(acme) repo:acme|beta repo:beta`,
+			searchType: SearchTypeRegex,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := Lint(c.input, c.searchType, c.opts)
+			if diff := cmp.Diff(c.want, got); diff != "" {
+				t.Errorf("mismatch (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestErrorDiagnostic(t *testing.T) {
+	_, err := Process(`foo case:yes case:no`, SearchTypeRegex)
+	got := ErrorDiagnostic(`foo case:yes case:no`, err, SearchTypeRegex)
+	want := Diagnostic{
+		Severity: SeverityError,
+		Code:     DiagnosticInvalidQuery,
+		Message:  `field "case" may not be used more than once`,
+		Range:    Range{Start: 13, End: 20},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("mismatch (-want, +got):\n%s", diff)
+	}
+
+	_, err = Process(`foo "bar`, SearchTypeRegex)
+	got = ErrorDiagnostic(`foo "bar`, err, SearchTypeRegex)
+	if got.Range.Start != 4 || len(got.Fixes) == 0 {
+		t.Errorf("got %+v, want a diagnostic at the unterminated quote with fixes", got)
+	}
+
+	got = ErrorDiagnostic(`foo`, errors.New("boom"), SearchTypeLiteral)
+	if got.Range != (Range{Start: 0, End: 3}) || got.Message != "boom" {
+		t.Errorf("got %+v, want a diagnostic for the whole query", got)
+	}
+}
+
+func TestNeverMatches(t *testing.T) {
+	for pattern, want := range map[string]bool{
+		`foo`:                    false,
+		`foo$`:                   false,
+		`^foo`:                   false,
+		`foo$\n^bar`:             false,
+		`foo$|bar`:               false,
+		`$^`:                     false,
+		`foo$x*`:                 false,
+		`foo$[\s\S]`:             false,
+		`foo$bar`:                true,
+		`(?m)foo$bar`:            true,
+		`foo^`:                   true,
+		`a^b|c$d`:                true,
+		`foo$(bar|baz)`:          true,
+		`foo$x*y`:                true,
+		`[^\x00-\x{10FFFF}]`:     true,
+		`(a[^\x00-\x{10FFFF}])+`: true,
+	} {
+		re, err := rxsyntax.Parse(pattern, rxsyntax.Perl)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := neverMatches(re); got != want {
+			t.Errorf("neverMatches(%q) = %v, want %v", pattern, got, want)
+		}
+	}
+}
@@ -25,6 +25,7 @@ OrTerm     → AndTerm { OR AndTerm }
 AndTerm    → Term { AND Term }
 Term       → (OrTerm) | Parameters
 Parameters → Parameter { " " Parameter }
+Parameter  → [ NOT ] Pattern | [ NOT ] Field:Value
 */
 
 type Node interface {
@@ -128,6 +129,7 @@ type keyword string
 const (
 	AND    keyword = "and"
 	OR     keyword = "or"
+	NOT    keyword = "not"
 	LPAREN keyword = "("
 	RPAREN keyword = ")"
 	SQUOTE keyword = "'"
@@ -254,6 +256,25 @@ func (p *parser) matchKeyword(keyword keyword) bool {
 	return strings.EqualFold(v, string(keyword))
 }
 
+// matchUnaryKeyword is like matchKeyword but also matches a keyword at the
+// start of the input or after an opening parenthesis. Unlike AND and OR, the
+// keyword only matches in uppercase, so that patterns like `is not nil` are
+// not negated.
+func (p *parser) matchUnaryKeyword(keyword keyword) bool {
+	if p.pos > 0 && !isSpace(p.buf[p.pos-1:p.pos]) && p.buf[p.pos-1] != '(' {
+		return false
+	}
+	v, err := p.peek(len(string(keyword)))
+	if err != nil {
+		return false
+	}
+	after := p.pos + len(string(keyword))
+	if after >= len(p.buf) || !isSpace(p.buf[after:after+1]) {
+		return false
+	}
+	return v == strings.ToUpper(string(keyword))
+}
+
 // skipSpaces advances the input and places the parser position at the next
 // non-space value.
 func (p *parser) skipSpaces() error {
@@ -686,6 +707,14 @@ func (p *parser) ParseParameter() (Parameter, bool, error) {
 	}
 
 	p.pos += advance
+	if strings.EqualFold(field, FieldRepo) {
+		// Predicates like repo:has.topic(payments) are scanned up to their
+		// closing parenthesis, which would otherwise end the value.
+		if value, advance, ok := scanRepoPredicate(p.buf[p.pos:]); ok {
+			p.pos += advance
+			return Parameter{Field: field, Value: value, Negated: negated}, true, nil
+		}
+	}
 	value, err := p.ParseFieldValue()
 	if err != nil {
 		return Parameter{}, false, err
@@ -703,10 +732,16 @@ func (p *parser) ParseParameter() (Parameter, bool, error) {
 // (2) Any nonterminal node is concatenated (ordered in the tree) if its
 // descendents contain one or more search patterns.
 func partitionParameters(nodes []Node) []Node {
-	var patterns, unorderedParams []Node
+	var patterns, negatedPatterns, unorderedParams []Node
 	for _, n := range nodes {
-		switch n.(type) {
+		switch v := n.(type) {
 		case Pattern:
+			if v.Negated {
+				// A negated pattern is not part of the concatenated
+				// pattern: foo NOT bar means foo and not bar.
+				negatedPatterns = append(negatedPatterns, n)
+				continue
+			}
 			patterns = append(patterns, n)
 		case Parameter:
 			unorderedParams = append(unorderedParams, n)
@@ -719,10 +754,9 @@ func partitionParameters(nodes []Node) []Node {
 		}
 	}
 	if len(patterns) > 1 {
-		orderedPatterns := newOperator(patterns, Concat)
-		return newOperator(append(unorderedParams, orderedPatterns...), And)
+		patterns = newOperator(patterns, Concat)
 	}
-	return newOperator(append(unorderedParams, patterns...), And)
+	return newOperator(append(append(unorderedParams, patterns...), negatedPatterns...), And)
 }
 
 // parseParameterParameterList scans for consecutive leaf nodes.
@@ -770,6 +804,12 @@ loop:
 		case p.matchKeyword(AND), p.matchKeyword(OR):
 			// Caller advances.
 			break loop
+		case p.matchUnaryKeyword(NOT):
+			node, err := p.parseNot()
+			if err != nil {
+				return nil, err
+			}
+			nodes = append(nodes, node)
 		default:
 			// First try parse a parameter as a search pattern containing parens.
 			if pattern, ok := p.ParseSearchPatternHeuristic(); ok {
@@ -791,6 +831,30 @@ loop:
 	return partitionParameters(nodes), nil
 }
 
+// parseNot parses a pattern or parameter preceded by the NOT keyword, and
+// returns it negated.
+func (p *parser) parseNot() (Node, error) {
+	start := p.pos
+	_ = p.expect(NOT) // Guaranteed to succeed.
+	if err := p.skipSpaces(); err != nil {
+		return nil, err
+	}
+	if p.done() || p.match(LPAREN) || p.match(RPAREN) || p.matchKeyword(AND) || p.matchKeyword(OR) || p.matchUnaryKeyword(NOT) {
+		return nil, &UnsupportedError{Msg: fmt.Sprintf("expected a search pattern or filter after NOT at %d", start)}
+	}
+	parameter, ok, err := p.ParseParameter()
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		parameter.Negated = !parameter.Negated
+		return parameter, nil
+	}
+	pattern := p.ParsePattern()
+	pattern.Negated = true
+	return pattern, nil
+}
+
 // reduce takes lists of left and right nodes and reduces them if possible. For example,
 // (and a (b and c))       => (and a b c)
 // (((a and b) or c) or d) => (or (and a b) c d)
@@ -969,11 +1033,22 @@ func ParseLiteralSearch(in string) ([]Node, error) {
 
 // ProcessAndOr query parses and validates an and/or query for a given search type.
 func ProcessAndOr(in string) (QueryInfo, error) {
+	return ProcessAndOrWithOptions(in, ProcessOptions{})
+}
+
+// ProcessAndOrWithOptions is like ProcessAndOr, with options.
+func ProcessAndOrWithOptions(in string, opts ProcessOptions) (QueryInfo, error) {
 	query, err := ParseAndOr(in)
 	if err != nil {
 		return nil, err
 	}
 	query = Map(query, LowercaseFieldNames, SubstituteAliases)
+	if opts.Globbing {
+		query, err = substituteGlobs(query)
+		if err != nil {
+			return nil, err
+		}
+	}
 	err = validate(query)
 	if err != nil {
 		return nil, err
@@ -662,6 +662,54 @@ func TestParse(t *testing.T) {
 			WantGrammar:   `(and "repo:foo bar" ":\\")`,
 			WantHeuristic: Same,
 		},
+		{
+			Name:          "Negated pattern",
+			Input:         `foo NOT bar`,
+			WantGrammar:   `(and "foo" "NOT bar")`,
+			WantHeuristic: Same,
+		},
+		{
+			Name:          "Negated pattern with concatenation",
+			Input:         `NOT foo bar baz`,
+			WantGrammar:   `(and (concat "bar" "baz") "NOT foo")`,
+			WantHeuristic: Same,
+		},
+		{
+			Name:          "Negated parameter",
+			Input:         `foo and NOT file:bar`,
+			WantGrammar:   `(and "foo" "-file:bar")`,
+			WantHeuristic: Same,
+		},
+		{
+			Name:          "Negated negated parameter",
+			Input:         `NOT -file:bar`,
+			WantGrammar:   `"file:bar"`,
+			WantHeuristic: Same,
+		},
+		{
+			Name:          "Not as part of a pattern",
+			Input:         `notfoo knot`,
+			WantGrammar:   `(concat "notfoo" "knot")`,
+			WantHeuristic: Same,
+		},
+		{
+			Name:          "Lowercase not is a pattern",
+			Input:         `is not nil`,
+			WantGrammar:   `(concat "is" "not" "nil")`,
+			WantHeuristic: Same,
+		},
+		{
+			Name:          "Dangling not",
+			Input:         `foo NOT`,
+			WantGrammar:   `(concat "foo" "NOT")`,
+			WantHeuristic: Same,
+		},
+		{
+			Name:          "Not before and",
+			Input:         `foo NOT and bar`,
+			WantGrammar:   `expected a search pattern or filter after NOT at 4`,
+			WantHeuristic: Same,
+		},
 	}
 	for _, tt := range cases {
 		t.Run(tt.Name, func(t *testing.T) {
@@ -0,0 +1,125 @@
+package query
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Names of the predicates accepted as repo: values. A predicate such as
+// repo:has.topic(payments) matches repositories by the metadata their code
+// host reports instead of by their name.
+const (
+	// PredicateHasTopic matches GitHub repository topics.
+	PredicateHasTopic = "has.topic"
+	// PredicateHasLabel matches GitLab project tags.
+	PredicateHasLabel = "has.label"
+	// PredicateHasProject matches Bitbucket Server project keys.
+	PredicateHasProject = "has.project"
+)
+
+var validRepoPredicates = map[string]struct{}{
+	PredicateHasTopic:   {},
+	PredicateHasLabel:   {},
+	PredicateHasProject: {},
+}
+
+// repoPredicatePrefix is the prefix shared by all repo: predicates. Values
+// that start with it and end in a parenthesized argument are interpreted as
+// predicates rather than as regular expressions.
+const repoPredicatePrefix = "has."
+
+// RepoPredicate is a parsed repo: predicate. For example, the value
+// "has.topic(payments)" is the predicate {Name: "has.topic", Argument:
+// "payments"}.
+type RepoPredicate struct {
+	Name     string
+	Argument string
+}
+
+func (p RepoPredicate) String() string {
+	return p.Name + "(" + p.Argument + ")"
+}
+
+// looksLikeRepoPredicate reports whether value has the syntactic shape of a
+// repo: predicate, i.e. has.name(argument).
+func looksLikeRepoPredicate(value string) bool {
+	open := strings.IndexByte(value, '(')
+	return strings.HasPrefix(strings.ToLower(value), repoPredicatePrefix) &&
+		open > len(repoPredicatePrefix) &&
+		strings.HasSuffix(value, ")") &&
+		isPredicateName(value[:open])
+}
+
+func isPredicateName(s string) bool {
+	for _, r := range s {
+		if !(r == '.' || r == '_' || 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z') {
+			return false
+		}
+	}
+	return true
+}
+
+// ParseRepoPredicate parses a repo: value as a predicate. It returns false if
+// the value is not shaped like a predicate, in which case it should be
+// interpreted as a regular expression, and an error if it is shaped like a
+// predicate that is not recognized or has an empty argument.
+func ParseRepoPredicate(value string) (RepoPredicate, bool, error) {
+	if !looksLikeRepoPredicate(value) {
+		return RepoPredicate{}, false, nil
+	}
+	open := strings.IndexByte(value, '(')
+	p := RepoPredicate{
+		Name:     strings.ToLower(value[:open]),
+		Argument: strings.TrimSpace(value[open+1 : len(value)-1]),
+	}
+	if _, ok := validRepoPredicates[p.Name]; !ok {
+		names := make([]string, 0, len(validRepoPredicates))
+		for name := range validRepoPredicates {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return RepoPredicate{}, true, fmt.Errorf("invalid repo: predicate %q, expected one of: %s", p.Name, strings.Join(names, ", "))
+	}
+	if p.Argument == "" {
+		return RepoPredicate{}, true, fmt.Errorf("repo: predicate %s requires an argument, e.g. %s(payments)", p.Name, p.Name)
+	}
+	return p, true, nil
+}
+
+// PartitionRepoPredicates splits repo: values into the values that are
+// predicates and the remaining values, which are regular expressions.
+// Values are assumed to have been validated.
+func PartitionRepoPredicates(values []string) (predicates []RepoPredicate, patterns []string) {
+	for _, value := range values {
+		if p, ok, err := ParseRepoPredicate(value); ok && err == nil {
+			predicates = append(predicates, p)
+			continue
+		}
+		patterns = append(patterns, value)
+	}
+	return predicates, patterns
+}
+
+// scanRepoPredicate scans a repo: predicate at the start of buf, up to and
+// including the parenthesis closing its argument, so that the argument may
+// contain whitespace. It returns false if buf does not start with a
+// predicate.
+func scanRepoPredicate(buf []byte) (string, int, bool) {
+	s := string(buf)
+	open := strings.IndexByte(s, '(')
+	if open <= len(repoPredicatePrefix) || !strings.HasPrefix(strings.ToLower(s), repoPredicatePrefix) || !isPredicateName(s[:open]) {
+		return "", 0, false
+	}
+	end := strings.IndexByte(s[open:], ')')
+	if end < 0 {
+		return "", 0, false
+	}
+	end += open + 1
+	if end < len(s) && !strings.ContainsAny(s[end:end+1], " \t\r\n)") {
+		// Something other than a separator follows, so this is a regular
+		// expression like has.x(a)b rather than a predicate.
+		return "", 0, false
+	}
+	return s[:end], end, true
+}
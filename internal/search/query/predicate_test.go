@@ -0,0 +1,107 @@
+package query
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseRepoPredicate(t *testing.T) {
+	cases := []struct {
+		input   string
+		want    RepoPredicate
+		wantOK  bool
+		wantErr string
+	}{
+		{input: "has.topic(payments)", want: RepoPredicate{Name: "has.topic", Argument: "payments"}, wantOK: true},
+		{input: "Has.Label( team a )", want: RepoPredicate{Name: "has.label", Argument: "team a"}, wantOK: true},
+		{input: "has.project(OPS)", want: RepoPredicate{Name: "has.project", Argument: "OPS"}, wantOK: true},
+		{input: "github.com/foo/bar"},
+		{input: "has.topic"},
+		{input: "has.(a)"},
+		{input: "has.topic(a)b"},
+		{input: "has\\.topic(a)"},
+		{
+			input:   "has.owner(alice)",
+			wantOK:  true,
+			wantErr: `invalid repo: predicate "has.owner", expected one of: has.label, has.project, has.topic`,
+		},
+		{
+			input:   "has.topic( )",
+			wantOK:  true,
+			wantErr: "repo: predicate has.topic requires an argument, e.g. has.topic(payments)",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.input, func(t *testing.T) {
+			got, ok, err := ParseRepoPredicate(c.input)
+			if ok != c.wantOK {
+				t.Fatalf("got ok %v, want %v", ok, c.wantOK)
+			}
+			if c.wantErr != "" {
+				if err == nil {
+					t.Fatalf("expected error %q", c.wantErr)
+				}
+				if diff := cmp.Diff(c.wantErr, err.Error()); diff != "" {
+					t.Fatal(diff)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(c.want, got); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
+
+func TestPartitionRepoPredicates(t *testing.T) {
+	predicates, patterns := PartitionRepoPredicates([]string{"has.topic(payments)", "^github\\.com/", "has.project(OPS)"})
+	wantPredicates := []RepoPredicate{
+		{Name: PredicateHasTopic, Argument: "payments"},
+		{Name: PredicateHasProject, Argument: "OPS"},
+	}
+	if diff := cmp.Diff(wantPredicates, predicates); diff != "" {
+		t.Error(diff)
+	}
+	if diff := cmp.Diff([]string{"^github\\.com/"}, patterns); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestParseAndOr_RepoPredicate(t *testing.T) {
+	cases := []struct {
+		input string
+		want  string
+	}{
+		{
+			input: "repo:has.topic(payments) foo",
+			want:  `(and "repo:has.topic(payments)" "foo")`,
+		},
+		{
+			input: "-repo:has.label(team a) foo",
+			want:  `(and "-repo:has.label(team a)" "foo")`,
+		},
+		{
+			input: "(repo:has.topic(payments) or repo:has.project(OPS)) foo",
+			want:  `(and (or "repo:has.topic(payments)" "repo:has.project(OPS)") "foo")`,
+		},
+		{
+			input: "repo:has.topic(payments) (a or b)",
+			want:  `(and "repo:has.topic(payments)" (or "a" "b"))`,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.input, func(t *testing.T) {
+			q, err := ParseAndOr(c.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(c.want, prettyPrint(q)); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
@@ -26,6 +26,14 @@ const (
 	FieldPatternType        = "patterntype"
 	FieldContent            = "content"
 	FieldVisibility         = "visibility"
+	FieldSelect             = "select"
+	FieldMultiline          = "multiline"
+	FieldContext            = "context"
+	FieldSort               = "sort"
+
+	// For symbol search only:
+	FieldSymbolParent = "symbolparent"
+	FieldSymbolMatch  = "symbolmatch"
 
 	// For diff and commit search only:
 	FieldBefore    = "before"
@@ -60,8 +68,15 @@ var (
 			FieldLang:        {Literal: types.StringType, Quoted: types.StringType, Negatable: true},
 			FieldType:        stringFieldType,
 			FieldPatternType: {Literal: types.StringType, Quoted: types.StringType, Singular: true},
-			FieldContent:     {Literal: types.StringType, Quoted: types.StringType, Singular: true},
+			FieldContent:     {Literal: types.StringType, Quoted: types.StringType, Negatable: true, Singular: true},
 			FieldVisibility:  {Literal: types.StringType, Quoted: types.StringType, Singular: true},
+			FieldSelect:      {Literal: types.StringType, Quoted: types.StringType, Singular: true},
+			FieldMultiline:   {Literal: types.BoolType, Quoted: types.BoolType, Singular: true},
+			FieldContext:     {Literal: types.StringType, Quoted: types.StringType, Singular: true},
+			FieldSort:        {Literal: types.StringType, Quoted: types.StringType, Singular: true},
+
+			FieldSymbolParent: {Literal: types.StringType, Quoted: types.StringType, Singular: true},
+			FieldSymbolMatch:  {Literal: types.StringType, Quoted: types.StringType, Singular: true},
 
 			FieldRepoHasFile:        regexpNegatableFieldType,
 			FieldRepoHasCommitAfter: {Literal: types.StringType, Quoted: types.StringType, Singular: true},
@@ -166,6 +181,43 @@ func (e *ValidationError) Error() string {
 // Validate validates legal combinations of fields and search patterns of a
 // successfully parsed query.
 func Validate(q QueryInfo, searchType SearchType) error {
+	for _, v := range q.Fields()[FieldSelect] {
+		if v.Not() {
+			return &ValidationError{Msg: `field "select" does not support negation`}
+		}
+		if _, err := ParseSelect(*v.String); err != nil {
+			return &ValidationError{Msg: err.Error()}
+		}
+	}
+	for _, v := range q.Fields()[FieldRepo] {
+		if _, _, err := ParseRepoPredicate(v.ToString()); err != nil {
+			return &ValidationError{Msg: err.Error()}
+		}
+	}
+	for _, v := range q.Fields()[FieldSort] {
+		if v.Not() {
+			return &ValidationError{Msg: `field "sort" does not support negation`}
+		}
+		if _, err := ParseSortBy(*v.String); err != nil {
+			return &ValidationError{Msg: err.Error()}
+		}
+	}
+	for _, v := range q.Fields()[FieldSymbolParent] {
+		if v.Not() {
+			return &ValidationError{Msg: `field "symbolparent" does not support negation`}
+		}
+	}
+	for _, v := range q.Fields()[FieldSymbolMatch] {
+		if v.Not() {
+			return &ValidationError{Msg: `field "symbolmatch" does not support negation`}
+		}
+		if _, err := ParseSymbolMatch(*v.String); err != nil {
+			return &ValidationError{Msg: err.Error()}
+		}
+	}
+	if q.Fields()[FieldMultiline] != nil && searchType != SearchTypeRegex {
+		return errors.New(`the parameter "multiline:" is only valid for regexp search`)
+	}
 	if searchType == SearchTypeStructural {
 		if q.Fields()[FieldCase] != nil {
 			return errors.New(`the parameter "case:" is not valid for structural search, matching is always case-sensitive`)
@@ -177,14 +229,32 @@ func Validate(q QueryInfo, searchType SearchType) error {
 	return nil
 }
 
+// ProcessOptions control how a query is processed.
+type ProcessOptions struct {
+	// Globbing interprets the values of the repo:, file: and repohasfile:
+	// fields as globs rather than regular expressions.
+	Globbing bool
+}
+
 // Process is a top level convenience function for processing a raw string into
 // a validated and type checked query, and the parse tree of the raw string.
 func Process(queryString string, searchType SearchType) (QueryInfo, error) {
+	return ProcessWithOptions(queryString, searchType, ProcessOptions{})
+}
+
+// ProcessWithOptions is like Process, with options.
+func ProcessWithOptions(queryString string, searchType SearchType, opts ProcessOptions) (QueryInfo, error) {
 	parseTree, err := Parse(queryString)
 	if err != nil {
 		return nil, err
 	}
 
+	if opts.Globbing {
+		if err := substituteGlobsInParseTree(parseTree); err != nil {
+			return nil, err
+		}
+	}
+
 	query, err := Check(parseTree)
 	if err != nil {
 		return nil, err
@@ -241,6 +311,12 @@ func (q *Query) IsCaseSensitive() bool {
 	return q.BoolValue(FieldCase)
 }
 
+// IsMultiline reports whether the query's search pattern is matched against
+// whole files, with . matching newlines.
+func (q *Query) IsMultiline() bool {
+	return q.BoolValue(FieldMultiline)
+}
+
 // Values returns the values for the given field.
 func (q *Query) Values(field string) []*types.Value {
 	if _, ok := q.conf.FieldTypes[field]; !ok {
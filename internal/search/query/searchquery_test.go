@@ -157,6 +157,18 @@ func TestQuery_Validate(t *testing.T) {
 			SearchType: SearchTypeStructural,
 			Want:       `the parameter "type:" is not valid for structural search, search is always performed on file content`,
 		},
+		{
+			Name:       `Literal search incompatible with "multiline:"`,
+			Query:      `multiline:yes foo`,
+			SearchType: SearchTypeLiteral,
+			Want:       `the parameter "multiline:" is only valid for regexp search`,
+		},
+		{
+			Name:       `Regexp search validates with "multiline:"`,
+			Query:      `multiline:yes foo.*bar`,
+			SearchType: SearchTypeRegex,
+			Want:       "",
+		},
 		{
 			Name:       `Structural search validates with "type:" on empty pattern`,
 			Query:      `patterntype:structural type:repo"`,
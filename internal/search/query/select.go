@@ -0,0 +1,106 @@
+package query
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Values of the first component of a select: path.
+const (
+	SelectRepository = "repo"
+	SelectFile       = "file"
+	SelectContent    = "content"
+	SelectSymbol     = "symbol"
+	SelectCommit     = "commit"
+)
+
+// selectors is the tree of valid select: paths. A nil subtree means that a
+// path may not be extended any further.
+type selectors map[string]selectors
+
+var validSelectors = selectors{
+	SelectRepository: nil,
+	SelectFile:       nil,
+	SelectContent:    nil,
+	SelectSymbol: selectors{
+		"file":          nil,
+		"module":        nil,
+		"namespace":     nil,
+		"package":       nil,
+		"class":         nil,
+		"method":        nil,
+		"property":      nil,
+		"field":         nil,
+		"constructor":   nil,
+		"enum":          nil,
+		"interface":     nil,
+		"function":      nil,
+		"variable":      nil,
+		"constant":      nil,
+		"string":        nil,
+		"number":        nil,
+		"boolean":       nil,
+		"array":         nil,
+		"object":        nil,
+		"key":           nil,
+		"null":          nil,
+		"enummember":    nil,
+		"struct":        nil,
+		"event":         nil,
+		"operator":      nil,
+		"typeparameter": nil,
+	},
+	SelectCommit: selectors{
+		"diff": selectors{
+			"added":   nil,
+			"removed": nil,
+		},
+	},
+}
+
+// SelectPath is a parsed value of the select: field. For example, the value
+// "symbol.function" is the path {"symbol", "function"}.
+type SelectPath []string
+
+// ParseSelect parses and validates a value of the select: field.
+func ParseSelect(value string) (SelectPath, error) {
+	path := SelectPath(strings.Split(strings.ToLower(value), "."))
+	tree := validSelectors
+	for i, part := range path {
+		subtree, ok := tree[part]
+		if !ok {
+			if i == 0 {
+				return nil, fmt.Errorf("invalid select: value %q, expected one of: %s", value, tree)
+			}
+			if len(tree) == 0 {
+				return nil, fmt.Errorf("invalid select: value %q, %q cannot be narrowed any further", value, path[:i])
+			}
+			return nil, fmt.Errorf("invalid select: value %q, %q may only be followed by one of: %s", value, path[:i], tree)
+		}
+		tree = subtree
+	}
+	return path, nil
+}
+
+// Root returns the kind of result selected, e.g. "symbol" for
+// "symbol.function".
+func (sp SelectPath) Root() string {
+	if len(sp) == 0 {
+		return ""
+	}
+	return sp[0]
+}
+
+func (sp SelectPath) String() string {
+	return strings.Join(sp, ".")
+}
+
+func (s selectors) String() string {
+	keys := make([]string, 0, len(s))
+	for k := range s {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return strings.Join(keys, ", ")
+}
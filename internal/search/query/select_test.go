@@ -0,0 +1,53 @@
+package query
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseSelect(t *testing.T) {
+	cases := []struct {
+		input   string
+		want    SelectPath
+		wantErr string
+	}{
+		{input: "repo", want: SelectPath{"repo"}},
+		{input: "content", want: SelectPath{"content"}},
+		{input: "symbol", want: SelectPath{"symbol"}},
+		{input: "Symbol.Function", want: SelectPath{"symbol", "function"}},
+		{input: "commit.diff.added", want: SelectPath{"commit", "diff", "added"}},
+		{
+			input:   "repository",
+			wantErr: `invalid select: value "repository", expected one of: commit, content, file, repo, symbol`,
+		},
+		{
+			input:   "repo.name",
+			wantErr: `invalid select: value "repo.name", "repo" cannot be narrowed any further`,
+		},
+		{
+			input:   "commit.diff.changed",
+			wantErr: `invalid select: value "commit.diff.changed", "commit.diff" may only be followed by one of: added, removed`,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.input, func(t *testing.T) {
+			got, err := ParseSelect(c.input)
+			if c.wantErr != "" {
+				if err == nil {
+					t.Fatalf("expected error %q", c.wantErr)
+				}
+				if diff := cmp.Diff(c.wantErr, err.Error()); diff != "" {
+					t.Fatal(diff)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(c.want, got); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
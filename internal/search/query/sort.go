@@ -0,0 +1,34 @@
+package query
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SortBy is a value of the sort: field, which determines the order of
+// search results.
+type SortBy string
+
+const (
+	// SortByPath orders results by repository name and file path. It is the
+	// default.
+	SortByPath SortBy = "path"
+	// SortByRelevance orders file matches by relevance signals such as
+	// whether the file defines a matching symbol.
+	SortByRelevance SortBy = "relevance"
+	// SortByRecency orders file matches by when they were last modified,
+	// most recent first.
+	SortByRecency SortBy = "recency"
+)
+
+// ParseSortBy parses and validates a value of the sort: field. The empty
+// string is SortByPath.
+func ParseSortBy(value string) (SortBy, error) {
+	switch s := SortBy(strings.ToLower(value)); s {
+	case "":
+		return SortByPath, nil
+	case SortByPath, SortByRelevance, SortByRecency:
+		return s, nil
+	}
+	return "", fmt.Errorf("invalid sort: value %q, expected one of: %s, %s, %s", value, SortByPath, SortByRecency, SortByRelevance)
+}
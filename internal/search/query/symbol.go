@@ -0,0 +1,35 @@
+package query
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SymbolMatch is a value of the symbolmatch: field, which determines how the
+// pattern of a symbol search is matched against symbol names.
+type SymbolMatch string
+
+const (
+	// SymbolMatchRegexp matches symbol names against the pattern as a
+	// regular expression. It is the default.
+	SymbolMatchRegexp SymbolMatch = "regexp"
+	// SymbolMatchExact matches symbol names equal to the pattern.
+	SymbolMatchExact SymbolMatch = "exact"
+	// SymbolMatchPrefix matches symbol names starting with the pattern.
+	SymbolMatchPrefix SymbolMatch = "prefix"
+	// SymbolMatchFuzzy matches symbol names containing the characters of the
+	// pattern in order, closest matches first.
+	SymbolMatchFuzzy SymbolMatch = "fuzzy"
+)
+
+// ParseSymbolMatch parses and validates a value of the symbolmatch: field.
+// The empty string is SymbolMatchRegexp.
+func ParseSymbolMatch(value string) (SymbolMatch, error) {
+	switch m := SymbolMatch(strings.ToLower(value)); m {
+	case "":
+		return SymbolMatchRegexp, nil
+	case SymbolMatchRegexp, SymbolMatchExact, SymbolMatchPrefix, SymbolMatchFuzzy:
+		return m, nil
+	}
+	return "", fmt.Errorf("invalid symbolmatch: value %q, expected one of: %s, %s, %s, %s", value, SymbolMatchExact, SymbolMatchFuzzy, SymbolMatchPrefix, SymbolMatchRegexp)
+}
@@ -27,6 +27,7 @@ type QueryInfo interface {
 	Fields() map[string][]*types.Value
 	BoolValue(field string) bool
 	IsCaseSensitive() bool
+	IsMultiline() bool
 	ParseTree() syntax.ParseTree
 }
 
@@ -65,6 +66,9 @@ func (q OrdinaryQuery) BoolValue(field string) bool {
 func (q OrdinaryQuery) IsCaseSensitive() bool {
 	return q.Query.IsCaseSensitive()
 }
+func (q OrdinaryQuery) IsMultiline() bool {
+	return q.Query.IsMultiline()
+}
 
 // AndOrQuery satisfies the interface for QueryInfo close to that of OrdinaryQuery.
 func (q AndOrQuery) RegexpPatterns(field string) (values, negatedValues []string) {
@@ -161,6 +165,10 @@ func (q AndOrQuery) IsCaseSensitive() bool {
 	return q.BoolValue("case")
 }
 
+func (q AndOrQuery) IsMultiline() bool {
+	return q.BoolValue(FieldMultiline)
+}
+
 func parseRegexpOrPanic(field, value string) *regexp.Regexp {
 	r, err := regexp.Compile(value)
 	if err != nil {
@@ -169,6 +177,19 @@ func parseRegexpOrPanic(field, value string) *regexp.Regexp {
 	return r
 }
 
+// NegatedPatternToParameter converts a pattern negated with NOT to an
+// equivalent -content: parameter. Patterns that are interpreted as strings
+// are quoted, since content: values are interpreted as regular expressions.
+func NegatedPatternToParameter(pattern Pattern) Parameter {
+	value := pattern.Value
+	if pattern.Annotation.Labels&(Quoted|HeuristicParensAsPatterns) != 0 {
+		value = regexp.QuoteMeta(value)
+	} else if _, err := regexp.Compile(value); err != nil {
+		value = regexp.QuoteMeta(value)
+	}
+	return Parameter{Field: FieldContent, Value: value, Negated: true}
+}
+
 // valueToTypedValue approximately preserves the field validation for
 // OrdinaryQuery processing. It does not check the validity of field negation or
 // if the same field is specified more than once.
@@ -194,7 +215,8 @@ func (q AndOrQuery) valueToTypedValue(field, value string, label labels) []*type
 		return []*types.Value{{String: &value}}
 
 	case
-		FieldCase:
+		FieldCase,
+		FieldMultiline:
 		b, _ := parseBool(value)
 		return []*types.Value{{Bool: &b}}
 
@@ -216,7 +238,11 @@ func (q AndOrQuery) valueToTypedValue(field, value string, label labels) []*type
 		FieldLang, "l", "language",
 		FieldType,
 		FieldPatternType,
-		FieldContent:
+		FieldContent,
+		FieldContext,
+		FieldSort,
+		FieldSymbolParent,
+		FieldSymbolMatch:
 		return []*types.Value{{String: &value}}
 
 	case FieldRepoHasFile:
@@ -240,7 +266,8 @@ func (q AndOrQuery) valueToTypedValue(field, value string, label labels) []*type
 		FieldMax,
 		FieldTimeout,
 		FieldReplace,
-		FieldCombyRule:
+		FieldCombyRule,
+		FieldSelect:
 		return []*types.Value{{String: &value}}
 	}
 	return []*types.Value{{String: &value}}
@@ -47,3 +47,22 @@ func TestValueToTypedValue(t *testing.T) {
 		}
 	})
 }
+
+func TestNegatedPatternToParameter(t *testing.T) {
+	cases := []struct {
+		pattern Pattern
+		want    string
+	}{
+		{Pattern{Value: "fo+", Negated: true}, "fo+"},
+		{Pattern{Value: "fo+", Negated: true, Annotation: Annotation{Labels: Literal | Quoted}}, `fo\+`},
+		{Pattern{Value: "foo(", Negated: true}, `foo\(`},
+		{Pattern{Value: "foo()", Negated: true, Annotation: Annotation{Labels: HeuristicParensAsPatterns}}, `foo\(\)`},
+	}
+	for _, c := range cases {
+		got := NegatedPatternToParameter(c.pattern)
+		want := Parameter{Field: FieldContent, Value: c.want, Negated: true}
+		if got != want {
+			t.Errorf("NegatedPatternToParameter(%v) = %v, want %v", c.pattern, got, want)
+		}
+	}
+}
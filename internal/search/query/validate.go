@@ -71,12 +71,14 @@ func containsAndOrExpression(nodes []Node) bool {
 	})
 }
 
-// ContainsAndOrKeyword returns true if this query contains or- or and-
+// ContainsAndOrKeyword returns true if this query contains or-, and- or not-
 // keywords. It is a temporary signal to determine whether we can fallback to
-// the older existing search functionality.
+// the older existing search functionality. The not-keyword is only matched in
+// uppercase, like the parser does.
 func ContainsAndOrKeyword(input string) bool {
 	lower := strings.ToLower(input)
-	return strings.Contains(lower, " and ") || strings.Contains(lower, " or ")
+	return strings.Contains(lower, " and ") || strings.Contains(lower, " or ") ||
+		strings.Contains(input, " NOT ") || strings.HasPrefix(input, "NOT ") || strings.Contains(input, "(NOT ")
 }
 
 // ContainsRegexpMetasyntax returns true if a string is a valid regular
@@ -99,7 +101,7 @@ func processTopLevel(nodes []Node) ([]Node, error) {
 	if term, ok := nodes[0].(Operator); ok {
 		if term.Kind == And && isPatternExpression([]Node{term}) {
 			return nodes, nil
-		} else if term.Kind == Or && isPatternExpression([]Node{term}) {
+		} else if term.Kind == Or && (isPatternExpression([]Node{term}) || isScopedPatternExpression(term)) {
 			return nodes, nil
 		} else if term.Kind == And {
 			return term.Operands, nil
@@ -112,11 +114,36 @@ func processTopLevel(nodes []Node) ([]Node, error) {
 	return nodes, nil
 }
 
+// isScopedPatternExpression returns true if node is an or-expression whose
+// operands are search pattern expressions, or groups of parameters that scope
+// a search pattern expression, like (type:file foo) in
+// "(type:file foo) or (type:commit bar)".
+func isScopedPatternExpression(node Node) bool {
+	term, ok := node.(Operator)
+	if !ok || term.Kind != Or {
+		return false
+	}
+	for _, operand := range term.Operands {
+		if isPatternExpression([]Node{operand}) {
+			continue
+		}
+		group, ok := operand.(Operator)
+		if !ok || group.Kind != And {
+			return false
+		}
+		if _, pattern, err := PartitionSearchPattern(group.Operands); err != nil || pattern == nil {
+			return false
+		}
+	}
+	return true
+}
+
 // PartitionSearchPattern partitions an and/or query into (1) a single search
 // pattern expression and (2) other parameters that scope the evaluation of
 // search patterns (e.g., to repos, files, etc.). It validates that a query
 // contains at most one search pattern expression and that scope parameters do
-// not contain nested expressions.
+// not contain nested expressions, except for the operands of or-expressions,
+// which may have their own scope parameters.
 func PartitionSearchPattern(nodes []Node) (parameters []Node, pattern Node, err error) {
 	if len(nodes) == 1 {
 		nodes, err = processTopLevel(nodes)
@@ -127,7 +154,7 @@ func PartitionSearchPattern(nodes []Node) (parameters []Node, pattern Node, err
 
 	var patterns []Node
 	for _, node := range nodes {
-		if isPatternExpression([]Node{node}) {
+		if isPatternExpression([]Node{node}) || isScopedPatternExpression(node) {
 			patterns = append(patterns, node)
 		} else if term, ok := node.(Parameter); ok {
 			parameters = append(parameters, term)
@@ -240,6 +267,28 @@ func validateField(field, value string, negated bool, seen map[string]struct{})
 		return nil
 	}
 
+	isValidRepoValue := func() error {
+		if _, ok, err := ParseRepoPredicate(value); ok {
+			return err
+		}
+		return isValidRegexp()
+	}
+
+	isValidSelect := func() error {
+		_, err := ParseSelect(value)
+		return err
+	}
+
+	isValidSort := func() error {
+		_, err := ParseSortBy(value)
+		return err
+	}
+
+	isValidSymbolMatch := func() error {
+		_, err := ParseSymbolMatch(value)
+		return err
+	}
+
 	isUnrecognizedField := func() error {
 		return fmt.Errorf("unrecognized field %q", field)
 	}
@@ -262,9 +311,10 @@ func validateField(field, value string, negated bool, seen map[string]struct{})
 		return satisfies(isSingular, isBoolean, isNotNegated)
 	case
 		FieldRepo:
-		return satisfies(isValidRegexp)
+		return satisfies(isValidRepoValue)
 	case
-		FieldRepoGroup:
+		FieldRepoGroup,
+		FieldContext:
 		return satisfies(isSingular, isNotNegated)
 	case
 		FieldFile:
@@ -280,9 +330,11 @@ func validateField(field, value string, negated bool, seen map[string]struct{})
 		FieldType:
 		return satisfies(isNotNegated)
 	case
-		FieldPatternType,
-		FieldContent:
+		FieldPatternType:
 		return satisfies(isSingular, isNotNegated)
+	case
+		FieldContent:
+		return satisfies(isSingular)
 	case
 		FieldRepoHasFile:
 		return satisfies(isValidRegexp)
@@ -305,7 +357,8 @@ func validateField(field, value string, negated bool, seen map[string]struct{})
 		FieldCount:
 		return satisfies(isSingular, isNumber, isNotNegated)
 	case
-		FieldStable:
+		FieldStable,
+		FieldMultiline:
 		return satisfies(isSingular, isBoolean, isNotNegated)
 	case
 		FieldMax,
@@ -313,6 +366,18 @@ func validateField(field, value string, negated bool, seen map[string]struct{})
 		FieldReplace,
 		FieldCombyRule:
 		return satisfies(isSingular, isNotNegated)
+	case
+		FieldSelect:
+		return satisfies(isSingular, isNotNegated, isValidSelect)
+	case
+		FieldSort:
+		return satisfies(isSingular, isNotNegated, isValidSort)
+	case
+		FieldSymbolParent:
+		return satisfies(isSingular, isNotNegated)
+	case
+		FieldSymbolMatch:
+		return satisfies(isSingular, isNotNegated, isValidSymbolMatch)
 	default:
 		return isUnrecognizedField()
 	}
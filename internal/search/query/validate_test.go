@@ -45,6 +45,58 @@ func TestAndOrQuery_Validation(t *testing.T) {
 			input: "count:-1",
 			want:  "field count requires a positive number",
 		},
+		{
+			input: "select:symbol.cats",
+			want:  `invalid select: value "symbol.cats", "symbol" may only be followed by one of: array, boolean, class, constant, constructor, enum, enummember, event, field, file, function, interface, key, method, module, namespace, null, number, object, operator, package, property, string, struct, typeparameter, variable`,
+		},
+		{
+			input: "multiline:sometimes",
+			want:  `invalid boolean "sometimes"`,
+		},
+		{
+			input: "-select:repo",
+			want:  `field "select" does not support negation`,
+		},
+		{
+			input: "sort:stars",
+			want:  `invalid sort: value "stars", expected one of: path, recency, relevance`,
+		},
+		{
+			input: "-sort:relevance",
+			want:  `field "sort" does not support negation`,
+		},
+		{
+			input: "sort:path sort:recency",
+			want:  `field "sort" may not be used more than once`,
+		},
+		{
+			input: "symbolmatch:soundex",
+			want:  `invalid symbolmatch: value "soundex", expected one of: exact, fuzzy, prefix, regexp`,
+		},
+		{
+			input: "-symbolmatch:exact",
+			want:  `field "symbolmatch" does not support negation`,
+		},
+		{
+			input: "-symbolparent:Bar",
+			want:  `field "symbolparent" does not support negation`,
+		},
+		{
+			input: "symbolparent:Bar symbolparent:Baz",
+			want:  `field "symbolparent" may not be used more than once`,
+		},
+		{
+			input: "repo:has.stars(10)",
+			want:  `invalid repo: predicate "has.stars", expected one of: has.label, has.project, has.topic`,
+		},
+		{
+			input: "repo:has.topic()",
+			want:  `repo: predicate has.topic requires an argument, e.g. has.topic(payments)`,
+		},
+		{
+			input: "context:a context:b",
+			want:  `field "context" may not be used more than once`,
+		},
 	}
 	for _, c := range cases {
 		t.Run("validate and/or query", func(t *testing.T) {
@@ -201,6 +253,14 @@ func TestPartitionSearchPattern(t *testing.T) {
 		},
 		{
 			input: "(file:foo x) or y",
+			want:  `(or (and "file:foo" "x") "y")`,
+		},
+		{
+			input: "repo:foo ((type:file x) or (type:commit author:bob y))",
+			want:  `"repo:foo" (or (and "type:file" "x") (and "type:commit" "author:bob" "y"))`,
+		},
+		{
+			input: "(type:file x) or (type:commit)",
 			want:  "cannot evaluate: unable to partition pure search pattern",
 		},
 		{
@@ -249,9 +309,21 @@ func TestContainsAndOrKeyword(t *testing.T) {
 	if !ContainsAndOrKeyword("repo:foo AND bar") {
 		t.Errorf("Expected query to contain keyword")
 	}
+	if !ContainsAndOrKeyword("NOT foo") {
+		t.Errorf("Expected query to contain keyword")
+	}
+	if !ContainsAndOrKeyword("foo NOT bar") {
+		t.Errorf("Expected query to contain keyword")
+	}
 	if ContainsAndOrKeyword("repo:foo bar") {
 		t.Errorf("Did not expect query to contain keyword")
 	}
+	if ContainsAndOrKeyword("nothing to see") {
+		t.Errorf("Did not expect query to contain keyword")
+	}
+	if ContainsAndOrKeyword("is not nil") {
+		t.Errorf("Did not expect lowercase not to be a keyword")
+	}
 }
 
 func TestForAll(t *testing.T) {
@@ -3,8 +3,10 @@ package search
 import (
 	"context"
 	"reflect"
+	"sort"
 	"strings"
 
+	"github.com/google/zoekt"
 	"github.com/sourcegraph/sourcegraph/cmd/frontend/types"
 	"github.com/sourcegraph/sourcegraph/internal/gitserver"
 	"github.com/sourcegraph/sourcegraph/internal/vcs/git"
@@ -61,6 +63,12 @@ type RepositoryRevisions struct {
 	// ListRefs is called to list all Git refs for a repository. It is intended to be mocked by
 	// tests. If nil, git.ListRefs is used.
 	ListRefs func(context.Context, gitserver.Repo) ([]git.Ref, error)
+
+	// IndexedBranches are the branches indexed by Zoekt that Revs resolve
+	// to. It is only set for repositories searched with indexed search which
+	// have branches besides the default branch indexed, to restrict the
+	// search to the requested branches.
+	IndexedBranches []zoekt.RepositoryBranch
 }
 
 func (r *RepositoryRevisions) Equal(other *RepositoryRevisions) bool {
@@ -86,6 +94,9 @@ func (r *RepositoryRevisions) Equal(other *RepositoryRevisions) bool {
 // - 'foo@*bar' refers to the 'foo' repo and all refs matching the glob 'bar/*',
 //   because git interprets the ref glob 'bar' as being 'bar/*' (see `man git-log`
 //   section on the --glob flag)
+// - 'foo@release-*' refers to the 'foo' repo and all branches matching the
+//   glob 'release-*'. A revspec containing '*' is not valid, so it is
+//   interpreted as the ref glob 'refs/heads/release-*'.
 func ParseRepositoryRevisions(repoAndOptionalRev string) (string, []RevisionSpecifier) {
 	i := strings.Index(repoAndOptionalRev, "@")
 	if i == -1 {
@@ -116,6 +127,11 @@ func parseRev(spec string) RevisionSpecifier {
 		return RevisionSpecifier{ExcludeRefGlob: spec[2:]}
 	} else if strings.HasPrefix(spec, "*") {
 		return RevisionSpecifier{RefGlob: spec[1:]}
+	} else if strings.Contains(spec, "*") {
+		if !strings.HasPrefix(spec, "refs/") {
+			spec = "refs/heads/" + spec
+		}
+		return RevisionSpecifier{RefGlob: spec}
 	}
 	return RevisionSpecifier{RevSpec: spec}
 }
@@ -157,6 +173,9 @@ func (r *RepositoryRevisions) RevSpecs() []string {
 // Note that not all callers need to expand these. If a caller is passing the ref globs as
 // command-line args to `git` directly (e.g., to `git log --glob ... --exclude ...`), it does not
 // need to use this function.
+//
+// The explicitly listed revspecs come first, in the order they are listed, followed by the refs
+// matched by the ref globs in sorted order.
 func (r *RepositoryRevisions) ExpandedRevSpecs(ctx context.Context) ([]string, error) {
 	listRefs := r.ListRefs
 	if listRefs == nil {
@@ -164,8 +183,9 @@ func (r *RepositoryRevisions) ExpandedRevSpecs(ctx context.Context) ([]string, e
 	}
 
 	var (
-		revSpecs = map[string]struct{}{}
-		globs    []git.RefGlob
+		revSpecs     = map[string]struct{}{}
+		revSpecsList []string
+		globs        []git.RefGlob
 	)
 	for _, rev := range r.Revs {
 		switch {
@@ -174,7 +194,10 @@ func (r *RepositoryRevisions) ExpandedRevSpecs(ctx context.Context) ([]string, e
 		case rev.ExcludeRefGlob != "":
 			globs = append(globs, git.RefGlob{Exclude: rev.ExcludeRefGlob})
 		default:
-			revSpecs[rev.RevSpec] = struct{}{}
+			if _, ok := revSpecs[rev.RevSpec]; !ok {
+				revSpecs[rev.RevSpec] = struct{}{}
+				revSpecsList = append(revSpecsList, rev.RevSpec)
+			}
 		}
 	}
 	if len(globs) > 0 {
@@ -188,16 +211,19 @@ func (r *RepositoryRevisions) ExpandedRevSpecs(ctx context.Context) ([]string, e
 			return nil, err
 		}
 
+		var matched []string
 		for _, ref := range allRefs {
 			if rg.Match(ref.Name) {
-				revSpecs[strings.TrimPrefix(ref.Name, "refs/heads/")] = struct{}{}
+				revSpec := strings.TrimPrefix(ref.Name, "refs/heads/")
+				if _, ok := revSpecs[revSpec]; !ok {
+					revSpecs[revSpec] = struct{}{}
+					matched = append(matched, revSpec)
+				}
 			}
 		}
+		sort.Strings(matched)
+		revSpecsList = append(revSpecsList, matched...)
 	}
 
-	revSpecsList := make([]string, 0, len(revSpecs))
-	for revSpec := range revSpecs {
-		revSpecsList = append(revSpecsList, revSpec)
-	}
 	return revSpecsList, nil
 }
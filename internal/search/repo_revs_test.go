@@ -1,8 +1,13 @@
 package search
 
 import (
+	"context"
 	"reflect"
 	"testing"
+
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/types"
+	"github.com/sourcegraph/sourcegraph/internal/gitserver"
+	"github.com/sourcegraph/sourcegraph/internal/vcs/git"
 )
 
 func TestParseRepositoryRevisions(t *testing.T) {
@@ -10,12 +15,14 @@ func TestParseRepositoryRevisions(t *testing.T) {
 		repo string
 		revs []RevisionSpecifier
 	}{
-		"repo":           {repo: "repo", revs: []RevisionSpecifier{}},
-		"repo@":          {repo: "repo", revs: []RevisionSpecifier{{RevSpec: ""}}},
-		"repo@rev":       {repo: "repo", revs: []RevisionSpecifier{{RevSpec: "rev"}}},
-		"repo@rev1:rev2": {repo: "repo", revs: []RevisionSpecifier{{RevSpec: "rev1"}, {RevSpec: "rev2"}}},
-		"repo@:rev1:":    {repo: "repo", revs: []RevisionSpecifier{{RevSpec: "rev1"}}},
-		"repo@*glob":     {repo: "repo", revs: []RevisionSpecifier{{RefGlob: "glob"}}},
+		"repo":                {repo: "repo", revs: []RevisionSpecifier{}},
+		"repo@":               {repo: "repo", revs: []RevisionSpecifier{{RevSpec: ""}}},
+		"repo@rev":            {repo: "repo", revs: []RevisionSpecifier{{RevSpec: "rev"}}},
+		"repo@rev1:rev2":      {repo: "repo", revs: []RevisionSpecifier{{RevSpec: "rev1"}, {RevSpec: "rev2"}}},
+		"repo@:rev1:":         {repo: "repo", revs: []RevisionSpecifier{{RevSpec: "rev1"}}},
+		"repo@*glob":          {repo: "repo", revs: []RevisionSpecifier{{RefGlob: "glob"}}},
+		"repo@release-*":      {repo: "repo", revs: []RevisionSpecifier{{RefGlob: "refs/heads/release-*"}}},
+		"repo@refs/tags/v1.*": {repo: "repo", revs: []RevisionSpecifier{{RefGlob: "refs/tags/v1.*"}}},
 		"repo@rev1:*glob1:^rev2": {
 			repo: "repo",
 			revs: []RevisionSpecifier{{RevSpec: "rev1"}, {RefGlob: "glob1"}, {RevSpec: "^rev2"}},
@@ -43,3 +50,27 @@ func TestParseRepositoryRevisions(t *testing.T) {
 		})
 	}
 }
+
+func TestRepositoryRevisions_ExpandedRevSpecs(t *testing.T) {
+	_, revs := ParseRepositoryRevisions("repo@mybranch:*refs/heads/release-*:*!refs/heads/release-2:master:release-3")
+	repoRevs := &RepositoryRevisions{
+		Repo: &types.Repo{Name: "repo"},
+		Revs: revs,
+		ListRefs: func(context.Context, gitserver.Repo) ([]git.Ref, error) {
+			return []git.Ref{
+				{Name: "refs/heads/release-3"},
+				{Name: "refs/heads/release-1"},
+				{Name: "refs/heads/release-2"},
+				{Name: "refs/heads/master"},
+			}, nil
+		},
+	}
+	got, err := repoRevs.ExpandedRevSpecs(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"mybranch", "master", "release-3", "release-1"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
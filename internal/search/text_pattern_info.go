@@ -2,6 +2,7 @@
 package search
 
 import (
+	"errors"
 	"regexp/syntax"
 )
 
@@ -10,6 +11,15 @@ func (p *TextPatternInfo) IsEmpty() bool {
 }
 
 func (p *TextPatternInfo) Validate() error {
+	if p.IsNegated {
+		if p.IsStructuralPat {
+			return errors.New("negated patterns are not supported in structural search")
+		}
+		if p.Pattern == "" {
+			return errors.New("negated patterns must not be empty")
+		}
+	}
+
 	if p.IsRegExp {
 		if _, err := syntax.Parse(p.Pattern, syntax.Perl); err != nil {
 			return err
@@ -64,6 +64,23 @@ type SymbolsParameters struct {
 	// need to match to get included in the result
 	ExcludePattern string
 
+	// Kinds, if nonempty, are the kinds of the symbols to return, such as
+	// "func" or "method". They are matched case-insensitively.
+	Kinds []string
+
+	// Parent, if nonempty, is the name of the parent (scope) of the symbols
+	// to return, such as the type of a method or the package of a Go
+	// function.
+	Parent string
+
+	// Languages, if nonempty, are the languages of the symbols to return,
+	// such as "Go". They are matched case-insensitively.
+	Languages []string
+
+	// NameMatch is how Query matches symbol names. See the NameMatch* values
+	// in package internal/symbols/protocol.
+	NameMatch string
+
 	// First indicates that only the first n symbols should be returned.
 	First int
 }
@@ -114,8 +131,14 @@ type TextPatternInfo struct {
 	IsCaseSensitive bool
 	FileMatchLimit  int32
 
-	// We do not support IsMultiline
-	// IsMultiline     bool
+	// IsMultiline if true will match the pattern against whole files, with
+	// . matching newlines. It only applies when IsRegExp is true.
+	IsMultiline bool
+
+	// IsNegated if true will match the files whose contents do not match
+	// the pattern, as in -content:pattern.
+	IsNegated bool
+
 	IncludePatterns []string
 	ExcludePattern  string
 
@@ -128,6 +151,10 @@ type TextPatternInfo struct {
 	PatternMatchesContent bool
 	PatternMatchesPath    bool
 
+	// IncludeCaptureGroups is whether the spans of the named capture groups
+	// of Pattern are returned for each match.
+	IncludeCaptureGroups bool
+
 	Languages []string
 }
 
@@ -149,12 +176,21 @@ func (p *TextPatternInfo) String() string {
 	if p.IsCaseSensitive {
 		args = append(args, "case")
 	}
+	if p.IsMultiline {
+		args = append(args, "multiline")
+	}
+	if p.IsNegated {
+		args = append(args, "negated")
+	}
 	if !p.PatternMatchesContent {
 		args = append(args, "nocontent")
 	}
 	if !p.PatternMatchesPath {
 		args = append(args, "nopath")
 	}
+	if p.IncludeCaptureGroups {
+		args = append(args, "captures")
+	}
 	if p.FileMatchLimit > 0 {
 		args = append(args, fmt.Sprintf("filematchlimit:%d", p.FileMatchLimit))
 	}
@@ -0,0 +1,126 @@
+package store
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"io/ioutil"
+
+	"github.com/pkg/errors"
+	"github.com/sourcegraph/sourcegraph/internal/archive"
+	"github.com/sourcegraph/sourcegraph/schema"
+)
+
+// archiveOptions configures the expansion of the archives in a repository,
+// such as .jar, .zip and .tar.gz files, into the files they contain.
+type archiveOptions struct {
+	// MaxDepth is the maximum depth of archives in archives expanded. A
+	// depth of 1 only expands the archives in the repository.
+	MaxDepth int
+
+	// MaxSizeBytes is the maximum size of an archive expanded, and the
+	// maximum number of bytes expanded from an archive in the repository,
+	// including from the archives in it.
+	MaxSizeBytes int64
+}
+
+// newArchiveOptions returns the archiveOptions of the search.archives site
+// configuration, or nil if archives are not expanded.
+func newArchiveOptions(c *schema.SearchArchives) *archiveOptions {
+	if c == nil || !c.Enabled {
+		return nil
+	}
+	o := &archiveOptions{MaxDepth: c.MaxDepth, MaxSizeBytes: int64(c.MaxSizeBytes)}
+	if o.MaxDepth <= 0 {
+		o.MaxDepth = 2
+	}
+	if o.MaxSizeBytes <= 0 {
+		o.MaxSizeBytes = 10 * 1000 * 1000
+	}
+	return o
+}
+
+// errArchiveTooLarge stops the expansion of an archive once more than
+// archiveOptions.MaxSizeBytes are expanded from it.
+var errArchiveTooLarge = errors.New("archive expands to more than the maximum size")
+
+// expandArchive writes the files in the archive data at path to zw, at paths
+// like path!/file, in the same way copySearchable writes the files of a
+// repository. The archives in it are expanded up to archives.MaxDepth, and at
+// most *remaining bytes are expanded.
+//
+// An archive that can't be read is expanded up to the first error. Errors
+// writing to zw are not returned, since they recur when the next file is
+// written.
+func expandArchive(zw *zip.Writer, path string, format archive.Format, data []byte, depth int, remaining *int64, largeFilePatterns []string, archives *archiveOptions) {
+	_ = archive.Walk(format, data, func(name string, size int64, r io.Reader) error {
+		name = path + archive.PathSeparator + name
+		nestedFormat := archive.FormatOf(name)
+		nested := nestedFormat != archive.NotArchive && depth < archives.MaxDepth && size <= archives.MaxSizeBytes
+		// We do not search the content of large files unless they are
+		// allowed.
+		if !nested && size > maxFileSize && !ignoreSizeMax(name, largeFilePatterns) {
+			return writeSkippedZipFile(zw, name)
+		}
+
+		if size > *remaining {
+			return errArchiveTooLarge
+		}
+		*remaining -= size
+		content, err := ioutil.ReadAll(io.LimitReader(r, size))
+		if err != nil {
+			return err
+		}
+
+		if err := writeSearchableZipFile(zw, name, content); err != nil {
+			return err
+		}
+		if nested {
+			expandArchive(zw, name, nestedFormat, content, depth+1, remaining, largeFilePatterns, archives)
+		}
+		return nil
+	})
+}
+
+// writeSearchableZipFile writes content to zw at name, or only name if the
+// content is binary. Same heuristic as copySearchable: assume the file is
+// binary if its first bytes contain a 0x00. We only search names of binary
+// files.
+func writeSearchableZipFile(zw *zip.Writer, name string, content []byte) error {
+	prefix := content
+	if len(prefix) > 32*1024 {
+		prefix = prefix[:32*1024]
+	}
+	if bytes.IndexByte(prefix, 0x00) >= 0 {
+		return writeSkippedZipFile(zw, name)
+	}
+	return writeZipFile(zw, name, content)
+}
+
+// skippedContentComment is the comment of the files in the zip archives of
+// Store whose content is not stored, because they are binary or too large.
+const skippedContentComment = "content skipped"
+
+// writeSkippedZipFile writes a file without content to zw at name, marked
+// as skipped so that searches can tell it apart from an empty file.
+func writeSkippedZipFile(zw *zip.Writer, name string) error {
+	_, err := zw.CreateHeader(&zip.FileHeader{
+		Name:    name,
+		Method:  zip.Store,
+		Comment: skippedContentComment,
+	})
+	return err
+}
+
+// writeZipFile writes a file named name with content to zw.
+func writeZipFile(zw *zip.Writer, name string, content []byte) error {
+	w, err := zw.CreateHeader(&zip.FileHeader{
+		Name:   name,
+		Method: zip.Store,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(content)
+	return err
+}
@@ -0,0 +1,172 @@
+package store
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"reflect"
+	"testing"
+)
+
+func TestCopySearchable_archives(t *testing.T) {
+	deeper := zipArchiveOf(t, map[string]string{"deeper.txt": "deeper"})
+	inner := zipArchiveOf(t, map[string]string{"deep/z.txt": "z", "deeper.zip": deeper})
+	jar := zipArchiveOf(t, map[string]string{
+		"com/x/Y.java":  "class Y {}",
+		"com/x/Y.class": "\xca\xfe\xba\xbe\x00\x00",
+		"inner.zip":     inner,
+	})
+	tgz := tarGzArchiveOf(t, map[string]string{"a.txt": "a"})
+	repo := tarArchiveOf(t, map[string]string{
+		"README.md":             "hello",
+		"lib/foo.jar":           jar,
+		"fixtures/data.tar.gz":  tgz,
+		"fixtures/broken.zip":   "not a zip",
+		"fixtures/plain.tar.gz": "not a tar.gz",
+	})
+
+	// Binary files are stored without content.
+	const skipped = "<skipped>"
+
+	tests := []struct {
+		name     string
+		archives *archiveOptions
+		want     map[string]string
+	}{{
+		name:     "disabled",
+		archives: nil,
+		want: map[string]string{
+			"README.md":             "hello",
+			"lib/foo.jar":           skipped,
+			"fixtures/data.tar.gz":  skipped,
+			"fixtures/broken.zip":   "not a zip",
+			"fixtures/plain.tar.gz": "not a tar.gz",
+		},
+	}, {
+		name:     "depth 2",
+		archives: &archiveOptions{MaxDepth: 2, MaxSizeBytes: 1 << 20},
+		want: map[string]string{
+			"README.md":                          "hello",
+			"lib/foo.jar":                        skipped,
+			"lib/foo.jar!/com/x/Y.java":          "class Y {}",
+			"lib/foo.jar!/com/x/Y.class":         skipped,
+			"lib/foo.jar!/inner.zip":             skipped,
+			"lib/foo.jar!/inner.zip!/deep/z.txt": "z",
+			"lib/foo.jar!/inner.zip!/deeper.zip": skipped,
+			"fixtures/data.tar.gz":               skipped,
+			"fixtures/data.tar.gz!/a.txt":        "a",
+			"fixtures/broken.zip":                "not a zip",
+			"fixtures/plain.tar.gz":              "not a tar.gz",
+		},
+	}, {
+		name:     "depth 1",
+		archives: &archiveOptions{MaxDepth: 1, MaxSizeBytes: 1 << 20},
+		want: map[string]string{
+			"README.md":                   "hello",
+			"lib/foo.jar":                 skipped,
+			"lib/foo.jar!/com/x/Y.java":   "class Y {}",
+			"lib/foo.jar!/com/x/Y.class":  skipped,
+			"lib/foo.jar!/inner.zip":      skipped,
+			"fixtures/data.tar.gz":        skipped,
+			"fixtures/data.tar.gz!/a.txt": "a",
+			"fixtures/broken.zip":         "not a zip",
+			"fixtures/plain.tar.gz":       "not a tar.gz",
+		},
+	}, {
+		name:     "too large",
+		archives: &archiveOptions{MaxDepth: 2, MaxSizeBytes: int64(len(tgz))},
+		want: map[string]string{
+			"README.md":                   "hello",
+			"lib/foo.jar":                 skipped,
+			"fixtures/data.tar.gz":        skipped,
+			"fixtures/data.tar.gz!/a.txt": "a",
+			"fixtures/broken.zip":         "not a zip",
+			"fixtures/plain.tar.gz":       "not a tar.gz",
+		},
+	}}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			zw := zip.NewWriter(&buf)
+			if err := copySearchable(tar.NewReader(bytes.NewReader([]byte(repo))), zw, nil, test.archives); err != nil {
+				t.Fatal(err)
+			}
+			if err := zw.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := map[string]string{}
+			for _, f := range zr.File {
+				rc, err := f.Open()
+				if err != nil {
+					t.Fatal(err)
+				}
+				b, err := ioutil.ReadAll(rc)
+				rc.Close()
+				if err != nil {
+					t.Fatal(err)
+				}
+				got[f.Name] = string(b)
+				if f.Comment == skippedContentComment {
+					got[f.Name] = skipped
+				}
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func zipArchiveOf(t *testing.T, files map[string]string) string {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func tarArchiveOf(t *testing.T, files map[string]string) string {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for name, content := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0600, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func tarGzArchiveOf(t *testing.T, files map[string]string) string {
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	if _, err := gw.Write([]byte(tarArchiveOf(t, files))); err != nil {
+		t.Fatal(err)
+	}
+	if err := gw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
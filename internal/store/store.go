@@ -9,6 +9,7 @@ import (
 	"encoding/hex"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"log"
 	"os"
 	"path/filepath"
@@ -18,6 +19,7 @@ import (
 
 	opentracing "github.com/opentracing/opentracing-go"
 	"github.com/sourcegraph/sourcegraph/internal/api"
+	"github.com/sourcegraph/sourcegraph/internal/archive"
 	"github.com/sourcegraph/sourcegraph/internal/conf"
 	"github.com/sourcegraph/sourcegraph/internal/diskcache"
 	"github.com/sourcegraph/sourcegraph/internal/gitserver"
@@ -134,9 +136,14 @@ func (s *Store) PrepareZip(ctx context.Context, repo gitserver.Repo, commit api.
 	}
 
 	largeFilePatterns := conf.Get().SearchLargeFiles
+	archives := newArchiveOptions(conf.Get().SearchArchives)
 
 	// key is a sha256 hash since we want to use it for the disk name
-	h := sha256.Sum256([]byte(fmt.Sprintf("%q %q %q", repo.Name, commit, largeFilePatterns)))
+	keyData := fmt.Sprintf("%q %q %q", repo.Name, commit, largeFilePatterns)
+	if archives != nil {
+		keyData += fmt.Sprintf(" archives=%+v", *archives)
+	}
+	h := sha256.Sum256([]byte(keyData))
 	key := hex.EncodeToString(h[:])
 	span.LogKV("key", key)
 
@@ -152,7 +159,7 @@ func (s *Store) PrepareZip(ctx context.Context, repo gitserver.Repo, commit api.
 		// since we're just going to close it again immediately.
 		bgctx := opentracing.ContextWithSpan(context.Background(), opentracing.SpanFromContext(ctx))
 		f, err := s.cache.Open(bgctx, key, func(ctx context.Context) (io.ReadCloser, error) {
-			return s.fetch(ctx, repo, commit, largeFilePatterns)
+			return s.fetch(ctx, repo, commit, largeFilePatterns, archives)
 		})
 		var path string
 		if f != nil {
@@ -179,7 +186,7 @@ func (s *Store) PrepareZip(ctx context.Context, repo gitserver.Repo, commit api.
 // fetch fetches an archive from the network and stores it on disk. It does
 // not populate the in-memory cache. You should probably be calling
 // prepareZip.
-func (s *Store) fetch(ctx context.Context, repo gitserver.Repo, commit api.CommitID, largeFilePatterns []string) (rc io.ReadCloser, err error) {
+func (s *Store) fetch(ctx context.Context, repo gitserver.Repo, commit api.CommitID, largeFilePatterns []string, archives *archiveOptions) (rc io.ReadCloser, err error) {
 	fetchQueueSize.Inc()
 	ctx, releaseFetchLimiter, err := s.fetchLimiter.Acquire(ctx) // Acquire concurrent fetches semaphore
 	if err != nil {
@@ -240,7 +247,7 @@ func (s *Store) fetch(ctx context.Context, repo gitserver.Repo, commit api.Commi
 		defer r.Close()
 		tr := tar.NewReader(r)
 		zw := zip.NewWriter(pw)
-		err := copySearchable(tr, zw, largeFilePatterns)
+		err := copySearchable(tr, zw, largeFilePatterns, archives)
 		if err1 := zw.Close(); err == nil {
 			err = err1
 		}
@@ -254,8 +261,9 @@ func (s *Store) fetch(ctx context.Context, repo gitserver.Repo, commit api.Commi
 
 // copySearchable copies searchable files from tr to zw. A searchable file is
 // any file that is a candidate for being searched (under size limit and
-// non-binary).
-func copySearchable(tr *tar.Reader, zw *zip.Writer, largeFilePatterns []string) error {
+// non-binary). If archives is non-nil, the files in archives are copied too,
+// see expandArchive.
+func copySearchable(tr *tar.Reader, zw *zip.Writer, largeFilePatterns []string, archives *archiveOptions) error {
 	// 32*1024 is the same size used by io.Copy
 	buf := make([]byte, 32*1024)
 	for {
@@ -279,38 +287,47 @@ func copySearchable(tr *tar.Reader, zw *zip.Writer, largeFilePatterns []string)
 			continue
 		}
 
-		// We are happy with the file, so we can write it to zw.
-		w, err := zw.CreateHeader(&zip.FileHeader{
-			Name:   hdr.Name,
-			Method: zip.Store,
-		})
-		if err != nil {
-			return err
+		if format := archive.FormatOf(hdr.Name); archives != nil && format != archive.NotArchive && hdr.Size <= archives.MaxSizeBytes {
+			// We search the archive like other files, and the files in
+			// it.
+			data, err := ioutil.ReadAll(tr)
+			if err != nil {
+				return err
+			}
+			if err := writeSearchableZipFile(zw, hdr.Name, data); err != nil {
+				return err
+			}
+			remaining := archives.MaxSizeBytes
+			expandArchive(zw, hdr.Name, format, data, 1, &remaining, largeFilePatterns, archives)
+			continue
 		}
 
 		n, err := tr.Read(buf)
-		switch err {
-		case io.EOF:
-			if n == 0 {
-				continue
-			}
-		case nil:
-		default:
+		if err != nil && err != io.EOF {
 			return err
 		}
 
 		// We do not search the content of large files unless they are
 		// allowed.
-		if hdr.Size > maxFileSize && !ignoreSizeMax(hdr.Name, largeFilePatterns) {
-			continue
-		}
-
+		//
 		// Heuristic: Assume file is binary if first 256 bytes contain a
 		// 0x00. Best effort, so ignore err. We only search names of binary files.
-		if n > 0 && bytes.IndexByte(buf[:n], 0x00) >= 0 {
+		if (hdr.Size > maxFileSize && !ignoreSizeMax(hdr.Name, largeFilePatterns)) || bytes.IndexByte(buf[:n], 0x00) >= 0 {
+			if err := writeSkippedZipFile(zw, hdr.Name); err != nil {
+				return err
+			}
 			continue
 		}
 
+		// We are happy with the file, so we can write it to zw.
+		w, err := zw.CreateHeader(&zip.FileHeader{
+			Name:   hdr.Name,
+			Method: zip.Store,
+		})
+		if err != nil {
+			return err
+		}
+
 		// First write the data already read into buf
 		nw, err := w.Write(buf[:n])
 		if err != nil {
@@ -152,7 +152,7 @@ func (f *ZipFile) PopulateFiles(r *zip.Reader) error {
 		if uint64(size) != file.UncompressedSize64 {
 			return errors.Errorf("file %s has size > 2gb: %v", file.Name, size)
 		}
-		f.Files[i] = SrcFile{Name: file.Name, Off: off, Len: int32(size)}
+		f.Files[i] = SrcFile{Name: file.Name, Off: off, Len: int32(size), ContentSkipped: file.Comment == skippedContentComment}
 		if size > f.MaxLen {
 			f.MaxLen = size
 		}
@@ -202,6 +202,10 @@ type SrcFile struct {
 	Name string
 	Off  int64
 	Len  int32
+
+	// ContentSkipped is true if the content of the file was not stored,
+	// because it is binary or too large to search.
+	ContentSkipped bool
 }
 
 // Data returns the contents of s, which is a SrcFile in f.
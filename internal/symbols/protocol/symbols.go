@@ -33,10 +33,55 @@ type SearchArgs struct {
 	// need to match to get included in the result
 	ExcludePattern string
 
+	// Kinds, if nonempty, are the kinds of the symbols to return, such as
+	// "func" or "method". They are matched case-insensitively.
+	Kinds []string
+
+	// Parent, if nonempty, is the name of the parent (scope) of the symbols
+	// to return, such as the type of a method or the package of a Go
+	// function.
+	Parent string
+
+	// Languages, if nonempty, are the languages of the symbols to return,
+	// such as "Go". They are matched case-insensitively.
+	Languages []string
+
+	// NameMatch is how Query matches symbol names: as a regular expression
+	// (NameMatchRegexp, the default), or literally as the exact name
+	// (NameMatchExact), a prefix of it (NameMatchPrefix), or its characters
+	// in order (NameMatchFuzzy).
+	NameMatch string
+
 	// First indicates that only the first n symbols should be returned.
 	First int
 }
 
+// Values of SearchArgs.NameMatch.
+const (
+	NameMatchRegexp = ""
+	NameMatchExact  = "exact"
+	NameMatchPrefix = "prefix"
+	NameMatchFuzzy  = "fuzzy"
+)
+
+// ListArgs are the arguments to list all symbols of a commit on the symbols
+// service.
+type ListArgs struct {
+	// Repo is the name of the repository to list the symbols of.
+	Repo api.RepoName `json:"repo"`
+
+	// CommitID is the commit to list the symbols of.
+	CommitID api.CommitID `json:"commitID"`
+
+	// Path, if nonempty, is the path of the only file to list the symbols
+	// of.
+	Path string
+
+	// First indicates that only the first n symbols, ordered by path and
+	// line, should be returned.
+	First int
+}
+
 // SearchResult is the result of a search on the symbols service.
 type SearchResult struct {
 	Symbols []Symbol // code symbols
@@ -54,5 +99,13 @@ type Symbol struct {
 	Signature  string
 	Pattern    string
 
+	// EndLine is the last line of the definition of the symbol, such as the
+	// closing brace of a class, or 0 if it is unknown.
+	EndLine int
+
+	// Extractor is the name of the symbol extractor that produced the
+	// symbol, such as "ctags" or "go/parser".
+	Extractor string
+
 	FileLimited bool
 }
@@ -1,6 +1,7 @@
 package git
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"fmt"
@@ -108,6 +109,77 @@ func Commits(ctx context.Context, repo gitserver.Repo, opt CommitsOptions) ([]*C
 	return commitLog(ctx, repo, opt)
 }
 
+// LastModified returns the committer date of the last commit reachable from
+// commit that modified each of the given paths, with a single git log over
+// all of them. The log stops once the date of every path is known. Paths
+// without a commit, and paths whose commit was not reached before ctx is
+// done, are omitted along with ctx.Err().
+func LastModified(ctx context.Context, repo gitserver.Repo, commit api.CommitID, paths []string) (map[string]time.Time, error) {
+	if Mocks.LastModified != nil {
+		return Mocks.LastModified(repo, commit, paths)
+	}
+
+	span, ctx := ot.StartSpanFromContext(ctx, "Git: LastModified")
+	span.SetTag("Commit", commit)
+	span.SetTag("Paths", len(paths))
+	defer span.Finish()
+
+	modified := make(map[string]time.Time, len(paths))
+	if len(paths) == 0 {
+		return modified, nil
+	}
+	if err := checkSpecArgSafety(string(commit)); err != nil {
+		return nil, err
+	}
+
+	wanted := make(map[string]bool, len(paths))
+	args := []string{"log", "--format=%x00%ct", "--name-only", "--no-renames", string(commit), "--"}
+	for _, p := range paths {
+		wanted[p] = true
+		args = append(args, ":(literal)"+p)
+	}
+	cmd := gitserver.DefaultClient.Command("git", args...)
+	cmd.Repo = repo
+	cmd.EnsureRevision = string(commit)
+	rc, err := gitserver.StdoutReader(ctx, cmd)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	// Each commit is a line with a NUL and its committer date, followed by
+	// the paths it modified.
+	var date time.Time
+	sc := bufio.NewScanner(rc)
+	for len(modified) < len(wanted) && sc.Scan() {
+		line := sc.Text()
+		if strings.HasPrefix(line, "\x00") {
+			sec, err := strconv.ParseInt(line[1:], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid commit date in git log output: %q", line)
+			}
+			date = time.Unix(sec, 0).UTC()
+			continue
+		}
+		// Paths with special characters are quoted like Go strings.
+		if strings.HasPrefix(line, `"`) {
+			if path, err := strconv.Unquote(line); err == nil {
+				line = path
+			}
+		}
+		if _, ok := modified[line]; !ok && wanted[line] {
+			modified[line] = date
+		}
+	}
+	if len(modified) == len(wanted) {
+		return modified, nil
+	}
+	if err := sc.Err(); err != nil {
+		return modified, err
+	}
+	return modified, ctx.Err()
+}
+
 // HasCommitAfter indicates the staleness of a repository. It returns a boolean indicating if a repository
 // contains a commit past a specified date.
 func HasCommitAfter(ctx context.Context, repo gitserver.Repo, date string, revspec string) (bool, error) {
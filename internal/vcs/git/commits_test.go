@@ -3,6 +3,7 @@ package git
 import (
 	"context"
 	"fmt"
+	"reflect"
 	"testing"
 	"time"
 
@@ -376,3 +377,32 @@ func TestRepository_Commits_options_path(t *testing.T) {
 		}
 	}
 }
+
+func TestLastModified(t *testing.T) {
+	t.Parallel()
+
+	gitCommands := []string{
+		"echo a > a && echo b > b && echo '*' > '*' && echo c > 'é \"c\"'",
+		"git add .",
+		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:05Z git commit -m commit1 --author='a <a@a.com>' --date 2006-01-02T15:04:05Z",
+		"echo a2 > a",
+		"git add a",
+		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:07Z git commit -m commit2 --author='a <a@a.com>' --date 2006-01-02T15:04:06Z",
+		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:09Z git commit --allow-empty -m commit3 --author='a <a@a.com>' --date 2006-01-02T15:04:08Z",
+	}
+	repo := MakeGitRepository(t, gitCommands...)
+
+	// The path "*" is not a glob matching every file.
+	got, err := LastModified(ctx, repo, "master", []string{"a", "*", `é "c"`, "doesnt-exist"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]time.Time{
+		"a":     MustParseTime(time.RFC3339, "2006-01-02T15:04:07Z"),
+		"*":     MustParseTime(time.RFC3339, "2006-01-02T15:04:05Z"),
+		`é "c"`: MustParseTime(time.RFC3339, "2006-01-02T15:04:05Z"),
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
@@ -3,6 +3,7 @@ package git
 import (
 	"io"
 	"os"
+	"time"
 
 	"github.com/sourcegraph/sourcegraph/internal/api"
 	"github.com/sourcegraph/sourcegraph/internal/gitserver"
@@ -24,6 +25,7 @@ var Mocks, emptyMocks struct {
 	Stat             func(commit api.CommitID, name string) (os.FileInfo, error)
 	GetObject        func(objectName string) (OID, ObjectType, error)
 	Commits          func(repo gitserver.Repo, opt CommitsOptions) ([]*Commit, error)
+	LastModified     func(repo gitserver.Repo, commit api.CommitID, paths []string) (map[string]time.Time, error)
 	MergeBase        func(repo gitserver.Repo, a, b api.CommitID) (api.CommitID, error)
 }
 
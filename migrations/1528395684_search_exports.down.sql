@@ -0,0 +1,6 @@
+BEGIN;
+
+DROP TABLE IF EXISTS search_export_chunks;
+DROP TABLE IF EXISTS search_exports;
+
+COMMIT;
@@ -0,0 +1,36 @@
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS search_exports (
+    id bigserial PRIMARY KEY,
+    user_id integer NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    query text NOT NULL,
+    version text NOT NULL,
+    pattern_type text NOT NULL,
+    format text NOT NULL,
+    state text NOT NULL DEFAULT 'queued',
+    failure_message text,
+    result_count integer NOT NULL DEFAULT 0,
+    created_at timestamp with time zone NOT NULL DEFAULT now(),
+    started_at timestamp with time zone,
+    finished_at timestamp with time zone,
+    -- Updated periodically by the frontend processing a search export, so
+    -- that exports whose frontend stopped can be told apart from those still
+    -- running on another replica.
+    heartbeat_at timestamp with time zone,
+    CONSTRAINT search_exports_format_check CHECK (format IN ('csv', 'jsonl')),
+    CONSTRAINT search_exports_state_check CHECK (state IN ('queued', 'processing', 'completed', 'errored'))
+);
+
+CREATE INDEX IF NOT EXISTS search_exports_user_id ON search_exports(user_id);
+CREATE INDEX IF NOT EXISTS search_exports_state ON search_exports(state);
+
+-- The exported data of a search export, stored in chunks in the order given
+-- by seq so that large exports are never held in memory as a whole.
+CREATE TABLE IF NOT EXISTS search_export_chunks (
+    export_id bigint NOT NULL REFERENCES search_exports(id) ON DELETE CASCADE,
+    seq integer NOT NULL,
+    data bytea NOT NULL,
+    PRIMARY KEY (export_id, seq)
+);
+
+COMMIT;
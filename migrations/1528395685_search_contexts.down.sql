@@ -0,0 +1,6 @@
+BEGIN;
+
+DROP TABLE IF EXISTS search_context_repos;
+DROP TABLE IF EXISTS search_contexts;
+
+COMMIT;
@@ -0,0 +1,26 @@
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS search_contexts (
+    id bigserial PRIMARY KEY,
+    name text NOT NULL,
+    description text NOT NULL DEFAULT '',
+    public boolean NOT NULL DEFAULT true,
+    namespace_user_id integer REFERENCES users(id) ON DELETE CASCADE,
+    namespace_org_id integer REFERENCES orgs(id) ON DELETE CASCADE,
+    created_at timestamp with time zone NOT NULL DEFAULT now(),
+    updated_at timestamp with time zone NOT NULL DEFAULT now(),
+    CONSTRAINT search_contexts_has_at_most_one_namespace CHECK (namespace_user_id IS NULL OR namespace_org_id IS NULL)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS search_contexts_name_without_namespace_unique ON search_contexts(name) WHERE namespace_user_id IS NULL AND namespace_org_id IS NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS search_contexts_name_namespace_user_id_unique ON search_contexts(name, namespace_user_id) WHERE namespace_user_id IS NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS search_contexts_name_namespace_org_id_unique ON search_contexts(name, namespace_org_id) WHERE namespace_org_id IS NOT NULL;
+
+CREATE TABLE IF NOT EXISTS search_context_repos (
+    search_context_id bigint NOT NULL REFERENCES search_contexts(id) ON DELETE CASCADE,
+    repo_id integer NOT NULL REFERENCES repo(id) ON DELETE CASCADE,
+    revision text NOT NULL,
+    CONSTRAINT search_context_repos_unique UNIQUE (search_context_id, repo_id, revision)
+);
+
+COMMIT;
@@ -0,0 +1,7 @@
+BEGIN;
+
+DROP INDEX IF EXISTS repo_metadata_tag_list_idx;
+DROP INDEX IF EXISTS repo_metadata_topics_idx;
+DROP TABLE IF EXISTS repo_groups;
+
+COMMIT;
@@ -0,0 +1,20 @@
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS repo_groups (
+    id serial PRIMARY KEY,
+    name text NOT NULL,
+    description text NOT NULL DEFAULT '',
+    repositories text[] NOT NULL DEFAULT '{}',
+    pattern text,
+    topics text[] NOT NULL DEFAULT '{}',
+    created_at timestamp with time zone NOT NULL DEFAULT now(),
+    updated_at timestamp with time zone NOT NULL DEFAULT now(),
+    CONSTRAINT repo_groups_name_unique UNIQUE (name)
+);
+
+-- Topics are stored in the metadata of GitHub repositories and as the tag
+-- list of GitLab projects.
+CREATE INDEX IF NOT EXISTS repo_metadata_topics_idx ON repo USING GIN ((metadata->'Topics'));
+CREATE INDEX IF NOT EXISTS repo_metadata_tag_list_idx ON repo USING GIN ((metadata->'tag_list'));
+
+COMMIT;
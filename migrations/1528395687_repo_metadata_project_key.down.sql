@@ -0,0 +1,5 @@
+BEGIN;
+
+DROP INDEX IF EXISTS repo_metadata_project_key_idx;
+
+COMMIT;
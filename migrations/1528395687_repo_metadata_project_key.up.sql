@@ -0,0 +1,7 @@
+BEGIN;
+
+-- Used by the repo:has.project() search predicate, which matches the key of
+-- the Bitbucket Server project a repository belongs to.
+CREATE INDEX IF NOT EXISTS repo_metadata_project_key_idx ON repo ((metadata->'project'->>'key'));
+
+COMMIT;
@@ -0,0 +1,16 @@
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS search_history (
+    id bigserial PRIMARY KEY,
+    user_id integer NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    query text NOT NULL,
+    pattern_type text NOT NULL,
+    version_context text NOT NULL DEFAULT '',
+    result_count integer NOT NULL DEFAULT 0,
+    created_at timestamp with time zone NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS search_history_user_id_id ON search_history(user_id, id);
+CREATE INDEX IF NOT EXISTS search_history_created_at ON search_history(created_at);
+
+COMMIT;
@@ -0,0 +1,6 @@
+BEGIN;
+
+DROP TABLE IF EXISTS global_symbols;
+DROP TABLE IF EXISTS global_symbol_repos;
+
+COMMIT;
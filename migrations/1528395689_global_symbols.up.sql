@@ -0,0 +1,27 @@
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS global_symbol_repos (
+    repo_id integer PRIMARY KEY REFERENCES repo(id) ON DELETE CASCADE,
+    commit_id text NOT NULL,
+    symbol_count integer NOT NULL,
+    indexed_at timestamp with time zone NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS global_symbols (
+    repo_id integer NOT NULL REFERENCES global_symbol_repos(repo_id) ON DELETE CASCADE,
+    name text NOT NULL,
+    path text NOT NULL,
+    line integer NOT NULL,
+    kind text NOT NULL,
+    language text NOT NULL,
+    parent text NOT NULL,
+    parent_kind text NOT NULL,
+    signature text NOT NULL,
+    pattern text NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS global_symbols_repo_id ON global_symbols(repo_id);
+CREATE INDEX IF NOT EXISTS global_symbols_name_prefix ON global_symbols(lower(name) text_pattern_ops);
+CREATE INDEX IF NOT EXISTS global_symbols_name_trgm ON global_symbols USING gin (lower(name) gin_trgm_ops);
+
+COMMIT;
@@ -405,7 +405,7 @@ type ExperimentalFeatures struct {
 	StructuralSearch string `json:"structuralSearch,omitempty"`
 	// TlsExternal description: Global TLS/SSL settings for Sourcegraph to use when communicating with code hosts.
 	TlsExternal *TlsExternal `json:"tls.external,omitempty"`
-	// VersionContexts description: JSON array of version context configuration
+	// VersionContexts description: DEPRECATED: Use search contexts instead, which are managed with the GraphQL API and used with the context: search field. A version context takes precedence over a search context with the same name. JSON array of version context configuration
 	VersionContexts []*VersionContext `json:"versionContexts,omitempty"`
 }
 
@@ -915,6 +915,36 @@ type SMTPServerConfig struct {
 	// Username description: The username to use when communicating with the SMTP server.
 	Username string `json:"username,omitempty"`
 }
+
+// SearchAdmission description: Admission control for expensive searches, such as a regular expression like `.*` over thousands of repositories. The cost of a search is estimated from the number of repositories it searches with and without the index, and from how expensive its pattern is to match. By default, no searches are rejected or queued.
+type SearchAdmission struct {
+	// ExpensiveCost description: Searches with a higher estimated cost count towards `maxConcurrentExpensivePerUser`.
+	ExpensiveCost int `json:"expensiveCost,omitempty"`
+	// MaxConcurrentExpensivePerUser description: The maximum number of expensive searches a user can run at the same time across all frontend replicas. Anonymous users share a single limit. More expensive searches of the user wait for one to finish, and are rejected after `queueTimeout`. The limit is shared through Redis. 0 (the default) disables the limit.
+	MaxConcurrentExpensivePerUser int `json:"maxConcurrentExpensivePerUser,omitempty"`
+	// MaxCost description: Searches with a higher estimated cost are rejected with an alert suggesting how to narrow them. Each repository searched with the index costs 1, each repository revision searched without the index costs 10, and the total is multiplied by 10 for patterns that can't use the index efficiently, such as `.*`, very short patterns and structural or negated patterns. 0 (the default) disables the limit.
+	MaxCost int `json:"maxCost,omitempty"`
+	// QueueTimeout description: How long an expensive search waits for another expensive search of the same user to finish, as a duration like "10s". Defaults to 10s.
+	QueueTimeout string `json:"queueTimeout,omitempty"`
+}
+
+// SearchArchives description: Search the files in archives committed to repositories, such as `.jar`, `.zip` and `.tar.gz` files. Matches in a file in an archive are reported at a path like `lib/foo.jar!/com/x/Y.java`. Archives are only expanded by unindexed search.
+type SearchArchives struct {
+	// Enabled description: Whether the files in archives are searched.
+	Enabled bool `json:"enabled,omitempty"`
+	// MaxDepth description: The maximum depth of archives in archives that are expanded. A depth of 1 only expands the archives committed to the repository. Defaults to 2.
+	MaxDepth int `json:"maxDepth,omitempty"`
+	// MaxSizeBytes description: The maximum size in bytes of an archive that is expanded. Larger archives are searched by name only. Defaults to 10000000 (10 MB).
+	MaxSizeBytes int `json:"maxSizeBytes,omitempty"`
+}
+
+// SearchGlobalSymbols description: A global symbol index in the database, which holds the symbols of the default branch of every repository. Symbol searches of the default branch of repositories which are not searched with indexed search use it instead of asking the symbols service about each repository. The index is updated in the background, so results may be from an older commit of the default branch.
+type SearchGlobalSymbols struct {
+	// Enabled description: Whether the global symbol index is maintained and searched.
+	Enabled bool `json:"enabled,omitempty"`
+	// MaxSymbolsPerRepo description: Repositories with more symbols are left out of the index, and searched with the symbols service. Defaults to 100000.
+	MaxSymbolsPerRepo int `json:"maxSymbolsPerRepo,omitempty"`
+}
 type SearchSavedQueries struct {
 	// Description description: Description of this saved query
 	Description string `json:"description"`
@@ -978,6 +1008,8 @@ type Settings struct {
 	SearchContextLines int `json:"search.contextLines,omitempty"`
 	// SearchDefaultPatternType description: The default pattern type (literal or regexp) that search queries will be intepreted as.
 	SearchDefaultPatternType string `json:"search.defaultPatternType,omitempty"`
+	// SearchGlobbing description: When active, the values of the repo:, file: and repohasfile: filters are globs, such as file:**/*.go, instead of regular expressions.
+	SearchGlobbing *bool `json:"search.globbing,omitempty"`
 	// SearchIncludeArchived description: Whether searches should include searching archived repositories.
 	SearchIncludeArchived *bool `json:"search.includeArchived,omitempty"`
 	// SearchIncludeForks description: Whether searches should include searching forked repositories.
@@ -1114,6 +1146,18 @@ type SiteConfiguration struct {
 	PermissionsUserMapping *PermissionsUserMapping `json:"permissions.userMapping,omitempty"`
 	// RepoListUpdateInterval description: Interval (in minutes) for checking code hosts (such as GitHub, Gitolite, etc.) for new repositories.
 	RepoListUpdateInterval int `json:"repoListUpdateInterval,omitempty"`
+	// SearchAdmission description: Admission control for expensive searches, such as a regular expression like `.*` over thousands of repositories. The cost of a search is estimated from the number of repositories it searches with and without the index, and from how expensive its pattern is to match. By default, no searches are rejected or queued.
+	SearchAdmission *SearchAdmission `json:"search.admission,omitempty"`
+	// SearchArchives description: Search the files in archives committed to repositories, such as `.jar`, `.zip` and `.tar.gz` files. Matches in a file in an archive are reported at a path like `lib/foo.jar!/com/x/Y.java`. Archives are only expanded by unindexed search.
+	SearchArchives *SearchArchives `json:"search.archives,omitempty"`
+	// SearchGlobalSymbols description: A global symbol index in the database, which holds the symbols of the default branch of every repository. Symbol searches of the default branch of repositories which are not searched with indexed search use it instead of asking the symbols service about each repository. The index is updated in the background, so results may be from an older commit of the default branch.
+	SearchGlobalSymbols *SearchGlobalSymbols `json:"search.globalSymbols,omitempty"`
+	// SearchHistoryEnabled description: Whether the search queries of signed-in users are recorded in their search history, which they can page through in the API. Disable this if search queries must not be stored. Disabling it does not delete the existing history, which is deleted after `search.history.retentionDays`.
+	SearchHistoryEnabled *bool `json:"search.history.enabled,omitempty"`
+	// SearchHistoryRetentionDays description: The number of days search history entries are kept before they are deleted.
+	SearchHistoryRetentionDays int `json:"search.history.retentionDays,omitempty"`
+	// SearchIndexBranches description: A map from repository name to a list of branches, besides the default branch, to index for indexed search. Searches of these branches, such as `repo:^github\.com/foo/bar$@release-1.0` or `repo:^github\.com/foo/bar$@release-*`, use the index instead of fetching an archive of the branch. At most 63 branches per repository are indexed.
+	SearchIndexBranches map[string][]string `json:"search.index.branches,omitempty"`
 	// SearchIndexEnabled description: Whether indexed search is enabled. If unset Sourcegraph detects the environment to decide if indexed search is enabled. Indexed search is RAM heavy, and is disabled by default in the single docker image. All other environments will have it enabled by default. The size of all your repository working copies is the amount of additional RAM required.
 	SearchIndexEnabled *bool `json:"search.index.enabled,omitempty"`
 	// SearchIndexSymbolsEnabled description: Whether indexed symbol search is enabled. This is contingent on the indexed search configuration, and is true by default for instances with indexed search enabled. Enabling this will cause every repository to re-index, which is a time consuming (several hours) operation. Additionally, it requires more storage and ram to accommodate the added symbols information in the search index.
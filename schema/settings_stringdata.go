@@ -194,6 +194,12 @@ const SettingsSchemaJSON = `{
       "type": "boolean",
       "default": false,
       "!go": { "pointer": true }
+    },
+    "search.globbing": {
+      "description": "When active, the values of the repo:, file: and repohasfile: filters are globs, such as file:**/*.go, instead of regular expressions.",
+      "type": "boolean",
+      "default": false,
+      "!go": { "pointer": true }
     }
   },
   "definitions": {
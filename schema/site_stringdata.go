@@ -34,6 +34,33 @@ const SiteSchemaJSON = `{
       "!go": { "pointer": true },
       "group": "Search"
     },
+    "search.index.branches": {
+      "description": "A map from repository name to a list of branches, besides the default branch, to index for indexed search. Searches of these branches, such as ` + "`" + `repo:^github\\.com/foo/bar$@release-1.0` + "`" + ` or ` + "`" + `repo:^github\\.com/foo/bar$@release-*` + "`" + `, use the index instead of fetching an archive of the branch. At most 63 branches per repository are indexed.",
+      "type": "object",
+      "additionalProperties": {
+        "type": "array",
+        "items": {
+          "type": "string"
+        },
+        "maxItems": 63
+      },
+      "group": "Search",
+      "examples": [{ "github.com/sourcegraph/sourcegraph": ["3.17", "3.18"] }]
+    },
+    "search.history.enabled": {
+      "description": "Whether the search queries of signed-in users are recorded in their search history, which they can page through in the API. Disable this if search queries must not be stored. Disabling it does not delete the existing history, which is deleted after ` + "`" + `search.history.retentionDays` + "`" + `.",
+      "type": "boolean",
+      "!go": { "pointer": true },
+      "default": true,
+      "group": "Search"
+    },
+    "search.history.retentionDays": {
+      "description": "The number of days search history entries are kept before they are deleted.",
+      "type": "integer",
+      "minimum": 1,
+      "default": 30,
+      "group": "Search"
+    },
     "search.largeFiles": {
       "description": "A list of file glob patterns where matching files will be indexed and searched regardless of their size. The glob pattern syntax can be found here: https://golang.org/pkg/path/filepath/#Match.",
       "type": "array",
@@ -43,6 +70,84 @@ const SiteSchemaJSON = `{
       "group": "Search",
       "examples": [["go.sum", "package-lock.json", "*.thrift"]]
     },
+    "search.admission": {
+      "description": "Admission control for expensive searches, such as a regular expression like ` + "`" + `.*` + "`" + ` over thousands of repositories. The cost of a search is estimated from the number of repositories it searches with and without the index, and from how expensive its pattern is to match. By default, no searches are rejected or queued.",
+      "type": "object",
+      "additionalProperties": false,
+      "properties": {
+        "maxCost": {
+          "description": "Searches with a higher estimated cost are rejected with an alert suggesting how to narrow them. Each repository searched with the index costs 1, each repository revision searched without the index costs 10, and the total is multiplied by 10 for patterns that can't use the index efficiently, such as ` + "`" + `.*` + "`" + `, very short patterns and structural or negated patterns. 0 (the default) disables the limit.",
+          "type": "integer",
+          "minimum": 0,
+          "default": 0
+        },
+        "expensiveCost": {
+          "description": "Searches with a higher estimated cost count towards ` + "`" + `maxConcurrentExpensivePerUser` + "`" + `.",
+          "type": "integer",
+          "minimum": 1,
+          "default": 1000
+        },
+        "maxConcurrentExpensivePerUser": {
+          "description": "The maximum number of expensive searches a user can run at the same time across all frontend replicas. Anonymous users share a single limit. More expensive searches of the user wait for one to finish, and are rejected after ` + "`" + `queueTimeout` + "`" + `. The limit is shared through Redis. 0 (the default) disables the limit.",
+          "type": "integer",
+          "minimum": 0,
+          "default": 0
+        },
+        "queueTimeout": {
+          "description": "How long an expensive search waits for another expensive search of the same user to finish, as a duration like \"10s\". Defaults to 10s.",
+          "type": "string",
+          "default": "10s"
+        }
+      },
+      "group": "Search",
+      "examples": [{ "maxCost": 100000, "expensiveCost": 1000, "maxConcurrentExpensivePerUser": 2, "queueTimeout": "10s" }]
+    },
+    "search.archives": {
+      "description": "Search the files in archives committed to repositories, such as ` + "`" + `.jar` + "`" + `, ` + "`" + `.zip` + "`" + ` and ` + "`" + `.tar.gz` + "`" + ` files. Matches in a file in an archive are reported at a path like ` + "`" + `lib/foo.jar!/com/x/Y.java` + "`" + `. Archives are only expanded by unindexed search.",
+      "type": "object",
+      "additionalProperties": false,
+      "properties": {
+        "enabled": {
+          "description": "Whether the files in archives are searched.",
+          "type": "boolean",
+          "default": false
+        },
+        "maxDepth": {
+          "description": "The maximum depth of archives in archives that are expanded. A depth of 1 only expands the archives committed to the repository. Defaults to 2.",
+          "type": "integer",
+          "minimum": 1,
+          "default": 2
+        },
+        "maxSizeBytes": {
+          "description": "The maximum size in bytes of an archive that is expanded. Larger archives are searched by name only. Defaults to 10000000 (10 MB).",
+          "type": "integer",
+          "minimum": 1,
+          "default": 10000000
+        }
+      },
+      "group": "Search",
+      "examples": [{ "enabled": true, "maxDepth": 2, "maxSizeBytes": 10000000 }]
+    },
+    "search.globalSymbols": {
+      "description": "A global symbol index in the database, which holds the symbols of the default branch of every repository. Symbol searches of the default branch of repositories which are not searched with indexed search use it instead of asking the symbols service about each repository. The index is updated in the background, so results may be from an older commit of the default branch.",
+      "type": "object",
+      "additionalProperties": false,
+      "properties": {
+        "enabled": {
+          "description": "Whether the global symbol index is maintained and searched.",
+          "type": "boolean",
+          "default": false
+        },
+        "maxSymbolsPerRepo": {
+          "description": "Repositories with more symbols are left out of the index, and searched with the symbols service. Defaults to 100000.",
+          "type": "integer",
+          "minimum": 1,
+          "default": 100000
+        }
+      },
+      "group": "Search",
+      "examples": [{ "enabled": true, "maxSymbolsPerRepo": 100000 }]
+    },
     "debug.search.symbolsParallelism": {
       "description": "(debug) controls the amount of symbol search parallelism. Defaults to 20. It is not recommended to change this outside of debugging scenarios. This option will be removed in a future version.",
       "type": "integer",
@@ -158,7 +263,7 @@ const SiteSchemaJSON = `{
           ]
         },
         "versionContexts": {
-          "description": "JSON array of version context configuration",
+          "description": "DEPRECATED: Use search contexts instead, which are managed with the GraphQL API and used with the context: search field. A version context takes precedence over a search context with the same name. JSON array of version context configuration",
           "type": "array",
           "items": {
             "title": "VersionContext",